@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/stellar-etl/internal/audit"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var auditSupplyCmd = &cobra.Command{
+	Use:   "audit_supply",
+	Short: "Checks the lumen supply in the ledger header against the ledger state.",
+	Long: `Sums the native balances of all accounts and the native claimable balances in the bucket list at a checkpoint, and compares
+	them with the total coins and the fee pool in the header of the checkpoint ledger. The audit of the checkpoint, with the breakdown of
+	the supply, is written to the output file, and a warning is logged if the supply does not add up.
+
+	The audit runs at the checkpoint that contains end-ledger. If start-ledger is set as well, it runs as a series at every checkpoint from
+	the one that contains start-ledger, or at every checkpoint-step-th checkpoint. Each checkpoint reads the whole bucket list.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, _, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		path, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("could not get output filename: %v", err)
+		}
+
+		startNum, err := cmd.Flags().GetUint32("start-ledger")
+		if err != nil {
+			return fmt.Errorf("could not get start sequence number: %v", err)
+		}
+
+		step, err := cmd.Flags().GetUint32("checkpoint-step")
+		if err != nil {
+			return fmt.Errorf("could not get checkpoint step: %v", err)
+		}
+
+		if startNum == 0 {
+			startNum = endNum
+		}
+
+		checkpoints := input.CheckpointsInRange(startNum, endNum, step)
+		if len(checkpoints) == 0 {
+			return fmt.Errorf("there are no checkpoints between start-ledger %d and end-ledger %d", startNum, endNum)
+		}
+
+		writer, err := newOutputWriter(format, indexPattern, path, useStdout, "supplyAudits", audit.SupplyAudit{})
+		if err != nil {
+			return err
+		}
+
+		unbalanced := []uint32{}
+		for _, checkpoint := range checkpoints {
+			snapshot, err := input.GetStateSnapshot(checkpoint, audit.SupplyEntryTypes...)
+			if err != nil {
+				return fmt.Errorf("could not read the bucket list at checkpoint %d: %v", checkpoint, err)
+			}
+
+			result, err := audit.AuditSupply(snapshot)
+			if err != nil {
+				return fmt.Errorf("could not audit checkpoint %d: %v", checkpoint, err)
+			}
+
+			if !result.Balanced {
+				cmdLogger.Warning("the lumen supply does not add up at ", result)
+				unbalanced = append(unbalanced, checkpoint)
+			}
+
+			err = writer.Write(result)
+			if err != nil {
+				return fmt.Errorf("could not encode audit: %v", err)
+			}
+		}
+
+		if err := closeWriter(writer); err != nil {
+			return err
+		}
+
+		summary, err := json.Marshal(map[string]interface{}{
+			"audited_checkpoints":    len(checkpoints),
+			"unbalanced_checkpoints": unbalanced,
+		})
+		if err != nil {
+			return fmt.Errorf("could not marshal the summary: %v", err)
+		}
+
+		fmt.Println(string(summary))
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditSupplyCmd)
+	utils.AddCommonFlags(auditSupplyCmd.Flags())
+	auditSupplyCmd.Flags().Uint32P("start-ledger", "s", 0, "If set, the audit runs at every checkpoint from the one that contains this ledger to the one that contains end-ledger")
+	auditSupplyCmd.Flags().Uint32("checkpoint-step", 1, "Number of checkpoints between the audits of a series")
+	auditSupplyCmd.Flags().StringP("output", "o", "supply_audits.txt", "Filename of the output file")
+	auditSupplyCmd.MarkFlagRequired("end-ledger")
+	/*
+		Current flags:
+			end-ledger: the audit runs at the checkpoint that contains this ledger (required)
+			start-ledger: if set, the audit runs as a series from the checkpoint that contains this ledger
+			checkpoint-step: number of checkpoints between the audits of a series
+
+			output: filename of the output file that the audits are written to
+			stdout: if set, the audits are printed to stdout
+			format: the format of the audits (json or avro)
+	*/
+}
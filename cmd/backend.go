@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// clampRange narrows the range [start, end] to the ledgers that the history archive holds if the clamp-range flag is set
+func clampRange(flags *pflag.FlagSet, start, end uint32) (uint32, uint32, error) {
+	clamp, err := utils.GetClampFlag(flags)
+	if err != nil || !clamp {
+		return start, end, err
+	}
+
+	clampedStart, clampedEnd, err := input.ClampLedgerRange(start, end)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not clamp the ledger range: %v", err)
+	}
+
+	if clampedStart != start || clampedEnd != end {
+		cmdLogger.Infof("Clamped the range [%d, %d] to [%d, %d], the part of it that the history archive holds", start, end, clampedStart, clampedEnd)
+	}
+
+	return clampedStart, clampedEnd, nil
+}
+
+// streamBatchSize is the number of ledgers in each batch that the archive commands export while they follow a metadata stream
+const streamBatchSize = 64
+
+// openLedgerBackend prepares the backend that the archive commands read the range [start, end] from. This is the metadata stream if the
+// meta-stream flag is set, and the history archive otherwise. Only a metadata stream can be followed, in which case end = 0 and
+// there is no limit. The history archive checkpoints are shared through the cache if it is not nil
+func openLedgerBackend(flags *pflag.FlagSet, cache *input.CheckpointCache, start, end uint32, limit int64) (ledgerbackend.LedgerBackend, error) {
+	metaStream, err := utils.GetMetaStreamFlag(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if metaStream == "" {
+		readAhead, err := utils.GetReadAheadFlag(flags)
+		if err != nil {
+			return nil, err
+		}
+
+		backend, err := input.PrepareArchiveBackend(start, end, readAhead, cache)
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare the history archive: %v", err)
+		}
+
+		return backend, nil
+	}
+
+	if end != 0 && end < start {
+		return nil, fmt.Errorf("End sequence number is less than start (%d < %d)", end, start)
+	}
+
+	if end == 0 && limit >= 0 {
+		return nil, fmt.Errorf("limit cannot be set when following a metadata stream")
+	}
+
+	backend, err := input.PrepareMetaStream(metaStream, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the metadata stream: %v", err)
+	}
+
+	return backend, nil
+}
+
+// exportRanges calls export with the ranges of ledgers that are exported together. A bounded range is a single batch. An unbounded range,
+// which follows a metadata stream, is exported in batches of streamBatchSize ledgers as they are written to the stream. The first error
+// that export returns stops the export and is returned
+func exportRanges(start, end uint32, export func(batchStart, batchEnd uint32) error) error {
+	if end != 0 {
+		return export(start, end)
+	}
+
+	for batchStart := start; ; batchStart += streamBatchSize {
+		if err := export(batchStart, batchStart+streamBatchSize-1); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/audit"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/transform"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var checkLiabilitiesCmd = &cobra.Command{
+	Use:   "check_liabilities",
+	Short: "Checks that the liabilities of accounts and trustlines match their offers.",
+	Long: `Reads the accounts, trustlines, and offers from the bucket list at the checkpoint that contains end-ledger, and checks
+	that the buying and selling liabilities of every account and trustline are the sums over the open offers of the account. Offers
+	whose seller does not hold enough of the selling asset to fund them are reported as well.
+
+	Each issue is written to the output file as a row, and a summary is printed when the check is done. Every entry has to be
+	transformed for the sums to be correct, so the command stops at the first entry that cannot be transformed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, _, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		path, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("could not get output filename: %v", err)
+		}
+
+		snapshot, err := input.GetStateSnapshot(endNum, xdr.LedgerEntryTypeAccount, xdr.LedgerEntryTypeTrustline, xdr.LedgerEntryTypeOffer)
+		if err != nil {
+			return fmt.Errorf("could not read the bucket list: %v", err)
+		}
+
+		baseReserve := uint32(snapshot.Header.BaseReserve)
+		accounts, trustlines, offers, err := transformSnapshot(snapshot, baseReserve)
+		if err != nil {
+			return err
+		}
+
+		issues, err := audit.CheckLiabilities(accounts, trustlines, offers, baseReserve)
+		if err != nil {
+			return fmt.Errorf("could not check liabilities: %v", err)
+		}
+
+		writer, err := newOutputWriter(format, indexPattern, path, useStdout, "liabilityIssues", audit.LiabilityIssue{})
+		if err != nil {
+			return err
+		}
+
+		issuesByKind := map[string]int{}
+		for _, issue := range issues {
+			issuesByKind[issue.Kind]++
+			err := writer.Write(issue)
+			if err != nil {
+				return fmt.Errorf("could not encode issue: %v", err)
+			}
+		}
+
+		if err := closeWriter(writer); err != nil {
+			return err
+		}
+
+		summary, err := json.Marshal(map[string]interface{}{
+			"checkpoint":     snapshot.Checkpoint,
+			"accounts":       len(accounts),
+			"trustlines":     len(trustlines),
+			"offers":         len(offers),
+			"issues":         len(issues),
+			"issues_by_kind": issuesByKind,
+			"base_reserve":   snapshot.Header.BaseReserve,
+			"liabilities_ok": len(issues) == 0,
+		})
+		if err != nil {
+			return fmt.Errorf("could not marshal the summary: %v", err)
+		}
+
+		fmt.Println(string(summary))
+
+		return nil
+	},
+}
+
+// transformSnapshot transforms the accounts of a state snapshot into composite rows, which hold their sponsorships, and its trustlines and
+// offers. The subentries of the accounts are not broken down. Other entries are ignored
+func transformSnapshot(snapshot input.StateSnapshot, baseReserve uint32) ([]transform.AccountCompositeOutput, []transform.TrustlineOutput, []transform.OfferOutput, error) {
+	accounts := []transform.AccountCompositeOutput{}
+	trustlines := []transform.TrustlineOutput{}
+	offers := []transform.OfferOutput{}
+	for _, change := range snapshot.Changes {
+		switch change.Type {
+		case xdr.LedgerEntryTypeAccount:
+			account, err := transform.TransformAccountComposite(change, nil, baseReserve)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("could not transform account: %v", err)
+			}
+
+			accounts = append(accounts, account)
+		case xdr.LedgerEntryTypeTrustline:
+			trustline, err := transform.TransformTrustline(change)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("could not transform trustline: %v", err)
+			}
+
+			trustlines = append(trustlines, trustline)
+		case xdr.LedgerEntryTypeOffer:
+			offer, err := transform.TransformOffer(change)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("could not transform offer: %v", err)
+			}
+
+			offers = append(offers, offer)
+		}
+	}
+
+	return accounts, trustlines, offers, nil
+}
+
+func init() {
+	rootCmd.AddCommand(checkLiabilitiesCmd)
+	utils.AddCommonFlags(checkLiabilitiesCmd.Flags())
+	checkLiabilitiesCmd.Flags().StringP("output", "o", "liabilities_issues.txt", "Filename of the output file")
+	checkLiabilitiesCmd.MarkFlagRequired("end-ledger")
+	/*
+		Current flags:
+			end-ledger: the ledger sequence number of the snapshot; the check runs on the checkpoint that contains it (required)
+			output: filename of the output file that the issues are written to
+			stdout: if set, the issues are printed to stdout
+			format: the format of the issues (json or avro)
+	*/
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var coreDaemonCmd = &cobra.Command{
+	Use:   "core_daemon",
+	Short: "Runs a stellar-core instance that can be shared by multiple exporters",
+	Long: `This command instantiates a stellar-core instance and shares the ledgers that it reads through a local socket.
+Commands that use stellar-core, like export_ledger_entry_changes and export_orderbooks, can read from the daemon by setting
+their core-socket flag to the socket path, instead of starting their own stellar-core instance.
+
+Ledgers are kept in memory until every connected exporter has read them. The daemon reads at most retain-ledgers ledgers ahead
+of the slowest exporter. The start-ledger of the daemon has to be at or before the earliest ledger that any exporter needs;
+export_orderbooks needs the checkpoint ledger before its own start-ledger.
+
+If the end-ledger is omitted, then the stellar-core node will continue running and reading ledgers as they are confirmed by the
+Stellar network. The daemon runs until it is interrupted.
+
+If meta-stream is set, the daemon reads the ledgers from the metadata stream of a stellar-core node that is already running instead
+of starting its own instance, which lets several exporters share a named pipe that only one reader can consume.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		execPath, configPath, startNum, endNum, coreSocket, retain, err := utils.GetDaemonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		metaStream, err := utils.GetMetaStreamFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		core, err := prepareCoreBackend(execPath, configPath, "", metaStream, startNum, endNum)
+		if err != nil {
+			return err
+		}
+
+		defer core.Close()
+		daemon, err := input.NewCoreDaemon(core, startNum, endNum, retain, cmdLogger)
+		if err != nil {
+			return fmt.Errorf("could not create the core daemon: %v", err)
+		}
+
+		listener, err := net.Listen("unix", coreSocket)
+		if err != nil {
+			return fmt.Errorf("could not listen on the core socket: %v", err)
+		}
+
+		// The socket file is left behind unless the listener is closed, so it is closed when the daemon is interrupted
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-interrupts
+			listener.Close()
+		}()
+
+		go func() {
+			if err := daemon.Run(); err != nil {
+				cmdLogger.Error("stopped reading ledgers: ", err)
+				return
+			}
+
+			cmdLogger.Info("read all the ledgers in the range; waiting for exporters to finish")
+		}()
+
+		cmdLogger.Infof("serving ledgers on %s", coreSocket)
+		daemon.Serve(listener)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(coreDaemonCmd)
+	utils.AddDaemonFlags(coreDaemonCmd.Flags())
+
+	coreDaemonCmd.MarkFlagRequired("start-ledger")
+	/*
+		Current flags:
+			start-ledger: the ledger sequence number of the first ledger that the daemon provides
+			end-ledger: the ledger sequence number of the last ledger that the daemon provides
+
+			core-executable: path to stellar-core executable
+			core-config: path to stellar-core config file
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is read instead of starting stellar-core
+			core-socket: path of the socket that exporters connect to
+			retain-ledgers: maximum number of ledgers that are read ahead of the slowest exporter
+	*/
+}
@@ -1,13 +1,12 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
+	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/xdr"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -18,69 +17,190 @@ var accountsCmd = &cobra.Command{
 	Long: `Exports historical account data from the genesis ledger to the provided end-ledger to an output file. 
 The command reads from the bucket list, which includes the full history of the Stellar ledger. As a result, it 
 should be used in an initial data dump. In order to get account information within a specified ledger range, see 
-the export_ledger_entry_changes command.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-		path := utils.MustBucketFlags(cmd.Flags(), cmdLogger)
+the export_ledger_entry_changes command.
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+If composite is set, the trustlines, offers, and data entries are read in the same pass over the bucket list, and each account is
+exported with the number of subentries of each kind, its number of signers, its total reserve, and its available balance.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
 		}
 
-		accounts, err := input.GetEntriesFromGenesis(endNum, xdr.LedgerEntryTypeAccount)
+		path, err := utils.GetBucketFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		composite, err := cmd.Flags().GetBool("composite")
+		if err != nil {
+			return fmt.Errorf("could not get composite flag: %v", err)
+		}
+
+		dataset, exampleRow := transform.AccountsDataset, interface{}(transform.AccountOutput{})
+		if composite {
+			dataset, exampleRow = transform.AccountCompositesDataset, transform.AccountCompositeOutput{}
+		}
+
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
+
+		// Rows are collected and committed to the table or delivered to the sinks as a single batch when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		program, err := rowProgram(cmd.Flags(), dataset, exampleRow, format)
+		if err != nil {
+			return err
+		}
+
+		exampleRow = program.ExampleRow(exampleRow)
+		table, err := openTable(tablePath, dataset, exampleRow)
+		if err != nil {
+			return err
+		}
+
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
 		if err != nil {
-			cmdLogger.Fatal("could not read accounts: ", err)
+			return err
+		}
+
+		rows := []interface{}{}
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, dataset, exampleRow)
+			if err != nil {
+				return err
+			}
+		}
+
+		accounts, transformAccount, err := readAccounts(endNum, composite)
+		if err != nil {
+			return err
 		}
 
-		failures := 0
 		for _, acc := range accounts {
-			transformed, err := transform.TransformAccount(acc)
+			transformed, err := transformAccount(acc)
+			if err != nil {
+				if err := failures.handle("could not transform account", err); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			row, keep, err := program.Apply(transformed)
 			if err != nil {
-				if strictExport {
-					cmdLogger.Fatal("could not transform account", err)
-				} else {
-					cmdLogger.Warning("could not transform account", err)
-					failures++
-					continue
+				if err := failures.handle("could not evaluate expressions for account", transform.NewInvalidDataError(dataset, err)); err != nil {
+					return err
 				}
+
+				continue
+			}
+
+			if !keep {
+				continue
+			}
+
+			if writer == nil {
+				rows = append(rows, row)
+				continue
 			}
 
-			marshalled, err := json.Marshal(transformed)
+			err = writer.Write(row)
 			if err != nil {
-				if strictExport {
-					cmdLogger.Fatal("could not json encode account", err)
-				} else {
-					cmdLogger.Warning("could not json encode account", err)
-					failures++
-					continue
+				if err := failures.handle("could not encode account", transform.NewSerializationError(dataset, err)); err != nil {
+					return err
 				}
+
+				continue
 			}
+		}
+
+		if table != nil {
+			if err := commitTable(table, rows, 1, endNum); err != nil {
+				return err
+			}
+		}
 
-			if !useStdout {
-				outFile.Write(marshalled)
-				outFile.WriteString("\n")
-			} else {
-				fmt.Println(string(marshalled))
+		if sinks != nil {
+			if err := deliverBatch(sinks, dataset, exampleRow, rows, 1, endNum); err != nil {
+				return err
+			}
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
 			}
 		}
 
 		if !strictExport {
-			printTransformStats(len(accounts), failures)
+			return printTransformStats(len(accounts), failures)
 		}
+
+		return nil
 	},
 }
 
+// readAccounts reads the accounts from the bucket list and returns them with the function that transforms them. Composite accounts are
+// read with their trustlines, offers, and data entries in a single pass, and the base reserve is taken from the checkpoint ledger
+func readAccounts(endNum uint32, composite bool) ([]ingestio.Change, func(ingestio.Change) (interface{}, error), error) {
+	if !composite {
+		accounts, err := input.GetEntriesFromGenesis(endNum, xdr.LedgerEntryTypeAccount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read accounts: %v", err)
+		}
+
+		return accounts, func(change ingestio.Change) (interface{}, error) {
+			return transform.TransformAccount(change)
+		}, nil
+	}
+
+	snapshot, err := input.GetStateSnapshot(endNum, xdr.LedgerEntryTypeAccount, xdr.LedgerEntryTypeTrustline, xdr.LedgerEntryTypeOffer, xdr.LedgerEntryTypeData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read accounts and their subentries: %v", err)
+	}
+
+	subentries, err := transform.CountAccountSubentries(snapshot.Changes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not count the subentries of the accounts: %v", err)
+	}
+
+	accounts := []ingestio.Change{}
+	for _, change := range snapshot.Changes {
+		if change.Type == xdr.LedgerEntryTypeAccount {
+			accounts = append(accounts, change)
+		}
+	}
+
+	baseReserve := uint32(snapshot.Header.BaseReserve)
+	return accounts, func(change ingestio.Change) (interface{}, error) {
+		return transform.TransformAccountComposite(change, subentries, baseReserve)
+	}, nil
+}
+
 func init() {
 	rootCmd.AddCommand(accountsCmd)
 	utils.AddCommonFlags(accountsCmd.Flags())
 	utils.AddBucketFlags("accounts", accountsCmd.Flags())
+	accountsCmd.Flags().Bool("composite", false, "If set, each account is exported with the number of its trustlines, offers, data entries, and signers, its total reserve, and its available balance")
 	accountsCmd.MarkFlagRequired("end-ledger")
 	/*
 		Current flags:
 			end-ledger: the ledger sequence number for the end of the export range (required)
 			output-file: filename of the output file
 			stdout: if set, output is printed to stdout
+			composite: if set, accounts are exported with their subentry breakdown, total reserve, and available balance
 
 		TODO: implement extra flags if possible
 			serialize-method: the method for serialization of the output data (JSON, XDR, etc)
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/toid"
+	"github.com/stellar/stellar-etl/internal/transform"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var depositsCmd = &cobra.Command{
+	Use:   "export_deposits",
+	Short: "Exports the deposits to a list of accounts over a specified range.",
+	Long: `Exports the payments, path payments, and claims of claimable balances that credit a list of deposit accounts over a specified
+	range to an output file. Each deposit holds the memo of its transaction, checked against the memos that its account expects.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, path, limit, verify, err := utils.GetArchiveFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, endNum, err = clampRange(cmd.Flags(), startNum, endNum)
+		if err != nil {
+			return err
+		}
+
+		addressesPath, err := cmd.Flags().GetString("addresses")
+		if err != nil {
+			return fmt.Errorf("could not get addresses filename: %v", err)
+		}
+
+		contents, err := ioutil.ReadFile(addressesPath)
+		if err != nil {
+			return fmt.Errorf("could not read the deposit accounts: %v", err)
+		}
+
+		accounts, err := transform.ParseDepositAccounts(bytes.NewReader(contents))
+		if err != nil {
+			return fmt.Errorf("could not parse the deposit accounts: %v", err)
+		}
+
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
+
+		// Rows are collected and committed to the table or delivered to the sinks in batches when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		program, err := rowProgram(cmd.Flags(), transform.DepositsDataset, transform.DepositOutput{}, format)
+		if err != nil {
+			return err
+		}
+
+		exampleRow := program.ExampleRow(transform.DepositOutput{})
+		table, err := openTable(tablePath, transform.DepositsDataset, exampleRow)
+		if err != nil {
+			return err
+		}
+
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
+		if err != nil {
+			return err
+		}
+
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, transform.DepositsDataset, exampleRow)
+			if err != nil {
+				return err
+			}
+		}
+
+		backend, err := openLedgerBackend(cmd.Flags(), checkpointCache(cmd), startNum, endNum, limit)
+		if err != nil {
+			return err
+		}
+
+		defer backend.Close()
+		attempts, incompleteClaims := 0, 0
+		err = exportRanges(startNum, endNum, func(batchStart, batchEnd uint32) error {
+			rows := []interface{}{}
+			transactions, err := input.GetTransactions(backend, batchStart, batchEnd, limit, verify)
+			if err != nil {
+				return fmt.Errorf("could not read transactions: %v", err)
+			}
+
+			for _, transformInput := range transactions {
+				deposits, err := transform.TransformDeposits(transformInput.Transaction, transformInput.LedgerHistory, accounts)
+				if err != nil {
+					ledgerSeq := transformInput.LedgerHistory.Header.LedgerSeq
+					errMsg := fmt.Sprintf("could not transform the deposits of transaction %d in ledger %d: ", transformInput.Transaction.Index, ledgerSeq)
+					if err := failures.handle(errMsg, err); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				// A transaction can make several deposits, so we need to ensure they are all exported
+				for _, transformed := range deposits {
+					if transformed.Incomplete {
+						incompleteClaims++
+					}
+
+					row, keep, err := program.Apply(transformed)
+					if err != nil {
+						parsedID := toid.Parse(transformed.OperationID)
+						locationString := fmt.Sprintf("from ledger %d, transaction %d, operation %d", parsedID.LedgerSequence, parsedID.TransactionOrder, parsedID.OperationOrder)
+						if err := failures.handle(fmt.Sprintf("could not evaluate expressions for deposit (%s): ", locationString), transform.NewInvalidDataError(transform.DepositsDataset, err)); err != nil {
+							return err
+						}
+
+						continue
+					}
+
+					if !keep {
+						continue
+					}
+
+					if writer == nil {
+						rows = append(rows, row)
+						continue
+					}
+
+					err = writer.Write(row)
+					if err != nil {
+						parsedID := toid.Parse(transformed.OperationID)
+						locationString := fmt.Sprintf("from ledger %d, transaction %d, operation %d", parsedID.LedgerSequence, parsedID.TransactionOrder, parsedID.OperationOrder)
+						if err := failures.handle(fmt.Sprintf("could not encode deposit (%s): ", locationString), transform.NewSerializationError(transform.DepositsDataset, err)); err != nil {
+							return err
+						}
+
+						continue
+					}
+				}
+			}
+
+			if table != nil {
+				if err := commitTable(table, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if sinks != nil {
+				if err := deliverBatch(sinks, transform.DepositsDataset, exampleRow, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if writer != nil {
+				if err := flushWriter(writer); err != nil {
+					return err
+				}
+			}
+
+			attempts += len(transactions)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
+			}
+		}
+
+		if incompleteClaims > 0 {
+			cmdLogger.Warnf("%d claims of claimable balances were read without the removal of the claimed balance in the transaction meta, so their amount, asset, and sender are unknown; read the ledgers with meta-stream to fill them in", incompleteClaims)
+		}
+
+		if !strictExport {
+			return printTransformStats(attempts, failures)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(depositsCmd)
+	utils.AddCommonFlags(depositsCmd.Flags())
+	utils.AddArchiveFlags("deposits", depositsCmd.Flags())
+	depositsCmd.Flags().String("addresses", "", "File that lists the deposit accounts, one per line, each followed by the memos that its deposits are expected to carry")
+	depositsCmd.MarkFlagRequired("end-ledger")
+	depositsCmd.MarkFlagRequired("addresses")
+
+	/*
+		Current flags:
+			start-ledger: the ledger sequence number for the beginning of the export period
+			end-ledger: the ledger sequence number for the end of the export range (*required)
+			clamp-range: narrow the range to the ledgers that the history archive holds
+			read-ahead: number of checkpoints that are downloaded from the history archive ahead of the one that is being exported
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is read instead of the history archive
+			addresses: file that lists the deposit accounts and the memos that they expect (*required)
+
+			limit: maximum number of transactions to scan for deposits
+
+			output-file: filename of the output file
+	*/
+}
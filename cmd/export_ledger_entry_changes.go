@@ -1,14 +1,22 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/delta"
+	"github.com/stellar/stellar-etl/internal/expr"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/sink"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -23,51 +31,181 @@ relevent data type that occurred during that batch.
 If the end-ledger is omitted, then the stellar-core node will continue running and exporting information as new ledgers are 
 confirmed by the Stellar network. 
 
-If no data type flags are set, then by default all of them are exported. If any are set, it is assumed that the others should not
-be exported.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
+If no data type flags are set, then by default accounts, offers, and trustlines are exported. If any are set, it is assumed that the 
+others should not be exported. If export-orderbooks is set, normalized orderbooks are exported in the same batches as the changes, using 
+a single pass over the ledgers of the same stellar-core instance.
 
-		execPath, configPath, startNum, batchSize, outputFolder := utils.MustCoreFlags(cmd.Flags(), cmdLogger)
-		exportAccounts, exportOffers, exportTrustlines := utils.MustExportTypeFlags(cmd.Flags(), cmdLogger)
+With --format debezium, each change is written as the envelope of a Debezium change event, with the entry before the change in before,
+the entry after it in after, op set to c, u, or d, and the ledger sequence, close time, and transaction hash of the change in source.
+Changes to the same entry within a batch are compacted into one event, whose source is that of the last of them. Orderbooks are written
+as JSON.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		execPath, configPath, startNum, batchSize, outputFolder, coreSocket, err := utils.GetCoreFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		exportAccounts, exportOffers, exportTrustlines, exportOrderbooks, err := utils.GetExportTypeFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		metaStream, err := utils.GetMetaStreamFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		format, err := utils.GetFormatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		indexPattern, err := indexPatternFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
+		if err != nil {
+			return err
+		}
 
+		statePath, onReset, err := utils.GetStateFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		// When the changes are committed to tables, the output folder is only needed for orderbooks. Sinks receive both
 		var folderPath string
-		if !useStdout {
-			folderPath = mustCreateFolder(outputFolder)
+		if !useStdout && sinks == nil && (tablePath == "" || exportOrderbooks) {
+			folderPath, err = createFolder(outputFolder)
+			if err != nil {
+				return err
+			}
+		}
+
+		if statePath == "" && folderPath != "" {
+			statePath = filepath.Join(folderPath, "state.json")
+		}
+
+		if onReset == "namespace" && sinks != nil {
+			return fmt.Errorf("on-reset namespace is not supported with sinks")
+		}
+
+		state, err := loadExportState(statePath, onReset)
+		if err != nil {
+			return err
 		}
 
 		if batchSize <= 0 {
-			cmdLogger.Fatalf("batch-size (%d) must be greater than 0", batchSize)
+			return fmt.Errorf("batch-size (%d) must be greater than 0", batchSize)
 		}
 
-		// If none of the export flags are set, then we assume that everything should be exported
-		if !exportAccounts && !exportOffers && !exportTrustlines {
-			exportAccounts, exportOffers, exportTrustlines = true, true, true
+		if format == output.DebeziumFormat && tablePath != "" {
+			return fmt.Errorf("changes in the %s format cannot be committed to tables", output.DebeziumFormat)
 		}
 
-		if configPath == "" && endNum == 0 {
-			cmdLogger.Fatal("stellar-core needs a config file path when exporting ledgers continuously (endNum = 0)")
+		// If none of the export flags are set, then we assume that all the changes should be exported. Orderbooks are only exported when requested
+		if !exportAccounts && !exportOffers && !exportTrustlines && !exportOrderbooks {
+			exportAccounts, exportOffers, exportTrustlines = true, true, true
 		}
 
-		var err error
-		execPath, err = filepath.Abs(execPath)
+		programs, err := newChangePrograms(cmd.Flags(), format)
 		if err != nil {
-			cmdLogger.Fatal("could not get absolute filepath for stellar-core executable: ", err)
+			return err
 		}
 
-		configPath, err = filepath.Abs(configPath)
-		if err != nil {
-			cmdLogger.Fatal("could not get absolute filepath for the config file: ", err)
+		// After a network reset, the output of the new network is kept apart from the output of the old one, in the folder and tables of its namespace
+		baseFolderPath, baseTablePath := folderPath, tablePath
+		var tables *changeTables
+		useNamespace := func(namespace string) error {
+			folderPath, tablePath = baseFolderPath, baseTablePath
+			if namespace != "" && folderPath != "" {
+				folderPath, err = createFolder(filepath.Join(folderPath, namespace))
+				if err != nil {
+					return err
+				}
+			}
+
+			if namespace != "" && tablePath != "" {
+				tablePath = strings.TrimRight(tablePath, "/") + "/" + namespace
+			}
+
+			tables, err = openChangeTables(tablePath, programs, exportAccounts, exportOffers, exportTrustlines)
+			return err
 		}
 
-		core, err := input.PrepareCaptiveCore(execPath, configPath, startNum, endNum)
+		err = useNamespace(state.namespace())
 		if err != nil {
-			cmdLogger.Fatal("error creating a prepared captive core instance: ", err)
+			return err
 		}
 
 		accChannel, offChannel, trustChannel := createChangeChannels(exportAccounts, exportOffers, exportTrustlines)
+		var orderbookChannel chan input.OrderbookBatch
+		if exportOrderbooks {
+			// The orderbook is read from the bucket list at the most recent checkpoint, so core has to start at that checkpoint to bring it up to date
+			checkpointSeq := utils.GetMostRecentCheckpoint(startNum)
+			core, err := prepareCoreBackend(execPath, configPath, coreSocket, metaStream, checkpointSeq, endNum)
+			if err != nil {
+				return err
+			}
+
+			defer core.Close()
+			orderbook, err := input.GetEntriesFromGenesis(checkpointSeq, xdr.LedgerEntryTypeOffer)
+			if err != nil {
+				return fmt.Errorf("could not read initial orderbook: %v", err)
+			}
+
+			orderbookChannel = make(chan input.OrderbookBatch)
+			channels := input.CombinedChannels{Accounts: accChannel, Offers: offChannel, Trustlines: trustChannel, Orderbooks: orderbookChannel}
+			go input.StreamChangesAndOrderbooks(state.wrap(core), checkpointSeq, startNum, endNum, batchSize, orderbook, channels, cmdLogger)
+		} else {
+			core, err := prepareCoreBackend(execPath, configPath, coreSocket, metaStream, startNum, endNum)
+			if err != nil {
+				return err
+			}
+
+			defer core.Close()
+			go input.StreamChanges(state.wrap(core), startNum, endNum, batchSize, accChannel, offChannel, trustChannel, cmdLogger)
+		}
+
+		// Each batch is checked against the history archive before it is written, so that a batch of a reset network is written to its new namespace
+		exportChanges := exportAccounts || exportOffers || exportTrustlines
+		exportBatch := func(batchStart, batchEnd uint32) error {
+			data, err := receiveBatchData(format, strictExport, exportChanges, programs, accChannel, offChannel, trustChannel, orderbookChannel)
+			if err != nil {
+				return err
+			}
+
+			switched, err := state.checkBatch(batchEnd, endNum == 0)
+			if err != nil {
+				return err
+			}
+
+			if switched {
+				if err := useNamespace(state.namespace()); err != nil {
+					return err
+				}
+			}
+
+			err = exportBatchData(batchStart, batchEnd, folderPath, format, indexPattern, useStdout, strictExport, tables, sinks, data)
+			if err != nil {
+				return err
+			}
+
+			return state.save()
+		}
 
-		go input.StreamChanges(core, startNum, endNum, batchSize, accChannel, offChannel, trustChannel, cmdLogger)
 		if endNum != 0 {
 			batchCount := uint32(math.Ceil(float64(endNum-startNum+1) / float64(batchSize)))
 			for i := uint32(0); i < batchCount; i++ {
@@ -78,8 +216,9 @@ be exported.`,
 					batchEnd = endNum
 				}
 
-				transformedAccounts, transformedOffers, transformedTrustlines := input.ReceiveChanges(accChannel, offChannel, trustChannel, strictExport, cmdLogger)
-				exportTransformedData(batchStart, batchEnd, folderPath, useStdout, strictExport, transformedAccounts, transformedOffers, transformedTrustlines)
+				if err := exportBatch(batchStart, batchEnd); err != nil {
+					return err
+				}
 			}
 
 		} else {
@@ -87,18 +226,196 @@ be exported.`,
 			for {
 				batchStart := startNum + batchNum*batchSize
 				batchEnd := batchStart + batchSize - 1
-				transformedAccounts, transformedOffers, transformedTrustlines := input.ReceiveChanges(accChannel, offChannel, trustChannel, strictExport, cmdLogger)
-				exportTransformedData(batchStart, batchEnd, folderPath, useStdout, strictExport, transformedAccounts, transformedOffers, transformedTrustlines)
+				if err := exportBatch(batchStart, batchEnd); err != nil {
+					return err
+				}
+
 				batchNum++
 			}
 		}
+
+		return nil
 	},
 }
 
-func mustCreateFolder(path string) string {
+// exportState keeps the state file of an export up to date. A nil exportState keeps no state
+type exportState struct {
+	path     string
+	onReset  string
+	state    input.ExportState
+	recorder *input.HashRecorder
+	verifier *input.ExportVerifier
+	verified uint32
+}
+
+// loadExportState reads the state file at path and checks that the ledgers it records are still in the history archive. If the network has
+// been reset and onReset is namespace, the state moves to a new namespace. Otherwise an error is returned. If path is empty, nil is returned
+func loadExportState(path, onReset string) (*exportState, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	state, err := input.LoadExportState(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the state file: %v", err)
+	}
+
+	verifier, err := input.NewExportVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the history archive: %v", err)
+	}
+
+	s := &exportState{path: path, onReset: onReset, state: state, verifier: verifier}
+	verified, err := verifier.Verify(state, 0)
+	if _, isReset := err.(*input.NetworkResetError); isReset && onReset == "namespace" {
+		return s, s.startNamespace(err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not verify the exported ledgers: %v", err)
+	}
+
+	s.verified = verified
+	return s, nil
+}
+
+func (s *exportState) namespace() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.state.Namespace
+}
+
+// startNamespace moves the state to a new namespace, in which no ledgers have been exported yet, after the network was reset
+func (s *exportState) startNamespace(reset error) error {
+	s.state = input.ExportState{Namespace: "reset-" + time.Now().UTC().Format("20060102T150405Z")}
+	s.verified = 0
+	cmdLogger.Warningf("%v; writing the output to the new namespace %s", reset, s.state.Namespace)
+	return s.save()
+}
+
+// wrap returns a backend that records the hashes of the ledgers that are read from core
+func (s *exportState) wrap(core ledgerbackend.LedgerBackend) ledgerbackend.LedgerBackend {
+	if s == nil {
+		return core
+	}
+
+	s.recorder = input.NewHashRecorder(core)
+	return s.recorder
+}
+
+func (s *exportState) save() error {
+	if s == nil {
+		return nil
+	}
+
+	err := s.state.Save(s.path)
+	if err != nil {
+		return fmt.Errorf("could not write the state file: %v", err)
+	}
+
+	return nil
+}
+
+/*
+	checkBatch records the hash of the last ledger of a batch that has been read, before the batch is written. The state is saved once
+	the batch is written. When the export follows the network, the ledgers exported before the batch are first compared with the history
+	archive as it publishes them. If the network has been reset, an error is returned, unless onReset is namespace, in which case the state
+	moves to a new namespace and true is returned, so that the batch is written to the output of the new namespace.
+*/
+func (s *exportState) checkBatch(end uint32, follow bool) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	switched := false
+	if follow {
+		verified, err := s.verifier.Verify(s.state, s.verified)
+		if _, isReset := err.(*input.NetworkResetError); isReset {
+			if s.onReset != "namespace" {
+				return false, err
+			}
+
+			if err := s.startNamespace(err); err != nil {
+				return false, err
+			}
+
+			switched = true
+		} else if err != nil {
+			cmdLogger.Warning("could not compare the exported ledgers with the history archive: ", err)
+		} else {
+			s.verified = verified
+		}
+	}
+
+	hash, ok := s.recorder.TakeHash(end)
+	if !ok {
+		cmdLogger.Warningf("could not record the hash of ledger %d in the state file", end)
+		return switched, nil
+	}
+
+	s.state.Record(end, hash)
+	return switched, nil
+}
+
+// prepareCoreBackend prepares a ledger backend for the range [start, end]. If coreSocket is set, the ledgers are read from a running core daemon,
+// and if metaStream is set, they are read from the metadata stream of a running stellar-core. Otherwise, a new captive core instance is started.
+// The range is unbounded when end = 0
+func prepareCoreBackend(execPath, configPath, coreSocket, metaStream string, start, end uint32) (ledgerbackend.LedgerBackend, error) {
+	if coreSocket != "" && metaStream != "" {
+		return nil, fmt.Errorf("core-socket and meta-stream cannot both be set")
+	}
+
+	if metaStream != "" {
+		backend, err := input.PrepareMetaStream(metaStream, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the metadata stream: %v", err)
+		}
+
+		return backend, nil
+	}
+
+	if coreSocket != "" {
+		backend, err := input.PrepareSocketBackend(coreSocket, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to the core daemon: %v", err)
+		}
+
+		return backend, nil
+	}
+
+	if execPath == "" {
+		return nil, fmt.Errorf("stellar-core needs an executable path when neither a core daemon socket nor a metadata stream is provided")
+	}
+
+	if configPath == "" && end == 0 {
+		return nil, fmt.Errorf("stellar-core needs a config file path when exporting ledgers continuously (endNum = 0)")
+	}
+
+	var err error
+	execPath, err = filepath.Abs(execPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute filepath for stellar-core executable: %v", err)
+	}
+
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute filepath for the config file: %v", err)
+	}
+
+	core, err := input.PrepareCaptiveCore(execPath, configPath, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error creating a prepared captive core instance: %v", err)
+	}
+
+	return core, nil
+}
+
+func createFolder(path string) (string, error) {
 	absolutePath, err := filepath.Abs(path)
 	if err != nil {
-		cmdLogger.Fatal("could not get absolute filepath: ", err)
+		return "", fmt.Errorf("could not get absolute filepath: %v", err)
 	}
 
 	_, err = os.Stat(path)
@@ -106,51 +423,343 @@ func mustCreateFolder(path string) string {
 	if os.IsNotExist(err) {
 		err := os.Mkdir(path, 0777)
 		if err != nil {
-			cmdLogger.Fatal("could not create folder: ", err)
+			return "", fmt.Errorf("could not create folder: %v", err)
 		}
 	}
 
-	return absolutePath
+	return absolutePath, nil
 }
 
-// exportEntry exports the provided entry, printing either to the file or to stdout.
-func exportEntry(entry interface{}, file *os.File, useStdout, strictExport bool) {
-	marshalled, err := json.Marshal(entry)
-	if err != nil {
-		if strictExport {
-			cmdLogger.Fatal("could not json encode account", err)
-		} else {
-			cmdLogger.Warning("could not json encode account", err)
+// exportEntries writes the entries in the output format, either to a new file at path or to stdout
+func exportEntries(format, indexPattern, path string, useStdout, strictExport bool, dataset string, exampleRow interface{}, entries []interface{}) error {
+	var file *os.File
+	if !useStdout {
+		var err error
+		file, err = formatOutFile(format, path)
+		if err != nil {
+			return err
 		}
+
+		defer file.Close()
 	}
 
-	if !useStdout {
-		file.Write(marshalled)
-		file.WriteString("\n")
-	} else {
-		fmt.Println(string(marshalled))
+	writer, err := newWriter(format, indexPattern, outputOf(file, useStdout), dataset, exampleRow)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err := writer.Write(entry)
+		if err != nil {
+			if strictExport {
+				closeWriter(writer)
+				return fmt.Errorf("could not encode entry: %v", err)
+			} else {
+				cmdLogger.Warning("could not encode entry", err)
+			}
+		}
 	}
+
+	return closeWriter(writer)
 }
 
-func exportTransformedData(start, end uint32, folderPath string, useStdout, strictExport bool, accounts []transform.AccountOutput, offers []transform.OfferOutput, trusts []transform.TrustlineOutput) {
-	var accountFile, offersFile, trustFile *os.File
-	if !useStdout {
-		accountFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-accounts.txt", start, end)))
-		offersFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-offers.txt", start, end)))
-		trustFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-trustlines.txt", start, end)))
+// changeTables holds the Delta Lake tables that the changes are committed to. The tables of data types that are not exported are nil
+type changeTables struct {
+	accounts   *delta.Table
+	offers     *delta.Table
+	trustlines *delta.Table
+}
+
+// openChangeTables opens the tables of the exported data types, with the derived columns of the programs. If tablePath is empty, the changes are
+// written to files and nil is returned
+func openChangeTables(tablePath string, programs changePrograms, exportAccounts, exportOffers, exportTrustlines bool) (*changeTables, error) {
+	if tablePath == "" {
+		return nil, nil
+	}
+
+	var err error
+	tables := &changeTables{}
+	accountRow, offerRow, trustRow := programs.exampleRows()
+	if exportAccounts {
+		if tables.accounts, err = openTable(tablePath, transform.AccountsDataset, accountRow); err != nil {
+			return nil, err
+		}
 	}
 
+	if exportOffers {
+		if tables.offers, err = openTable(tablePath, transform.OffersDataset, offerRow); err != nil {
+			return nil, err
+		}
+	}
+
+	if exportTrustlines {
+		if tables.trustlines, err = openTable(tablePath, transform.TrustlinesDataset, trustRow); err != nil {
+			return nil, err
+		}
+	}
+
+	return tables, nil
+}
+
+// changeRows returns the transformed changes as rows that can be written, committed, or delivered
+func changeRows(accounts []transform.AccountOutput, offers []transform.OfferOutput, trusts []transform.TrustlineOutput) (accountRows, offerRows, trustRows []interface{}) {
+	accountRows = make([]interface{}, 0, len(accounts))
 	for _, acc := range accounts {
-		exportEntry(acc, accountFile, useStdout, strictExport)
+		accountRows = append(accountRows, acc)
 	}
 
+	offerRows = make([]interface{}, 0, len(offers))
 	for _, off := range offers {
-		exportEntry(off, offersFile, useStdout, strictExport)
+		offerRows = append(offerRows, off)
 	}
 
+	trustRows = make([]interface{}, 0, len(trusts))
 	for _, trust := range trusts {
-		exportEntry(trust, trustFile, useStdout, strictExport)
+		trustRows = append(trustRows, trust)
+	}
+
+	return
+}
+
+// envelopeRows returns the envelopes of the change events as rows that can be written or delivered
+func envelopeRows(accounts, offers, trusts []transform.ChangeEnvelope) (accountRows, offerRows, trustRows []interface{}) {
+	toRows := func(envelopes []transform.ChangeEnvelope) []interface{} {
+		rows := make([]interface{}, 0, len(envelopes))
+		for _, envelope := range envelopes {
+			rows = append(rows, envelope)
+		}
+
+		return rows
+	}
+
+	return toRows(accounts), toRows(offers), toRows(trusts)
+}
+
+// changePrograms holds the derived columns and row filters of the change datasets. Datasets without expressions have a nil program
+type changePrograms struct {
+	accounts   *expr.Program
+	offers     *expr.Program
+	trustlines *expr.Program
+}
+
+// newChangePrograms compiles the programs of the change datasets. Changes in the debezium format have no programs, since their rows are envelopes
+func newChangePrograms(flags *pflag.FlagSet, format string) (changePrograms, error) {
+	if format == output.DebeziumFormat {
+		path, err := utils.GetExpressionsFlag(flags)
+		if err != nil {
+			return changePrograms{}, err
+		}
+
+		if path != "" {
+			return changePrograms{}, fmt.Errorf("expressions cannot be applied to changes in the %s format", output.DebeziumFormat)
+		}
+
+		return changePrograms{}, nil
+	}
+
+	var programs changePrograms
+	var err error
+	if programs.accounts, err = rowProgram(flags, transform.AccountsDataset, transform.AccountOutput{}, format); err != nil {
+		return changePrograms{}, err
+	}
+
+	if programs.offers, err = rowProgram(flags, transform.OffersDataset, transform.OfferOutput{}, format); err != nil {
+		return changePrograms{}, err
+	}
+
+	if programs.trustlines, err = rowProgram(flags, transform.TrustlinesDataset, transform.TrustlineOutput{}, format); err != nil {
+		return changePrograms{}, err
+	}
+
+	return programs, nil
+}
+
+// exampleRows returns the example rows of the change datasets, which have the derived columns that the programs add
+func (p changePrograms) exampleRows() (accountRow, offerRow, trustRow interface{}) {
+	return p.accounts.ExampleRow(transform.AccountOutput{}), p.offers.ExampleRow(transform.OfferOutput{}), p.trustlines.ExampleRow(transform.TrustlineOutput{})
+}
+
+// applyProgram applies the expressions of the dataset to the rows. Rows that the filter drops, or whose expressions cannot be evaluated, are left out.
+// If strictExport is set, an error is returned for the first row whose expressions cannot be evaluated
+func applyProgram(program *expr.Program, dataset string, rows []interface{}, strictExport bool) ([]interface{}, error) {
+	if program == nil {
+		return rows, nil
+	}
+
+	kept := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		applied, keep, err := program.Apply(row)
+		if err != nil {
+			if strictExport {
+				return nil, fmt.Errorf("could not evaluate expressions for %s: %v", dataset, err)
+			} else {
+				cmdLogger.Warning(fmt.Sprintf("could not evaluate expressions for %s: ", dataset), err)
+			}
+
+			continue
+		}
+
+		if keep {
+			kept = append(kept, applied)
+		}
 	}
+
+	return kept, nil
+}
+
+// commitTransformedData commits the changes of the batch [start, end] to the tables of the exported data types
+func commitTransformedData(start, end uint32, tables *changeTables, accountRows, offerRows, trustRows []interface{}) error {
+	if tables.accounts != nil {
+		if err := commitTable(tables.accounts, accountRows, start, end); err != nil {
+			return err
+		}
+	}
+
+	if tables.offers != nil {
+		if err := commitTable(tables.offers, offerRows, start, end); err != nil {
+			return err
+		}
+	}
+
+	if tables.trustlines != nil {
+		if err := commitTable(tables.trustlines, trustRows, start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliverTransformedData delivers the changes of the batch [start, end] to the sinks. Only the exported data types are delivered
+func deliverTransformedData(start, end uint32, sinks *sink.Fanout, data batchData) error {
+	if data.accounts {
+		if err := deliverBatch(sinks, transform.AccountsDataset, data.accountRow, data.accountRows, start, end); err != nil {
+			return err
+		}
+	}
+
+	if data.offers {
+		if err := deliverBatch(sinks, transform.OffersDataset, data.offerRow, data.offerRows, start, end); err != nil {
+			return err
+		}
+	}
+
+	if data.trustlines {
+		if err := deliverBatch(sinks, transform.TrustlinesDataset, data.trustRow, data.trustRows, start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportTransformedData(start, end uint32, folderPath, format, indexPattern string, useStdout, strictExport bool, data batchData) error {
+	changesPath := func(dataset string) string {
+		return filepath.Join(folderPath, fmt.Sprintf("%d-%d-%s%s", start, end, dataset, output.Extension(format)))
+	}
+
+	err := exportEntries(format, indexPattern, changesPath(transform.AccountsDataset), useStdout, strictExport, transform.AccountsDataset, data.accountRow, data.accountRows)
+	if err != nil {
+		return err
+	}
+
+	err = exportEntries(format, indexPattern, changesPath(transform.OffersDataset), useStdout, strictExport, transform.OffersDataset, data.offerRow, data.offerRows)
+	if err != nil {
+		return err
+	}
+
+	return exportEntries(format, indexPattern, changesPath(transform.TrustlinesDataset), useStdout, strictExport, transform.TrustlinesDataset, data.trustRow, data.trustRows)
+}
+
+// batchData holds the rows of the changes of a batch and its orderbooks, which are received before any of them are written
+type batchData struct {
+	changes                           bool
+	accounts, offers, trustlines      bool
+	accountRows, offerRows, trustRows []interface{}
+	accountRow, offerRow, trustRow    interface{} // the example rows of the changes, with their derived columns
+	orderbook                         *input.OrderbookParser
+}
+
+// receiveBatchData receives the next batch from each of the channels. The orderbook channel is nil unless orderbooks are exported. The changes
+// are filtered and given derived columns by the programs. In the debezium format, the changes are the envelopes of change events instead of rows
+func receiveBatchData(format string, strictExport, exportChanges bool, programs changePrograms, accChannel, offChannel, trustChannel chan input.ChangeBatch, orderbookChannel chan input.OrderbookBatch) (batchData, error) {
+	data := batchData{changes: exportChanges, accounts: accChannel != nil, offers: offChannel != nil, trustlines: trustChannel != nil}
+	data.accountRow, data.offerRow, data.trustRow = programs.exampleRows()
+	if exportChanges {
+		if format == output.DebeziumFormat {
+			accounts, offers, trustlines, err := input.ReceiveChangeEnvelopes(accChannel, offChannel, trustChannel, strictExport, cmdLogger)
+			if err != nil {
+				return batchData{}, err
+			}
+
+			data.accountRows, data.offerRows, data.trustRows = envelopeRows(accounts, offers, trustlines)
+		} else {
+			accounts, offers, trustlines, err := input.ReceiveChanges(accChannel, offChannel, trustChannel, strictExport, cmdLogger)
+			if err != nil {
+				return batchData{}, err
+			}
+
+			data.accountRows, data.offerRows, data.trustRows = changeRows(accounts, offers, trustlines)
+		}
+
+		var err error
+		if data.accountRows, err = applyProgram(programs.accounts, transform.AccountsDataset, data.accountRows, strictExport); err != nil {
+			return batchData{}, err
+		}
+
+		if data.offerRows, err = applyProgram(programs.offers, transform.OffersDataset, data.offerRows, strictExport); err != nil {
+			return batchData{}, err
+		}
+
+		if data.trustRows, err = applyProgram(programs.trustlines, transform.TrustlinesDataset, data.trustRows, strictExport); err != nil {
+			return batchData{}, err
+		}
+	}
+
+	if orderbookChannel != nil {
+		orderbook, err := input.ReceiveParsedOrderbooks(orderbookChannel, strictExport, cmdLogger)
+		if err != nil {
+			return batchData{}, err
+		}
+
+		data.orderbook = orderbook
+	}
+
+	return data, nil
+}
+
+// exportBatchData exports a batch that has been received. The changes are committed to the tables if tables is not nil, and the changes and
+// orderbooks are delivered to the sinks if sinks is not nil. Batches are only written to files if neither is set
+func exportBatchData(start, end uint32, folderPath, format, indexPattern string, useStdout, strictExport bool, tables *changeTables, sinks *sink.Fanout, data batchData) error {
+	if data.changes {
+		if tables != nil {
+			if err := commitTransformedData(start, end, tables, data.accountRows, data.offerRows, data.trustRows); err != nil {
+				return err
+			}
+		}
+
+		if sinks != nil {
+			if err := deliverTransformedData(start, end, sinks, data); err != nil {
+				return err
+			}
+		}
+
+		if tables == nil && sinks == nil {
+			if err := exportTransformedData(start, end, folderPath, format, indexPattern, useStdout, strictExport, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if data.orderbook != nil {
+		if sinks != nil {
+			return deliverOrderbook(start, end, sinks, data.orderbook)
+		}
+
+		return exportOrderbook(start, end, folderPath, format, indexPattern, useStdout, strictExport, data.orderbook)
+	}
+
+	return nil
 }
 
 func createChangeChannels(exportAccounts, exportOffers, exportTrustlines bool) (accChan, offChan, trustChan chan input.ChangeBatch) {
@@ -174,9 +783,12 @@ func init() {
 	utils.AddCommonFlags(exportLedgerEntryChangesCmd.Flags())
 	utils.AddCoreFlags(exportLedgerEntryChangesCmd.Flags(), "changes_output/")
 	utils.AddExportTypeFlags(exportLedgerEntryChangesCmd.Flags())
+	utils.AddTableFlags(exportLedgerEntryChangesCmd.Flags())
+	utils.AddSinkFlags(exportLedgerEntryChangesCmd.Flags())
+	utils.AddExpressionsFlag(exportLedgerEntryChangesCmd.Flags())
+	utils.AddStateFlags(exportLedgerEntryChangesCmd.Flags())
 
 	exportLedgerEntryChangesCmd.MarkFlagRequired("start-ledger")
-	exportLedgerEntryChangesCmd.MarkFlagRequired("core-executable")
 	/*
 		Current flags:
 			start-ledger: the ledger sequence number for the beginning of the export period
@@ -189,14 +801,29 @@ func init() {
 
 			core-executable: path to stellar-core executable
 			core-config: path to stellar-core config file
+			core-socket: path to the socket of a running core daemon, which is used instead of starting stellar-core
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is used instead of starting stellar-core
 
 			If none of the export_X flags are set, assume everything should be exported
 				export_accounts: boolean flag; if set then accounts should be exported
 				export_trustlines: boolean flag; if set then trustlines should be exported
 				export_offers: boolean flag; if set then offers should be exported
+			export_orderbooks: boolean flag; if set then normalized orderbooks are exported in the same batches as the changes
+
+			table-path: if set, the changes of each batch are committed to Delta Lake tables instead of written to files; orderbooks are still written to files
+			format: the format of the output files (json, avro, debezium, or es-bulk); debezium writes each change as the envelope of a change event
+			index-pattern: the name of the index of each dataset in the es-bulk format
+
+			sink: destination that every batch of changes and orderbooks is delivered to instead of the output folder; can be repeated
+			optional-sink: like sink, but batches are complete even if the sink does not confirm them
+			sink-attempts: number of times each sink tries to deliver a batch
+
+			expressions: file with the derived columns and row filters of the changes
+
+			state-file: file that records the hashes of the exported ledgers; defaults to state.json in the output folder
+			on-reset: stop, or namespace to write the output of a reset network to a new subfolder
 
 		TODO: implement extra flags if possible
-			serialize-method: the method for serialization of the output data (JSON, XDR, etc)
 			start and end time as a replacement for start and end sequence numbers
 	*/
 }
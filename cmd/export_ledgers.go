@@ -1,121 +1,163 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
 
-func createOutputFile(filepath string) error {
-	var _, err = os.Stat(filepath)
-	if os.IsNotExist(err) {
-		var file, err = os.Create(filepath)
+var ledgersCmd = &cobra.Command{
+	Use:   "export_ledgers",
+	Short: "Exports the ledger data.",
+	Long:  `Exports ledger data within the specified range to an output file. Data is appended to the output file after being encoded as a JSON object.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
 		if err != nil {
 			return err
 		}
 
-		defer file.Close()
-	}
-
-	return nil
-}
-
-func mustOutFile(path string) *os.File {
-	absolutePath, err := filepath.Abs(path)
-	if err != nil {
-		cmdLogger.Fatal("could not get absolute filepath: ", err)
-	}
+		startNum, path, limit, verify, err := utils.GetArchiveFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-	err = createOutputFile(absolutePath)
-	if err != nil {
-		cmdLogger.Fatal("could not create output file: ", err)
-	}
+		startNum, endNum, err = clampRange(cmd.Flags(), startNum, endNum)
+		if err != nil {
+			return err
+		}
 
-	outFile, err := os.OpenFile(absolutePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		cmdLogger.Fatal("error in opening output file: ", err)
-	}
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
 
-	return outFile
-}
+		// Rows are collected and committed to the table or delivered to the sinks in batches when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-// Prints the number of attempted, failed, and successful transformations as a JSON object
-func printTransformStats(attempts, failures int) {
-	resultsMap := map[string]int{
-		"attempted_transforms":  attempts,
-		"failed_transforms":     failures,
-		"successful_transforms": attempts - failures,
-	}
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-	results, err := json.Marshal(resultsMap)
-	if err != nil {
-		cmdLogger.Fatal("Could not marshall results: ", err)
-	}
+		program, err := rowProgram(cmd.Flags(), transform.LedgersDataset, transform.LedgerOutput{}, format)
+		if err != nil {
+			return err
+		}
 
-	fmt.Println(string(results))
-}
+		exampleRow := program.ExampleRow(transform.LedgerOutput{})
+		table, err := openTable(tablePath, transform.LedgersDataset, exampleRow)
+		if err != nil {
+			return err
+		}
 
-var ledgersCmd = &cobra.Command{
-	Use:   "export_ledgers",
-	Short: "Exports the ledger data.",
-	Long:  `Exports ledger data within the specified range to an output file. Data is appended to the output file after being encoded as a JSON object.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-		startNum, path, limit := utils.MustArchiveFlags(cmd.Flags(), cmdLogger)
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
+		if err != nil {
+			return err
+		}
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, transform.LedgersDataset, exampleRow)
+			if err != nil {
+				return err
+			}
 		}
 
-		ledgers, err := input.GetLedgers(startNum, endNum, limit)
+		backend, err := openLedgerBackend(cmd.Flags(), checkpointCache(cmd), startNum, endNum, limit)
 		if err != nil {
-			cmdLogger.Fatal("could not read ledgers: ", err)
+			return err
 		}
 
-		failures := 0
-		for i, lcm := range ledgers {
-			transformed, err := transform.TransformLedger(lcm)
+		defer backend.Close()
+		attempts := 0
+		err = exportRanges(startNum, endNum, func(batchStart, batchEnd uint32) error {
+			rows := []interface{}{}
+			ledgers, err := input.GetLedgers(backend, batchStart, batchEnd, limit, verify)
 			if err != nil {
-				errMsg := fmt.Sprintf("could not transform ledger %d: ", startNum+uint32(i))
-				if strictExport {
-					cmdLogger.Fatal(errMsg, err)
-				} else {
-					cmdLogger.Warning(errMsg, err)
-					failures++
+				return fmt.Errorf("could not read ledgers: %v", err)
+			}
+
+			for i, lcm := range ledgers {
+				transformed, err := transform.TransformLedger(lcm)
+				if err != nil {
+					if err := failures.handle(fmt.Sprintf("could not transform ledger %d: ", batchStart+uint32(i)), err); err != nil {
+						return err
+					}
+
 					continue
 				}
-			}
 
-			marshalled, err := json.Marshal(transformed)
-			if err != nil {
-				errMsg := fmt.Sprintf("could not json encode ledger %d: ", startNum+uint32(i))
-				if strictExport {
-					cmdLogger.Fatal(errMsg, err)
-				} else {
-					cmdLogger.Warning(errMsg, err)
-					failures++
+				row, keep, err := program.Apply(transformed)
+				if err != nil {
+					if err := failures.handle(fmt.Sprintf("could not evaluate expressions for ledger %d: ", batchStart+uint32(i)), transform.NewInvalidDataError(transform.LedgersDataset, err)); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				if !keep {
+					continue
+				}
+
+				if writer == nil {
+					rows = append(rows, row)
+					continue
+				}
+
+				err = writer.Write(row)
+				if err != nil {
+					if err := failures.handle(fmt.Sprintf("could not encode ledger %d: ", batchStart+uint32(i)), transform.NewSerializationError(transform.LedgersDataset, err)); err != nil {
+						return err
+					}
+
 					continue
 				}
 			}
 
-			if !useStdout {
-				outFile.Write(marshalled)
-				outFile.WriteString("\n")
-			} else {
-				fmt.Println(string(marshalled))
+			if table != nil {
+				if err := commitTable(table, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if sinks != nil {
+				if err := deliverBatch(sinks, transform.LedgersDataset, exampleRow, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if writer != nil {
+				if err := flushWriter(writer); err != nil {
+					return err
+				}
+			}
+
+			attempts += len(ledgers)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
 			}
 		}
 
 		if !strictExport {
-			printTransformStats(len(ledgers), failures)
+			return printTransformStats(attempts, failures)
 		}
+
+		return nil
 	},
 }
 
@@ -128,6 +170,10 @@ func init() {
 		Current flags:
 			start-ledger: the ledger sequence number for the beginning of the export period
 			end-ledger: the ledger sequence number for the end of the export range (required)
+			clamp-range: narrow the range to the ledgers that the history archive holds
+			read-ahead: number of checkpoints that are downloaded from the history archive ahead of the one that is being exported
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is read instead of the history archive
+			expressions: file with the derived columns and row filters of the ledgers
 
 			limit: maximum number of ledgers to export; default to 60 (1 ledger per 5 seconds over our 5 minute update period)
 			output-file: filename of the output file
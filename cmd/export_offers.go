@@ -1,13 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
-
 	"github.com/spf13/cobra"
 	"github.com/stellar/go/xdr"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -20,55 +18,124 @@ var offersCmd = &cobra.Command{
 	The command reads from the bucket list, which includes the full history of the Stellar ledger. As a result, it 
 	should be used in an initial data dump. In order to get offer information within a specified ledger range, see 
 	the export_ledger_entry_changes command.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-		path := utils.MustBucketFlags(cmd.Flags(), cmdLogger)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		path, err := utils.GetBucketFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
+
+		// Rows are collected and committed to the table or delivered to the sinks as a single batch when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		program, err := rowProgram(cmd.Flags(), transform.OffersDataset, transform.OfferOutput{}, format)
+		if err != nil {
+			return err
+		}
+
+		exampleRow := program.ExampleRow(transform.OfferOutput{})
+		table, err := openTable(tablePath, transform.OffersDataset, exampleRow)
+		if err != nil {
+			return err
+		}
+
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
+		if err != nil {
+			return err
+		}
+
+		rows := []interface{}{}
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, transform.OffersDataset, exampleRow)
+			if err != nil {
+				return err
+			}
 		}
 
 		offers, err := input.GetEntriesFromGenesis(endNum, xdr.LedgerEntryTypeOffer)
 		if err != nil {
-			cmdLogger.Fatal("could not read offers: ", err)
+			return fmt.Errorf("could not read offers: %v", err)
 		}
 
-		failures := 0
 		for _, offer := range offers {
 			transformed, err := transform.TransformOffer(offer)
 			if err != nil {
-				if strictExport {
-					cmdLogger.Fatal("could not transform offer", err)
-				} else {
-					cmdLogger.Warning("could not transform offer", err)
-					failures++
-					continue
+				if err := failures.handle("could not transform offer", err); err != nil {
+					return err
 				}
+
+				continue
+			}
+
+			row, keep, err := program.Apply(transformed)
+			if err != nil {
+				if err := failures.handle("could not evaluate expressions for offer", transform.NewInvalidDataError(transform.OffersDataset, err)); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if !keep {
+				continue
 			}
 
-			marshalled, err := json.Marshal(transformed)
+			if writer == nil {
+				rows = append(rows, row)
+				continue
+			}
+
+			err = writer.Write(row)
 			if err != nil {
-				if strictExport {
-					cmdLogger.Fatal("could not json encode offer", err)
-				} else {
-					cmdLogger.Warning("could not json encode offer", err)
-					failures++
-					continue
+				if err := failures.handle("could not encode offer", transform.NewSerializationError(transform.OffersDataset, err)); err != nil {
+					return err
 				}
+
+				continue
 			}
+		}
 
-			if !useStdout {
-				outFile.Write(marshalled)
-				outFile.WriteString("\n")
-			} else {
-				fmt.Println(string(marshalled))
+		if table != nil {
+			if err := commitTable(table, rows, 1, endNum); err != nil {
+				return err
+			}
+		}
+
+		if sinks != nil {
+			if err := deliverBatch(sinks, transform.OffersDataset, exampleRow, rows, 1, endNum); err != nil {
+				return err
+			}
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
 			}
 		}
 
 		if !strictExport {
-			printTransformStats(len(offers), failures)
+			return printTransformStats(len(offers), failures)
 		}
+
+		return nil
 	},
 }
 
@@ -1,12 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -15,60 +14,154 @@ var operationsCmd = &cobra.Command{
 	Use:   "export_operations",
 	Short: "Exports the operations data over a specified range",
 	Long:  `Exports the operations data over a specified range. Each operation is an individual command that mutates the Stellar ledger.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-		startNum, path, limit := utils.MustArchiveFlags(cmd.Flags(), cmdLogger)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, path, limit, verify, err := utils.GetArchiveFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, endNum, err = clampRange(cmd.Flags(), startNum, endNum)
+		if err != nil {
+			return err
+		}
+
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
+
+		// Rows are collected and committed to the table or delivered to the sinks in batches when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		program, err := rowProgram(cmd.Flags(), transform.OperationsDataset, transform.OperationOutput{}, format)
+		if err != nil {
+			return err
+		}
+
+		exampleRow := program.ExampleRow(transform.OperationOutput{})
+		table, err := openTable(tablePath, transform.OperationsDataset, exampleRow)
+		if err != nil {
+			return err
+		}
+
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
+		if err != nil {
+			return err
+		}
+
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, transform.OperationsDataset, exampleRow)
+			if err != nil {
+				return err
+			}
 		}
 
-		operations, err := input.GetOperations(startNum, endNum, limit)
+		backend, err := openLedgerBackend(cmd.Flags(), checkpointCache(cmd), startNum, endNum, limit)
 		if err != nil {
-			cmdLogger.Fatal("could not read operations: ", err)
+			return err
 		}
 
-		failures := 0
-		for _, transformInput := range operations {
-			transformed, err := transform.TransformOperation(transformInput.Operation, transformInput.OperationIndex, transformInput.Transaction, transformInput.LedgerSeqNum)
+		defer backend.Close()
+		attempts := 0
+		err = exportRanges(startNum, endNum, func(batchStart, batchEnd uint32) error {
+			rows := []interface{}{}
+			operations, err := input.GetOperations(backend, batchStart, batchEnd, limit, verify)
 			if err != nil {
-				txIndex := transformInput.Transaction.Index
-				errMsg := fmt.Sprintf("could not transform operation %d in transaction %d in ledger %d: ", transformInput.OperationIndex, txIndex, transformInput.LedgerSeqNum)
-				if strictExport {
-					cmdLogger.Fatal(errMsg, err)
-				} else {
-					cmdLogger.Warning(errMsg, err)
-					failures++
+				return fmt.Errorf("could not read operations: %v", err)
+			}
+
+			for _, transformInput := range operations {
+				transformed, err := transform.TransformOperation(transformInput.Operation, transformInput.OperationIndex, transformInput.Transaction, transformInput.LedgerSeqNum, transformInput.LedgerCloseTime)
+				if err != nil {
+					txIndex := transformInput.Transaction.Index
+					errMsg := fmt.Sprintf("could not transform operation %d in transaction %d in ledger %d: ", transformInput.OperationIndex, txIndex, transformInput.LedgerSeqNum)
+					if err := failures.handle(errMsg, err); err != nil {
+						return err
+					}
+
 					continue
 				}
 
-			}
+				row, keep, err := program.Apply(transformed)
+				if err != nil {
+					if err := failures.handle(fmt.Sprintf("could not evaluate expressions for operation %d in transaction %d in ledger %d: ", transformInput.OperationIndex, transformInput.Transaction.Index, transformInput.LedgerSeqNum), transform.NewInvalidDataError(transform.OperationsDataset, err)); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				if !keep {
+					continue
+				}
+
+				if writer == nil {
+					rows = append(rows, row)
+					continue
+				}
+
+				err = writer.Write(row)
+				if err != nil {
+					txIndex := transformInput.Transaction.Index
+					errMsg := fmt.Sprintf("could not encode operation %d in ledger %d: ", transformInput.OperationIndex, txIndex)
+					if err := failures.handle(errMsg, transform.NewSerializationError(transform.OperationsDataset, err)); err != nil {
+						return err
+					}
 
-			marshalled, err := json.Marshal(transformed)
-			if err != nil {
-				txIndex := transformInput.Transaction.Index
-				errMsg := fmt.Sprintf("could not json encode operation %d in ledger %d: ", transformInput.OperationIndex, txIndex)
-				if strictExport {
-					cmdLogger.Fatal(errMsg, err)
-				} else {
-					cmdLogger.Warning(errMsg, err)
-					failures++
 					continue
 				}
 			}
 
-			if !useStdout {
-				outFile.Write(marshalled)
-				outFile.WriteString("\n")
-			} else {
-				fmt.Println(string(marshalled))
+			if table != nil {
+				if err := commitTable(table, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if sinks != nil {
+				if err := deliverBatch(sinks, transform.OperationsDataset, exampleRow, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if writer != nil {
+				if err := flushWriter(writer); err != nil {
+					return err
+				}
+			}
+
+			attempts += len(operations)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
 			}
 		}
 
 		if !strictExport {
-			printTransformStats(len(operations), failures)
+			return printTransformStats(attempts, failures)
 		}
+
+		return nil
 	},
 }
 
@@ -82,6 +175,9 @@ func init() {
 		Current flags:
 			start-ledger: the ledger sequence number for the beginning of the export period
 			end-ledger: the ledger sequence number for the end of the export range (required)
+			clamp-range: narrow the range to the ledgers that the history archive holds
+			read-ahead: number of checkpoints that are downloaded from the history archive ahead of the one that is being exported
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is read instead of the history archive
 
 			limit: maximum number of operations to export; default to 6,000,000
 				each transaction can have up to 100 operations
@@ -9,6 +9,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/stellar/go/xdr"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/sink"
+	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
 
@@ -18,54 +21,78 @@ var exportOrderbooksCmd = &cobra.Command{
 	Short: "This command exports the historical orderbooks",
 	Long: `This command instantiates a stellar-core instance and uses it to export normalized orderbooks.
 	The information is exported in batches determined by the batch-size flag. The normalized data is exported in multiple 
-	different files within the exported data folder. These files are dimAccounts.txt, dimOffers.txt, dimMarkets.txt, and factEvents.txt.
+	different files within the exported data folder. These files are dimAccounts, dimOffers, dimMarkets, and factEvents, with the extension of the output format.
 	These files contain normalized data that helps save storage space. 
 	
 	If the end-ledger is omitted, then the stellar-core node will continue running and exporting information as new ledgers are 
 	confirmed by the Stellar network. In this unbounded case, a stellar-core config file is required.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-
-		execPath, configPath, startNum, batchSize, outputFolder := utils.MustCoreFlags(cmd.Flags(), cmdLogger)
-		var folderPath string
-		if !useStdout {
-			folderPath = mustCreateFolder(outputFolder)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
 		}
 
-		if batchSize <= 0 {
-			cmdLogger.Fatalf("batch-size (%d) must be greater than 0", batchSize)
+		execPath, configPath, startNum, batchSize, outputFolder, coreSocket, err := utils.GetCoreFlags(cmd.Flags())
+		if err != nil {
+			return err
 		}
 
-		if configPath == "" && endNum == 0 {
-			cmdLogger.Fatal("stellar-core needs a config file path when exporting ledgers continuously (endNum = 0)")
+		metaStream, err := utils.GetMetaStreamFlag(cmd.Flags())
+		if err != nil {
+			return err
 		}
 
-		var err error
-		execPath, err = filepath.Abs(execPath)
+		format, indexPattern, err := formatFlag(cmd.Flags())
 		if err != nil {
-			cmdLogger.Fatal("could not get absolute filepath for stellar-core executable: ", err)
+			return err
 		}
 
-		configPath, err = filepath.Abs(configPath)
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
 		if err != nil {
-			cmdLogger.Fatal("could not get absolute filepath for the config file: ", err)
+			return err
+		}
+
+		var folderPath string
+		if !useStdout && sinks == nil {
+			folderPath, err = createFolder(outputFolder)
+			if err != nil {
+				return err
+			}
+		}
+
+		if batchSize <= 0 {
+			return fmt.Errorf("batch-size (%d) must be greater than 0", batchSize)
 		}
 
 		checkpointSeq := utils.GetMostRecentCheckpoint(startNum)
-		core, err := input.PrepareCaptiveCore(execPath, configPath, checkpointSeq, endNum)
+		core, err := prepareCoreBackend(execPath, configPath, coreSocket, metaStream, checkpointSeq, endNum)
 		if err != nil {
-			cmdLogger.Fatal("error creating a prepared captive core instance: ", err)
+			return err
 		}
 
+		defer core.Close()
 		orderbook, err := input.GetEntriesFromGenesis(checkpointSeq, xdr.LedgerEntryTypeOffer)
 		if err != nil {
-			cmdLogger.Fatal("could not read initial orderbook: ", err)
+			return fmt.Errorf("could not read initial orderbook: %v", err)
 		}
 
 		orderbookChannel := make(chan input.OrderbookBatch)
 
 		go input.StreamOrderbooks(core, startNum, endNum, batchSize, orderbookChannel, orderbook, cmdLogger)
 
+		exportBatch := func(batchStart, batchEnd uint32) error {
+			parser, err := input.ReceiveParsedOrderbooks(orderbookChannel, strictExport, cmdLogger)
+			if err != nil {
+				return err
+			}
+
+			if sinks != nil {
+				return deliverOrderbook(batchStart, batchEnd, sinks, parser)
+			}
+
+			return exportOrderbook(batchStart, batchEnd, folderPath, format, indexPattern, useStdout, strictExport, parser)
+		}
+
 		// If the end sequence number is defined, we work in a closed range and export a finite number of batches
 		if endNum != 0 {
 			batchCount := uint32(math.Ceil(float64(endNum-startNum+1) / float64(batchSize)))
@@ -77,8 +104,9 @@ var exportOrderbooksCmd = &cobra.Command{
 					batchEnd = endNum
 				}
 
-				parser := input.ReceiveParsedOrderbooks(orderbookChannel, strictExport, cmdLogger)
-				exportOrderbook(batchStart, batchEnd, folderPath, useStdout, strictExport, parser)
+				if err := exportBatch(batchStart, batchEnd); err != nil {
+					return err
+				}
 			}
 		} else {
 			// otherwise, we export in an unbounded manner where batches are constantly exported
@@ -86,48 +114,113 @@ var exportOrderbooksCmd = &cobra.Command{
 			for {
 				batchStart := startNum + batchNum*batchSize
 				batchEnd := batchStart + batchSize - 1
-				parser := input.ReceiveParsedOrderbooks(orderbookChannel, strictExport, cmdLogger)
-				exportOrderbook(batchStart, batchEnd, folderPath, useStdout, strictExport, parser)
+				if err := exportBatch(batchStart, batchEnd); err != nil {
+					return err
+				}
+
 				batchNum++
 			}
 		}
+
+		return nil
 	},
 }
 
-// writeSlice writes the slice either to a file or to stdout. When useStdout is false, the file is written to, and when useStdout is true, std out is written to.
-func writeSlice(file *os.File, useStdout bool, slice [][]byte) {
-	for _, v := range slice {
-		if !useStdout {
-			file.WriteString(string(v) + "\n")
-		} else {
-			fmt.Println(string(v))
+// writeSlice writes the rows in the output format, either to a new file at path or to stdout
+func writeSlice(format, indexPattern, path string, useStdout bool, dataset string, exampleRow interface{}, rows []interface{}) error {
+	var file *os.File
+	if !useStdout {
+		var err error
+		file, err = formatOutFile(format, path)
+		if err != nil {
+			return err
 		}
+
+		defer file.Close()
 	}
 
-	if file != nil {
-		file.Close()
+	writer, err := newWriter(format, indexPattern, outputOf(file, useStdout), dataset, exampleRow)
+	if err != nil {
+		return err
 	}
+
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			closeWriter(writer)
+			return fmt.Errorf("could not encode orderbook row: %v", err)
+		}
+	}
+
+	return closeWriter(writer)
 }
 
-func exportOrderbook(start, end uint32, folderPath string, useStdout, strictExport bool, parser *input.OrderbookParser) {
-	var marketsFile, offersFile, accountsFile, eventsFile *os.File
-	if !useStdout {
-		marketsFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-dimMarkets.txt", start, end)))
-		offersFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-dimOffers.txt", start, end)))
-		accountsFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-dimAccounts.txt", start, end)))
-		eventsFile = mustOutFile(filepath.Join(folderPath, fmt.Sprintf("%d-%d-factEvents.txt", start, end)))
+// orderbookDatasets are the normalized datasets of an orderbook, with the example rows of their types
+var orderbookDatasets = []struct {
+	name       string
+	exampleRow interface{}
+	rows       func(parser *input.OrderbookParser) []interface{}
+}{
+	{"dimMarkets", transform.DimMarket{}, func(parser *input.OrderbookParser) []interface{} {
+		rows := make([]interface{}, 0, len(parser.Markets))
+		for _, market := range parser.Markets {
+			rows = append(rows, market)
+		}
+
+		return rows
+	}},
+	{"dimOffers", transform.DimOffer{}, func(parser *input.OrderbookParser) []interface{} {
+		rows := make([]interface{}, 0, len(parser.Offers))
+		for _, offer := range parser.Offers {
+			rows = append(rows, offer)
+		}
+
+		return rows
+	}},
+	{"dimAccounts", transform.DimAccount{}, func(parser *input.OrderbookParser) []interface{} {
+		rows := make([]interface{}, 0, len(parser.Accounts))
+		for _, account := range parser.Accounts {
+			rows = append(rows, account)
+		}
+
+		return rows
+	}},
+	{"factEvents", transform.FactOfferEvent{}, func(parser *input.OrderbookParser) []interface{} {
+		rows := make([]interface{}, 0, len(parser.Events))
+		for _, event := range parser.Events {
+			rows = append(rows, event)
+		}
+
+		return rows
+	}},
+}
+
+// deliverOrderbook delivers the normalized orderbook of the batch [start, end] to the sinks, with one batch for each of the normalized datasets
+func deliverOrderbook(start, end uint32, sinks *sink.Fanout, parser *input.OrderbookParser) error {
+	for _, dataset := range orderbookDatasets {
+		if err := deliverBatch(sinks, dataset.name, dataset.exampleRow, dataset.rows(parser), start, end); err != nil {
+			return err
+		}
 	}
 
-	writeSlice(marketsFile, useStdout, parser.Markets)
-	writeSlice(offersFile, useStdout, parser.Offers)
-	writeSlice(accountsFile, useStdout, parser.Accounts)
-	writeSlice(eventsFile, useStdout, parser.Events)
+	return nil
+}
+
+func exportOrderbook(start, end uint32, folderPath, format, indexPattern string, useStdout, strictExport bool, parser *input.OrderbookParser) error {
+	for _, dataset := range orderbookDatasets {
+		path := filepath.Join(folderPath, fmt.Sprintf("%d-%d-%s%s", start, end, dataset.name, output.Extension(format)))
+		if err := writeSlice(format, indexPattern, path, useStdout, dataset.name, dataset.exampleRow, dataset.rows(parser)); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(exportOrderbooksCmd)
 	utils.AddCommonFlags(exportOrderbooksCmd.Flags())
 	utils.AddCoreFlags(exportOrderbooksCmd.Flags(), "orderbooks_output/")
+	utils.AddSinkFlags(exportOrderbooksCmd.Flags())
 
 	exportOrderbooksCmd.MarkFlagRequired("start-ledger")
 	/*
@@ -142,5 +235,13 @@ func init() {
 
 			core-executable: path to stellar-core executable
 			core-config: path to stellar-core config file
+			core-socket: path to the socket of a running core daemon, which is used instead of starting stellar-core
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is used instead of starting stellar-core
+
+			format: the format of the output files (json or avro)
+
+			sink: destination that every batch is delivered to instead of the output folder; can be repeated
+			optional-sink: like sink, but batches are complete even if the sink does not confirm them
+			sink-attempts: number of times each sink tries to deliver a batch
 	*/
 }
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/stellar-etl/internal/audit"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var exportStateStatsCmd = &cobra.Command{
+	Use:   "export_state_stats",
+	Short: "Exports the size of the ledger state at a series of checkpoints.",
+	Long: `Counts the live accounts, trustlines, offers, data entries, and claimable balances in the bucket list at each checkpoint of a
+	range, along with the number of buckets and their total size. Each checkpoint is written to the output file as one row.
+
+	The stats are computed from the keys of the entries in each bucket. Buckets that a checkpoint shares with the one before it are not
+	downloaded again, so a series of nearby checkpoints only reads the buckets that changed between them. Without start-ledger, only the
+	checkpoint that contains end-ledger is exported.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, _, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		path, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("could not get output filename: %v", err)
+		}
+
+		startNum, err := cmd.Flags().GetUint32("start-ledger")
+		if err != nil {
+			return fmt.Errorf("could not get start sequence number: %v", err)
+		}
+
+		step, err := cmd.Flags().GetUint32("checkpoint-step")
+		if err != nil {
+			return fmt.Errorf("could not get checkpoint step: %v", err)
+		}
+
+		if startNum == 0 {
+			startNum = endNum
+		}
+
+		checkpoints := input.CheckpointsInRange(startNum, endNum, step)
+		if len(checkpoints) == 0 {
+			return fmt.Errorf("there are no checkpoints between start-ledger %d and end-ledger %d", startNum, endNum)
+		}
+
+		reader, err := input.NewBucketListReader()
+		if err != nil {
+			return fmt.Errorf("could not connect to the history archive: %v", err)
+		}
+
+		writer, err := newOutputWriter(format, indexPattern, path, useStdout, "stateStats", audit.StateStats{})
+		if err != nil {
+			return err
+		}
+
+		for _, checkpoint := range checkpoints {
+			snapshot, err := reader.Read(checkpoint)
+			if err != nil {
+				return fmt.Errorf("could not read the bucket list at checkpoint %d: %v", checkpoint, err)
+			}
+
+			stats, err := audit.ComputeStateStats(snapshot)
+			if err != nil {
+				return fmt.Errorf("could not compute the state stats of checkpoint %d: %v", checkpoint, err)
+			}
+
+			err = writer.Write(stats)
+			if err != nil {
+				return fmt.Errorf("could not encode state stats: %v", err)
+			}
+		}
+
+		if err := closeWriter(writer); err != nil {
+			return err
+		}
+
+		summary, err := json.Marshal(map[string]interface{}{
+			"exported_checkpoints": len(checkpoints),
+			"buckets_downloaded":   reader.Downloads,
+			"buckets_reused":       reader.Reuses,
+		})
+		if err != nil {
+			return fmt.Errorf("could not marshal the summary: %v", err)
+		}
+
+		fmt.Println(string(summary))
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportStateStatsCmd)
+	utils.AddCommonFlags(exportStateStatsCmd.Flags())
+	exportStateStatsCmd.Flags().Uint32P("start-ledger", "s", 0, "If set, the stats are exported at every checkpoint from the one that contains this ledger to the one that contains end-ledger")
+	exportStateStatsCmd.Flags().Uint32("checkpoint-step", 1, "Number of checkpoints between the rows of a series")
+	exportStateStatsCmd.Flags().StringP("output", "o", "state_stats.txt", "Filename of the output file")
+	exportStateStatsCmd.MarkFlagRequired("end-ledger")
+	/*
+		Current flags:
+			end-ledger: the stats are exported at the checkpoint that contains this ledger (required)
+			start-ledger: if set, the stats are exported as a series from the checkpoint that contains this ledger
+			checkpoint-step: number of checkpoints between the rows of a series
+
+			output: filename of the output file that the stats are written to
+			stdout: if set, the stats are printed to stdout
+			format: the format of the stats (json or avro)
+	*/
+}
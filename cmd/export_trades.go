@@ -1,14 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/stellar/stellar-etl/internal/toid"
 
 	"github.com/spf13/cobra"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -18,62 +17,159 @@ var tradesCmd = &cobra.Command{
 	Use:   "export_trades",
 	Short: "Exports the trade data",
 	Long:  `Exports trade data within the specified range to an output file`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-		startNum, path, limit := utils.MustArchiveFlags(cmd.Flags(), cmdLogger)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, path, limit, verify, err := utils.GetArchiveFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, endNum, err = clampRange(cmd.Flags(), startNum, endNum)
+		if err != nil {
+			return err
+		}
+
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
+
+		// Rows are collected and committed to the table or delivered to the sinks in batches when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		program, err := rowProgram(cmd.Flags(), transform.TradesDataset, transform.TradeOutput{}, format)
+		if err != nil {
+			return err
+		}
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+		exampleRow := program.ExampleRow(transform.TradeOutput{})
+		table, err := openTable(tablePath, transform.TradesDataset, exampleRow)
+		if err != nil {
+			return err
 		}
 
-		trades, err := input.GetTrades(startNum, endNum, limit)
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
 		if err != nil {
-			cmdLogger.Fatal("could not read trades: ", err)
+			return err
 		}
 
-		failures := 0
-		for _, tradeInput := range trades {
-			trades, err := transform.TransformTrade(tradeInput.OperationIndex, tradeInput.OperationHistoryID, tradeInput.Transaction, tradeInput.CloseTime)
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, transform.TradesDataset, exampleRow)
 			if err != nil {
-				parsedID := toid.Parse(tradeInput.OperationHistoryID)
-				locationString := fmt.Sprintf("from ledger %d, transaction %d, operation %d", parsedID.LedgerSequence, parsedID.TransactionOrder, parsedID.OperationOrder)
-				if strictExport {
-					cmdLogger.Fatalf("could not transform trade (%s): %v", locationString, err)
-				} else {
-					cmdLogger.Warningf("could not transform trade (%s): %v", locationString, err)
-					failures++
-					continue
-				}
+				return err
+			}
+		}
+
+		backend, err := openLedgerBackend(cmd.Flags(), checkpointCache(cmd), startNum, endNum, limit)
+		if err != nil {
+			return err
+		}
+
+		defer backend.Close()
+		attempts := 0
+		err = exportRanges(startNum, endNum, func(batchStart, batchEnd uint32) error {
+			rows := []interface{}{}
+			trades, err := input.GetTrades(backend, batchStart, batchEnd, limit, verify)
+			if err != nil {
+				return fmt.Errorf("could not read trades: %v", err)
 			}
 
-			// We can get multiple trades from each transform, so we need to ensure they are all exported
-			for _, transformed := range trades {
-				marshalled, err := json.Marshal(transformed)
+			for _, tradeInput := range trades {
+				trades, err := transform.TransformTrade(tradeInput.OperationIndex, tradeInput.OperationHistoryID, tradeInput.Transaction, tradeInput.CloseTime)
 				if err != nil {
 					parsedID := toid.Parse(tradeInput.OperationHistoryID)
 					locationString := fmt.Sprintf("from ledger %d, transaction %d, operation %d", parsedID.LedgerSequence, parsedID.TransactionOrder, parsedID.OperationOrder)
-					if strictExport {
-						cmdLogger.Fatalf("could not JSON encode trade (%s): %v", locationString, err)
-					} else {
-						cmdLogger.Warningf("could not JSON encode trade (%s): %v", locationString, err)
-						failures++
+					if err := failures.handle(fmt.Sprintf("could not transform trade (%s): ", locationString), err); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				// We can get multiple trades from each transform, so we need to ensure they are all exported
+				for _, transformed := range trades {
+					row, keep, err := program.Apply(transformed)
+					if err != nil {
+						parsedID := toid.Parse(tradeInput.OperationHistoryID)
+						locationString := fmt.Sprintf("from ledger %d, transaction %d, operation %d", parsedID.LedgerSequence, parsedID.TransactionOrder, parsedID.OperationOrder)
+						if err := failures.handle(fmt.Sprintf("could not evaluate expressions for trade (%s): ", locationString), transform.NewInvalidDataError(transform.TradesDataset, err)); err != nil {
+							return err
+						}
+
 						continue
 					}
+
+					if !keep {
+						continue
+					}
+
+					if writer == nil {
+						rows = append(rows, row)
+						continue
+					}
+
+					err = writer.Write(row)
+					if err != nil {
+						parsedID := toid.Parse(tradeInput.OperationHistoryID)
+						locationString := fmt.Sprintf("from ledger %d, transaction %d, operation %d", parsedID.LedgerSequence, parsedID.TransactionOrder, parsedID.OperationOrder)
+						if err := failures.handle(fmt.Sprintf("could not encode trade (%s): ", locationString), transform.NewSerializationError(transform.TradesDataset, err)); err != nil {
+							return err
+						}
+
+						continue
+					}
+				}
+			}
+
+			if table != nil {
+				if err := commitTable(table, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if sinks != nil {
+				if err := deliverBatch(sinks, transform.TradesDataset, exampleRow, rows, batchStart, batchEnd); err != nil {
+					return err
 				}
+			}
 
-				if !useStdout {
-					outFile.Write(marshalled)
-					outFile.WriteString("\n")
-				} else {
-					fmt.Println(string(marshalled))
+			if writer != nil {
+				if err := flushWriter(writer); err != nil {
+					return err
 				}
 			}
+
+			attempts += len(trades)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
+			}
 		}
 
 		if !strictExport {
-			printTransformStats(len(trades), failures)
+			return printTransformStats(attempts, failures)
 		}
+
+		return nil
 	},
 }
 
@@ -1,12 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/output"
 	"github.com/stellar/stellar-etl/internal/transform"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -15,60 +14,154 @@ var transactionsCmd = &cobra.Command{
 	Use:   "export_transactions",
 	Short: "Exports the transaction data over a specified range.",
 	Long:  `Exports the transaction data over a specified range to an output file.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		endNum, useStdout, strictExport := utils.MustCommonFlags(cmd.Flags(), cmdLogger)
-		startNum, path, limit := utils.MustArchiveFlags(cmd.Flags(), cmdLogger)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		endNum, useStdout, strictExport, err := utils.GetCommonFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, path, limit, verify, err := utils.GetArchiveFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		startNum, endNum, err = clampRange(cmd.Flags(), startNum, endNum)
+		if err != nil {
+			return err
+		}
+
+		failures, err := newTransformFailures(cmd.Flags(), strictExport)
+		if err != nil {
+			return err
+		}
+
+		// Rows are collected and committed to the table or delivered to the sinks in batches when either is set
+		format, indexPattern, err := formatFlag(cmd.Flags())
+		if err != nil {
+			return err
+		}
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+		tablePath, err := utils.GetTableFlags(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		program, err := rowProgram(cmd.Flags(), transform.TransactionsDataset, transform.TransactionOutput{}, format)
+		if err != nil {
+			return err
+		}
+
+		exampleRow := program.ExampleRow(transform.TransactionOutput{})
+		table, err := openTable(tablePath, transform.TransactionsDataset, exampleRow)
+		if err != nil {
+			return err
+		}
+
+		sinks, err := openSinks(cmd.Flags(), format, indexPattern)
+		if err != nil {
+			return err
+		}
+
+		var writer output.Writer
+		if table == nil && sinks == nil {
+			writer, err = newOutputWriter(format, indexPattern, path, useStdout, transform.TransactionsDataset, exampleRow)
+			if err != nil {
+				return err
+			}
 		}
 
-		transactions, err := input.GetTransactions(startNum, endNum, limit)
+		backend, err := openLedgerBackend(cmd.Flags(), checkpointCache(cmd), startNum, endNum, limit)
 		if err != nil {
-			cmdLogger.Fatal("could not read transactions: ", err)
+			return err
 		}
 
-		failures := 0
-		for _, transformInput := range transactions {
-			transformed, err := transform.TransformTransaction(transformInput.Transaction, transformInput.LedgerHistory)
+		defer backend.Close()
+		attempts := 0
+		err = exportRanges(startNum, endNum, func(batchStart, batchEnd uint32) error {
+			rows := []interface{}{}
+			transactions, err := input.GetTransactions(backend, batchStart, batchEnd, limit, verify)
 			if err != nil {
-				ledgerSeq := transformInput.LedgerHistory.Header.LedgerSeq
-				errMsg := fmt.Sprintf("could not transform transaction %d in ledger %d: ", transformInput.Transaction.Index, ledgerSeq)
-				if strictExport {
-					cmdLogger.Fatal(errMsg, err)
-				} else {
-					cmdLogger.Warning(errMsg, err)
-					failures++
+				return fmt.Errorf("could not read transactions: %v", err)
+			}
+
+			for _, transformInput := range transactions {
+				transformed, err := transform.TransformTransaction(transformInput.Transaction, transformInput.LedgerHistory)
+				if err != nil {
+					ledgerSeq := transformInput.LedgerHistory.Header.LedgerSeq
+					errMsg := fmt.Sprintf("could not transform transaction %d in ledger %d: ", transformInput.Transaction.Index, ledgerSeq)
+					if err := failures.handle(errMsg, err); err != nil {
+						return err
+					}
+
 					continue
 				}
 
-			}
+				row, keep, err := program.Apply(transformed)
+				if err != nil {
+					if err := failures.handle(fmt.Sprintf("could not evaluate expressions for transaction %d in ledger %d: ", transformInput.Transaction.Index, transformInput.LedgerHistory.Header.LedgerSeq), transform.NewInvalidDataError(transform.TransactionsDataset, err)); err != nil {
+						return err
+					}
+
+					continue
+				}
+
+				if !keep {
+					continue
+				}
+
+				if writer == nil {
+					rows = append(rows, row)
+					continue
+				}
+
+				err = writer.Write(row)
+				if err != nil {
+					ledgerSeq := transformInput.LedgerHistory.Header.LedgerSeq
+					errMsg := fmt.Sprintf("could not encode transaction %d in ledger %d: ", transformInput.Transaction.Index, ledgerSeq)
+					if err := failures.handle(errMsg, transform.NewSerializationError(transform.TransactionsDataset, err)); err != nil {
+						return err
+					}
 
-			marshalled, err := json.Marshal(transformed)
-			if err != nil {
-				ledgerSeq := transformInput.LedgerHistory.Header.LedgerSeq
-				errMsg := fmt.Sprintf("could not json encode transaction %d in ledger %d: ", transformInput.Transaction.Index, ledgerSeq)
-				if strictExport {
-					cmdLogger.Fatal(errMsg, err)
-				} else {
-					cmdLogger.Warning(errMsg, err)
-					failures++
 					continue
 				}
 			}
 
-			if !useStdout {
-				outFile.Write(marshalled)
-				outFile.WriteString("\n")
-			} else {
-				fmt.Println(string(marshalled))
+			if table != nil {
+				if err := commitTable(table, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if sinks != nil {
+				if err := deliverBatch(sinks, transform.TransactionsDataset, exampleRow, rows, batchStart, batchEnd); err != nil {
+					return err
+				}
+			}
+
+			if writer != nil {
+				if err := flushWriter(writer); err != nil {
+					return err
+				}
+			}
+
+			attempts += len(transactions)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if writer != nil {
+			if err := closeWriter(writer); err != nil {
+				return err
 			}
 		}
 
 		if !strictExport {
-			printTransformStats(len(transactions), failures)
+			return printTransformStats(attempts, failures)
 		}
+
+		return nil
 	},
 }
 
@@ -82,6 +175,9 @@ func init() {
 		Current flags:
 			start-ledger: the ledger sequence number for the beginning of the export period
 			end-ledger: the ledger sequence number for the end of the export range (*required)
+			clamp-range: narrow the range to the ledgers that the history archive holds
+			read-ahead: number of checkpoints that are downloaded from the history archive ahead of the one that is being exported
+			meta-stream: file, named pipe, or fd:N that a running stellar-core writes its metadata stream to, which is read instead of the history archive
 
 			limit: maximum number of transactions to export
 				TODO: measure a good default value that ensures all transactions within a 5 minute period will be exported with a single call
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/stellar/stellar-etl/internal/expr"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+/*
+	rowProgram compiles the derived columns and row filter that the expressions flag configures for the dataset, and returns an error if they
+	do not type check against exampleRow. Derived columns are added to the schemas of the other formats and of tables through the example row
+	of the program, but the horizon and debezium formats have fixed resources, so rows with derived columns cannot be written in them. If
+	there are no expressions for the dataset, nil is returned, which keeps every row as it is.
+*/
+func rowProgram(flags *pflag.FlagSet, dataset string, exampleRow interface{}, format string) (*expr.Program, error) {
+	path, err := utils.GetExpressionsFlag(flags)
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	config, err := expr.LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load expressions: %v", err)
+	}
+
+	program, err := config.Compile(dataset, exampleRow)
+	if err != nil {
+		return nil, fmt.Errorf("could not compile expressions: %v", err)
+	}
+
+	if program.HasColumns() && (format == output.HorizonFormat || format == output.DebeziumFormat) {
+		return nil, fmt.Errorf("the derived columns of %s cannot be written in the %s format", dataset, format)
+	}
+
+	return program, nil
+}
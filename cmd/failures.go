@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/stellar/stellar-etl/internal/transform"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// transformFailures counts the failed transformations of an export by error category, and decides which failures stop the export
+type transformFailures struct {
+	strictExport bool
+	fatal        map[transform.ErrorCategory]bool
+	byCategory   map[transform.ErrorCategory]int
+	total        int
+}
+
+// newTransformFailures creates a transformFailures from the strict-export and fatal-errors flags
+func newTransformFailures(flags *pflag.FlagSet, strictExport bool) (*transformFailures, error) {
+	names, err := utils.GetFatalErrorsFlag(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := transform.ParseErrorCategories(names)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse fatal error categories: %v", err)
+	}
+
+	failures := &transformFailures{
+		strictExport: strictExport,
+		fatal:        make(map[transform.ErrorCategory]bool),
+		byCategory:   make(map[transform.ErrorCategory]int),
+	}
+
+	for _, category := range categories {
+		failures.fatal[category] = true
+	}
+
+	return failures, nil
+}
+
+// handle reports a failed transformation. If strict-export is set, or the category of the error is listed in fatal-errors, an error is returned
+// that stops the export. Otherwise, the error is logged as a warning and counted.
+func (f *transformFailures) handle(errMsg string, err error) error {
+	category := transform.CategoryOf(err)
+	if f.strictExport || f.fatal[category] {
+		return fmt.Errorf("%s%v", errMsg, err)
+	}
+
+	cmdLogger.Warning(errMsg, err)
+	f.byCategory[category]++
+	f.total++
+	return nil
+}
+
+// Prints the number of attempted, failed, and successful transformations as a JSON object. If there were failures, they are also broken down by category
+func printTransformStats(attempts int, failures *transformFailures) error {
+	resultsMap := map[string]interface{}{
+		"attempted_transforms":  attempts,
+		"failed_transforms":     failures.total,
+		"successful_transforms": attempts - failures.total,
+	}
+
+	if failures.total > 0 {
+		resultsMap["failed_transforms_by_category"] = failures.byCategory
+	}
+
+	results, err := json.Marshal(resultsMap)
+	if err != nil {
+		return fmt.Errorf("Could not marshall results: %v", err)
+	}
+
+	fmt.Println(string(results))
+	return nil
+}
@@ -12,64 +12,115 @@ import (
 )
 
 type ledgerRange struct {
-	Start int64 `json:"start"`
-	End   int64 `json:"end"`
+	Start     int64     `json:"start"`
+	End       int64     `json:"end"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
 }
 
 var getLedgerRangeFromTimesCmd = &cobra.Command{
 	Use:   "get_ledger_range_from_times",
 	Short: "Converts a time range into a ledger range",
-	Long: `Converts a time range into a ledger range. Times must be in the format YYYY-MM-DDTHH:MM:SS.SSSZ.
+	Long: `Converts a time range into a ledger range. Times can be in an RFC3339 format, a date, or a unix timestamp.
 
-	Some examples include: 2006-01-02T15:04:05-07:00, 2009-11-10T18:00:00-05:00, or 2019-09-13T23:00:00+00:00.
-	If the time range goes into the future, the ledger range will end on the most recent ledger. If the time
-	range covers time before the network started, the ledger range will start with the genesis ledger.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Some examples include: 2006-01-02T15:04:05-07:00, 2019-09-13T23:00:00Z, 2019-09-13T23:00:00.250Z, 2019-09-13, or 1568415600.
+	Times without an offset are treated as UTC. If the time range goes into the future, the ledger range will end on the most
+	recent ledger. If the time range covers time before the network started, the ledger range will start with the genesis ledger.
+
+	By default, the range starts with the ledger that closed on or directly after the start time, and ends with the ledger that
+	closed on or directly after the end time. The exclusive-start flag makes the range start with the first ledger that closed
+	after the start time, and the exclusive-end flag makes the range end with the last ledger that closed before the end time.
+
+	If start-ledger is provided instead of the times, the command does the reverse and looks up the close times of start-ledger
+	and end-ledger. The output always contains the close times of the first and last ledgers in the range.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		startString, err := cmd.Flags().GetString("start-time")
 		if err != nil {
-			cmdLogger.Fatal("could not get start time: ", err)
+			return fmt.Errorf("could not get start time: %v", err)
 		}
 
 		endString, err := cmd.Flags().GetString("end-time")
 		if err != nil {
-			cmdLogger.Fatal("could not get end time: ", err)
+			return fmt.Errorf("could not get end time: %v", err)
 		}
 
-		path, err := cmd.Flags().GetString("output")
+		exclusiveStart, err := cmd.Flags().GetBool("exclusive-start")
 		if err != nil {
-			cmdLogger.Fatal("could not get output path: ", err)
+			return fmt.Errorf("could not get exclusive start boolean: %v", err)
 		}
 
-		useStdout, err := cmd.Flags().GetBool("stdout")
+		exclusiveEnd, err := cmd.Flags().GetBool("exclusive-end")
 		if err != nil {
-			cmdLogger.Fatal("could not get stdout boolean: ", err)
+			return fmt.Errorf("could not get exclusive end boolean: %v", err)
 		}
 
-		var outFile *os.File
-		if !useStdout {
-			outFile = mustOutFile(path)
+		startLedger, err := cmd.Flags().GetInt64("start-ledger")
+		if err != nil {
+			return fmt.Errorf("could not get start ledger: %v", err)
 		}
 
-		formatString := "2006-01-02T15:04:05-07:00"
-		startTime, err := time.Parse(formatString, startString)
+		endLedger, err := cmd.Flags().GetInt64("end-ledger")
 		if err != nil {
-			cmdLogger.Fatal("could not parse start time: ", err)
+			return fmt.Errorf("could not get end ledger: %v", err)
 		}
 
-		endTime, err := time.Parse(formatString, endString)
+		path, err := cmd.Flags().GetString("output")
 		if err != nil {
-			cmdLogger.Fatal("could not parse end time: ", err)
+			return fmt.Errorf("could not get output path: %v", err)
 		}
 
-		startLedger, endLedger, err := input.GetLedgerRange(startTime, endTime)
+		useStdout, err := cmd.Flags().GetBool("stdout")
 		if err != nil {
-			cmdLogger.Fatal("could not calculate ledger range: ", err)
+			return fmt.Errorf("could not get stdout boolean: %v", err)
 		}
 
-		toExport := ledgerRange{Start: startLedger, End: endLedger}
+		var outFile *os.File
+		if !useStdout {
+			outFile, err = openOutFile(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		var found input.LedgerRange
+		if startLedger != 0 {
+			if startString != "" || endString != "" {
+				return fmt.Errorf("the start and end times cannot be combined with the start and end ledgers")
+			}
+
+			if endLedger == 0 {
+				endLedger = startLedger
+			}
+
+			found, err = input.GetLedgerCloseTimes(startLedger, endLedger)
+			if err != nil {
+				return fmt.Errorf("could not get ledger close times: %v", err)
+			}
+		} else {
+			if startString == "" || endString == "" {
+				return fmt.Errorf("either the start and end times or the start ledger have to be provided")
+			}
+
+			startTime, err := input.ParseTime(startString)
+			if err != nil {
+				return fmt.Errorf("could not parse start time: %v", err)
+			}
+
+			endTime, err := input.ParseTime(endString)
+			if err != nil {
+				return fmt.Errorf("could not parse end time: %v", err)
+			}
+
+			found, err = input.GetLedgerRange(startTime, endTime, exclusiveStart, exclusiveEnd)
+			if err != nil {
+				return fmt.Errorf("could not calculate ledger range: %v", err)
+			}
+		}
+
+		toExport := ledgerRange{Start: found.Start, End: found.End, StartTime: found.StartCloseTime, EndTime: found.EndCloseTime}
 		marshalled, err := json.Marshal(toExport)
 		if err != nil {
-			cmdLogger.Fatal("could not json encode ledger range", err)
+			return fmt.Errorf("could not json encode ledger range: %v", err)
 		}
 
 		if !useStdout {
@@ -78,6 +129,8 @@ var getLedgerRangeFromTimesCmd = &cobra.Command{
 		} else {
 			fmt.Println(string(marshalled))
 		}
+
+		return nil
 	},
 }
 
@@ -86,9 +139,24 @@ func init() {
 
 	getLedgerRangeFromTimesCmd.Flags().StringP("start-time", "s", "", "The start time")
 	getLedgerRangeFromTimesCmd.Flags().StringP("end-time", "e", "", "The end time")
+	getLedgerRangeFromTimesCmd.Flags().Bool("exclusive-start", false, "If set, the range starts with the first ledger that closed after the start time")
+	getLedgerRangeFromTimesCmd.Flags().Bool("exclusive-end", false, "If set, the range ends with the last ledger that closed before the end time")
+	getLedgerRangeFromTimesCmd.Flags().Int64("start-ledger", 0, "If set, the close times of the start and end ledgers are looked up instead of a ledger range")
+	getLedgerRangeFromTimesCmd.Flags().Int64("end-ledger", 0, "The end ledger when looking up close times; defaults to the start ledger")
 	getLedgerRangeFromTimesCmd.Flags().StringP("output", "o", "exported_range.txt", "Filename of the output file")
 	getLedgerRangeFromTimesCmd.Flags().Bool("stdout", false, "If set, the output will be printed to stdout instead of to a file")
 
-	getLedgerRangeFromTimesCmd.MarkFlagRequired("start-time")
-	getLedgerRangeFromTimesCmd.MarkFlagRequired("end-time")
+	/*
+		Current flags:
+			start-time: the start of the time range
+			end-time: the end of the time range
+			exclusive-start: whether the range starts with the first ledger closed after start-time
+			exclusive-end: whether the range ends with the last ledger closed before end-time
+
+			start-ledger: the first ledger to look up the close time of (reverse mode)
+			end-ledger: the last ledger to look up the close time of (reverse mode)
+
+			output: the filename of the output file
+			stdout: if set, the output is printed to stdout
+	*/
 }
@@ -13,6 +13,18 @@ func TestConvertTimes(t *testing.T) {
 			golden:  "",
 			wantErr: fmt.Errorf("could not parse start time: parsing time \\"),
 		},
+		{
+			name:    "missing times",
+			args:    []string{"get_ledger_range_from_times", "-s", "2016-11-10T18:00:00Z", "--stdout"},
+			golden:  "",
+			wantErr: fmt.Errorf("either the start and end times or the start ledger have to be provided"),
+		},
+		{
+			name:    "times and ledgers",
+			args:    []string{"get_ledger_range_from_times", "-s", "2016-11-10T18:00:00Z", "--start-ledger", "100", "--stdout"},
+			golden:  "",
+			wantErr: fmt.Errorf("the start and end times cannot be combined with the start and end ledgers"),
+		},
 		{
 			name:    "normal range",
 			args:    []string{"get_ledger_range_from_times", "-s", "2016-11-10T18:00:00-05:00", "-e", "2019-09-13T23:00:00+00:00", "--stdout"},
@@ -73,6 +85,36 @@ func TestConvertTimes(t *testing.T) {
 			golden:  "early_checkpoint_range.golden",
 			wantErr: nil,
 		},
+		{
+			name:    "close times of the range (30822015-30822018)",
+			args:    []string{"get_ledger_range_from_times", "-s", "2020-07-28T00:10:40Z", "-e", "2020-07-28T00:10:56Z", "--stdout"},
+			golden:  "close_time_range.golden",
+			wantErr: nil,
+		},
+		{
+			name:    "exclusive start (30822016-30822018)",
+			args:    []string{"get_ledger_range_from_times", "-s", "2020-07-28T00:10:40Z", "-e", "2020-07-28T00:10:56Z", "--exclusive-start", "--stdout"},
+			golden:  "exclusive_start.golden",
+			wantErr: nil,
+		},
+		{
+			name:    "exclusive end (30822015-30822017)",
+			args:    []string{"get_ledger_range_from_times", "-s", "2020-07-28T00:10:40Z", "-e", "2020-07-28T00:10:56Z", "--exclusive-end", "--stdout"},
+			golden:  "exclusive_end.golden",
+			wantErr: nil,
+		},
+		{
+			name:    "ledger close times",
+			args:    []string{"get_ledger_range_from_times", "--start-ledger", "30822015", "--end-ledger", "30822019", "--stdout"},
+			golden:  "ledger_close_times.golden",
+			wantErr: nil,
+		},
+		{
+			name:    "close time of a single ledger",
+			args:    []string{"get_ledger_range_from_times", "--start-ledger", "30822016", "--stdout"},
+			golden:  "single_ledger_close_time.golden",
+			wantErr: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+func createOutputFile(filepath string) error {
+	var _, err = os.Stat(filepath)
+	if os.IsNotExist(err) {
+		var file, err = os.Create(filepath)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+	}
+
+	return nil
+}
+
+func openOutFile(path string) (*os.File, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute filepath: %v", err)
+	}
+
+	err = createOutputFile(absolutePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not create output file: %v", err)
+	}
+
+	outFile, err := os.OpenFile(absolutePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error in opening output file: %v", err)
+	}
+
+	return outFile, nil
+}
+
+// newOutFile creates the file at path, truncating it if it already exists
+func newOutFile(path string) (*os.File, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not get absolute filepath: %v", err)
+	}
+
+	outFile, err := os.Create(absolutePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not create output file: %v", err)
+	}
+
+	return outFile, nil
+}
+
+// formatOutFile opens the output file at path. Files in formats that support appending are appended to, and other files are replaced
+func formatOutFile(format, path string) (*os.File, error) {
+	if output.SupportsAppend(format) {
+		return openOutFile(path)
+	}
+
+	return newOutFile(path)
+}
+
+// newOutputWriter creates a writer that encodes the rows of the dataset in the output format. The rows are written to the file at path,
+// or to stdout if useStdout is set
+func newOutputWriter(format, indexPattern, path string, useStdout bool, dataset string, exampleRow interface{}) (output.Writer, error) {
+	var file *os.File
+	if !useStdout {
+		var err error
+		file, err = formatOutFile(format, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newWriter(format, indexPattern, outputOf(file, useStdout), dataset, exampleRow)
+}
+
+// openWriters holds the output writers that have not been closed yet, so that the rows they buffer can be written out before a fatal error ends the process
+var openWriters = struct {
+	sync.Mutex
+	writers map[output.Writer]bool
+}{writers: map[output.Writer]bool{}}
+
+func init() {
+	// Fatal errors run the exit handlers before the process exits
+	logrus.RegisterExitHandler(flushOpenWriters)
+}
+
+// newWriter creates a writer for the format that writes to out, and keeps track of it until it is closed
+func newWriter(format, indexPattern string, out io.Writer, dataset string, exampleRow interface{}) (output.Writer, error) {
+	writer, err := output.NewWriter(format, indexPattern, out, dataset, exampleRow)
+	if err != nil {
+		return nil, fmt.Errorf("could not create output writer: %v", err)
+	}
+
+	openWriters.Lock()
+	openWriters.writers[writer] = true
+	openWriters.Unlock()
+	return writer, nil
+}
+
+// flushOpenWriters writes out the rows that the writers that are still open have buffered. Errors are ignored, since the process is already exiting
+func flushOpenWriters() {
+	openWriters.Lock()
+	defer openWriters.Unlock()
+	for writer := range openWriters.writers {
+		writer.Flush()
+	}
+}
+
+// outputOf returns the destination of exported rows, which is stdout if useStdout is set and file otherwise
+func outputOf(file *os.File, useStdout bool) io.Writer {
+	if useStdout {
+		return os.Stdout
+	}
+
+	return file
+}
+
+// closeWriter writes out the rows that the writer has buffered
+func closeWriter(writer output.Writer) error {
+	openWriters.Lock()
+	delete(openWriters.writers, writer)
+	openWriters.Unlock()
+
+	err := writer.Close()
+	if err != nil {
+		return fmt.Errorf("could not write buffered output: %v", err)
+	}
+
+	return nil
+}
+
+// flushWriter writes out the rows that the writer has buffered, so that the exported batch can be read from the output
+func flushWriter(writer output.Writer) error {
+	err := writer.Flush()
+	if err != nil {
+		return fmt.Errorf("could not write buffered output: %v", err)
+	}
+
+	return nil
+}
+
+// formatFlag gets the values of the format and index-pattern flags of a command that exports rows. The debezium format is rejected, since
+// only the changes of export_ledger_entry_changes can be written in it
+func formatFlag(flags *pflag.FlagSet) (string, string, error) {
+	format, err := utils.GetFormatFlag(flags)
+	if err != nil {
+		return "", "", err
+	}
+
+	if format == output.DebeziumFormat {
+		return "", "", fmt.Errorf("only the changes of export_ledger_entry_changes can be written in the %s format", output.DebeziumFormat)
+	}
+
+	indexPattern, err := indexPatternFlag(flags)
+	if err != nil {
+		return "", "", err
+	}
+
+	return format, indexPattern, nil
+}
+
+// indexPatternFlag gets the pattern that rows in the es-bulk format name their index with from the index-pattern flag
+func indexPatternFlag(flags *pflag.FlagSet) (string, error) {
+	pattern, err := utils.GetIndexPatternFlag(flags)
+	if err != nil {
+		return "", err
+	}
+
+	err = output.CheckIndexPattern(pattern)
+	if err != nil {
+		return "", fmt.Errorf("could not set the index pattern: %v", err)
+	}
+
+	return pattern, nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/transform"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// exampleRows maps every exported dataset to an example of its rows, from which the schemas of the dataset are derived
+var exampleRows = map[string]interface{}{
+	transform.LedgersDataset:           transform.LedgerOutput{},
+	transform.TransactionsDataset:      transform.TransactionOutput{},
+	transform.OperationsDataset:        transform.OperationOutput{},
+	transform.TradesDataset:            transform.TradeOutput{},
+	transform.DepositsDataset:          transform.DepositOutput{},
+	transform.AccountsDataset:          transform.AccountOutput{},
+	transform.AccountCompositesDataset: transform.AccountCompositeOutput{},
+	transform.OffersDataset:            transform.OfferOutput{},
+	transform.TrustlinesDataset:        transform.TrustlineOutput{},
+	"dimMarkets":                       transform.DimMarket{},
+	"dimOffers":                        transform.DimOffer{},
+	"dimAccounts":                      transform.DimAccount{},
+	"factEvents":                       transform.FactOfferEvent{},
+}
+
+// datasetNames returns the names of the datasets in exampleRows in alphabetical order
+func datasetNames() []string {
+	names := []string{}
+	for name := range exampleRows {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// schemaExampleRow returns the example row of the dataset, with the derived columns that the expressions flag configures for it in the format
+func schemaExampleRow(flags *pflag.FlagSet, dataset, format string) (interface{}, error) {
+	exampleRow, ok := exampleRows[dataset]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %s; the datasets are %s", dataset, strings.Join(datasetNames(), ", "))
+	}
+
+	program, err := rowProgram(flags, dataset, exampleRow, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return program.ExampleRow(exampleRow), nil
+}
+
+var printAvroSchemaCmd = &cobra.Command{
+	Use:   "print_avro_schema",
+	Short: "Prints the Avro schema of a dataset",
+	Long: `Prints the Avro schema of the rows of a dataset, as it is embedded in the files that are exported with --format avro.
+	The schema can be registered in a schema registry before any data is exported.
+
+	The datasets are ledgers, transactions, operations, trades, accounts, offers, and trustlines, as well as the normalized
+	orderbook datasets dimMarkets, dimOffers, dimAccounts, and factEvents. If the expressions flag is set, the derived columns of the
+	dataset are added to the schema.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataset, err := cmd.Flags().GetString("dataset")
+		if err != nil {
+			return fmt.Errorf("could not get dataset: %v", err)
+		}
+
+		exampleRow, err := schemaExampleRow(cmd.Flags(), dataset, output.AvroFormat)
+		if err != nil {
+			return err
+		}
+
+		schema, err := output.AvroSchema(dataset, exampleRow)
+		if err != nil {
+			return fmt.Errorf("could not derive Avro schema: %v", err)
+		}
+
+		var indented bytes.Buffer
+		err = json.Indent(&indented, []byte(schema), "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not format Avro schema: %v", err)
+		}
+
+		fmt.Println(indented.String())
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(printAvroSchemaCmd)
+	printAvroSchemaCmd.Flags().StringP("dataset", "d", "", "The dataset to print the schema of")
+	printAvroSchemaCmd.MarkFlagRequired("dataset")
+	utils.AddExpressionsFlag(printAvroSchemaCmd.Flags())
+	/*
+		Current flags:
+			dataset: the dataset to print the Avro schema of
+			expressions: filepath of a JSON file with the derived columns of the dataset
+	*/
+}
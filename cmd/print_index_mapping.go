@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/stellar-etl/internal/output"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var printIndexMappingCmd = &cobra.Command{
+	Use:   "print_index_mapping",
+	Short: "Prints the index mapping of a dataset",
+	Long: `Prints the Elasticsearch and OpenSearch index mapping of the rows of a dataset, as they are written with --format es-bulk.
+	The mapping can be used to create the index before any data is indexed, so that the field types do not depend on the first
+	documents that are indexed. Strings are mapped as keywords, timestamps as dates, and integers as longs.
+
+	The datasets are the same as those of print_avro_schema. If the expressions flag is set, the derived columns of the dataset
+	are added to the mapping.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataset, err := cmd.Flags().GetString("dataset")
+		if err != nil {
+			return fmt.Errorf("could not get dataset: %v", err)
+		}
+
+		exampleRow, err := schemaExampleRow(cmd.Flags(), dataset, output.ESBulkFormat)
+		if err != nil {
+			return err
+		}
+
+		mapping, err := output.IndexMapping(dataset, exampleRow)
+		if err != nil {
+			return fmt.Errorf("could not derive index mapping: %v", err)
+		}
+
+		var indented bytes.Buffer
+		err = json.Indent(&indented, []byte(mapping), "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not format index mapping: %v", err)
+		}
+
+		fmt.Println(indented.String())
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(printIndexMappingCmd)
+	printIndexMappingCmd.Flags().StringP("dataset", "d", "", "The dataset to print the index mapping of")
+	printIndexMappingCmd.MarkFlagRequired("dataset")
+	utils.AddExpressionsFlag(printIndexMappingCmd.Flags())
+	/*
+		Current flags:
+			dataset: the dataset to print the index mapping of
+			expressions: filepath of a JSON file with the derived columns of the dataset
+	*/
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/network"
+	"github.com/stellar/stellar-etl/internal/audit"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+var proveTransactionCmd = &cobra.Command{
+	Use:   "prove_transaction",
+	Short: "Builds a proof that a transaction was included in a ledger.",
+	Long: `Reads the ledger that contains the transaction and the ledgers after it up to the trusted ledger from the history archives,
+	and writes a self-contained proof that the transaction was included in the ledger. The proof holds the transaction set of the ledger,
+	the hash of the set, and every header from the ledger up to the trusted ledger, each of which holds the hash of the one before it.
+
+	The trusted ledger defaults to the checkpoint that contains the ledger. The proof can be checked offline with verify_transaction_proof,
+	which needs the hash of the trusted ledger from a trusted source for the proof to hold.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		transactionHash, err := cmd.Flags().GetString("transaction-hash")
+		if err != nil {
+			return fmt.Errorf("could not get transaction hash: %v", err)
+		}
+
+		ledgerNum, err := cmd.Flags().GetUint32("ledger")
+		if err != nil {
+			return fmt.Errorf("could not get ledger sequence number: %v", err)
+		}
+
+		trustedNum, err := cmd.Flags().GetUint32("trusted-ledger")
+		if err != nil {
+			return fmt.Errorf("could not get trusted ledger sequence number: %v", err)
+		}
+
+		path, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return fmt.Errorf("could not get output filename: %v", err)
+		}
+
+		useStdout, err := cmd.Flags().GetBool("stdout")
+		if err != nil {
+			return fmt.Errorf("could not get stdout boolean: %v", err)
+		}
+
+		if trustedNum == 0 {
+			trustedNum, err = utils.GetCheckpointNum(ledgerNum, math.MaxUint32)
+			if err != nil {
+				return fmt.Errorf("could not get the checkpoint of the ledger: %v", err)
+			}
+		}
+
+		if trustedNum < ledgerNum {
+			return fmt.Errorf("the trusted ledger %d is before the ledger %d", trustedNum, ledgerNum)
+		}
+
+		backend, err := input.PrepareArchiveBackend(ledgerNum, trustedNum, utils.DefaultReadAhead, checkpointCache(cmd))
+		if err != nil {
+			return fmt.Errorf("could not create archive backend: %v", err)
+		}
+		defer backend.Close()
+
+		ledgers, err := input.GetLedgers(backend, ledgerNum, trustedNum, -1, true)
+		if err != nil {
+			return fmt.Errorf("could not read ledgers: %v", err)
+		}
+
+		proof, err := audit.ProveTransaction(transactionHash, ledgers, network.PublicNetworkPassphrase)
+		if err != nil {
+			return fmt.Errorf("could not build the proof: %v", err)
+		}
+
+		marshalled, err := json.MarshalIndent(proof, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal the proof: %v", err)
+		}
+
+		if useStdout {
+			fmt.Println(string(marshalled))
+			return nil
+		}
+
+		err = ioutil.WriteFile(path, append(marshalled, '\n'), 0644)
+		if err != nil {
+			return fmt.Errorf("could not write the proof: %v", err)
+		}
+
+		cmdLogger.Infof("wrote the proof of transaction %s in ledger %d up to trusted ledger %d with hash %s to %s",
+			transactionHash, proof.LedgerSequence, proof.TrustedLedger, proof.TrustedHash, path)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proveTransactionCmd)
+	proveTransactionCmd.Flags().String("transaction-hash", "", "The hex-encoded hash of the transaction")
+	proveTransactionCmd.Flags().Uint32P("ledger", "l", 0, "The ledger sequence number that the transaction was included in")
+	proveTransactionCmd.Flags().Uint32("trusted-ledger", 0, "The ledger sequence number that the proof chains to; defaults to the checkpoint that contains the ledger")
+	proveTransactionCmd.Flags().StringP("output", "o", "transaction_proof.json", "Filename of the output file")
+	proveTransactionCmd.Flags().Bool("stdout", false, "If set, the proof is printed to stdout")
+	proveTransactionCmd.MarkFlagRequired("transaction-hash")
+	proveTransactionCmd.MarkFlagRequired("ledger")
+	/*
+		Current flags:
+			transaction-hash: the hex-encoded hash of the transaction (required)
+			ledger: the ledger sequence number that the transaction was included in (required)
+			trusted-ledger: the ledger that the proof chains to; defaults to the checkpoint that contains the ledger
+			output: filename of the output file that the proof is written to
+			stdout: if set, the proof is printed to stdout
+	*/
+}
@@ -22,14 +22,19 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+	SilenceErrors: true,
+	// The usage is printed when the arguments cannot be parsed, but not when a command fails once it has started
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Commands return their errors, which are logged as fatal errors so that the buffered rows of open writers are written out before the process exits.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		cmdLogger.Fatal(err)
 	}
 }
 
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/pipeline"
+)
+
+var runPipelineCmd = &cobra.Command{
+	Use:   "run_pipeline [pipeline file]",
+	Short: "Runs the steps of a pipeline file in a single process.",
+	Long: `Runs the steps that are declared in a YAML pipeline file, like resolving a ledger range, exporting datasets, and running checks.
+	Each step runs a stellar-etl command in this process after the steps that it depends on have succeeded. The steps share a cache of the
+	history archive checkpoints that they download, so steps that export the same range download it once. Failed steps are retried, and the
+	steps that depend on a step that keeps failing are skipped.
+
+	When every step has finished, a report of the run is written to the report file and a summary is printed. The command fails if any
+	step did not succeed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reportPath, err := cmd.Flags().GetString("report")
+		if err != nil {
+			return fmt.Errorf("could not get report filename: %v", err)
+		}
+
+		p, err := pipeline.Load(args[0])
+		if err != nil {
+			return fmt.Errorf("could not load the pipeline: %v", err)
+		}
+
+		if reportPath == "" {
+			reportPath = p.Report
+		}
+
+		if reportPath == "" {
+			reportPath = "pipeline_report.json"
+		}
+
+		cachedCheckpoints, err := cmd.Flags().GetInt("cached-checkpoints")
+		if err != nil {
+			return fmt.Errorf("could not get cached checkpoints: %v", err)
+		}
+
+		report, err := p.Run(newCommandRunner(cachedCheckpoints))
+		if err != nil {
+			return fmt.Errorf("invalid pipeline: %v", err)
+		}
+
+		err = report.Write(reportPath)
+		if err != nil {
+			return fmt.Errorf("could not write the report: %v", err)
+		}
+
+		stepsByStatus := map[string]int{}
+		for _, step := range report.Steps {
+			stepsByStatus[step.Status]++
+		}
+
+		summary, err := json.Marshal(map[string]interface{}{
+			"pipeline":        report.Pipeline,
+			"status":          report.Status,
+			"steps_by_status": stepsByStatus,
+			"report":          reportPath,
+		})
+		if err != nil {
+			return fmt.Errorf("could not marshal the summary: %v", err)
+		}
+
+		fmt.Println(string(summary))
+		if !report.Succeeded() {
+			return fmt.Errorf("the pipeline did not succeed; the errors of the steps are in %s", reportPath)
+		}
+
+		return nil
+	},
+}
+
+// commandRunner runs the steps of a pipeline as commands of this process. The context of the steps holds what they share
+type commandRunner struct {
+	ctx context.Context
+}
+
+// checkpointCacheKey is the key of the checkpoint cache in the context of the steps
+type checkpointCacheKey struct{}
+
+func newCommandRunner(cachedCheckpoints int) commandRunner {
+	return commandRunner{ctx: context.WithValue(context.Background(), checkpointCacheKey{}, input.NewCheckpointCache(cachedCheckpoints))}
+}
+
+// checkpointCache returns the checkpoint cache that the steps of a pipeline share, or nil if the command does not run as a step
+func checkpointCache(cmd *cobra.Command) *input.CheckpointCache {
+	if cmd.Context() == nil {
+		return nil
+	}
+
+	cache, _ := cmd.Context().Value(checkpointCacheKey{}).(*input.CheckpointCache)
+	return cache
+}
+
+func findCommand(name string) (*cobra.Command, error) {
+	if name == runPipelineCmd.Name() {
+		return nil, fmt.Errorf("steps cannot run pipelines")
+	}
+
+	for _, command := range rootCmd.Commands() {
+		if command.Name() == name {
+			return command, nil
+		}
+	}
+
+	return nil, fmt.Errorf("there is no command named %q", name)
+}
+
+func (commandRunner) Flags(command string) (map[string]bool, error) {
+	found, err := findCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := map[string]bool{}
+	found.Flags().VisitAll(func(flag *pflag.Flag) {
+		flags[flag.Name] = true
+	})
+
+	return flags, nil
+}
+
+/*
+	Run runs the command with the arguments. The flags of the command are reset first, since they keep their values from earlier steps.
+	The output that the command prints is passed through as it is printed, and its last line is the summary of the step if it is a JSON
+	object. Commands keep the context of their first run, so every step of the pipeline runs with the same context.
+*/
+func (runner commandRunner) Run(command string, args []string) (summary map[string]interface{}, err error) {
+	found, err := findCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	resetFlags(found.Flags())
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("could not capture the output of the command: %v", err)
+	}
+
+	defer reader.Close()
+	stdout, tail := os.Stdout, &lineTail{}
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		io.Copy(io.MultiWriter(stdout, tail), reader)
+	}()
+
+	os.Stdout = writer
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("the command panicked: %v", r)
+			}
+		}()
+
+		rootCmd.SetArgs(append([]string{command}, args...))
+		err = rootCmd.ExecuteContext(runner.ctx)
+	}()
+
+	os.Stdout = stdout
+	writer.Close()
+	<-copied
+	if err != nil {
+		return nil, err
+	}
+
+	return tail.summary(), nil
+}
+
+// lineTail keeps the last line that is not blank of what is written to it
+type lineTail struct {
+	last, current []byte
+}
+
+func (t *lineTail) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		if end == -1 {
+			t.current = append(t.current, p...)
+			break
+		}
+
+		t.current = append(t.current, p[:end]...)
+		if len(bytes.TrimSpace(t.current)) > 0 {
+			t.last = append(t.last[:0], t.current...)
+		}
+
+		t.current = t.current[:0]
+		p = p[end+1:]
+	}
+
+	return written, nil
+}
+
+// summary decodes the last line if it is a JSON object, and returns nil otherwise
+func (t *lineTail) summary() map[string]interface{} {
+	line := t.last
+	if len(bytes.TrimSpace(t.current)) > 0 {
+		line = t.current
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	summary := map[string]interface{}{}
+	if decoder.Decode(&summary) != nil {
+		return nil
+	}
+
+	return summary
+}
+
+// resetFlags sets the flags back to their default values
+func resetFlags(flags *pflag.FlagSet) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if slice, isSlice := flag.Value.(pflag.SliceValue); isSlice {
+			values := []string{}
+			if trimmed := strings.Trim(flag.DefValue, "[]"); trimmed != "" {
+				values = strings.Split(trimmed, ",")
+			}
+
+			slice.Replace(values)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+
+		flag.Changed = false
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(runPipelineCmd)
+	runPipelineCmd.Flags().String("report", "", "Filename of the run report. Defaults to the report of the pipeline file, or pipeline_report.json")
+	runPipelineCmd.Flags().Int("cached-checkpoints", 32, "Number of downloaded history archive checkpoints that the steps share. 0 turns the cache off")
+	/*
+		Current flags:
+			report: filename of the run report; overrides the report in the pipeline file
+			cached-checkpoints: number of downloaded history archive checkpoints that are kept for later steps
+	*/
+}
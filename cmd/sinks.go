@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/stellar/stellar-etl/internal/sink"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// openSinks opens the sinks from the sink flags, which receive the rows in the output format. If no sinks are set, nil is returned
+func openSinks(flags *pflag.FlagSet, format, indexPattern string) (*sink.Fanout, error) {
+	requiredSinks, optionalSinks, attempts, err := utils.GetSinkFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(requiredSinks) == 0 && len(optionalSinks) == 0 {
+		return nil, nil
+	}
+
+	fanout := sink.NewFanout(attempts)
+	for _, location := range requiredSinks {
+		s, err := openSink(location, format, indexPattern)
+		if err != nil {
+			return nil, err
+		}
+
+		fanout.Add(s, true)
+	}
+
+	for _, location := range optionalSinks {
+		s, err := openSink(location, format, indexPattern)
+		if err != nil {
+			return nil, err
+		}
+
+		fanout.Add(s, false)
+	}
+
+	return fanout, nil
+}
+
+func openSink(location, format, indexPattern string) (sink.Sink, error) {
+	s, err := sink.Open(location, format, indexPattern)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sink: %v", err)
+	}
+
+	return s, nil
+}
+
+// deliverBatch delivers the rows of the batch [start, end] to the sinks. An error is returned unless every required sink confirmed the batch
+func deliverBatch(sinks *sink.Fanout, dataset string, exampleRow interface{}, rows []interface{}, start, end uint32) error {
+	results, err := sinks.Deliver(sink.Batch{Dataset: dataset, Start: start, End: end, Rows: rows, ExampleRow: exampleRow})
+	for _, result := range results {
+		if result.Err != nil && !result.Required {
+			cmdLogger.Warnf("optional sink %s did not confirm batch %d-%d of %s after %d attempts: %v", result.Sink, start, end, dataset, result.Attempts, result.Err)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not deliver batch: %v", err)
+	}
+
+	return nil
+}
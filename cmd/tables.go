@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stellar/stellar-etl/internal/delta"
+)
+
+// openTable opens the Delta Lake table of the dataset, which is the folder with the name of the dataset under tablePath.
+// If tablePath is empty, the data is not committed to a table and nil is returned
+func openTable(tablePath, dataset string, exampleRow interface{}) (*delta.Table, error) {
+	if tablePath == "" {
+		return nil, nil
+	}
+
+	table, err := delta.OpenTable(strings.TrimRight(tablePath, "/")+"/"+dataset, exampleRow)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the %s table: %v", dataset, err)
+	}
+
+	return table, nil
+}
+
+// commitTable commits the rows of the batch [start, end] to the table, replacing the rows of any earlier export of the same batch
+func commitTable(table *delta.Table, rows []interface{}, start, end uint32) error {
+	err := table.CommitBatch(rows, start, end)
+	if err != nil {
+		return fmt.Errorf("could not commit batch %d-%d to the table: %v", start, end, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/network"
+	"github.com/stellar/stellar-etl/internal/audit"
+)
+
+var verifyTransactionProofCmd = &cobra.Command{
+	Use:   "verify_transaction_proof",
+	Short: "Verifies a transaction inclusion proof offline.",
+	Long: `Reads a proof written by prove_transaction and checks it without reading anything from the network. The hash of every header
+	is recomputed, each header has to refer to the one before it, the transaction set has to hash to the hash in the header of the ledger,
+	and the transaction has to be in the set. The transaction hashes are computed with the network-passphrase, not the passphrase in the
+	proof. The result is printed as JSON, and the command fails if any check does not hold.
+
+	The proof only holds if the hash of the trusted ledger is confirmed with a trusted source, like a stellar-core node or a second history
+	archive. That hash has to be passed as trusted-hash. With allow-untrusted, the proof is checked without it, and the trusted_hash of
+	the result has to be compared with the trusted source instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := cmd.Flags().GetString("proof")
+		if err != nil {
+			return fmt.Errorf("could not get proof filename: %v", err)
+		}
+
+		trustedHash, err := cmd.Flags().GetString("trusted-hash")
+		if err != nil {
+			return fmt.Errorf("could not get trusted hash: %v", err)
+		}
+
+		allowUntrusted, err := cmd.Flags().GetBool("allow-untrusted")
+		if err != nil {
+			return fmt.Errorf("could not get allow-untrusted boolean: %v", err)
+		}
+
+		passphrase, err := cmd.Flags().GetString("network-passphrase")
+		if err != nil {
+			return fmt.Errorf("could not get network passphrase: %v", err)
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read the proof: %v", err)
+		}
+
+		var proof audit.InclusionProof
+		err = json.Unmarshal(contents, &proof)
+		if err != nil {
+			return fmt.Errorf("could not decode the proof: %v", err)
+		}
+
+		verification, err := audit.VerifyInclusionProof(proof, passphrase, trustedHash, allowUntrusted)
+		if err != nil {
+			return fmt.Errorf("the proof does not hold: %v", err)
+		}
+
+		if !verification.TrustedHashChecked {
+			cmdLogger.Warnf("no trusted hash was provided, so the proof only holds if ledger %d has hash %s", verification.TrustedLedger, verification.TrustedHash)
+		}
+
+		marshalled, err := json.Marshal(verification)
+		if err != nil {
+			return fmt.Errorf("could not marshal the verification: %v", err)
+		}
+
+		fmt.Println(string(marshalled))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyTransactionProofCmd)
+	verifyTransactionProofCmd.Flags().StringP("proof", "p", "transaction_proof.json", "Filename of the proof")
+	verifyTransactionProofCmd.Flags().String("trusted-hash", "", "The hex-encoded hash of the trusted ledger, from a trusted source")
+	verifyTransactionProofCmd.Flags().Bool("allow-untrusted", false, "If set, the proof is checked without a trusted hash, and only holds if the trusted_hash of the result is confirmed with a trusted source")
+	verifyTransactionProofCmd.Flags().String("network-passphrase", network.PublicNetworkPassphrase, "The passphrase of the network of the transaction, which its hash is computed with")
+	/*
+		Current flags:
+			proof: filename of the proof written by prove_transaction
+			trusted-hash: the hash of the trusted ledger from a trusted source; the proof has to chain to it
+			allow-untrusted: if set, the proof is checked without a trusted hash
+			network-passphrase: the passphrase of the network of the transaction; defaults to the public network
+	*/
+}
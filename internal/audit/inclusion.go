@@ -0,0 +1,229 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// ProofHeader is a ledger header in an inclusion proof, as base64 XDR, along with its sequence number and hash
+type ProofHeader struct {
+	Sequence uint32 `json:"sequence"`
+	Hash     string `json:"hash"`
+	Header   string `json:"header"`
+}
+
+/*
+	InclusionProof proves that a transaction was included in a ledger. Stellar does not build Merkle trees over transaction sets, so the proof
+	holds the whole transaction set of the ledger, whose hash is in the ledger header. Each header holds the hash of the header before it, so
+	the headers from the ledger of the transaction up to the trusted ledger chain the ledger to the hash of the trusted ledger. The proof
+	only holds if that hash is confirmed with a source that the verifier trusts, like a stellar-core node or a second history archive.
+*/
+type InclusionProof struct {
+	NetworkPassphrase  string        `json:"network_passphrase"`
+	TransactionHash    string        `json:"transaction_hash"`
+	LedgerSequence     uint32        `json:"ledger_sequence"`
+	TransactionSet     []string      `json:"transaction_set"`
+	TransactionSetHash string        `json:"transaction_set_hash"`
+	Headers            []ProofHeader `json:"headers"`
+	TrustedLedger      uint32        `json:"trusted_ledger"`
+	TrustedHash        string        `json:"trusted_hash"`
+}
+
+// ProofVerification is the outcome of verifying an inclusion proof. TrustedHashChecked is set when the trusted hash was compared with a hash that the verifier provided
+type ProofVerification struct {
+	TransactionHash    string `json:"transaction_hash"`
+	LedgerSequence     uint32 `json:"ledger_sequence"`
+	TrustedLedger      uint32 `json:"trusted_ledger"`
+	TrustedHash        string `json:"trusted_hash"`
+	TrustedHashChecked bool   `json:"trusted_hash_checked"`
+}
+
+// hashHeader hashes the XDR of a ledger header, which is how stellar-core computes ledger hashes
+func hashHeader(header xdr.LedgerHeader) (xdr.Hash, []byte, error) {
+	raw, err := header.MarshalBinary()
+	if err != nil {
+		return xdr.Hash{}, nil, err
+	}
+
+	return xdr.Hash(sha256.Sum256(raw)), raw, nil
+}
+
+// findTransaction returns the position of the envelope with the provided hash in the transaction set, or -1 if the set does not have it
+func findTransaction(envelopes []xdr.TransactionEnvelope, transactionHash, passphrase string) (int, error) {
+	for i, envelope := range envelopes {
+		hash, err := network.HashTransactionInEnvelope(envelope, passphrase)
+		if err != nil {
+			return -1, fmt.Errorf("could not hash transaction %d of the transaction set: %v", i, err)
+		}
+
+		if utils.HashToHexString(hash) == transactionHash {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+/*
+	ProveTransaction builds the proof that the transaction with the provided hash is in the first of the ledgers. The ledgers have to be
+	consecutive, and the last of them is the trusted ledger. The transaction hashes are computed with the passphrase of the network.
+*/
+func ProveTransaction(transactionHash string, ledgers []xdr.LedgerCloseMeta, passphrase string) (InclusionProof, error) {
+	if len(ledgers) == 0 {
+		return InclusionProof{}, fmt.Errorf("there are no ledgers to build the proof from")
+	}
+
+	first := ledgers[0].V0
+	seq := uint32(first.LedgerHeader.Header.LedgerSeq)
+	index, err := findTransaction(first.TxSet.Txs, transactionHash, passphrase)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+
+	if index < 0 {
+		return InclusionProof{}, fmt.Errorf("transaction %s is not in the transaction set of ledger %d", transactionHash, seq)
+	}
+
+	proof := InclusionProof{
+		NetworkPassphrase:  passphrase,
+		TransactionHash:    transactionHash,
+		LedgerSequence:     seq,
+		TransactionSet:     make([]string, 0, len(first.TxSet.Txs)),
+		TransactionSetHash: utils.HashToHexString(first.LedgerHeader.Header.ScpValue.TxSetHash),
+		Headers:            make([]ProofHeader, 0, len(ledgers)),
+	}
+
+	for _, envelope := range first.TxSet.Txs {
+		encoded, err := xdr.MarshalBase64(envelope)
+		if err != nil {
+			return InclusionProof{}, fmt.Errorf("could not encode the transaction set of ledger %d: %v", seq, err)
+		}
+
+		proof.TransactionSet = append(proof.TransactionSet, encoded)
+	}
+
+	for _, ledger := range ledgers {
+		header := ledger.V0.LedgerHeader.Header
+		hash, raw, err := hashHeader(header)
+		if err != nil {
+			return InclusionProof{}, fmt.Errorf("could not encode the header of ledger %d: %v", header.LedgerSeq, err)
+		}
+
+		proof.Headers = append(proof.Headers, ProofHeader{
+			Sequence: uint32(header.LedgerSeq),
+			Hash:     utils.HashToHexString(hash),
+			Header:   base64.StdEncoding.EncodeToString(raw),
+		})
+	}
+
+	trusted := proof.Headers[len(proof.Headers)-1]
+	proof.TrustedLedger, proof.TrustedHash = trusted.Sequence, trusted.Hash
+	return proof, nil
+}
+
+/*
+	VerifyInclusionProof checks an inclusion proof without reading anything from the network. It recomputes the hash of every header and
+	checks that each header refers to the one before it, that the transaction set hashes to the hash in the header of the ledger, and that
+	the transaction is in the set. The transaction hashes are computed with the passphrase of the verifier, and not with the one that the
+	proof claims. The proof has to chain to trustedHash, the hash of the trusted ledger from a trusted source. Only if allowUntrusted is set
+	can trustedHash be empty, in which case the hash that the proof chains to is returned, and has to be compared with a trusted source
+	for the proof to hold.
+*/
+func VerifyInclusionProof(proof InclusionProof, passphrase, trustedHash string, allowUntrusted bool) (ProofVerification, error) {
+	if len(proof.Headers) == 0 {
+		return ProofVerification{}, fmt.Errorf("the proof has no ledger headers")
+	}
+
+	if trustedHash == "" && !allowUntrusted {
+		return ProofVerification{}, fmt.Errorf("the proof cannot be verified without the hash of the trusted ledger %d", proof.TrustedLedger)
+	}
+
+	if proof.NetworkPassphrase != passphrase {
+		return ProofVerification{}, fmt.Errorf("the proof is for the network %q, but the passphrase of the network is %q", proof.NetworkPassphrase, passphrase)
+	}
+
+	headers := make([]xdr.LedgerHeader, 0, len(proof.Headers))
+	var previousHash xdr.Hash
+	for i, proofHeader := range proof.Headers {
+		var header xdr.LedgerHeader
+		if err := xdr.SafeUnmarshalBase64(proofHeader.Header, &header); err != nil {
+			return ProofVerification{}, fmt.Errorf("could not decode the header of ledger %d: %v", proofHeader.Sequence, err)
+		}
+
+		expectedSeq := proof.LedgerSequence + uint32(i)
+		if uint32(header.LedgerSeq) != expectedSeq || proofHeader.Sequence != expectedSeq {
+			return ProofVerification{}, fmt.Errorf("header %d of the proof is for ledger %d, but ledger %d follows ledger %d", i, header.LedgerSeq, expectedSeq, expectedSeq-1)
+		}
+
+		hash, _, err := hashHeader(header)
+		if err != nil {
+			return ProofVerification{}, fmt.Errorf("could not encode the header of ledger %d: %v", expectedSeq, err)
+		}
+
+		if utils.HashToHexString(hash) != proofHeader.Hash {
+			return ProofVerification{}, fmt.Errorf("the header of ledger %d hashes to %s, but the proof has %s", expectedSeq, utils.HashToHexString(hash), proofHeader.Hash)
+		}
+
+		if i > 0 && header.PreviousLedgerHash != previousHash {
+			return ProofVerification{}, fmt.Errorf("the header of ledger %d refers to the previous ledger hash %s, but ledger %d hashes to %s",
+				expectedSeq, utils.HashToHexString(header.PreviousLedgerHash), expectedSeq-1, utils.HashToHexString(previousHash))
+		}
+
+		headers = append(headers, header)
+		previousHash = hash
+	}
+
+	trusted := proof.Headers[len(proof.Headers)-1]
+	if trusted.Sequence != proof.TrustedLedger || trusted.Hash != proof.TrustedHash {
+		return ProofVerification{}, fmt.Errorf("the proof chains to ledger %d with hash %s, but it claims ledger %d with hash %s", trusted.Sequence, trusted.Hash, proof.TrustedLedger, proof.TrustedHash)
+	}
+
+	if trustedHash != "" && trustedHash != trusted.Hash {
+		return ProofVerification{}, fmt.Errorf("the proof chains to ledger %d with hash %s, but the trusted hash is %s", trusted.Sequence, trusted.Hash, trustedHash)
+	}
+
+	envelopes := make([]xdr.TransactionEnvelope, 0, len(proof.TransactionSet))
+	for i, encoded := range proof.TransactionSet {
+		var envelope xdr.TransactionEnvelope
+		if err := xdr.SafeUnmarshalBase64(encoded, &envelope); err != nil {
+			return ProofVerification{}, fmt.Errorf("could not decode transaction %d of the transaction set: %v", i, err)
+		}
+
+		envelopes = append(envelopes, envelope)
+	}
+
+	header := headers[0]
+	txSetHash, err := input.HashTransactionSet(header.PreviousLedgerHash, envelopes)
+	if err != nil {
+		return ProofVerification{}, fmt.Errorf("could not hash the transaction set: %v", err)
+	}
+
+	if txSetHash != header.ScpValue.TxSetHash || utils.HashToHexString(txSetHash) != proof.TransactionSetHash {
+		return ProofVerification{}, fmt.Errorf("the transaction set hashes to %s, but the header of ledger %d has %s and the proof has %s",
+			utils.HashToHexString(txSetHash), proof.LedgerSequence, utils.HashToHexString(header.ScpValue.TxSetHash), proof.TransactionSetHash)
+	}
+
+	index, err := findTransaction(envelopes, proof.TransactionHash, passphrase)
+	if err != nil {
+		return ProofVerification{}, err
+	}
+
+	if index < 0 {
+		return ProofVerification{}, fmt.Errorf("transaction %s is not in the transaction set of ledger %d", proof.TransactionHash, proof.LedgerSequence)
+	}
+
+	verification := ProofVerification{
+		TransactionHash:    proof.TransactionHash,
+		LedgerSequence:     proof.LedgerSequence,
+		TrustedLedger:      trusted.Sequence,
+		TrustedHash:        trusted.Hash,
+		TrustedHashChecked: trustedHash != "",
+	}
+	return verification, nil
+}
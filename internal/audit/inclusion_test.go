@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// makeLedgerChain returns consecutive ledgers starting at start, whose headers refer to each other. The first ledger has the transactions
+func makeLedgerChain(t *testing.T, start uint32, count int, transactions []xdr.TransactionEnvelope) []xdr.LedgerCloseMeta {
+	ledgers := []xdr.LedgerCloseMeta{}
+	previousHash := xdr.Hash{0x01}
+	for i := 0; i < count; i++ {
+		header := xdr.LedgerHeader{LedgerSeq: xdr.Uint32(start + uint32(i)), PreviousLedgerHash: previousHash}
+		txSet := xdr.TransactionSet{PreviousLedgerHash: previousHash}
+		if i == 0 {
+			txSet.Txs = transactions
+		}
+
+		txSetHash, err := input.HashTransactionSet(previousHash, txSet.Txs)
+		assert.NoError(t, err)
+		header.ScpValue.TxSetHash = txSetHash
+
+		raw, err := header.MarshalBinary()
+		assert.NoError(t, err)
+		hash := xdr.Hash(sha256.Sum256(raw))
+		ledgers = append(ledgers, xdr.LedgerCloseMeta{V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{Hash: hash, Header: header},
+			TxSet:        txSet,
+		}})
+		previousHash = hash
+	}
+
+	return ledgers
+}
+
+func TestProveTransaction(t *testing.T) {
+	type functionInput struct {
+		transactionHash string
+		ledgers         []xdr.LedgerCloseMeta
+	}
+	type functionOutput struct {
+		err error
+	}
+
+	transactions := []xdr.TransactionEnvelope{utils.CreateSampleTx(1), utils.CreateSampleTx(2)}
+	hash, err := network.HashTransactionInEnvelope(transactions[1], network.TestNetworkPassphrase)
+	assert.NoError(t, err)
+	transactionHash := utils.HashToHexString(hash)
+	ledgers := makeLedgerChain(t, 100, 3, transactions)
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{transactionHash, ledgers}, functionOutput{nil}},
+		{
+			functionInput{transactionHash, ledgers[1:]},
+			functionOutput{fmt.Errorf("transaction %s is not in the transaction set of ledger 101", transactionHash)},
+		},
+		{functionInput{transactionHash, nil}, functionOutput{fmt.Errorf("there are no ledgers to build the proof from")}},
+	}
+
+	for _, test := range tests {
+		proof, err := ProveTransaction(test.input.transactionHash, test.input.ledgers, network.TestNetworkPassphrase)
+		assert.Equal(t, test.output.err, err)
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, uint32(100), proof.LedgerSequence)
+		assert.Len(t, proof.TransactionSet, 2)
+		assert.Len(t, proof.Headers, 3)
+		assert.Equal(t, uint32(102), proof.TrustedLedger)
+		assert.Equal(t, utils.HashToHexString(ledgers[2].V0.LedgerHeader.Hash), proof.TrustedHash)
+		assert.Equal(t, utils.HashToHexString(ledgers[0].V0.LedgerHeader.Header.ScpValue.TxSetHash), proof.TransactionSetHash)
+	}
+}
+
+func TestVerifyInclusionProof(t *testing.T) {
+	type functionInput struct {
+		proof          InclusionProof
+		trustedHash    string
+		allowUntrusted bool
+	}
+	type functionOutput struct {
+		verification ProofVerification
+		err          error
+	}
+
+	transactions := []xdr.TransactionEnvelope{utils.CreateSampleTx(1), utils.CreateSampleTx(2)}
+	hash, err := network.HashTransactionInEnvelope(transactions[0], network.TestNetworkPassphrase)
+	assert.NoError(t, err)
+	transactionHash := utils.HashToHexString(hash)
+	ledgers := makeLedgerChain(t, 100, 3, transactions)
+	proof, err := ProveTransaction(transactionHash, ledgers, network.TestNetworkPassphrase)
+	assert.NoError(t, err)
+
+	// Each of these proofs changes one part of the valid proof
+	missingTransaction := proof
+	missingTransaction.TransactionSet = proof.TransactionSet[1:]
+
+	forkedLedgers := makeLedgerChain(t, 100, 3, transactions)
+	forkedLedgers[1].V0.LedgerHeader.Header.PreviousLedgerHash = xdr.Hash{0x02}
+	forkedProof, err := ProveTransaction(transactionHash, forkedLedgers, network.TestNetworkPassphrase)
+	assert.NoError(t, err)
+
+	rehashedHeader := proof
+	rehashedHeader.Headers = append([]ProofHeader{}, proof.Headers...)
+	rehashedHeader.Headers[2].Hash = proof.Headers[1].Hash
+
+	otherNetwork := proof
+	otherNetwork.NetworkPassphrase = network.PublicNetworkPassphrase
+
+	trustedHash := utils.HashToHexString(ledgers[2].V0.LedgerHeader.Hash)
+	verification := ProofVerification{TransactionHash: transactionHash, LedgerSequence: 100, TrustedLedger: 102, TrustedHash: trustedHash}
+	checkedVerification := verification
+	checkedVerification.TrustedHashChecked = true
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{proof, "", true}, functionOutput{verification, nil}},
+		{functionInput{proof, trustedHash, false}, functionOutput{checkedVerification, nil}},
+		{functionInput{proof, "", false}, functionOutput{ProofVerification{}, fmt.Errorf("the proof cannot be verified without the hash of the trusted ledger 102")}},
+		{
+			functionInput{proof, proof.Headers[1].Hash, false},
+			functionOutput{ProofVerification{}, fmt.Errorf("the proof chains to ledger 102 with hash %s, but the trusted hash is %s", trustedHash, proof.Headers[1].Hash)},
+		},
+		{
+			functionInput{missingTransaction, trustedHash, false},
+			functionOutput{ProofVerification{}, fmt.Errorf("the transaction set hashes to %s, but the header of ledger 100 has %s and the proof has %s",
+				utils.HashToHexString(mustHashTransactionSet(t, ledgers[0].V0.LedgerHeader.Header.PreviousLedgerHash, transactions[1:])), proof.TransactionSetHash, proof.TransactionSetHash)},
+		},
+		{
+			functionInput{forkedProof, "", true},
+			functionOutput{ProofVerification{}, fmt.Errorf("the header of ledger 101 refers to the previous ledger hash %s, but ledger 100 hashes to %s",
+				utils.HashToHexString(xdr.Hash{0x02}), forkedProof.Headers[0].Hash)},
+		},
+		{
+			functionInput{rehashedHeader, trustedHash, false},
+			functionOutput{ProofVerification{}, fmt.Errorf("the header of ledger 102 hashes to %s, but the proof has %s", trustedHash, proof.Headers[1].Hash)},
+		},
+		{
+			functionInput{otherNetwork, trustedHash, false},
+			functionOutput{ProofVerification{}, fmt.Errorf("the proof is for the network %q, but the passphrase of the network is %q", network.PublicNetworkPassphrase, network.TestNetworkPassphrase)},
+		},
+		{functionInput{InclusionProof{}, "", true}, functionOutput{ProofVerification{}, fmt.Errorf("the proof has no ledger headers")}},
+	}
+
+	for _, test := range tests {
+		actualVerification, err := VerifyInclusionProof(test.input.proof, network.TestNetworkPassphrase, test.input.trustedHash, test.input.allowUntrusted)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.verification, actualVerification)
+	}
+}
+
+func mustHashTransactionSet(t *testing.T, previousLedgerHash xdr.Hash, envelopes []xdr.TransactionEnvelope) xdr.Hash {
+	hash, err := input.HashTransactionSet(previousLedgerHash, envelopes)
+	assert.NoError(t, err)
+	return hash
+}
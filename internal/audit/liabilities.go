@@ -0,0 +1,245 @@
+/*
+	Package audit checks the consistency of exported ledger state. The checks run on the transformed rows of a single snapshot, so they
+	find problems in the exported data as well as in the ledger itself.
+*/
+package audit
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/transform"
+)
+
+// The kinds of issues that CheckLiabilities reports
+const (
+	LiabilitiesMismatch = "liabilities_mismatch"
+	MissingHolding      = "missing_holding"
+	UnfundedOffer       = "unfunded_offer"
+)
+
+/*
+	LiabilityIssue is a problem found by CheckLiabilities. For a liabilities mismatch, the recorded liabilities are the ones on the account
+	or trustline of the asset, and the offer liabilities are the sums over the open offers of the account. A missing holding is an account
+	or trustline that the offers of an account need, but that does not exist. For an unfunded offer, OfferID is set, and Available is the
+	amount of the selling asset that the seller can spend on its offers.
+*/
+type LiabilityIssue struct {
+	Kind                       string `json:"kind"`
+	AccountID                  string `json:"account_id"`
+	Asset                      string `json:"asset"`
+	OfferID                    int64  `json:"offer_id"`
+	RecordedBuyingLiabilities  int64  `json:"recorded_buying_liabilities"`
+	RecordedSellingLiabilities int64  `json:"recorded_selling_liabilities"`
+	OfferBuyingLiabilities     int64  `json:"offer_buying_liabilities"`
+	OfferSellingLiabilities    int64  `json:"offer_selling_liabilities"`
+	Available                  int64  `json:"available"`
+}
+
+// holding identifies the balance of an asset that an account holds: its native balance or one of its trustlines
+type holding struct {
+	account string
+	asset   string
+}
+
+type liabilities struct {
+	buying  int64
+	selling int64
+}
+
+// assetString returns native for the native asset, and CODE:ISSUER for other assets
+func assetString(asset xdr.Asset) (string, string, error) {
+	var assetType, code, issuer string
+	if err := asset.Extract(&assetType, &code, &issuer); err != nil {
+		return "", "", err
+	}
+
+	if asset.Type == xdr.AssetTypeAssetTypeNative {
+		return "native", "", nil
+	}
+
+	return code + ":" + issuer, issuer, nil
+}
+
+// decodeAsset decodes an asset of an offer, which is the base64 encoding of its XDR
+func decodeAsset(encoded string) (string, string, error) {
+	var asset xdr.Asset
+	if err := xdr.SafeUnmarshalBase64(encoded, &asset); err != nil {
+		return "", "", fmt.Errorf("could not decode asset %s: %v", encoded, err)
+	}
+
+	return assetString(asset)
+}
+
+/*
+	offerLiabilities returns the amounts that an offer can buy and sell, which are the liabilities that it adds to the holdings of its
+	seller. stellar-core rounds them in favour of the more valuable asset, so an offer to sell 10 at a price of 1/3 has selling
+	liabilities of 9 and buying liabilities of 3.
+*/
+func offerLiabilities(amount int64, priceN, priceD int32) (buying, selling int64) {
+	if priceN <= 0 || priceD <= 0 {
+		return 0, 0
+	}
+
+	n, d := big.NewInt(int64(priceN)), big.NewInt(int64(priceD))
+	value := new(big.Int).Mul(big.NewInt(amount), n)
+	if priceN > priceD {
+		// The selling asset is more valuable, so all of it is sold and the buying amount is rounded up
+		value.Add(value, d)
+		value.Sub(value, big.NewInt(1))
+		return value.Div(value, d).Int64(), amount
+	}
+
+	buyingAmount := value.Div(value, d)
+	buying = buyingAmount.Int64()
+	sold := new(big.Int).Mul(buyingAmount, d)
+	return buying, sold.Div(sold, n).Int64()
+}
+
+/*
+	minimumBalance is the native balance that an account has to keep, given the base reserve of the ledger. Like the reserve of the account
+	composites, it is two base reserves for the account, and one for each subentry and each entry that the account sponsors, less one for
+	each of its entries that another account sponsors.
+*/
+func minimumBalance(account transform.AccountCompositeOutput, baseReserve uint32) int64 {
+	return (2 + int64(account.NumSubentries) + int64(account.NumSponsoring) - int64(account.NumSponsored)) * int64(baseReserve)
+}
+
+/*
+	CheckLiabilities compares the buying and selling liabilities of the accounts and trustlines in a snapshot with the sums over the open
+	offers of each account, and finds the offers whose seller does not hold enough of the selling asset to fund all of its offers. Issuers
+	can sell and buy any amount of their own assets, so offers of an issuer in its own asset are not checked. The accounts are composite
+	rows, so that the minimum balance of an account is derived from its subentries and sponsorships and the base reserve.
+*/
+func CheckLiabilities(accounts []transform.AccountCompositeOutput, trustlines []transform.TrustlineOutput, offers []transform.OfferOutput, baseReserve uint32) ([]LiabilityIssue, error) {
+	accountsByID := map[string]transform.AccountCompositeOutput{}
+	for _, account := range accounts {
+		if !account.Deleted {
+			accountsByID[account.AccountID] = account
+		}
+	}
+
+	trustlinesByHolding := map[holding]transform.TrustlineOutput{}
+	for _, trustline := range trustlines {
+		if !trustline.Deleted {
+			trustlinesByHolding[holding{trustline.AccountID, trustline.AssetCode + ":" + trustline.AssetIssuer}] = trustline
+		}
+	}
+
+	offerSums := map[holding]liabilities{}
+	offersBySelling := map[holding][]transform.OfferOutput{}
+	for _, offer := range offers {
+		if offer.Deleted {
+			continue
+		}
+
+		selling, sellingIssuer, err := decodeAsset(offer.SellingAsset)
+		if err != nil {
+			return nil, fmt.Errorf("offer %d: %v", offer.OfferID, err)
+		}
+
+		buying, buyingIssuer, err := decodeAsset(offer.BuyingAsset)
+		if err != nil {
+			return nil, fmt.Errorf("offer %d: %v", offer.OfferID, err)
+		}
+
+		buyingAmount, sellingAmount := offerLiabilities(offer.Amount, offer.PriceN, offer.PriceD)
+		if sellingIssuer != offer.SellerID {
+			key := holding{offer.SellerID, selling}
+			sums := offerSums[key]
+			sums.selling += sellingAmount
+			offerSums[key] = sums
+			offersBySelling[key] = append(offersBySelling[key], offer)
+		}
+
+		if buyingIssuer != offer.SellerID {
+			key := holding{offer.SellerID, buying}
+			sums := offerSums[key]
+			sums.buying += buyingAmount
+			offerSums[key] = sums
+		}
+	}
+
+	issues := []LiabilityIssue{}
+	addMismatch := func(kind string, key holding, recorded liabilities) {
+		sums := offerSums[key]
+		if kind == LiabilitiesMismatch && recorded == sums {
+			return
+		}
+
+		issues = append(issues, LiabilityIssue{
+			Kind:                       kind,
+			AccountID:                  key.account,
+			Asset:                      key.asset,
+			RecordedBuyingLiabilities:  recorded.buying,
+			RecordedSellingLiabilities: recorded.selling,
+			OfferBuyingLiabilities:     sums.buying,
+			OfferSellingLiabilities:    sums.selling,
+		})
+	}
+
+	// Every holding is compared, so that liabilities without any offers are found as well
+	for _, account := range accountsByID {
+		addMismatch(LiabilitiesMismatch, holding{account.AccountID, "native"}, liabilities{account.BuyingLiabilities, account.SellingLiabilities})
+	}
+
+	for key, trustline := range trustlinesByHolding {
+		addMismatch(LiabilitiesMismatch, key, liabilities{trustline.BuyingLiabilities, trustline.SellingLiabilities})
+	}
+
+	for key := range offerSums {
+		_, hasAccount := accountsByID[key.account]
+		_, hasTrustline := trustlinesByHolding[key]
+		if (key.asset == "native" && !hasAccount) || (key.asset != "native" && !hasTrustline) {
+			addMismatch(MissingHolding, key, liabilities{})
+		}
+	}
+
+	for key, sellingOffers := range offersBySelling {
+		var available int64
+		if key.asset == "native" {
+			if account, ok := accountsByID[key.account]; ok {
+				available = account.Balance - minimumBalance(account, baseReserve)
+			}
+		} else {
+			available = trustlinesByHolding[key].Balance
+		}
+
+		if available >= offerSums[key].selling {
+			continue
+		}
+
+		for _, offer := range sellingOffers {
+			buyingAmount, sellingAmount := offerLiabilities(offer.Amount, offer.PriceN, offer.PriceD)
+			issues = append(issues, LiabilityIssue{
+				Kind:                    UnfundedOffer,
+				AccountID:               key.account,
+				Asset:                   key.asset,
+				OfferID:                 offer.OfferID,
+				OfferBuyingLiabilities:  buyingAmount,
+				OfferSellingLiabilities: sellingAmount,
+				Available:               available,
+			})
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].AccountID != issues[j].AccountID {
+			return issues[i].AccountID < issues[j].AccountID
+		}
+
+		if issues[i].Asset != issues[j].Asset {
+			return issues[i].Asset < issues[j].Asset
+		}
+
+		if issues[i].Kind != issues[j].Kind {
+			return issues[i].Kind < issues[j].Kind
+		}
+
+		return issues[i].OfferID < issues[j].OfferID
+	})
+
+	return issues, nil
+}
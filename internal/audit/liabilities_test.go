@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/stellar-etl/internal/transform"
+)
+
+const (
+	testSeller  = "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA"
+	testIssuer  = "GCQTGZQQ5G4PTM2GL7CDIFKUBIPEC52BROAQIAPW53XBRJVN6ZJVTG6V"
+	testReserve = 5000000
+)
+
+func encodeAsset(t *testing.T, asset xdr.Asset) string {
+	encoded, err := xdr.MarshalBase64(asset)
+	assert.NoError(t, err)
+	return encoded
+}
+
+func TestOfferLiabilities(t *testing.T) {
+	type functionInput struct {
+		amount         int64
+		priceN, priceD int32
+	}
+	type functionOutput struct {
+		buying, selling int64
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{10, 1, 3}, functionOutput{3, 9}},
+		{functionInput{10, 3, 1}, functionOutput{30, 10}},
+		{functionInput{10, 3, 2}, functionOutput{15, 10}},
+		{functionInput{7, 5, 3}, functionOutput{12, 7}},
+		{functionInput{1000, 1, 1}, functionOutput{1000, 1000}},
+		{functionInput{9223372036854775807, 1, 2}, functionOutput{4611686018427387903, 9223372036854775806}},
+		{functionInput{10, 0, 1}, functionOutput{0, 0}},
+	}
+
+	for _, test := range tests {
+		buying, selling := offerLiabilities(test.input.amount, test.input.priceN, test.input.priceD)
+		assert.Equal(t, test.output, functionOutput{buying, selling})
+	}
+}
+
+func TestCheckLiabilities(t *testing.T) {
+	native := encodeAsset(t, xdr.MustNewNativeAsset())
+	usd := encodeAsset(t, xdr.MustNewCreditAsset("USD", testIssuer))
+	usdTrustline := transform.TrustlineOutput{AccountID: testSeller, AssetCode: "USD", AssetIssuer: testIssuer, AssetType: 1, Balance: 500, TrustlineLimit: 10000}
+
+	type functionInput struct {
+		accounts   []transform.AccountCompositeOutput
+		trustlines []transform.TrustlineOutput
+		offers     []transform.OfferOutput
+	}
+	type functionOutput struct {
+		issues []LiabilityIssue
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			// Selling 100 XLM for USD at 1/2 has liabilities of 50 USD, and the account keeps 3 base reserves
+			functionInput{
+				[]transform.AccountCompositeOutput{{AccountID: testSeller, Balance: 3*testReserve + 100, SellingLiabilities: 100, NumSubentries: 1}},
+				[]transform.TrustlineOutput{func() transform.TrustlineOutput { t := usdTrustline; t.BuyingLiabilities = 50; return t }()},
+				[]transform.OfferOutput{{SellerID: testSeller, OfferID: 1, SellingAsset: native, BuyingAsset: usd, Amount: 100, PriceN: 1, PriceD: 2}},
+			},
+			functionOutput{[]LiabilityIssue{}},
+		},
+		{
+			// The account sponsors two entries and one of its entries is sponsored, so it keeps 4 base reserves and cannot fund the offer
+			functionInput{
+				[]transform.AccountCompositeOutput{{AccountID: testSeller, Balance: 4*testReserve + 99, SellingLiabilities: 100, NumSubentries: 1, NumSponsoring: 2, NumSponsored: 1}},
+				[]transform.TrustlineOutput{func() transform.TrustlineOutput { t := usdTrustline; t.BuyingLiabilities = 50; return t }()},
+				[]transform.OfferOutput{{SellerID: testSeller, OfferID: 1, SellingAsset: native, BuyingAsset: usd, Amount: 100, PriceN: 1, PriceD: 2}},
+			},
+			functionOutput{[]LiabilityIssue{
+				{Kind: UnfundedOffer, AccountID: testSeller, Asset: "native", OfferID: 1, OfferBuyingLiabilities: 50, OfferSellingLiabilities: 100, Available: 99},
+			}},
+		},
+		{
+			functionInput{
+				[]transform.AccountCompositeOutput{{AccountID: testSeller, Balance: 3*testReserve + 100, SellingLiabilities: 90, NumSubentries: 1}},
+				[]transform.TrustlineOutput{usdTrustline},
+				[]transform.OfferOutput{{SellerID: testSeller, OfferID: 1, SellingAsset: native, BuyingAsset: usd, Amount: 100, PriceN: 1, PriceD: 2}},
+			},
+			functionOutput{[]LiabilityIssue{
+				{Kind: LiabilitiesMismatch, AccountID: testSeller, Asset: "USD:" + testIssuer, OfferBuyingLiabilities: 50},
+				{Kind: LiabilitiesMismatch, AccountID: testSeller, Asset: "native", RecordedSellingLiabilities: 90, OfferSellingLiabilities: 100},
+			}},
+		},
+		{
+			// The trustline only holds 500 USD, so both offers that sell USD are unfunded
+			functionInput{
+				[]transform.AccountCompositeOutput{{AccountID: testSeller, Balance: 3 * testReserve, BuyingLiabilities: 1200, NumSubentries: 3}},
+				[]transform.TrustlineOutput{func() transform.TrustlineOutput { t := usdTrustline; t.SellingLiabilities = 600; return t }()},
+				[]transform.OfferOutput{
+					{SellerID: testSeller, OfferID: 7, SellingAsset: usd, BuyingAsset: native, Amount: 400, PriceN: 2, PriceD: 1},
+					{SellerID: testSeller, OfferID: 3, SellingAsset: usd, BuyingAsset: native, Amount: 200, PriceN: 2, PriceD: 1},
+				},
+			},
+			functionOutput{[]LiabilityIssue{
+				{Kind: UnfundedOffer, AccountID: testSeller, Asset: "USD:" + testIssuer, OfferID: 3, OfferBuyingLiabilities: 400, OfferSellingLiabilities: 200, Available: 500},
+				{Kind: UnfundedOffer, AccountID: testSeller, Asset: "USD:" + testIssuer, OfferID: 7, OfferBuyingLiabilities: 800, OfferSellingLiabilities: 400, Available: 500},
+			}},
+		},
+		{
+			// Offers of the issuer in its own asset are not checked, but the offer still needs a trustline for the asset that it buys
+			functionInput{
+				[]transform.AccountCompositeOutput{{AccountID: testIssuer, Balance: 2 * testReserve, BuyingLiabilities: 10}},
+				nil,
+				[]transform.OfferOutput{
+					{SellerID: testIssuer, OfferID: 2, SellingAsset: usd, BuyingAsset: native, Amount: 10, PriceN: 1, PriceD: 1},
+					{SellerID: testIssuer, OfferID: 4, SellingAsset: native, BuyingAsset: encodeAsset(t, xdr.MustNewCreditAsset("EUR", testSeller)), Amount: 0, PriceN: 1, PriceD: 1},
+				},
+			},
+			functionOutput{[]LiabilityIssue{
+				{Kind: MissingHolding, AccountID: testIssuer, Asset: "EUR:" + testSeller},
+			}},
+		},
+		{
+			functionInput{
+				nil,
+				nil,
+				[]transform.OfferOutput{{SellerID: testSeller, OfferID: 5, SellingAsset: native, BuyingAsset: usd, Amount: 10, PriceN: 1, PriceD: 1, Deleted: false}},
+			},
+			functionOutput{[]LiabilityIssue{
+				{Kind: MissingHolding, AccountID: testSeller, Asset: "USD:" + testIssuer, OfferBuyingLiabilities: 10},
+				{Kind: MissingHolding, AccountID: testSeller, Asset: "native", OfferSellingLiabilities: 10},
+				{Kind: UnfundedOffer, AccountID: testSeller, Asset: "native", OfferID: 5, OfferBuyingLiabilities: 10, OfferSellingLiabilities: 10, Available: 0},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		issues, err := CheckLiabilities(test.input.accounts, test.input.trustlines, test.input.offers, testReserve)
+		assert.NoError(t, err)
+		assert.Equal(t, test.output.issues, issues)
+	}
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// StateStats is the size of the ledger state at a checkpoint: the number of live entries of each type, and the number and size of the buckets that hold them
+type StateStats struct {
+	Checkpoint        uint32    `json:"checkpoint"`
+	ClosedAt          time.Time `json:"closed_at"`
+	Accounts          int64     `json:"accounts"`
+	Trustlines        int64     `json:"trustlines"`
+	Offers            int64     `json:"offers"`
+	DataEntries       int64     `json:"data_entries"`
+	ClaimableBalances int64     `json:"claimable_balances"`
+	Buckets           int64     `json:"buckets"`
+	BucketBytes       int64     `json:"bucket_bytes"`
+}
+
+/*
+	ComputeStateStats counts the live entries in the bucket list of a checkpoint. Buckets hold the changes to the entries over different
+	ranges of ledgers, so the buckets are read from the newest to the oldest, and only the first occurrence of each key counts. An entry is
+	live if that occurrence is not a tombstone. BucketBytes is the size of the uncompressed XDR of the buckets.
+*/
+func ComputeStateStats(snapshot input.BucketListSnapshot) (StateStats, error) {
+	closedAt, err := utils.TimePointToUTCTimeStamp(snapshot.Header.ScpValue.CloseTime)
+	if err != nil {
+		return StateStats{}, err
+	}
+
+	stats := StateStats{Checkpoint: snapshot.Checkpoint, ClosedAt: closedAt, Buckets: int64(len(snapshot.Buckets))}
+	seen := map[string]bool{}
+	for _, bucket := range snapshot.Buckets {
+		stats.BucketBytes += bucket.Size
+		for _, key := range bucket.Keys {
+			if seen[key.Key] {
+				continue
+			}
+
+			seen[key.Key] = true
+			if !key.Live {
+				continue
+			}
+
+			switch key.Type {
+			case xdr.LedgerEntryTypeAccount:
+				stats.Accounts++
+			case xdr.LedgerEntryTypeTrustline:
+				stats.Trustlines++
+			case xdr.LedgerEntryTypeOffer:
+				stats.Offers++
+			case xdr.LedgerEntryTypeData:
+				stats.DataEntries++
+			case xdr.LedgerEntryTypeClaimableBalance:
+				stats.ClaimableBalances++
+			}
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/stellar-etl/internal/input"
+)
+
+func TestComputeStateStats(t *testing.T) {
+	type functionInput struct {
+		buckets []input.Bucket
+	}
+	type functionOutput struct {
+		stats StateStats
+	}
+
+	closedAt := time.Date(2020, 12, 1, 10, 30, 15, 0, time.UTC)
+	account := func(key string, live bool) input.BucketKey {
+		return input.BucketKey{Key: key, Type: xdr.LedgerEntryTypeAccount, Live: live}
+	}
+
+	newest := input.Bucket{Size: 100, Keys: []input.BucketKey{
+		account("a", false),
+		{Key: "t", Type: xdr.LedgerEntryTypeTrustline, Live: true},
+		{Key: "o", Type: xdr.LedgerEntryTypeOffer, Live: true},
+	}}
+	oldest := input.Bucket{Size: 1000, Keys: []input.BucketKey{
+		account("a", true),
+		account("b", true),
+		{Key: "t", Type: xdr.LedgerEntryTypeTrustline, Live: true},
+		{Key: "o", Type: xdr.LedgerEntryTypeOffer, Live: false},
+		{Key: "d", Type: xdr.LedgerEntryTypeData, Live: true},
+		{Key: "c", Type: xdr.LedgerEntryTypeClaimableBalance, Live: true},
+	}}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{[]input.Bucket{newest, oldest}},
+			functionOutput{StateStats{
+				Checkpoint: 127, ClosedAt: closedAt, Accounts: 1, Trustlines: 1, Offers: 1, DataEntries: 1, ClaimableBalances: 1,
+				Buckets: 2, BucketBytes: 1100,
+			}},
+		},
+		{
+			functionInput{[]input.Bucket{oldest}},
+			functionOutput{StateStats{
+				Checkpoint: 127, ClosedAt: closedAt, Accounts: 2, Trustlines: 1, Offers: 0, DataEntries: 1, ClaimableBalances: 1,
+				Buckets: 1, BucketBytes: 1000,
+			}},
+		},
+		{
+			functionInput{[]input.Bucket{}},
+			functionOutput{StateStats{Checkpoint: 127, ClosedAt: closedAt}},
+		},
+	}
+
+	for _, test := range tests {
+		snapshot := input.BucketListSnapshot{
+			Checkpoint: 127,
+			Header:     xdr.LedgerHeader{ScpValue: xdr.StellarValue{CloseTime: xdr.TimePoint(closedAt.Unix())}},
+			Buckets:    test.input.buckets,
+		}
+
+		stats, err := ComputeStateStats(snapshot)
+		assert.NoError(t, err)
+		assert.Equal(t, test.output.stats, stats)
+	}
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/input"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+/*
+	SupplyAudit compares the lumens in the ledger state at a checkpoint with the supply in the header of the checkpoint ledger. Every
+	lumen in TotalCoins is either in the balance of an account, in a native claimable balance, or in the fee pool, so Discrepancy is
+	TotalCoins minus the sum of the three, and is 0 for a consistent ledger.
+*/
+type SupplyAudit struct {
+	Checkpoint            uint32    `json:"checkpoint"`
+	ClosedAt              time.Time `json:"closed_at"`
+	TotalCoins            int64     `json:"total_coins"`
+	FeePool               int64     `json:"fee_pool"`
+	AccountBalances       int64     `json:"account_balances"`
+	AccountCount          int64     `json:"account_count"`
+	ClaimableBalances     int64     `json:"claimable_balances"`
+	ClaimableBalanceCount int64     `json:"claimable_balance_count"`
+	Discrepancy           int64     `json:"discrepancy"`
+	Balanced              bool      `json:"balanced"`
+}
+
+// AuditSupply sums the native balances of the accounts and claimable balances in the snapshot, and compares them with the supply in its header
+func AuditSupply(snapshot input.StateSnapshot) (SupplyAudit, error) {
+	closedAt, err := utils.TimePointToUTCTimeStamp(snapshot.Header.ScpValue.CloseTime)
+	if err != nil {
+		return SupplyAudit{}, err
+	}
+
+	audit := SupplyAudit{
+		Checkpoint: snapshot.Checkpoint,
+		ClosedAt:   closedAt,
+		TotalCoins: int64(snapshot.Header.TotalCoins),
+		FeePool:    int64(snapshot.Header.FeePool),
+	}
+
+	for _, change := range snapshot.Changes {
+		entry, removed, err := utils.ExtractEntryFromChange(change)
+		if err != nil {
+			return SupplyAudit{}, err
+		}
+
+		// A snapshot of the bucket list only has live entries, but a removed entry holds no lumens either way
+		if removed {
+			continue
+		}
+
+		switch entry.Data.Type {
+		case xdr.LedgerEntryTypeAccount:
+			audit.AccountBalances += int64(entry.Data.MustAccount().Balance)
+			audit.AccountCount++
+		case xdr.LedgerEntryTypeClaimableBalance:
+			claimableBalance := entry.Data.MustClaimableBalance()
+			if claimableBalance.Asset.Type == xdr.AssetTypeAssetTypeNative {
+				audit.ClaimableBalances += int64(claimableBalance.Amount)
+				audit.ClaimableBalanceCount++
+			}
+		}
+	}
+
+	audit.Discrepancy = audit.TotalCoins - audit.AccountBalances - audit.ClaimableBalances - audit.FeePool
+	audit.Balanced = audit.Discrepancy == 0
+	return audit, nil
+}
+
+// SupplyEntryTypes are the types of the ledger entries that hold lumens, which AuditSupply needs in its snapshot
+var SupplyEntryTypes = []xdr.LedgerEntryType{xdr.LedgerEntryTypeAccount, xdr.LedgerEntryTypeClaimableBalance}
+
+// String describes the breakdown of the audit
+func (a SupplyAudit) String() string {
+	return fmt.Sprintf("checkpoint %d: total coins %d, accounts hold %d, native claimable balances hold %d, the fee pool holds %d, discrepancy %d",
+		a.Checkpoint, a.TotalCoins, a.AccountBalances, a.ClaimableBalances, a.FeePool, a.Discrepancy)
+}
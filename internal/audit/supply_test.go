@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/stellar-etl/internal/input"
+)
+
+func accountChange(balance int64) ingestio.Change {
+	return ingestio.Change{
+		Type: xdr.LedgerEntryTypeAccount,
+		Post: &xdr.LedgerEntry{Data: xdr.LedgerEntryData{
+			Type:    xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{AccountId: xdr.MustAddress(testSeller), Balance: xdr.Int64(balance)},
+		}},
+	}
+}
+
+func claimableBalanceChange(asset xdr.Asset, amount int64) ingestio.Change {
+	return ingestio.Change{
+		Type: xdr.LedgerEntryTypeClaimableBalance,
+		Post: &xdr.LedgerEntry{Data: xdr.LedgerEntryData{
+			Type:             xdr.LedgerEntryTypeClaimableBalance,
+			ClaimableBalance: &xdr.ClaimableBalanceEntry{Asset: asset, Amount: xdr.Int64(amount)},
+		}},
+	}
+}
+
+func TestAuditSupply(t *testing.T) {
+	type functionInput struct {
+		totalCoins, feePool int64
+		changes             []ingestio.Change
+	}
+	type functionOutput struct {
+		audit SupplyAudit
+	}
+
+	closedAt := time.Date(2020, 12, 1, 10, 30, 15, 0, time.UTC)
+	changes := []ingestio.Change{
+		accountChange(700),
+		accountChange(200),
+		claimableBalanceChange(xdr.MustNewNativeAsset(), 60),
+		claimableBalanceChange(xdr.MustNewCreditAsset("USD", testIssuer), 5000),
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{1000, 40, changes},
+			functionOutput{SupplyAudit{
+				Checkpoint: 127, ClosedAt: closedAt, TotalCoins: 1000, FeePool: 40, AccountBalances: 900, AccountCount: 2,
+				ClaimableBalances: 60, ClaimableBalanceCount: 1, Discrepancy: 0, Balanced: true,
+			}},
+		},
+		{
+			functionInput{1000, 30, changes},
+			functionOutput{SupplyAudit{
+				Checkpoint: 127, ClosedAt: closedAt, TotalCoins: 1000, FeePool: 30, AccountBalances: 900, AccountCount: 2,
+				ClaimableBalances: 60, ClaimableBalanceCount: 1, Discrepancy: 10, Balanced: false,
+			}},
+		},
+		{
+			functionInput{1000, 40, nil},
+			functionOutput{SupplyAudit{Checkpoint: 127, ClosedAt: closedAt, TotalCoins: 1000, FeePool: 40, Discrepancy: 960}},
+		},
+	}
+
+	for _, test := range tests {
+		snapshot := input.StateSnapshot{
+			Checkpoint: 127,
+			Header: xdr.LedgerHeader{
+				TotalCoins: xdr.Int64(test.input.totalCoins),
+				FeePool:    xdr.Int64(test.input.feePool),
+				ScpValue:   xdr.StellarValue{CloseTime: xdr.TimePoint(closedAt.Unix())},
+			},
+			Changes: test.input.changes,
+		}
+
+		audit, err := AuditSupply(snapshot)
+		assert.NoError(t, err)
+		assert.Equal(t, test.output.audit, audit)
+	}
+}
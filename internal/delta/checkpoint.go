@@ -0,0 +1,332 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+)
+
+const (
+	// A checkpoint of the table is written after every checkpointInterval commits, which is the default interval of Delta Lake
+	checkpointInterval = 10
+	lastCheckpointName = "_last_checkpoint"
+)
+
+// lastCheckpoint is the content of the _last_checkpoint file, which points readers to the most recent checkpoint of the table
+type lastCheckpoint struct {
+	Version int64 `json:"version"`
+	Size    int64 `json:"size"`
+	Parts   int   `json:"parts,omitempty"`
+}
+
+/*
+	checkpointSchema is the parquet schema of the checkpoints that the table writes. Each row of a checkpoint holds one action, in the
+	column of its type, so that a checkpoint has a protocol row, a metaData row, and an add row for every file of the table. Maps and
+	lists use the standard parquet layout, like the checkpoints that Delta Lake itself writes.
+*/
+const checkpointSchema = `message checkpoint {
+	optional group protocol {
+		optional int32 minReaderVersion;
+		optional int32 minWriterVersion;
+	}
+	optional group metaData {
+		optional binary id (STRING);
+		optional group format {
+			optional binary provider (STRING);
+			optional group options (MAP) {
+				repeated group key_value {
+					required binary key (STRING);
+					optional binary value (STRING);
+				}
+			}
+		}
+		optional binary schemaString (STRING);
+		optional group partitionColumns (LIST) {
+			repeated group list {
+				optional binary element (STRING);
+			}
+		}
+		optional group configuration (MAP) {
+			repeated group key_value {
+				required binary key (STRING);
+				optional binary value (STRING);
+			}
+		}
+		optional int64 createdTime;
+	}
+	optional group add {
+		optional binary path (STRING);
+		optional group partitionValues (MAP) {
+			repeated group key_value {
+				required binary key (STRING);
+				optional binary value (STRING);
+			}
+		}
+		optional int64 size;
+		optional int64 modificationTime;
+		optional boolean dataChange;
+		optional group tags (MAP) {
+			repeated group key_value {
+				required binary key (STRING);
+				optional binary value (STRING);
+			}
+		}
+	}
+}`
+
+func checkpointFileName(version int64) string {
+	return fmt.Sprintf("%s/%0*d.checkpoint.parquet", logDir, commitFileNameSize, version)
+}
+
+// writeCheckpoint writes the checkpoint of the snapshot, and then points the _last_checkpoint file to it
+func (t *Table) writeCheckpoint(current snapshot) error {
+	data, size, err := encodeCheckpoint(current)
+	if err != nil {
+		return fmt.Errorf("could not encode checkpoint %d: %v", current.version, err)
+	}
+
+	if err := t.storage.Write(checkpointFileName(current.version), data); err != nil {
+		return fmt.Errorf("could not write checkpoint %d: %v", current.version, err)
+	}
+
+	pointer, err := json.Marshal(lastCheckpoint{Version: current.version, Size: size})
+	if err != nil {
+		return err
+	}
+
+	if err := t.storage.Write(logDir+"/"+lastCheckpointName, pointer); err != nil {
+		return fmt.Errorf("could not write %s: %v", lastCheckpointName, err)
+	}
+
+	return nil
+}
+
+// readCheckpoint returns the snapshot of the checkpoint that the _last_checkpoint file points to
+func (t *Table) readCheckpoint() (snapshot, error) {
+	current := snapshot{version: -1, files: map[string]addAction{}}
+	data, err := t.storage.Read(logDir + "/" + lastCheckpointName)
+	if err != nil {
+		return current, fmt.Errorf("could not read %s: %v", lastCheckpointName, err)
+	}
+
+	var pointer lastCheckpoint
+	if err := json.Unmarshal(data, &pointer); err != nil {
+		return current, fmt.Errorf("could not decode %s: %v", lastCheckpointName, err)
+	}
+
+	if pointer.Parts > 1 {
+		return current, fmt.Errorf("checkpoint %d has %d parts, and checkpoints with several parts are not supported", pointer.Version, pointer.Parts)
+	}
+
+	data, err = t.storage.Read(checkpointFileName(pointer.Version))
+	if err != nil {
+		return current, fmt.Errorf("could not read checkpoint %d: %v", pointer.Version, err)
+	}
+
+	actions, err := decodeCheckpoint(data)
+	if err != nil {
+		return current, fmt.Errorf("could not decode checkpoint %d: %v", pointer.Version, err)
+	}
+
+	for _, action := range actions {
+		current.apply(action)
+	}
+
+	current.version = pointer.Version
+	return current, nil
+}
+
+// encodeCheckpoint encodes the actions that recreate the snapshot as a checkpoint, and returns it with the number of actions in it
+func encodeCheckpoint(current snapshot) ([]byte, int64, error) {
+	schema, err := parquetschema.ParseSchemaDefinition(checkpointSchema)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse checkpoint schema: %v", err)
+	}
+
+	var file bytes.Buffer
+	writer := goparquet.NewFileWriter(&file, goparquet.WithCompressionCodec(parquet.CompressionCodec_SNAPPY), goparquet.WithCreator("stellar-etl"))
+	if err := writer.SetSchemaDefinition(schema); err != nil {
+		return nil, 0, fmt.Errorf("could not set checkpoint schema: %v", err)
+	}
+
+	records := []map[string]interface{}{}
+	if current.protocol != nil {
+		records = append(records, map[string]interface{}{"protocol": map[string]interface{}{
+			"minReaderVersion": int32(current.protocol.MinReaderVersion),
+			"minWriterVersion": int32(current.protocol.MinWriterVersion),
+		}})
+	}
+
+	if current.metaData != nil {
+		partitionColumns := map[string]interface{}{}
+		if len(current.metaData.PartitionColumns) > 0 {
+			elements := []map[string]interface{}{}
+			for _, name := range current.metaData.PartitionColumns {
+				elements = append(elements, map[string]interface{}{"element": []byte(name)})
+			}
+
+			partitionColumns = map[string]interface{}{"list": elements}
+		}
+
+		records = append(records, map[string]interface{}{"metaData": map[string]interface{}{
+			"id":               []byte(current.metaData.ID),
+			"format":           map[string]interface{}{"provider": []byte(current.metaData.Format.Provider), "options": encodeStringMap(current.metaData.Format.Options)},
+			"schemaString":     []byte(current.metaData.SchemaString),
+			"partitionColumns": partitionColumns,
+			"configuration":    encodeStringMap(current.metaData.Configuration),
+			"createdTime":      current.metaData.CreatedTime,
+		}})
+	}
+
+	// Files are written in the order of their paths, so that checkpoints of the same snapshot are the same
+	paths := []string{}
+	for path := range current.files {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		file := current.files[path]
+		records = append(records, map[string]interface{}{"add": map[string]interface{}{
+			"path":             []byte(file.Path),
+			"partitionValues":  encodeStringMap(file.PartitionValues),
+			"size":             file.Size,
+			"modificationTime": file.ModificationTime,
+			"dataChange":       file.DataChange,
+			"tags":             encodeStringMap(file.Tags),
+		}})
+	}
+
+	for _, record := range records {
+		if err := writer.AddData(record); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	return file.Bytes(), int64(len(records)), nil
+}
+
+// decodeCheckpoint returns the protocol, metaData, and add actions of a checkpoint. Other actions, like the tombstones of removed files,
+// do not change the snapshot, so they are skipped
+func decodeCheckpoint(data []byte) ([]logAction, error) {
+	reader, err := goparquet.NewFileReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []logAction{}
+	for {
+		row, err := reader.NextRow()
+		if err == io.EOF {
+			return actions, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if protocol, ok := row["protocol"].(map[string]interface{}); ok {
+			actions = append(actions, logAction{Protocol: &protocolAction{
+				MinReaderVersion: int(checkpointInt(protocol["minReaderVersion"])),
+				MinWriterVersion: int(checkpointInt(protocol["minWriterVersion"])),
+			}})
+		}
+
+		if metaData, ok := row["metaData"].(map[string]interface{}); ok {
+			format, _ := metaData["format"].(map[string]interface{})
+			partitionColumns := []string{}
+			if columns, ok := metaData["partitionColumns"].(map[string]interface{}); ok {
+				elements, _ := columns["list"].([]map[string]interface{})
+				for _, element := range elements {
+					partitionColumns = append(partitionColumns, checkpointString(element["element"]))
+				}
+			}
+
+			actions = append(actions, logAction{MetaData: &metaDataAction{
+				ID:               checkpointString(metaData["id"]),
+				Format:           formatSpec{Provider: checkpointString(format["provider"]), Options: decodeStringMap(format["options"])},
+				SchemaString:     checkpointString(metaData["schemaString"]),
+				PartitionColumns: partitionColumns,
+				Configuration:    decodeStringMap(metaData["configuration"]),
+				CreatedTime:      checkpointInt(metaData["createdTime"]),
+			}})
+		}
+
+		if add, ok := row["add"].(map[string]interface{}); ok {
+			dataChange, _ := add["dataChange"].(bool)
+			actions = append(actions, logAction{Add: &addAction{
+				Path:             checkpointString(add["path"]),
+				PartitionValues:  decodeStringMap(add["partitionValues"]),
+				Size:             checkpointInt(add["size"]),
+				ModificationTime: checkpointInt(add["modificationTime"]),
+				DataChange:       dataChange,
+				Tags:             decodeStringMap(add["tags"]),
+			}})
+		}
+	}
+}
+
+// encodeStringMap returns the value of a map column, whose entries are written in the order of their keys. The parquet writer writes an
+// empty repeated group as a missing one, so empty maps are written without their key_value group, which still makes them empty rather than null
+func encodeStringMap(values map[string]string) map[string]interface{} {
+	if len(values) == 0 {
+		return map[string]interface{}{}
+	}
+
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	entries := []map[string]interface{}{}
+	for _, key := range keys {
+		entries = append(entries, map[string]interface{}{"key": []byte(key), "value": []byte(values[key])})
+	}
+
+	return map[string]interface{}{"key_value": entries}
+}
+
+// decodeStringMap returns the entries of a map column. A missing map has no entries
+func decodeStringMap(value interface{}) map[string]string {
+	values := map[string]string{}
+	group, _ := value.(map[string]interface{})
+	entries, _ := group["key_value"].([]map[string]interface{})
+	for _, entry := range entries {
+		values[checkpointString(entry["key"])] = checkpointString(entry["value"])
+	}
+
+	return values
+}
+
+func checkpointString(value interface{}) string {
+	switch value := value.(type) {
+	case []byte:
+		return string(value)
+	case string:
+		return value
+	default:
+		return ""
+	}
+}
+
+func checkpointInt(value interface{}) int64 {
+	switch value := value.(type) {
+	case int32:
+		return int64(value)
+	case int64:
+		return value
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,170 @@
+package delta
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// columnKind is the type of a column; it decides both the parquet physical type and the Delta Lake type of the column
+type columnKind int
+
+const (
+	booleanColumn columnKind = iota
+	integerColumn
+	longColumn
+	unsignedLongColumn // unsigned 64 bit integers are stored as decimals, since they do not fit in a long
+	doubleColumn
+	stringColumn
+	timestampColumn
+	jsonColumn // nested structs, slices and maps are stored as JSON strings
+)
+
+// deltaTypes maps each column kind to the name of its type in a Delta Lake schema
+var deltaTypes = map[columnKind]string{
+	booleanColumn:      "boolean",
+	integerColumn:      "integer",
+	longColumn:         "long",
+	unsignedLongColumn: "decimal(20,0)",
+	doubleColumn:       "double",
+	stringColumn:       "string",
+	timestampColumn:    "timestamp",
+	jsonColumn:         "string",
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// column is a top level field of an output struct, or a derived column that is added to the fields
+type column struct {
+	name    string
+	field   int // index of the struct field, or of the value of the derived column
+	kind    columnKind
+	derived bool
+}
+
+// tableRow is a row that is written to a table: an output struct and the values of its derived columns
+type tableRow struct {
+	value   reflect.Value
+	derived []interface{}
+}
+
+// value returns the value that the row has in the column
+func (col column) value(row tableRow) reflect.Value {
+	if col.derived {
+		return reflect.ValueOf(row.derived[col.field])
+	}
+
+	return row.value.Field(col.field)
+}
+
+/*
+	columnsOf derives the columns of a table from an output struct, like the ones in schema.go. Column names are taken from the json tags,
+	so that the tables match the JSON output. Delta Lake has no unsigned types, so unsigned 32 bit integers are stored as longs and unsigned
+	64 bit integers as decimals.
+*/
+func columnsOf(rowType reflect.Type) ([]column, error) {
+	if rowType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("rows have to be structs, not %s", rowType)
+	}
+
+	columns := []column{}
+	for _, field := range utils.JSONFields(rowType) {
+		columns = append(columns, column{name: field.Name, field: field.Index, kind: kindOf(field.Type)})
+	}
+
+	return columns, nil
+}
+
+// derivedColumnsOf returns the columns of the derived columns with the names, whose types are the types of the values
+func derivedColumnsOf(names []string, values []interface{}) ([]column, error) {
+	columns := []column{}
+	for i, name := range names {
+		if name == partitionName {
+			return nil, fmt.Errorf("derived column %s has the name of the partition column", name)
+		}
+
+		if values[i] == nil {
+			return nil, fmt.Errorf("derived column %s has no type", name)
+		}
+
+		columns = append(columns, column{name: name, field: i, kind: kindOf(reflect.TypeOf(values[i])), derived: true})
+	}
+
+	return columns, nil
+}
+
+func kindOf(fieldType reflect.Type) columnKind {
+	if fieldType == timeType {
+		return timestampColumn
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		return booleanColumn
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return integerColumn
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return longColumn
+	case reflect.Uint, reflect.Uint64:
+		return unsignedLongColumn
+	case reflect.Float32, reflect.Float64:
+		return doubleColumn
+	case reflect.String:
+		return stringColumn
+	default:
+		return jsonColumn
+	}
+}
+
+// partitionColumn returns the index of the column that the table is partitioned by, which is the first timestamp column. If there is no
+// timestamp column, then the table is not partitioned and -1 is returned
+func partitionColumn(columns []column) int {
+	for i, col := range columns {
+		if col.kind == timestampColumn {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// closeDate returns the value of the close_date partition of the row
+func closeDate(row tableRow, col column) string {
+	return col.value(row).Interface().(time.Time).UTC().Format("2006-01-02")
+}
+
+// jsonValue returns the JSON encoding of a nested value
+func jsonValue(value reflect.Value) ([]byte, error) {
+	return json.Marshal(value.Interface())
+}
+
+type schemaField struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Nullable bool              `json:"nullable"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type schemaStruct struct {
+	Type   string        `json:"type"`
+	Fields []schemaField `json:"fields"`
+}
+
+// schemaString returns the schema of the table in the format of a Delta Lake metaData action. The partition column is a date column
+// that is appended to the columns of the row
+func schemaString(columns []column, partitioned bool) (string, error) {
+	schema := schemaStruct{Type: "struct", Fields: []schemaField{}}
+	for _, col := range columns {
+		schema.Fields = append(schema.Fields, schemaField{Name: col.name, Type: deltaTypes[col.kind], Nullable: false, Metadata: map[string]string{}})
+	}
+
+	if partitioned {
+		schema.Fields = append(schema.Fields, schemaField{Name: partitionName, Type: "date", Nullable: true, Metadata: map[string]string{}})
+	}
+
+	marshalled, err := json.Marshal(schema)
+	return string(marshalled), err
+}
@@ -0,0 +1,117 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/fraugster/parquet-go/parquet"
+	"github.com/fraugster/parquet-go/parquetschema"
+)
+
+// unsignedLongLength is the number of bytes of the fixed length byte arrays that hold the decimal(20,0) values of unsigned long columns
+const unsignedLongLength = 9
+
+// parquetTypes maps each column kind to the type and annotation of its column in a parquet schema
+var parquetTypes = map[columnKind]string{
+	booleanColumn:      "boolean %s",
+	integerColumn:      "int32 %s",
+	longColumn:         "int64 %s",
+	unsignedLongColumn: fmt.Sprintf("fixed_len_byte_array(%d) %%s (DECIMAL(20, 0))", unsignedLongLength),
+	doubleColumn:       "double %s",
+	stringColumn:       "binary %s (STRING)",
+	timestampColumn:    "int64 %s (TIMESTAMP(MICROS, true))",
+	jsonColumn:         "binary %s (STRING)",
+}
+
+// parquetSchema returns the parquet schema of the columns, in which every column is required
+func parquetSchema(columns []column) (*parquetschema.SchemaDefinition, error) {
+	var schema strings.Builder
+	schema.WriteString("message schema {\n")
+	for _, col := range columns {
+		fmt.Fprintf(&schema, "required "+parquetTypes[col.kind]+";\n", col.name)
+	}
+
+	schema.WriteString("}\n")
+	return parquetschema.ParseSchemaDefinition(schema.String())
+}
+
+// encodeParquet encodes the rows as a snappy compressed parquet file with a single row group
+func encodeParquet(columns []column, rows []tableRow) ([]byte, error) {
+	schema, err := parquetSchema(columns)
+	if err != nil {
+		return nil, fmt.Errorf("could not create parquet schema: %v", err)
+	}
+
+	var file bytes.Buffer
+	writer := goparquet.NewFileWriter(&file, goparquet.WithCompressionCodec(parquet.CompressionCodec_SNAPPY), goparquet.WithCreator("stellar-etl"))
+	if err := writer.SetSchemaDefinition(schema); err != nil {
+		return nil, fmt.Errorf("could not set parquet schema: %v", err)
+	}
+
+	for _, row := range rows {
+		record, err := parquetRecord(columns, row)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writer.AddData(record); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return file.Bytes(), nil
+}
+
+// parquetRecord returns the values that the row has in the columns, keyed by the names of the columns
+func parquetRecord(columns []column, row tableRow) (map[string]interface{}, error) {
+	record := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		value := col.value(row)
+		switch col.kind {
+		case booleanColumn:
+			record[col.name] = value.Bool()
+		case integerColumn:
+			record[col.name] = int32(integerValue(value))
+		case longColumn:
+			record[col.name] = integerValue(value)
+		case unsignedLongColumn:
+			// The decimal is the big endian two's complement of the value, so the first byte is always 0
+			encoded := make([]byte, unsignedLongLength)
+			binary.BigEndian.PutUint64(encoded[1:], value.Uint())
+			record[col.name] = encoded
+		case doubleColumn:
+			record[col.name] = value.Float()
+		case timestampColumn:
+			record[col.name] = value.Interface().(time.Time).UnixNano() / int64(time.Microsecond)
+		case stringColumn:
+			record[col.name] = []byte(value.String())
+		case jsonColumn:
+			encoded, err := jsonValue(value)
+			if err != nil {
+				return nil, err
+			}
+
+			record[col.name] = encoded
+		}
+	}
+
+	return record, nil
+}
+
+func integerValue(value reflect.Value) int64 {
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return int64(value.Uint())
+	default:
+		return value.Int()
+	}
+}
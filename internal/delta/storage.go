@@ -0,0 +1,199 @@
+package delta
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// storage reads and writes the files of a table. Paths are relative to the root of the table and always use forward slashes
+type storage interface {
+	Read(name string) ([]byte, error)
+	Write(name string, data []byte) error
+	// WriteIfAbsent writes the file only if it does not exist yet, and reports whether it was written
+	WriteIfAbsent(name string, data []byte) (bool, error)
+	// List returns the names of the files in the directory; a missing directory has no files
+	List(dir string) ([]string, error)
+}
+
+// newStorage returns the storage for a table location, which is either a local path or an S3 URL in the form s3://bucket/prefix
+func newStorage(location string) (storage, error) {
+	if strings.HasPrefix(location, "s3://") {
+		bucketAndPrefix := strings.TrimPrefix(location, "s3://")
+		parts := strings.SplitN(bucketAndPrefix, "/", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("S3 location %s does not have a bucket", location)
+		}
+
+		prefix := ""
+		if len(parts) == 2 {
+			prefix = strings.Trim(parts[1], "/")
+		}
+
+		// Credentials and the region are read from the environment and the shared AWS config, like the AWS CLI does
+		sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+		if err != nil {
+			return nil, fmt.Errorf("could not create an AWS session: %v", err)
+		}
+
+		return &s3Storage{client: s3.New(sess), bucket: parts[0], prefix: prefix}, nil
+	}
+
+	root, err := filepath.Abs(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localStorage{root: root}, nil
+}
+
+// localStorage stores a table in a folder on the local file system
+type localStorage struct {
+	root string
+}
+
+func (s *localStorage) path(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+func (s *localStorage) Read(name string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(name))
+}
+
+// writeTemp writes the data to a temporary file in the directory of the file, so that it can be moved into place atomically
+func (s *localStorage) writeTemp(name string, data []byte) (string, error) {
+	dir := filepath.Dir(s.path(name))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+
+	tempFile, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+
+	return tempFile.Name(), nil
+}
+
+func (s *localStorage) Write(name string, data []byte) error {
+	tempPath, err := s.writeTemp(name, data)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, s.path(name))
+}
+
+// WriteIfAbsent links the temporary file to its final path, which fails if the file already exists. This makes commits atomic
+// even if multiple exports write to the same table
+func (s *localStorage) WriteIfAbsent(name string, data []byte) (bool, error) {
+	tempPath, err := s.writeTemp(name, data)
+	if err != nil {
+		return false, err
+	}
+
+	defer os.Remove(tempPath)
+	err = os.Link(tempPath, s.path(name))
+	if os.IsExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *localStorage) List(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(s.path(dir))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, info := range infos {
+		if !info.IsDir() {
+			names = append(names, info.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// s3Storage stores a table under a prefix of an S3 bucket
+type s3Storage struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func (s *s3Storage) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+func (s *s3Storage) Read(name string) ([]byte, error) {
+	output, err := s.client.GetObject(&s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(name))})
+	if err != nil {
+		return nil, err
+	}
+
+	defer output.Body.Close()
+	return ioutil.ReadAll(output.Body)
+}
+
+func (s *s3Storage) Write(name string, data []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(name)), Body: bytes.NewReader(data)})
+	return err
+}
+
+/*
+	WriteIfAbsent puts the object with an If-None-Match: * condition, so that S3 rejects the write if the object already exists. This keeps
+	commits atomic when multiple exports write to the same table, as long as the bucket is on S3 or on a store that supports conditional
+	writes. Stores that ignore the header overwrite the object instead.
+*/
+func (s *s3Storage) WriteIfAbsent(name string, data []byte) (bool, error) {
+	request, _ := s.client.PutObjectRequest(&s3.PutObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(s.key(name)), Body: bytes.NewReader(data)})
+	request.HTTPRequest.Header.Set("If-None-Match", "*")
+	err := request.Send()
+	if requestErr, ok := err.(awserr.RequestFailure); ok && requestErr.StatusCode() == http.StatusPreconditionFailed {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (s *s3Storage) List(dir string) ([]string, error) {
+	dirPrefix := s.key(dir) + "/"
+	names := []string{}
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(s.bucket), Prefix: aws.String(dirPrefix), Delimiter: aws.String("/")},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				names = append(names, strings.TrimPrefix(aws.StringValue(object.Key), dirPrefix))
+			}
+
+			return true
+		})
+
+	return names, err
+}
@@ -0,0 +1,53 @@
+package delta
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3WriteIfAbsent(t *testing.T) {
+	// The server acts like S3 with conditional writes: objects are only put if the request has If-None-Match: * and the key is new
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("If-None-Match") != "*" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := objects[r.URL.Path]; ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		objects[r.URL.Path] = string(body)
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+		MaxRetries:       aws.Int(0),
+	})
+	assert.NoError(t, err)
+
+	storage := &s3Storage{client: s3.New(sess), bucket: "bucket", prefix: "tables/ledgers"}
+	written, err := storage.WriteIfAbsent("_delta_log/00000000000000000000.json", []byte("first"))
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = storage.WriteIfAbsent("_delta_log/00000000000000000000.json", []byte("second"))
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.Equal(t, map[string]string{"/bucket/tables/ledgers/_delta_log/00000000000000000000.json": "first"}, objects)
+}
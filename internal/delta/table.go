@@ -0,0 +1,421 @@
+package delta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+const (
+	logDir             = "_delta_log"
+	partitionName      = "close_date"
+	batchTag           = "stellar_etl_batch"
+	maxCommitAttempts  = 10
+	readerVersion      = 1
+	writerVersion      = 2
+	dataFileExtension  = ".parquet"
+	commitFileNameSize = 20
+)
+
+var commitFilePattern = regexp.MustCompile(`^\d{20}\.json$`)
+
+// The actions of the Delta Lake transaction log that the table reads and writes. Other actions in the log are ignored
+type protocolAction struct {
+	MinReaderVersion int `json:"minReaderVersion"`
+	MinWriterVersion int `json:"minWriterVersion"`
+}
+
+type formatSpec struct {
+	Provider string            `json:"provider"`
+	Options  map[string]string `json:"options"`
+}
+
+type metaDataAction struct {
+	ID               string            `json:"id"`
+	Format           formatSpec        `json:"format"`
+	SchemaString     string            `json:"schemaString"`
+	PartitionColumns []string          `json:"partitionColumns"`
+	Configuration    map[string]string `json:"configuration"`
+	CreatedTime      int64             `json:"createdTime"`
+}
+
+type addAction struct {
+	Path             string            `json:"path"`
+	PartitionValues  map[string]string `json:"partitionValues"`
+	Size             int64             `json:"size"`
+	ModificationTime int64             `json:"modificationTime"`
+	DataChange       bool              `json:"dataChange"`
+	Tags             map[string]string `json:"tags,omitempty"`
+}
+
+type removeAction struct {
+	Path              string `json:"path"`
+	DeletionTimestamp int64  `json:"deletionTimestamp"`
+	DataChange        bool   `json:"dataChange"`
+}
+
+type commitInfo struct {
+	Timestamp           int64             `json:"timestamp"`
+	Operation           string            `json:"operation"`
+	OperationParameters map[string]string `json:"operationParameters"`
+}
+
+type logAction struct {
+	CommitInfo *commitInfo     `json:"commitInfo,omitempty"`
+	Protocol   *protocolAction `json:"protocol,omitempty"`
+	MetaData   *metaDataAction `json:"metaData,omitempty"`
+	Add        *addAction      `json:"add,omitempty"`
+	Remove     *removeAction   `json:"remove,omitempty"`
+}
+
+// snapshot is the state of the table after replaying its transaction log
+type snapshot struct {
+	version  int64 // -1 if the table does not exist yet
+	protocol *protocolAction
+	metaData *metaDataAction
+	files    map[string]addAction
+}
+
+// apply replays an action of the transaction log on the snapshot
+func (s *snapshot) apply(action logAction) {
+	switch {
+	case action.Protocol != nil:
+		s.protocol = action.Protocol
+	case action.MetaData != nil:
+		s.metaData = action.MetaData
+	case action.Add != nil:
+		s.files[action.Add.Path] = *action.Add
+	case action.Remove != nil:
+		delete(s.files, action.Remove.Path)
+	}
+}
+
+/*
+	Table is a Delta Lake table that export batches are committed to. Each batch is written as one parquet data file per close date,
+	and the files are added to the table in a single commit to its transaction log. The data files of a batch are tagged with the ledger
+	range of the batch, so committing the same batch again atomically replaces the files from the earlier commit.
+*/
+type Table struct {
+	storage   storage
+	rowType   reflect.Type
+	columns   []column
+	derived   int
+	partition int
+	schema    string
+}
+
+/*
+	OpenTable opens the table at location, which is either a local path or an S3 URL. The schema of the table is derived from the type of
+	exampleRow, which should be one of the output structs from the transform package. The derived columns of an example row with derived
+	columns are added after the columns of the struct, with the types of their values. The table is created with its first commit.
+*/
+func OpenTable(location string, exampleRow interface{}) (*Table, error) {
+	base, names, values := output.SplitRow(exampleRow)
+	rowType := reflect.TypeOf(base)
+	if rowType == nil {
+		return nil, fmt.Errorf("rows have to be structs, not nil")
+	}
+
+	columns, err := columnsOf(rowType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tables are only partitioned by the columns of the struct, so that adding a derived column does not change the partitions
+	partition := partitionColumn(columns)
+	derived, err := derivedColumnsOf(names, values)
+	if err != nil {
+		return nil, err
+	}
+
+	columns = append(columns, derived...)
+	schema, err := schemaString(columns, partition != -1)
+	if err != nil {
+		return nil, err
+	}
+
+	tableStorage, err := newStorage(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Table{storage: tableStorage, rowType: rowType, columns: columns, derived: len(derived), partition: partition, schema: schema}, nil
+}
+
+// CommitBatch writes the rows of the batch [batchStart, batchEnd] to the table, replacing any files that an earlier commit of the same batch added
+func (t *Table) CommitBatch(rows []interface{}, batchStart, batchEnd uint32) error {
+	batch := fmt.Sprintf("%d-%d", batchStart, batchEnd)
+	adds, err := t.writeDataFiles(rows, batch)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < maxCommitAttempts; attempt++ {
+		current, err := t.readSnapshot()
+		if err != nil {
+			return err
+		}
+
+		actions, err := t.commitActions(current, batch, adds)
+		if err != nil {
+			return err
+		}
+
+		// Nothing changes if the batch is empty and was never committed before
+		if len(actions) == 1 {
+			return nil
+		}
+
+		var commit bytes.Buffer
+		for _, action := range actions {
+			encoded, err := json.Marshal(action)
+			if err != nil {
+				return err
+			}
+
+			commit.Write(encoded)
+			commit.WriteString("\n")
+		}
+
+		written, err := t.storage.WriteIfAbsent(commitFileName(current.version+1), commit.Bytes())
+		if err != nil {
+			return fmt.Errorf("could not write commit %d: %v", current.version+1, err)
+		}
+
+		if !written {
+			continue
+		}
+
+		// The snapshot that was read is only used by this attempt, so the commit can be replayed on it to get the snapshot to checkpoint
+		current.version++
+		if current.version == 0 || current.version%checkpointInterval != 0 {
+			return nil
+		}
+
+		for _, action := range actions {
+			current.apply(action)
+		}
+
+		if err := t.writeCheckpoint(current); err != nil {
+			return fmt.Errorf("committed batch %s as version %d, but %v", batch, current.version, err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("could not commit batch %s after %d attempts; other exports kept committing to the table", batch, maxCommitAttempts)
+}
+
+// commitActions returns the actions that commit the batch on top of the snapshot. The first action is always the commit info
+func (t *Table) commitActions(current snapshot, batch string, adds []addAction) ([]logAction, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	actions := []logAction{{CommitInfo: &commitInfo{
+		Timestamp:           now,
+		Operation:           "WRITE",
+		OperationParameters: map[string]string{"mode": "Append", "batch": batch},
+	}}}
+
+	if current.metaData == nil {
+		partitionColumns := []string{}
+		if t.partition != -1 {
+			partitionColumns = append(partitionColumns, partitionName)
+		}
+
+		id, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions,
+			logAction{Protocol: &protocolAction{MinReaderVersion: readerVersion, MinWriterVersion: writerVersion}},
+			logAction{MetaData: &metaDataAction{
+				ID:               id,
+				Format:           formatSpec{Provider: "parquet", Options: map[string]string{}},
+				SchemaString:     t.schema,
+				PartitionColumns: partitionColumns,
+				Configuration:    map[string]string{},
+				CreatedTime:      now,
+			}})
+	} else if current.metaData.SchemaString != t.schema {
+		return nil, fmt.Errorf("the schema of the table does not match the schema of the exported data")
+	}
+
+	replaced := []string{}
+	for path, file := range current.files {
+		if file.Tags[batchTag] == batch {
+			replaced = append(replaced, path)
+		}
+	}
+
+	sort.Strings(replaced)
+	for _, path := range replaced {
+		actions = append(actions, logAction{Remove: &removeAction{Path: path, DeletionTimestamp: now, DataChange: true}})
+	}
+
+	for i := range adds {
+		actions = append(actions, logAction{Add: &adds[i]})
+	}
+
+	return actions, nil
+}
+
+// writeDataFiles writes one parquet file for each close date in the rows, and returns the add actions for the files
+func (t *Table) writeDataFiles(rows []interface{}, batch string) ([]addAction, error) {
+	partitions := map[string][]tableRow{}
+	for _, row := range rows {
+		base, _, values := output.SplitRow(row)
+		if reflect.TypeOf(base) != t.rowType {
+			return nil, fmt.Errorf("row of type %T cannot be written to a table of %s", base, t.rowType)
+		}
+
+		if len(values) != t.derived {
+			return nil, fmt.Errorf("row with %d derived columns cannot be written to a table with %d", len(values), t.derived)
+		}
+
+		value := tableRow{value: reflect.ValueOf(base), derived: values}
+		for _, col := range t.columns[len(t.columns)-t.derived:] {
+			if values[col.field] == nil || kindOf(reflect.TypeOf(values[col.field])) != col.kind {
+				return nil, fmt.Errorf("derived column %s has a value of type %T, which does not match its %s column", col.name, values[col.field], deltaTypes[col.kind])
+			}
+		}
+
+		date := ""
+		if t.partition != -1 {
+			date = closeDate(value, t.columns[t.partition])
+		}
+
+		partitions[date] = append(partitions[date], value)
+	}
+
+	dates := []string{}
+	for date := range partitions {
+		dates = append(dates, date)
+	}
+
+	sort.Strings(dates)
+	adds := []addAction{}
+	for _, date := range dates {
+		data, err := encodeParquet(t.columns, partitions[date])
+		if err != nil {
+			return nil, fmt.Errorf("could not encode batch %s: %v", batch, err)
+		}
+
+		id, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		// File names are unique, so replacing a batch never overwrites a file that readers of an older version of the table still use
+		path := fmt.Sprintf("part-%s-%s%s", batch, id, dataFileExtension)
+		partitionValues := map[string]string{}
+		if t.partition != -1 {
+			path = fmt.Sprintf("%s=%s/%s", partitionName, date, path)
+			partitionValues[partitionName] = date
+		}
+
+		if err := t.storage.Write(path, data); err != nil {
+			return nil, fmt.Errorf("could not write data file %s: %v", path, err)
+		}
+
+		adds = append(adds, addAction{
+			Path:             path,
+			PartitionValues:  partitionValues,
+			Size:             int64(len(data)),
+			ModificationTime: time.Now().UnixNano() / int64(time.Millisecond),
+			DataChange:       true,
+			Tags:             map[string]string{batchTag: batch},
+		})
+	}
+
+	return adds, nil
+}
+
+/*
+	readSnapshot reads the most recent checkpoint that the _last_checkpoint file points to, and replays the commits after it. Tables
+	without checkpoints are replayed from their first commit. Commits before the checkpoint are not read, so they can be cleaned up.
+*/
+func (t *Table) readSnapshot() (snapshot, error) {
+	current := snapshot{version: -1, files: map[string]addAction{}}
+	names, err := t.storage.List(logDir)
+	if err != nil {
+		return current, fmt.Errorf("could not list the transaction log: %v", err)
+	}
+
+	commits := []string{}
+	checkpointed := false
+	for _, name := range names {
+		if commitFilePattern.MatchString(name) {
+			commits = append(commits, name)
+		} else if name == lastCheckpointName {
+			checkpointed = true
+		}
+	}
+
+	if checkpointed {
+		current, err = t.readCheckpoint()
+		if err != nil {
+			return current, err
+		}
+	}
+
+	sort.Strings(commits)
+	for _, name := range commits {
+		version, _ := strconv.ParseInt(name[:commitFileNameSize], 10, 64)
+		if version <= current.version {
+			continue
+		}
+
+		if version != current.version+1 {
+			return current, fmt.Errorf("the transaction log is missing commit %d", current.version+1)
+		}
+
+		data, err := t.storage.Read(commitFileName(version))
+		if err != nil {
+			return current, fmt.Errorf("could not read commit %d: %v", version, err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+		for scanner.Scan() {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+
+			var action logAction
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				return current, fmt.Errorf("could not decode commit %d: %v", version, err)
+			}
+
+			current.apply(action)
+		}
+
+		current.version = version
+	}
+
+	return current, nil
+}
+
+func commitFileName(version int64) string {
+	return fmt.Sprintf("%s/%0*d.json", logDir, commitFileNameSize, version)
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]), nil
+}
@@ -0,0 +1,345 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	goparquet "github.com/fraugster/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type testRow struct {
+	Sequence uint32    `json:"sequence"`
+	ClosedAt time.Time `json:"closed_at"`
+	Amount   float64   `json:"amount"`
+	Count    int32     `json:"count"`
+	Deleted  bool      `json:"deleted"`
+	Hash     string    `json:"hash"`
+	Path     []string  `json:"path"`
+	internal int
+}
+
+type unpartitionedRow struct {
+	AccountID string `json:"account_id"`
+	Balance   int64  `json:"balance"`
+}
+
+func makeTestRows(closeTimes ...time.Time) []interface{} {
+	rows := []interface{}{}
+	for i, closeTime := range closeTimes {
+		rows = append(rows, testRow{Sequence: uint32(i + 1), ClosedAt: closeTime, Amount: 1.5, Count: 2, Deleted: i%2 == 0, Hash: "abc", Path: []string{"native"}})
+	}
+
+	return rows
+}
+
+func readCommit(t *testing.T, folder string, version int64) []logAction {
+	data, err := ioutil.ReadFile(filepath.Join(folder, filepath.FromSlash(commitFileName(version))))
+	assert.NoError(t, err)
+
+	actions := []logAction{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var action logAction
+		assert.NoError(t, json.Unmarshal([]byte(line), &action))
+		actions = append(actions, action)
+	}
+
+	return actions
+}
+
+func addedPaths(actions []logAction) []string {
+	paths := []string{}
+	for _, action := range actions {
+		if action.Add != nil {
+			paths = append(paths, action.Add.Path)
+		}
+	}
+
+	return paths
+}
+
+func removedPaths(actions []logAction) []string {
+	paths := []string{}
+	for _, action := range actions {
+		if action.Remove != nil {
+			paths = append(paths, action.Remove.Path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func TestColumnsOf(t *testing.T) {
+	columns, err := columnsOf(reflect.TypeOf(testRow{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []column{
+		{name: "sequence", field: 0, kind: longColumn},
+		{name: "closed_at", field: 1, kind: timestampColumn},
+		{name: "amount", field: 2, kind: doubleColumn},
+		{name: "count", field: 3, kind: integerColumn},
+		{name: "deleted", field: 4, kind: booleanColumn},
+		{name: "hash", field: 5, kind: stringColumn},
+		{name: "path", field: 6, kind: jsonColumn},
+	}, columns)
+	assert.Equal(t, 1, partitionColumn(columns))
+
+	schema, err := schemaString(columns[:2], true)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"struct","fields":[{"name":"sequence","type":"long","nullable":false,"metadata":{}},`+
+		`{"name":"closed_at","type":"timestamp","nullable":false,"metadata":{}},{"name":"close_date","type":"date","nullable":true,"metadata":{}}]}`, schema)
+
+	_, err = columnsOf(reflect.TypeOf(1))
+	assert.EqualError(t, err, "rows have to be structs, not int")
+}
+
+// numericRow holds the values at the edges of the integer types, which the parquet files have to keep exactly
+type numericRow struct {
+	Sequence  uint32    `json:"sequence"`
+	Fee       uint64    `json:"fee"`
+	Balance   int64     `json:"balance"`
+	Flags     uint16    `json:"flags"`
+	ClosedAt  time.Time `json:"closed_at"`
+	Signers   []string  `json:"signers"`
+	Sponsored bool      `json:"sponsored"`
+}
+
+func TestEncodeParquet(t *testing.T) {
+	columns, err := columnsOf(reflect.TypeOf(numericRow{}))
+	assert.NoError(t, err)
+	assert.Equal(t, unsignedLongColumn, columns[1].kind)
+
+	closedAt := time.Date(2020, 12, 1, 23, 59, 55, 123456000, time.UTC)
+	rows := []tableRow{
+		{value: reflect.ValueOf(numericRow{Sequence: math.MaxUint32, Fee: math.MaxUint64, Balance: math.MinInt64, Flags: math.MaxUint16, ClosedAt: closedAt, Signers: []string{"GABC"}, Sponsored: true})},
+		{value: reflect.ValueOf(numericRow{Sequence: 1 << 31, Fee: 1 << 63, Balance: math.MaxInt64, ClosedAt: closedAt, Signers: []string{}})},
+		{value: reflect.ValueOf(numericRow{})},
+	}
+
+	data, err := encodeParquet(columns, rows)
+	assert.NoError(t, err)
+
+	reader, err := goparquet.NewFileReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), reader.NumRows())
+
+	// Unsigned longs are decimal(20,0) values, which are stored as big endian two's complement integers
+	maxFee := []byte{0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	highFee := []byte{0, 0x80, 0, 0, 0, 0, 0, 0, 0}
+	zeroFee := make([]byte, unsignedLongLength)
+
+	expected := []map[string]interface{}{
+		{"sequence": int64(math.MaxUint32), "fee": maxFee, "balance": int64(math.MinInt64), "flags": int32(math.MaxUint16),
+			"closed_at": closedAt.UnixNano() / 1000, "signers": []byte(`["GABC"]`), "sponsored": true},
+		{"sequence": int64(1 << 31), "fee": highFee, "balance": int64(math.MaxInt64), "flags": int32(0),
+			"closed_at": closedAt.UnixNano() / 1000, "signers": []byte(`[]`), "sponsored": false},
+		{"sequence": int64(0), "fee": zeroFee, "balance": int64(0), "flags": int32(0),
+			"closed_at": time.Time{}.UnixNano() / 1000, "signers": []byte(`null`), "sponsored": false},
+	}
+
+	for _, want := range expected {
+		row, err := reader.NextRow()
+		assert.NoError(t, err)
+		assert.Equal(t, want, row)
+	}
+
+	_, err = reader.NextRow()
+	assert.Equal(t, io.EOF, err)
+
+	fee := reader.GetColumnByName("fee").Element()
+	assert.Equal(t, int32(20), fee.GetPrecision())
+	assert.Equal(t, int32(0), fee.GetScale())
+	assert.True(t, reader.GetColumnByName("closed_at").Element().GetLogicalType().IsSetTIMESTAMP())
+}
+
+func TestCommitBatch(t *testing.T) {
+	folder, err := ioutil.TempDir("", "delta-table")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	table, err := OpenTable(folder, testRow{})
+	assert.NoError(t, err)
+
+	firstDay := time.Date(2020, 12, 1, 23, 59, 55, 0, time.UTC)
+	secondDay := time.Date(2020, 12, 2, 0, 0, 1, 0, time.UTC)
+	assert.NoError(t, table.CommitBatch(makeTestRows(firstDay, firstDay, secondDay), 100, 163))
+
+	first := readCommit(t, folder, 0)
+	assert.NotNil(t, first[0].CommitInfo)
+	assert.Equal(t, &protocolAction{MinReaderVersion: 1, MinWriterVersion: 2}, first[1].Protocol)
+	assert.Equal(t, []string{"close_date"}, first[2].MetaData.PartitionColumns)
+	assert.Equal(t, table.schema, first[2].MetaData.SchemaString)
+
+	firstPaths := addedPaths(first)
+	assert.Len(t, firstPaths, 2)
+	assert.True(t, strings.HasPrefix(firstPaths[0], "close_date=2020-12-01/part-100-163-"))
+	assert.True(t, strings.HasPrefix(firstPaths[1], "close_date=2020-12-02/part-100-163-"))
+	assert.Equal(t, map[string]string{"close_date": "2020-12-01"}, first[3].Add.PartitionValues)
+	assert.Equal(t, map[string]string{batchTag: "100-163"}, first[3].Add.Tags)
+	for _, path := range firstPaths {
+		info, err := os.Stat(filepath.Join(folder, filepath.FromSlash(path)))
+		assert.NoError(t, err)
+		assert.True(t, info.Size() > 0)
+	}
+
+	// Committing the same batch again replaces its files
+	assert.NoError(t, table.CommitBatch(makeTestRows(secondDay), 100, 163))
+	second := readCommit(t, folder, 1)
+	sort.Strings(firstPaths)
+	assert.Equal(t, firstPaths, removedPaths(second))
+	assert.Len(t, addedPaths(second), 1)
+
+	// Other batches are left untouched
+	assert.NoError(t, table.CommitBatch(makeTestRows(secondDay), 164, 227))
+	third := readCommit(t, folder, 2)
+	assert.Empty(t, removedPaths(third))
+
+	current, err := table.readSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), current.version)
+	assert.Len(t, current.files, 2)
+
+	// An empty batch that was never committed does not create a commit
+	assert.NoError(t, table.CommitBatch([]interface{}{}, 228, 291))
+	current, err = table.readSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), current.version)
+
+	assert.EqualError(t, table.CommitBatch([]interface{}{unpartitionedRow{}}, 228, 291), "row of type delta.unpartitionedRow cannot be written to a table of delta.testRow")
+
+	otherTable, err := OpenTable(folder, unpartitionedRow{})
+	assert.NoError(t, err)
+	assert.EqualError(t, otherTable.CommitBatch([]interface{}{unpartitionedRow{}}, 100, 163), "the schema of the table does not match the schema of the exported data")
+}
+
+func TestCommitUnpartitionedBatch(t *testing.T) {
+	folder, err := ioutil.TempDir("", "delta-table")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	table, err := OpenTable(folder, unpartitionedRow{})
+	assert.NoError(t, err)
+	assert.NoError(t, table.CommitBatch([]interface{}{unpartitionedRow{AccountID: "GABC", Balance: 10}}, 1, 500))
+
+	first := readCommit(t, folder, 0)
+	assert.Equal(t, []string{}, first[2].MetaData.PartitionColumns)
+	paths := addedPaths(first)
+	assert.Len(t, paths, 1)
+	assert.True(t, strings.HasPrefix(paths[0], "part-1-500-"))
+	assert.Equal(t, map[string]string{}, first[3].Add.PartitionValues)
+}
+
+// derivedRow adds derived columns to a row, like the rows of the expr package
+type derivedRow struct {
+	row    interface{}
+	names  []string
+	values []interface{}
+}
+
+func (r derivedRow) BaseRow() interface{} {
+	return r.row
+}
+
+func (r derivedRow) DerivedColumns() ([]string, []interface{}) {
+	return r.names, r.values
+}
+
+func TestCommitDerivedColumns(t *testing.T) {
+	folder, err := ioutil.TempDir("", "delta-table")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	names := []string{"rich", "funded_at"}
+	table, err := OpenTable(folder, derivedRow{unpartitionedRow{}, names, []interface{}{false, time.Time{}}})
+	assert.NoError(t, err)
+
+	// A derived timestamp does not partition the table
+	assert.Equal(t, -1, table.partition)
+	assert.Equal(t, `{"type":"struct","fields":[{"name":"account_id","type":"string","nullable":false,"metadata":{}},`+
+		`{"name":"balance","type":"long","nullable":false,"metadata":{}},{"name":"rich","type":"boolean","nullable":false,"metadata":{}},`+
+		`{"name":"funded_at","type":"timestamp","nullable":false,"metadata":{}}]}`, table.schema)
+
+	fundedAt := time.Date(2020, 12, 1, 10, 30, 15, 0, time.UTC)
+	row := derivedRow{unpartitionedRow{AccountID: "GABC", Balance: 10}, names, []interface{}{true, fundedAt}}
+	assert.NoError(t, table.CommitBatch([]interface{}{row}, 1, 500))
+
+	paths := addedPaths(readCommit(t, folder, 0))
+	assert.Len(t, paths, 1)
+	data, err := ioutil.ReadFile(filepath.Join(folder, filepath.FromSlash(paths[0])))
+	assert.NoError(t, err)
+	reader, err := goparquet.NewFileReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	record, err := reader.NextRow()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"account_id": []byte("GABC"), "balance": int64(10), "rich": true, "funded_at": fundedAt.UnixNano() / 1000}, record)
+
+	assert.EqualError(t, table.CommitBatch([]interface{}{unpartitionedRow{}}, 1, 500), "row with 0 derived columns cannot be written to a table with 2")
+	assert.EqualError(t, table.CommitBatch([]interface{}{derivedRow{unpartitionedRow{}, names, []interface{}{"yes", fundedAt}}}, 1, 500),
+		"derived column rich has a value of type string, which does not match its boolean column")
+
+	_, err = OpenTable(folder, derivedRow{testRow{}, []string{"close_date"}, []interface{}{""}})
+	assert.EqualError(t, err, "derived column close_date has the name of the partition column")
+}
+
+func TestCheckpoint(t *testing.T) {
+	folder, err := ioutil.TempDir("", "delta-table")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	table, err := OpenTable(folder, testRow{})
+	assert.NoError(t, err)
+
+	// Every other commit replaces the batch of the commit before it, so that the checkpoint has to leave out the replaced files
+	day := time.Date(2020, 12, 1, 12, 0, 0, 0, time.UTC)
+	for i := uint32(0); i <= checkpointInterval+1; i++ {
+		batchStart := 64 * (i / 2)
+		assert.NoError(t, table.CommitBatch(makeTestRows(day, day.AddDate(0, 0, int(i))), batchStart, batchStart+63))
+	}
+
+	lastCheckpointPath := filepath.Join(folder, logDir, lastCheckpointName)
+	data, err := ioutil.ReadFile(lastCheckpointPath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":10,"size":14}`, string(data))
+
+	checkpointed, err := table.readSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(checkpointInterval+1), checkpointed.version)
+
+	// The snapshot read from the checkpoint matches the snapshot replayed from every commit
+	assert.NoError(t, os.Remove(lastCheckpointPath))
+	replayed, err := table.readSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, replayed, checkpointed)
+	assert.Len(t, replayed.files, 12)
+	assert.NoError(t, ioutil.WriteFile(lastCheckpointPath, data, 0644))
+
+	// Commits before the checkpoint are not read, so they can be cleaned up
+	for version := int64(0); version < checkpointInterval; version++ {
+		assert.NoError(t, os.Remove(filepath.Join(folder, filepath.FromSlash(commitFileName(version)))))
+	}
+
+	cleaned, err := table.readSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, replayed, cleaned)
+
+	assert.NoError(t, table.CommitBatch(makeTestRows(day), 0, 63))
+	current, err := table.readSnapshot()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(checkpointInterval+2), current.version)
+	assert.Len(t, current.files, 11)
+
+	// Without the checkpoint, the log is missing the commits before it
+	assert.NoError(t, os.Remove(lastCheckpointPath))
+	_, err = table.readSnapshot()
+	assert.EqualError(t, err, "the transaction log is missing commit 0")
+}
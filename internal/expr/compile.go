@@ -0,0 +1,683 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// Type is the type of a value in an expression
+type Type int
+
+const (
+	BoolType Type = iota
+	IntType
+	FloatType
+	StringType
+	TimeType
+)
+
+var typeNames = map[Type]string{
+	BoolType:   "bool",
+	IntType:    "int",
+	FloatType:  "float",
+	StringType: "string",
+	TimeType:   "time",
+}
+
+func (t Type) String() string {
+	return typeNames[t]
+}
+
+func (t Type) numeric() bool {
+	return t == IntType || t == FloatType
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// column is a column that expressions can refer to: either a top level field of the output struct, or a derived column defined before the expression
+type column struct {
+	typ     Type
+	field   int // index of the struct field, or -1 for derived columns
+	derived int // index of the derived column
+	// unsupported is set for fields whose Go type has no expression type, like nested structs; referring to them is an error
+	unsupported reflect.Type
+}
+
+/*
+	schemaOf derives the columns of an output struct. Column names are taken from the json tags, so that expressions use the names of the
+	output. Integers are int64s in expressions, so unsigned 64 bit fields, like the hashed ids of the markets, are not supported: half of
+	their values do not fit.
+*/
+func schemaOf(rowType reflect.Type) (map[string]column, error) {
+	if rowType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("rows have to be structs, not %s", rowType)
+	}
+
+	schema := map[string]column{}
+	for _, field := range utils.JSONFields(rowType) {
+		col := column{field: field.Index}
+		switch kind := field.Type.Kind(); {
+		case field.Type == timeType:
+			col.typ = TimeType
+		case kind == reflect.Bool:
+			col.typ = BoolType
+		case kind == reflect.Uint || kind == reflect.Uint64:
+			col.unsupported = field.Type
+		case kind >= reflect.Int && kind <= reflect.Uint32:
+			col.typ = IntType
+		case kind == reflect.Float32 || kind == reflect.Float64:
+			col.typ = FloatType
+		case kind == reflect.String:
+			col.typ = StringType
+		default:
+			col.unsupported = field.Type
+		}
+
+		schema[field.Name] = col
+	}
+
+	return schema, nil
+}
+
+// env holds the row that an expression is evaluated on, and the values of the derived columns computed so far
+type env struct {
+	row     reflect.Value
+	derived []interface{}
+}
+
+// evaluator computes the value of an expression. Values are bools, int64s, float64s, strings, or time.Times, according to the type of the expression
+type evaluator func(e *env) (interface{}, error)
+
+// compiled is a type checked expression. Constant expressions do not refer to any columns, so they can be evaluated without a row
+type compiled struct {
+	typ      Type
+	eval     evaluator
+	constant bool
+}
+
+// compiler type checks syntax trees against the columns of a dataset, and turns them into evaluators
+type compiler struct {
+	schema map[string]column
+	lists  map[string][]interface{}
+}
+
+func errorAt(n node, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), n.position())
+}
+
+func (c *compiler) compile(n node) (compiled, error) {
+	switch n := n.(type) {
+	case literalNode:
+		return c.constant(n.value), nil
+	case identNode:
+		return c.compileColumn(n)
+	case unaryNode:
+		return c.compileUnary(n)
+	case binaryNode:
+		return c.compileBinary(n)
+	case callNode:
+		return c.compileCall(n)
+	case inNode:
+		return c.compileIn(n)
+	default:
+		return compiled{}, fmt.Errorf("unknown expression node %T", n)
+	}
+}
+
+func (c *compiler) constant(value interface{}) compiled {
+	var typ Type
+	switch value.(type) {
+	case bool:
+		typ = BoolType
+	case int64:
+		typ = IntType
+	case float64:
+		typ = FloatType
+	case string:
+		typ = StringType
+	case time.Time:
+		typ = TimeType
+	}
+
+	return compiled{typ: typ, constant: true, eval: func(*env) (interface{}, error) { return value, nil }}
+}
+
+// fold evaluates constant expressions once, so that they are not recomputed for every row
+func fold(result compiled) (compiled, error) {
+	if !result.constant {
+		return result, nil
+	}
+
+	value, err := result.eval(nil)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	result.eval = func(*env) (interface{}, error) { return value, nil }
+	return result, nil
+}
+
+func (c *compiler) compileColumn(n identNode) (compiled, error) {
+	col, ok := c.schema[n.name]
+	if !ok {
+		return compiled{}, errorAt(n, "unknown column %s", n.name)
+	}
+
+	if col.unsupported != nil {
+		return compiled{}, errorAt(n, "column %s has type %s, which expressions do not support", n.name, col.unsupported)
+	}
+
+	if col.field < 0 {
+		return compiled{typ: col.typ, eval: func(e *env) (interface{}, error) { return e.derived[col.derived], nil }}, nil
+	}
+
+	var read func(field reflect.Value) interface{}
+	switch col.typ {
+	case BoolType:
+		read = func(field reflect.Value) interface{} { return field.Bool() }
+	case IntType:
+		read = func(field reflect.Value) interface{} {
+			if field.Kind() >= reflect.Uint {
+				return int64(field.Uint())
+			}
+
+			return field.Int()
+		}
+	case FloatType:
+		read = func(field reflect.Value) interface{} { return field.Float() }
+	case StringType:
+		read = func(field reflect.Value) interface{} { return field.String() }
+	case TimeType:
+		read = func(field reflect.Value) interface{} { return field.Interface().(time.Time) }
+	}
+
+	return compiled{typ: col.typ, eval: func(e *env) (interface{}, error) { return read(e.row.Field(col.field)), nil }}, nil
+}
+
+func (c *compiler) compileUnary(n unaryNode) (compiled, error) {
+	operand, err := c.compile(n.operand)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	result := compiled{typ: operand.typ, constant: operand.constant}
+	switch {
+	case n.op == "!" && operand.typ == BoolType:
+		result.eval = func(e *env) (interface{}, error) {
+			value, err := operand.eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			return !value.(bool), nil
+		}
+	case n.op == "-" && operand.typ == IntType:
+		result.eval = func(e *env) (interface{}, error) {
+			value, err := operand.eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			if value.(int64) == math.MinInt64 {
+				return nil, fmt.Errorf("the result of -%d does not fit in an int", value)
+			}
+
+			return -value.(int64), nil
+		}
+	case n.op == "-" && operand.typ == FloatType:
+		result.eval = func(e *env) (interface{}, error) {
+			value, err := operand.eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			return -value.(float64), nil
+		}
+	default:
+		return compiled{}, errorAt(n, "operator %s cannot be applied to %s", n.op, operand.typ)
+	}
+
+	return fold(result)
+}
+
+// operands evaluates both sides of a binary operator
+func operands(left, right compiled, e *env) (interface{}, interface{}, error) {
+	l, err := left.eval(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := right.eval(e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return l, r, nil
+}
+
+func toFloat(value interface{}) float64 {
+	if integer, ok := value.(int64); ok {
+		return float64(integer)
+	}
+
+	return value.(float64)
+}
+
+// coerceTime converts a constant string that is compared with a time into a time, so that filters can be written like closed_at >= "2021-01-01T00:00:00Z"
+func coerceTime(side, other compiled, n node) (compiled, error) {
+	if side.typ != StringType || other.typ != TimeType || !side.constant {
+		return side, nil
+	}
+
+	value, _ := side.eval(nil)
+	parsed, err := time.Parse(time.RFC3339, value.(string))
+	if err != nil {
+		return compiled{}, errorAt(n, "%q is not an RFC 3339 time", value)
+	}
+
+	return compiled{typ: TimeType, constant: true, eval: func(*env) (interface{}, error) { return parsed, nil }}, nil
+}
+
+func (c *compiler) compileBinary(n binaryNode) (compiled, error) {
+	left, err := c.compile(n.left)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	right, err := c.compile(n.right)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	if left, err = coerceTime(left, right, n); err != nil {
+		return compiled{}, err
+	}
+
+	if right, err = coerceTime(right, left, n); err != nil {
+		return compiled{}, err
+	}
+
+	var result compiled
+	switch n.op {
+	case "&&", "||":
+		result, err = compileLogical(n, left, right)
+	case "==", "!=", "<", "<=", ">", ">=":
+		result, err = compileComparison(n, left, right)
+	default:
+		result, err = compileArithmetic(n, left, right)
+	}
+
+	if err != nil {
+		return compiled{}, err
+	}
+
+	result.constant = left.constant && right.constant
+	return fold(result)
+}
+
+// compileLogical compiles && and ||, which only evaluate their right side if the left side does not decide the result
+func compileLogical(n binaryNode, left, right compiled) (compiled, error) {
+	if left.typ != BoolType || right.typ != BoolType {
+		return compiled{}, errorAt(n, "operator %s cannot be applied to %s and %s", n.op, left.typ, right.typ)
+	}
+
+	decisive := n.op == "||"
+	return compiled{typ: BoolType, eval: func(e *env) (interface{}, error) {
+		l, err := left.eval(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if l.(bool) == decisive {
+			return decisive, nil
+		}
+
+		return right.eval(e)
+	}}, nil
+}
+
+// compare returns -1, 0, or 1 as l is less than, equal to, or greater than r. Both values have the same type, or are both numeric
+func compare(l, r interface{}) int {
+	switch l := l.(type) {
+	case string:
+		return strings.Compare(l, r.(string))
+	case time.Time:
+		switch {
+		case l.Before(r.(time.Time)):
+			return -1
+		case l.After(r.(time.Time)):
+			return 1
+		}
+
+		return 0
+	case bool:
+		if l == r.(bool) {
+			return 0
+		}
+
+		return 1
+	}
+
+	if l, ok := l.(int64); ok {
+		if r, ok := r.(int64); ok {
+			switch {
+			case l < r:
+				return -1
+			case l > r:
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	lf, rf := toFloat(l), toFloat(r)
+	switch {
+	case lf < rf:
+		return -1
+	case lf > rf:
+		return 1
+	}
+
+	return 0
+}
+
+func compileComparison(n binaryNode, left, right compiled) (compiled, error) {
+	comparable := left.typ == right.typ || (left.typ.numeric() && right.typ.numeric())
+	ordered := left.typ != BoolType
+	if !comparable || (!ordered && n.op != "==" && n.op != "!=") {
+		return compiled{}, errorAt(n, "operator %s cannot be applied to %s and %s", n.op, left.typ, right.typ)
+	}
+
+	var test func(order int) bool
+	switch n.op {
+	case "==":
+		test = func(order int) bool { return order == 0 }
+	case "!=":
+		test = func(order int) bool { return order != 0 }
+	case "<":
+		test = func(order int) bool { return order < 0 }
+	case "<=":
+		test = func(order int) bool { return order <= 0 }
+	case ">":
+		test = func(order int) bool { return order > 0 }
+	case ">=":
+		test = func(order int) bool { return order >= 0 }
+	}
+
+	return compiled{typ: BoolType, eval: func(e *env) (interface{}, error) {
+		l, r, err := operands(left, right, e)
+		if err != nil {
+			return nil, err
+		}
+
+		return test(compare(l, r)), nil
+	}}, nil
+}
+
+/*
+	compileArithmetic compiles + - * / and %. Integer operands give integer results, except for /, which always divides as floats so that
+	ratios like amount / price_d are not truncated. + also concatenates strings. Results that are not finite, like divisions by zero, and
+	integer results that overflow an int64 are errors, since they cannot be exported.
+*/
+func compileArithmetic(n binaryNode, left, right compiled) (compiled, error) {
+	if n.op == "+" && left.typ == StringType && right.typ == StringType {
+		return compiled{typ: StringType, eval: func(e *env) (interface{}, error) {
+			l, r, err := operands(left, right, e)
+			if err != nil {
+				return nil, err
+			}
+
+			return l.(string) + r.(string), nil
+		}}, nil
+	}
+
+	if !left.typ.numeric() || !right.typ.numeric() || (n.op == "%" && (left.typ != IntType || right.typ != IntType)) {
+		return compiled{}, errorAt(n, "operator %s cannot be applied to %s and %s", n.op, left.typ, right.typ)
+	}
+
+	if n.op != "/" && left.typ == IntType && right.typ == IntType {
+		return compiled{typ: IntType, eval: func(e *env) (interface{}, error) {
+			l, r, err := operands(left, right, e)
+			if err != nil {
+				return nil, err
+			}
+
+			a, b := l.(int64), r.(int64)
+			overflow := fmt.Errorf("the result of %d %s %d does not fit in an int", a, n.op, b)
+			switch n.op {
+			case "+":
+				sum := a + b
+				if (b > 0 && sum < a) || (b < 0 && sum > a) {
+					return nil, overflow
+				}
+
+				return sum, nil
+			case "-":
+				difference := a - b
+				if (b < 0 && difference < a) || (b > 0 && difference > a) {
+					return nil, overflow
+				}
+
+				return difference, nil
+			case "*":
+				product := a * b
+				if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) || (a != 0 && product/a != b) {
+					return nil, overflow
+				}
+
+				return product, nil
+			}
+
+			if b == 0 {
+				return nil, fmt.Errorf("modulo by zero")
+			}
+
+			return a % b, nil
+		}}, nil
+	}
+
+	return compiled{typ: FloatType, eval: func(e *env) (interface{}, error) {
+		l, r, err := operands(left, right, e)
+		if err != nil {
+			return nil, err
+		}
+
+		var result float64
+		a, b := toFloat(l), toFloat(r)
+		switch n.op {
+		case "+":
+			result = a + b
+		case "-":
+			result = a - b
+		case "*":
+			result = a * b
+		case "/":
+			if b == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+
+			result = a / b
+		}
+
+		if math.IsInf(result, 0) || math.IsNaN(result) {
+			return nil, fmt.Errorf("the result of %v %s %v is not a finite number", l, n.op, r)
+		}
+
+		return result, nil
+	}}, nil
+}
+
+// functions are the functions that expressions can call, by name. Each function checks its argument types and returns its result type
+var functions = map[string]func(n callNode, args []compiled) (compiled, error){
+	"lower": stringFunction(strings.ToLower),
+	"upper": stringFunction(strings.ToUpper),
+	"contains": func(n callNode, args []compiled) (compiled, error) {
+		if len(args) != 2 || args[0].typ != StringType || args[1].typ != StringType {
+			return compiled{}, errorAt(n, "contains takes two strings")
+		}
+
+		return compiled{typ: BoolType, eval: func(e *env) (interface{}, error) {
+			s, sub, err := operands(args[0], args[1], e)
+			if err != nil {
+				return nil, err
+			}
+
+			return strings.Contains(s.(string), sub.(string)), nil
+		}}, nil
+	},
+	"float": func(n callNode, args []compiled) (compiled, error) {
+		if len(args) != 1 || !args[0].typ.numeric() {
+			return compiled{}, errorAt(n, "float takes one number")
+		}
+
+		return compiled{typ: FloatType, eval: func(e *env) (interface{}, error) {
+			value, err := args[0].eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			return toFloat(value), nil
+		}}, nil
+	},
+	"int": func(n callNode, args []compiled) (compiled, error) {
+		if len(args) != 1 || !args[0].typ.numeric() {
+			return compiled{}, errorAt(n, "int takes one number")
+		}
+
+		return compiled{typ: IntType, eval: func(e *env) (interface{}, error) {
+			value, err := args[0].eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			if integer, ok := value.(int64); ok {
+				return integer, nil
+			}
+
+			return int64(value.(float64)), nil
+		}}, nil
+	},
+}
+
+func stringFunction(f func(string) string) func(n callNode, args []compiled) (compiled, error) {
+	return func(n callNode, args []compiled) (compiled, error) {
+		if len(args) != 1 || args[0].typ != StringType {
+			return compiled{}, errorAt(n, "%s takes one string", n.name)
+		}
+
+		return compiled{typ: StringType, eval: func(e *env) (interface{}, error) {
+			value, err := args[0].eval(e)
+			if err != nil {
+				return nil, err
+			}
+
+			return f(value.(string)), nil
+		}}, nil
+	}
+}
+
+func (c *compiler) compileCall(n callNode) (compiled, error) {
+	function, ok := functions[n.name]
+	if !ok {
+		return compiled{}, errorAt(n, "unknown function %s", n.name)
+	}
+
+	args := make([]compiled, len(n.args))
+	constant := true
+	for i, arg := range n.args {
+		compiledArg, err := c.compile(arg)
+		if err != nil {
+			return compiled{}, err
+		}
+
+		args[i] = compiledArg
+		constant = constant && compiledArg.constant
+	}
+
+	result, err := function(n, args)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	result.constant = constant
+	return fold(result)
+}
+
+// setKey normalizes a value for lookups in the set of an in expression. Numbers are compared as floats if either side is a float
+func setKey(value interface{}, asFloat bool) interface{} {
+	if asFloat {
+		return toFloat(value)
+	}
+
+	if t, ok := value.(time.Time); ok {
+		return t.UnixNano()
+	}
+
+	return value
+}
+
+func (c *compiler) compileIn(n inNode) (compiled, error) {
+	operand, err := c.compile(n.operand)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	items := []compiled{}
+	if n.listName != "" {
+		values, ok := c.lists[n.listName]
+		if !ok {
+			return compiled{}, errorAt(n, "unknown list %s", n.listName)
+		}
+
+		for _, value := range values {
+			items = append(items, c.constant(value))
+		}
+	} else {
+		for _, item := range n.items {
+			compiledItem, err := c.compile(item)
+			if err != nil {
+				return compiled{}, err
+			}
+
+			if !compiledItem.constant {
+				return compiled{}, errorAt(item, "the items of a list have to be constants")
+			}
+
+			items = append(items, compiledItem)
+		}
+	}
+
+	asFloat := operand.typ == FloatType
+	for i, item := range items {
+		if items[i], err = coerceTime(item, operand, n); err != nil {
+			return compiled{}, err
+		}
+
+		if items[i].typ != operand.typ && !(items[i].typ.numeric() && operand.typ.numeric()) {
+			return compiled{}, errorAt(n, "a %s cannot be in a list of %s", operand.typ, items[i].typ)
+		}
+
+		asFloat = asFloat || items[i].typ == FloatType
+	}
+
+	set := map[interface{}]bool{}
+	for _, item := range items {
+		value, _ := item.eval(nil)
+		set[setKey(value, asFloat)] = true
+	}
+
+	return fold(compiled{typ: BoolType, constant: operand.constant, eval: func(e *env) (interface{}, error) {
+		value, err := operand.eval(e)
+		if err != nil {
+			return nil, err
+		}
+
+		return set[setKey(value, asFloat)], nil
+	}})
+}
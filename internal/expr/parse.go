@@ -0,0 +1,395 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	numberToken
+	stringToken
+	identToken
+	operatorToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	at   int
+}
+
+// operators lists the operators and punctuation of the language. Two character operators come first, so that they are matched before their prefixes
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")", "[", "]", ","}
+
+// tokenize splits an expression into tokens. Strings are quoted with single or double quotes, and backslash escapes the next character
+func tokenize(source string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+
+			tokens = append(tokens, token{numberToken, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+
+			tokens = append(tokens, token{identToken, string(runes[start:i]), start})
+		case r == '"' || r == '\'':
+			start := i
+			var text strings.Builder
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+
+				text.WriteRune(runes[i])
+			}
+
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+
+			i++
+			tokens = append(tokens, token{stringToken, text.String(), start})
+		default:
+			matched := ""
+			for _, operator := range operators {
+				if strings.HasPrefix(string(runes[i:]), operator) {
+					matched = operator
+					break
+				}
+			}
+
+			if matched == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+
+			tokens = append(tokens, token{operatorToken, matched, i})
+			i += len([]rune(matched))
+		}
+	}
+
+	return append(tokens, token{endToken, "", len(runes)}), nil
+}
+
+// node is an element of the syntax tree of an expression. Nodes record their position in the source for error messages
+type node interface {
+	position() int
+}
+
+type literalNode struct {
+	at    int
+	value interface{} // int64, float64, string, or bool
+}
+
+type identNode struct {
+	at   int
+	name string
+}
+
+type unaryNode struct {
+	at      int
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	at          int
+	op          string
+	left, right node
+}
+
+type callNode struct {
+	at   int
+	name string
+	args []node
+}
+
+// inNode tests whether the operand is one of the items, or one of the values of the named list if listName is set
+type inNode struct {
+	at       int
+	operand  node
+	items    []node
+	listName string
+}
+
+func (n literalNode) position() int { return n.at }
+func (n identNode) position() int   { return n.at }
+func (n unaryNode) position() int   { return n.at }
+func (n binaryNode) position() int  { return n.at }
+func (n callNode) position() int    { return n.at }
+func (n inNode) position() int      { return n.at }
+
+/*
+	parser is a recursive descent parser for expressions. From the lowest to the highest precedence, the operators are
+		||
+		&&
+		== != < <= > >= in
+		+ -
+		* / %
+		! and unary -
+*/
+type parser struct {
+	tokens []token
+	next   int
+}
+
+// parse reads the syntax tree of an expression
+func parse(source string) (node, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != endToken {
+		return nil, p.unexpected()
+	}
+
+	return root, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != endToken {
+		p.next++
+	}
+
+	return t
+}
+
+// accept consumes the next token if it is one of the operators
+func (p *parser) accept(ops ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != operatorToken && !(t.kind == identToken && t.text == "in") {
+		return t, false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			return p.advance(), true
+		}
+	}
+
+	return t, false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return p.unexpected()
+	}
+
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	if t.kind == endToken {
+		return fmt.Errorf("unexpected end of expression")
+	}
+
+	return fmt.Errorf("unexpected %q at position %d", t.text, t.at)
+}
+
+// parseBinary parses a left associative chain of the operators, whose operands are parsed by operand
+func (p *parser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = binaryNode{t.at, t.text, left, right}
+	}
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+// parseComparison parses at most one comparison, since chains like a < b < c are more likely to be mistakes than intended
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in")
+	if !ok {
+		return left, nil
+	}
+
+	if t.text == "in" {
+		return p.parseList(t.at, left)
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	return binaryNode{t.at, t.text, left, right}, nil
+}
+
+// parseList parses the right side of in, which is either a bracketed list of constants or the name of a list from the configuration
+func (p *parser) parseList(at int, operand node) (node, error) {
+	if t := p.peek(); t.kind == identToken {
+		p.advance()
+		return inNode{at: at, operand: operand, listName: t.text}, nil
+	}
+
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+
+	items := []node{}
+	for {
+		if _, ok := p.accept("]"); ok {
+			return inNode{at: at, operand: operand, items: items}, nil
+		}
+
+		if len(items) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		item, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if t, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return unaryNode{t.at, t.text, operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case numberToken:
+		p.advance()
+		if integer, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return literalNode{t.at, integer}, nil
+		}
+
+		float, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.at)
+		}
+
+		return literalNode{t.at, float}, nil
+	case stringToken:
+		p.advance()
+		return literalNode{t.at, t.text}, nil
+	case identToken:
+		p.advance()
+		switch t.text {
+		case "true":
+			return literalNode{t.at, true}, nil
+		case "false":
+			return literalNode{t.at, false}, nil
+		case "in":
+			return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.at)
+		}
+
+		if _, ok := p.accept("("); !ok {
+			return identNode{t.at, t.text}, nil
+		}
+
+		args := []node{}
+		for {
+			if _, ok := p.accept(")"); ok {
+				return callNode{t.at, t.text, args}, nil
+			}
+
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, arg)
+		}
+	case operatorToken:
+		if t.text == "(" {
+			p.advance()
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+
+			return inner, nil
+		}
+	}
+
+	return nil, p.unexpected()
+}
@@ -0,0 +1,276 @@
+/*
+	Package expr adds derived columns to exported rows and filters them, with expressions that are configured per dataset. Expressions refer to
+	the columns of a dataset by the names in its output, and are type checked against the output struct of the dataset before the export starts.
+
+	An expression combines columns and constants with arithmetic (+ - * / %), comparisons (== != < <= > >=), logic (&& || !), membership
+	tests (column in ["a", "b"], or column in name for a list from the configuration), and the functions lower, upper, contains, float, and int.
+*/
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+var columnNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ColumnConfig defines a derived column, which is added to every exported row of the dataset
+type ColumnConfig struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// DatasetConfig holds the expressions of a dataset. Only the rows for which Filter is true are exported; an empty filter keeps every row
+type DatasetConfig struct {
+	Filter  string         `json:"filter"`
+	Columns []ColumnConfig `json:"columns"`
+}
+
+// Config is the content of an expressions file. Lists are named lists of constants for membership tests, like the addresses of exchanges
+type Config struct {
+	Lists    map[string][]interface{} `json:"lists"`
+	Datasets map[string]DatasetConfig `json:"datasets"`
+}
+
+// LoadConfig reads an expressions file. The values of lists have to be all strings or all numbers
+func LoadConfig(path string) (Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	defer file.Close()
+
+	// Numbers are decoded as json.Number, so that large integers like account IDs do not lose precision as floats
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	config := Config{}
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("could not decode expressions file %s: %v", path, err)
+	}
+
+	for name, values := range config.Lists {
+		for i, value := range values {
+			switch value := value.(type) {
+			case string:
+			case json.Number:
+				if integer, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+					values[i] = integer
+				} else if float, err := value.Float64(); err == nil {
+					values[i] = float
+				} else {
+					return Config{}, fmt.Errorf("list %s: %v", name, err)
+				}
+			default:
+				return Config{}, fmt.Errorf("list %s: values have to be strings or numbers, not %v", name, value)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+type derivedColumn struct {
+	name string
+	typ  Type
+	eval evaluator
+}
+
+// zeroValues holds the zero value of each type, with the Go type that evaluators return for it
+var zeroValues = map[Type]interface{}{
+	BoolType:   false,
+	IntType:    int64(0),
+	FloatType:  float64(0),
+	StringType: "",
+	TimeType:   time.Time{},
+}
+
+// Program applies the expressions of a dataset to its rows. A nil Program keeps every row as it is
+type Program struct {
+	filter  evaluator
+	columns []derivedColumn
+}
+
+// Compile type checks the expressions of the dataset against the columns of exampleRow. If the configuration has no expressions for the
+// dataset, nil is returned
+func (c Config) Compile(dataset string, exampleRow interface{}) (*Program, error) {
+	datasetConfig, ok := c.Datasets[dataset]
+	if !ok || (datasetConfig.Filter == "" && len(datasetConfig.Columns) == 0) {
+		return nil, nil
+	}
+
+	schema, err := schemaOf(reflect.TypeOf(exampleRow))
+	if err != nil {
+		return nil, err
+	}
+
+	comp := &compiler{schema: schema, lists: c.Lists}
+	program := &Program{}
+
+	// Derived columns can refer to the columns defined before them, and the filter can refer to all of them
+	for i, columnConfig := range datasetConfig.Columns {
+		if !columnNamePattern.MatchString(columnConfig.Name) {
+			return nil, fmt.Errorf("%s: %q is not a valid column name", dataset, columnConfig.Name)
+		}
+
+		if _, exists := schema[columnConfig.Name]; exists {
+			return nil, fmt.Errorf("%s: there already is a column named %s", dataset, columnConfig.Name)
+		}
+
+		result, err := compileSource(comp, columnConfig.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("%s: column %s: %v", dataset, columnConfig.Name, err)
+		}
+
+		schema[columnConfig.Name] = column{typ: result.typ, field: -1, derived: i}
+		program.columns = append(program.columns, derivedColumn{columnConfig.Name, result.typ, result.eval})
+	}
+
+	if datasetConfig.Filter != "" {
+		result, err := compileSource(comp, datasetConfig.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: filter: %v", dataset, err)
+		}
+
+		if result.typ != BoolType {
+			return nil, fmt.Errorf("%s: filter: the filter has type %s, not bool", dataset, result.typ)
+		}
+
+		program.filter = result.eval
+	}
+
+	return program, nil
+}
+
+func compileSource(comp *compiler, source string) (compiled, error) {
+	root, err := parse(source)
+	if err != nil {
+		return compiled{}, err
+	}
+
+	return comp.compile(root)
+}
+
+// HasColumns reports whether the program adds derived columns to the rows, which changes their schema
+func (p *Program) HasColumns() bool {
+	return p != nil && len(p.columns) > 0
+}
+
+// ExampleRow returns the example row of the rows that Apply returns, from which the output formats and tables derive their schemas. If the
+// program has derived columns, it is a Row with the zero values of their types; otherwise it is exampleRow
+func (p *Program) ExampleRow(exampleRow interface{}) interface{} {
+	if !p.HasColumns() {
+		return exampleRow
+	}
+
+	names := make([]string, len(p.columns))
+	values := make([]interface{}, len(p.columns))
+	for i, col := range p.columns {
+		names[i] = col.name
+		values[i] = zeroValues[col.typ]
+	}
+
+	return Row{Row: exampleRow, Names: names, Values: values}
+}
+
+// Apply evaluates the expressions on a row. It returns the row to export, which is a Row if the program has derived columns, and false if the filter drops the row
+func (p *Program) Apply(row interface{}) (interface{}, bool, error) {
+	if p == nil {
+		return row, true, nil
+	}
+
+	e := &env{row: reflect.Indirect(reflect.ValueOf(row)), derived: make([]interface{}, len(p.columns))}
+	for i, col := range p.columns {
+		value, err := col.eval(e)
+		if err != nil {
+			return nil, false, fmt.Errorf("could not evaluate column %s: %v", col.name, err)
+		}
+
+		e.derived[i] = value
+	}
+
+	if p.filter != nil {
+		keep, err := p.filter(e)
+		if err != nil {
+			return nil, false, fmt.Errorf("could not evaluate filter: %v", err)
+		}
+
+		if !keep.(bool) {
+			return nil, false, nil
+		}
+	}
+
+	if len(p.columns) == 0 {
+		return row, true, nil
+	}
+
+	names := make([]string, len(p.columns))
+	for i, col := range p.columns {
+		names[i] = col.name
+	}
+
+	return Row{Row: row, Names: names, Values: e.derived}, true, nil
+}
+
+// Row is an exported row with derived columns. Its JSON encoding is the encoding of the row with the derived columns added at the end
+type Row struct {
+	Row    interface{}
+	Names  []string
+	Values []interface{}
+}
+
+// AppendJSON implements output.JSONAppender
+func (r Row) AppendJSON(dst []byte) ([]byte, error) {
+	start := len(dst)
+	dst, err := output.AppendJSON(dst, r.Row)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dst) < start+2 || dst[len(dst)-1] != '}' {
+		return nil, fmt.Errorf("derived columns can only be added to rows that are encoded as JSON objects")
+	}
+
+	// The closing brace of the row is replaced by the derived columns
+	empty := len(dst) == start+2
+	dst = dst[:len(dst)-1]
+	for i, name := range r.Names {
+		if i > 0 || !empty {
+			dst = append(dst, ',')
+		}
+
+		dst = strconv.AppendQuote(dst, name)
+		dst = append(dst, ':')
+		value, err := json.Marshal(r.Values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		dst = append(dst, value...)
+	}
+
+	return append(dst, '}'), nil
+}
+
+// BaseRow implements output.DerivedRow
+func (r Row) BaseRow() interface{} {
+	return r.Row
+}
+
+// DerivedColumns implements output.DerivedRow
+func (r Row) DerivedColumns() ([]string, []interface{}) {
+	return r.Names, r.Values
+}
+
+// MarshalJSON implements json.Marshaler, so that rows with derived columns have the same encoding wherever they are encoded
+func (r Row) MarshalJSON() ([]byte, error) {
+	return r.AppendJSON(nil)
+}
@@ -0,0 +1,217 @@
+package expr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+type testTrade struct {
+	BaseAccount string    `json:"base_account_address"`
+	BaseAmount  int64     `json:"base_amount"`
+	PriceN      int32     `json:"price_n"`
+	PriceD      uint32    `json:"price_d"`
+	Price       float64   `json:"price"`
+	IsSeller    bool      `json:"base_is_seller"`
+	ClosedAt    time.Time `json:"ledger_closed_at"`
+	Details     []string  `json:"details"`
+	MarketID    uint64    `json:"market_id"`
+}
+
+var testConfig = Config{Lists: map[string][]interface{}{"exchanges": {"GEXCHANGE", "GOTHER"}, "amounts": {int64(10), 2.5}}}
+
+func compileWith(filter string, columns ...ColumnConfig) (*Program, error) {
+	config := testConfig
+	config.Datasets = map[string]DatasetConfig{"trades": {Filter: filter, Columns: columns}}
+	return config.Compile("trades", testTrade{})
+}
+
+func TestCompile(t *testing.T) {
+	type functionInput struct {
+		filter  string
+		columns []ColumnConfig
+	}
+	type functionOutput struct {
+		err error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{"base_amount * price_n / price_d > 10.5 && !base_is_seller", nil}, functionOutput{nil}},
+		{functionInput{`ledger_closed_at >= "2021-01-01T00:00:00Z" || base_account_address in exchanges`, nil}, functionOutput{nil}},
+		{functionInput{"base_amount in [1, -2, 3.5] && lower(base_account_address) in ['ga', 'gb']", nil}, functionOutput{nil}},
+		{functionInput{"notional > 100", []ColumnConfig{{"notional", "base_amount * price"}}}, functionOutput{nil}},
+		{
+			functionInput{"base_amount", nil},
+			functionOutput{fmt.Errorf("trades: filter: the filter has type int, not bool")},
+		},
+		{
+			functionInput{"base_amount > base_account_address", nil},
+			functionOutput{fmt.Errorf("trades: filter: operator > cannot be applied to int and string at position 12")},
+		},
+		{
+			functionInput{"amount > 1", nil},
+			functionOutput{fmt.Errorf("trades: filter: unknown column amount at position 0")},
+		},
+		{
+			functionInput{"details == 1", nil},
+			functionOutput{fmt.Errorf("trades: filter: column details has type []string, which expressions do not support at position 0")},
+		},
+		{
+			functionInput{"market_id > 0", nil},
+			functionOutput{fmt.Errorf("trades: filter: column market_id has type uint64, which expressions do not support at position 0")},
+		},
+		{
+			functionInput{"base_account_address in amounts", nil},
+			functionOutput{fmt.Errorf("trades: filter: a string cannot be in a list of int at position 21")},
+		},
+		{
+			functionInput{"(base_amount > 1", nil},
+			functionOutput{fmt.Errorf("trades: filter: unexpected end of expression")},
+		},
+		{
+			functionInput{`ledger_closed_at > "yesterday"`, nil},
+			functionOutput{fmt.Errorf(`trades: filter: "yesterday" is not an RFC 3339 time at position 17`)},
+		},
+		{
+			functionInput{"", []ColumnConfig{{"price", "1"}}},
+			functionOutput{fmt.Errorf("trades: there already is a column named price")},
+		},
+		{
+			functionInput{"", []ColumnConfig{{"a b", "1"}}},
+			functionOutput{fmt.Errorf(`trades: "a b" is not a valid column name`)},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := compileWith(test.input.filter, test.input.columns...)
+		assert.Equal(t, test.output.err, err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	type functionInput struct {
+		filter  string
+		columns []ColumnConfig
+		row     testTrade
+	}
+	type functionOutput struct {
+		json string
+		keep bool
+		err  error
+	}
+
+	row := testTrade{BaseAccount: "GEXCHANGE", BaseAmount: 20, PriceN: 3, PriceD: 2, Price: 1.5, ClosedAt: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}
+	rowJSON := `{"base_account_address":"GEXCHANGE","base_amount":20,"price_n":3,"price_d":2,"price":1.5,"base_is_seller":false,` +
+		`"ledger_closed_at":"2021-03-01T00:00:00Z","details":null,"market_id":0`
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{"base_amount > 10", nil, row}, functionOutput{rowJSON + "}", true, nil}},
+		{functionInput{"base_amount % 3 == 1", nil, row}, functionOutput{"", false, nil}},
+		{functionInput{`ledger_closed_at < "2021-01-01T00:00:00Z"`, nil, row}, functionOutput{"", false, nil}},
+		{
+			functionInput{"", []ColumnConfig{{"notional", "base_amount * price_n / price_d"}, {"is_exchange", "base_account_address in exchanges"}}, row},
+			functionOutput{rowJSON + `,"notional":30,"is_exchange":true}`, true, nil},
+		},
+		{
+			functionInput{"notional >= 30", []ColumnConfig{{"notional", "base_amount * price"}, {"label", "lower(base_account_address) + '-' + 'x'"}}, row},
+			functionOutput{rowJSON + `,"notional":30,"label":"gexchange-x"}`, true, nil},
+		},
+		{
+			functionInput{"", []ColumnConfig{{"ratio", "base_amount / (price_d - 2)"}}, row},
+			functionOutput{"", false, fmt.Errorf("could not evaluate column ratio: division by zero")},
+		},
+		{
+			functionInput{"", []ColumnConfig{{"big", "base_amount * 461168601842738791"}}, row},
+			functionOutput{"", false, fmt.Errorf("could not evaluate column big: the result of 20 * 461168601842738791 does not fit in an int")},
+		},
+		{
+			functionInput{"base_amount + 9223372036854775800 > 0", nil, row},
+			functionOutput{"", false, fmt.Errorf("could not evaluate filter: the result of 20 + 9223372036854775800 does not fit in an int")},
+		},
+		{
+			functionInput{"-9223372036854775800 - base_amount < 0", nil, row},
+			functionOutput{"", false, fmt.Errorf("could not evaluate filter: the result of -9223372036854775800 - 20 does not fit in an int")},
+		},
+	}
+
+	for _, test := range tests {
+		program, err := compileWith(test.input.filter, test.input.columns...)
+		assert.NoError(t, err)
+
+		applied, keep, err := program.Apply(test.input.row)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.keep, keep)
+		if test.output.json != "" {
+			encoded, err := output.MarshalJSON(applied)
+			assert.NoError(t, err)
+			assert.Equal(t, test.output.json, string(encoded))
+		}
+	}
+}
+
+func TestExampleRow(t *testing.T) {
+	program, err := compileWith("", ColumnConfig{"notional", "base_amount * price"}, ColumnConfig{"is_exchange", "base_account_address in exchanges"},
+		ColumnConfig{"size", "base_amount * 2"}, ColumnConfig{"label", "lower(base_account_address)"}, ColumnConfig{"closed_at", "ledger_closed_at"})
+	assert.NoError(t, err)
+
+	names := []string{"notional", "is_exchange", "size", "label", "closed_at"}
+	exampleRow := program.ExampleRow(testTrade{})
+	assert.Equal(t, Row{Row: testTrade{}, Names: names, Values: []interface{}{float64(0), false, int64(0), "", time.Time{}}}, exampleRow)
+
+	// The rows that the program returns can be written in the formats whose schema is derived from the example row
+	applied, keep, err := program.Apply(testTrade{BaseAccount: "GEXCHANGE", BaseAmount: 20, Price: 1.5})
+	assert.NoError(t, err)
+	assert.True(t, keep)
+
+	var out bytes.Buffer
+	writer, err := output.NewWriter(output.AvroFormat, "", &out, "trades", exampleRow)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(applied))
+	assert.NoError(t, writer.Close())
+
+	filterOnly, err := compileWith("base_amount > 10")
+	assert.NoError(t, err)
+	assert.Equal(t, testTrade{}, filterOnly.ExampleRow(testTrade{}))
+
+	var noProgram *Program
+	assert.Equal(t, testTrade{}, noProgram.ExampleRow(testTrade{}))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "expressions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "expressions.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"lists": {"exchanges": ["GEXCHANGE"], "ids": [9007199254740993, 1.5]},
+		"datasets": {"trades": {"filter": "base_account_address in exchanges", "columns": [{"name": "double", "expression": "base_amount * 2"}]}}
+	}`), 0644)
+	assert.NoError(t, err)
+
+	config, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(9007199254740993), 1.5}, config.Lists["ids"])
+
+	program, err := config.Compile("trades", testTrade{})
+	assert.NoError(t, err)
+	assert.True(t, program.HasColumns())
+
+	program, err = config.Compile("ledgers", testTrade{})
+	assert.NoError(t, err)
+	assert.Nil(t, program)
+}
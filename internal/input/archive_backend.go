@@ -0,0 +1,268 @@
+package input
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// ledgerArchive is the part of a history archive that the archive backend uses
+type ledgerArchive interface {
+	GetRootHAS() (historyarchive.HistoryArchiveState, error)
+	CategoryCheckpointExists(cat string, chk uint32) (bool, error)
+	GetXdrStream(pth string) (*historyarchive.XdrStream, error)
+}
+
+/*
+	ArchiveBackend is a ledger backend that reads the ledgers from the history archive, like the HistoryArchiveBackend of the Go SDK, but
+	downloads checkpoints ahead of the one that is being read. When a ledger is requested, the ledger, transactions, and results files of
+	its checkpoint and of the readAhead checkpoints after it are downloaded in the background, so that the downloads overlap with the
+	transforms of the ledgers that were already read. Checkpoints after the end of the range are not downloaded ahead, and checkpoints
+	before the requested one are dropped, so ledgers should be requested in increasing order. If the backend has a cache, the checkpoints
+	are taken from it, and the ones that are downloaded are added to it. The backend is not safe for concurrent use.
+*/
+type ArchiveBackend struct {
+	archive   ledgerArchive
+	end       uint32
+	readAhead uint32
+	cache     *CheckpointCache
+	loads     map[uint32]*checkpointLoad
+}
+
+var _ ledgerbackend.LedgerBackend = (*ArchiveBackend)(nil)
+
+// checkpointLoad is the download of the ledgers of a checkpoint. The other fields are set before done is closed
+type checkpointLoad struct {
+	done    chan struct{}
+	found   bool
+	ledgers map[uint32]xdr.LedgerCloseMeta
+	err     error
+}
+
+// NewArchiveBackend creates a backend that reads the ledgers up to end from the archive, with readAhead checkpoints downloaded ahead.
+// The cache can be nil, in which case every checkpoint is downloaded
+func NewArchiveBackend(archive ledgerArchive, end, readAhead uint32, cache *CheckpointCache) *ArchiveBackend {
+	return &ArchiveBackend{archive: archive, end: end, readAhead: readAhead, cache: cache, loads: map[uint32]*checkpointLoad{}}
+}
+
+// GetLatestLedgerSequence returns the latest ledger in the history archive
+func (b *ArchiveBackend) GetLatestLedgerSequence() (uint32, error) {
+	has, err := b.archive.GetRootHAS()
+	if err != nil {
+		return 0, fmt.Errorf("could not get root HAS: %v", err)
+	}
+
+	return has.CurrentLedger, nil
+}
+
+// PrepareRange does nothing, because the checkpoints are downloaded as their ledgers are requested
+func (b *ArchiveBackend) PrepareRange(ledgerRange ledgerbackend.Range) error {
+	return nil
+}
+
+// IsPrepared returns true, because any range can be read from the history archive
+func (b *ArchiveBackend) IsPrepared(ledgerRange ledgerbackend.Range) (bool, error) {
+	return true, nil
+}
+
+// GetLedger returns the ledger close meta of the ledger, which has no transaction meta. The first returned value is false when the archive does not have the checkpoint of the ledger
+func (b *ArchiveBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	checkpoint := utils.GetMostRecentCheckpoint(sequence + checkpointFrequency - 1)
+	for loaded := range b.loads {
+		if loaded < checkpoint {
+			delete(b.loads, loaded)
+		}
+	}
+
+	b.startLoad(checkpoint)
+	for i := uint32(1); i <= b.readAhead; i++ {
+		next := checkpoint + i*checkpointFrequency
+		if next-checkpointFrequency+1 > b.end {
+			break
+		}
+
+		b.startLoad(next)
+	}
+
+	load := b.loads[checkpoint]
+	<-load.done
+	if load.err != nil || !load.found {
+		// The download is dropped so that the checkpoint is downloaded again if the ledger is requested again
+		delete(b.loads, checkpoint)
+		if b.cache != nil {
+			b.cache.drop(checkpoint, load)
+		}
+
+		return false, xdr.LedgerCloseMeta{}, load.err
+	}
+
+	meta, ok := load.ledgers[sequence]
+	if !ok {
+		return false, xdr.LedgerCloseMeta{}, fmt.Errorf("checkpoint %d was downloaded but does not have ledger %d", checkpoint, sequence)
+	}
+
+	return true, meta, nil
+}
+
+// Close drops the downloaded checkpoints. Downloads that are still running are finished in the background and dropped
+func (b *ArchiveBackend) Close() error {
+	b.loads = map[uint32]*checkpointLoad{}
+	return nil
+}
+
+// startLoad starts the download of the checkpoint, unless it has already been started or the cache holds it
+func (b *ArchiveBackend) startLoad(checkpoint uint32) {
+	if _, ok := b.loads[checkpoint]; ok {
+		return
+	}
+
+	start := func() *checkpointLoad {
+		load := &checkpointLoad{done: make(chan struct{})}
+		go func() {
+			defer close(load.done)
+			load.found, load.ledgers, load.err = loadCheckpointLedgers(b.archive, checkpoint)
+		}()
+
+		return load
+	}
+
+	if b.cache != nil {
+		b.loads[checkpoint] = b.cache.load(checkpoint, start)
+	} else {
+		b.loads[checkpoint] = start()
+	}
+}
+
+// checkpointFiles holds the entries of the files of a checkpoint
+type checkpointFiles struct {
+	headers      []xdr.LedgerHeaderHistoryEntry
+	transactions []xdr.TransactionHistoryEntry
+	results      []xdr.TransactionHistoryResultEntry
+}
+
+/*
+	loadCheckpointLedgers downloads the ledger, transactions, and results files of the checkpoint at the same time, and combines them into
+	the ledger close metas of its ledgers. The first returned value is false when the archive has none of the files, and an error is
+	returned when it only has some of them.
+*/
+func loadCheckpointLedgers(archive ledgerArchive, checkpoint uint32) (bool, map[uint32]xdr.LedgerCloseMeta, error) {
+	categories := []string{"ledger", "transactions", "results"}
+	existing := 0
+	for _, category := range categories {
+		exists, err := archive.CategoryCheckpointExists(category, checkpoint)
+		if err != nil {
+			return false, nil, fmt.Errorf("could not check if the %s file of checkpoint %d exists: %v", category, checkpoint, err)
+		}
+
+		if exists {
+			existing++
+		}
+	}
+
+	if existing == 0 {
+		return false, nil, nil
+	}
+
+	if existing != len(categories) {
+		return false, nil, fmt.Errorf("the history archive is broken: checkpoint %d only has some of its files", checkpoint)
+	}
+
+	var files checkpointFiles
+	errs := make(chan error, len(categories))
+	go func() {
+		errs <- readCheckpointFile(archive, "ledger", checkpoint, func(stream *historyarchive.XdrStream) error {
+			var entry xdr.LedgerHeaderHistoryEntry
+			if err := stream.ReadOne(&entry); err != nil {
+				return err
+			}
+
+			files.headers = append(files.headers, entry)
+			return nil
+		})
+	}()
+	go func() {
+		errs <- readCheckpointFile(archive, "transactions", checkpoint, func(stream *historyarchive.XdrStream) error {
+			var entry xdr.TransactionHistoryEntry
+			if err := stream.ReadOne(&entry); err != nil {
+				return err
+			}
+
+			files.transactions = append(files.transactions, entry)
+			return nil
+		})
+	}()
+	go func() {
+		errs <- readCheckpointFile(archive, "results", checkpoint, func(stream *historyarchive.XdrStream) error {
+			var entry xdr.TransactionHistoryResultEntry
+			if err := stream.ReadOne(&entry); err != nil {
+				return err
+			}
+
+			files.results = append(files.results, entry)
+			return nil
+		})
+	}()
+
+	var firstErr error
+	for range categories {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return false, nil, firstErr
+	}
+
+	ledgers := map[uint32]xdr.LedgerCloseMeta{}
+	for _, header := range files.headers {
+		ledgers[uint32(header.Header.LedgerSeq)] = xdr.LedgerCloseMeta{V0: &xdr.LedgerCloseMetaV0{LedgerHeader: header}}
+	}
+
+	for _, entry := range files.transactions {
+		meta, ok := ledgers[uint32(entry.LedgerSeq)]
+		if !ok {
+			return false, nil, fmt.Errorf("checkpoint %d has the transactions of ledger %d but not its header", checkpoint, entry.LedgerSeq)
+		}
+
+		meta.V0.TxSet = entry.TxSet
+	}
+
+	for _, entry := range files.results {
+		meta, ok := ledgers[uint32(entry.LedgerSeq)]
+		if !ok {
+			return false, nil, fmt.Errorf("checkpoint %d has the results of ledger %d but not its header", checkpoint, entry.LedgerSeq)
+		}
+
+		meta.V0.TxProcessing = make([]xdr.TransactionResultMeta, len(entry.TxResultSet.Results))
+		for i, result := range entry.TxResultSet.Results {
+			meta.V0.TxProcessing[i].Result = result
+		}
+	}
+
+	return true, ledgers, nil
+}
+
+// readCheckpointFile reads the entries of a file of the checkpoint with readOne, which appends the next entry, until the file ends
+func readCheckpointFile(archive ledgerArchive, category string, checkpoint uint32, readOne func(stream *historyarchive.XdrStream) error) error {
+	stream, err := archive.GetXdrStream(historyarchive.CategoryCheckpointPath(category, checkpoint))
+	if err != nil {
+		return fmt.Errorf("could not open the %s file of checkpoint %d: %v", category, checkpoint, err)
+	}
+	defer stream.Close()
+
+	for {
+		err := readOne(stream)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not read the %s file of checkpoint %d: %v", category, checkpoint, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package input
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLedgerArchive serves the files of checkpoints from memory, and counts the files that are read
+type fakeLedgerArchive struct {
+	sync.Mutex
+	files map[string][]byte
+	reads map[string]int
+	fail  map[string]bool
+}
+
+func newFakeLedgerArchive() *fakeLedgerArchive {
+	return &fakeLedgerArchive{files: map[string][]byte{}, reads: map[string]int{}, fail: map[string]bool{}}
+}
+
+func (a *fakeLedgerArchive) GetRootHAS() (historyarchive.HistoryArchiveState, error) {
+	return historyarchive.HistoryArchiveState{CurrentLedger: 255}, nil
+}
+
+func (a *fakeLedgerArchive) CategoryCheckpointExists(cat string, chk uint32) (bool, error) {
+	a.Lock()
+	defer a.Unlock()
+	_, ok := a.files[historyarchive.CategoryCheckpointPath(cat, chk)]
+	return ok, nil
+}
+
+func (a *fakeLedgerArchive) GetXdrStream(pth string) (*historyarchive.XdrStream, error) {
+	a.Lock()
+	defer a.Unlock()
+	a.reads[pth]++
+	if a.fail[pth] {
+		return nil, fmt.Errorf("could not download %s", pth)
+	}
+
+	return historyarchive.NewXdrStream(ioutil.NopCloser(bytes.NewReader(a.files[pth]))), nil
+}
+
+// readCount returns the number of times that the file of the checkpoint was read
+func (a *fakeLedgerArchive) readCount(category string, checkpoint uint32) int {
+	a.Lock()
+	defer a.Unlock()
+	return a.reads[historyarchive.CategoryCheckpointPath(category, checkpoint)]
+}
+
+// addFile stores the entries as the file of the checkpoint, framed like the records of an XDR file
+func (a *fakeLedgerArchive) addFile(t *testing.T, category string, checkpoint uint32, entries ...encoding.BinaryMarshaler) {
+	var data bytes.Buffer
+	for _, entry := range entries {
+		raw, err := entry.MarshalBinary()
+		assert.NoError(t, err)
+		binary.Write(&data, binary.BigEndian, uint32(len(raw))|0x80000000)
+		data.Write(raw)
+	}
+
+	a.files[historyarchive.CategoryCheckpointPath(category, checkpoint)] = data.Bytes()
+}
+
+// addCheckpoint stores the files of a checkpoint in which ledger first has a transaction with one result, and the other ledgers are empty
+func (a *fakeLedgerArchive) addCheckpoint(t *testing.T, checkpoint uint32) {
+	first := ArchiveRange{EarliestCheckpoint: checkpoint}.EarliestLedger()
+	headers := []encoding.BinaryMarshaler{}
+	for ledger := first; ledger <= checkpoint; ledger++ {
+		headers = append(headers, xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(ledger)}})
+	}
+
+	envelope := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+		V1:   &xdr.TransactionV1Envelope{Tx: xdr.Transaction{SourceAccount: xdr.MustMuxedAddress("GCEODJVUUVYVFD5KT4TOEDTMXQ76OPFOQC2EMYYMLPXQCUVPOB6XRWPQ")}},
+	}
+	result := xdr.TransactionResultPair{
+		TransactionHash: xdr.Hash{byte(checkpoint)},
+		Result:          xdr.TransactionResult{Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxBadSeq}},
+	}
+
+	a.addFile(t, "ledger", checkpoint, headers...)
+	a.addFile(t, "transactions", checkpoint, xdr.TransactionHistoryEntry{LedgerSeq: xdr.Uint32(first), TxSet: xdr.TransactionSet{Txs: []xdr.TransactionEnvelope{envelope}}})
+	a.addFile(t, "results", checkpoint, xdr.TransactionHistoryResultEntry{LedgerSeq: xdr.Uint32(first), TxResultSet: xdr.TransactionResultSet{Results: []xdr.TransactionResultPair{result}}})
+}
+
+func TestArchiveBackendReadsLedgers(t *testing.T) {
+	archive := newFakeLedgerArchive()
+	archive.addCheckpoint(t, 63)
+	archive.addCheckpoint(t, 127)
+	backend := NewArchiveBackend(archive, 127, 2, nil)
+
+	ok, ledger, err := backend.GetLedger(1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1), ledger.LedgerSequence())
+	assert.Equal(t, 1, len(ledger.V0.TxSet.Txs))
+	assert.Equal(t, xdr.Hash{63}, ledger.V0.TxProcessing[0].Result.TransactionHash)
+
+	ok, ledger, err = backend.GetLedger(100)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(100), ledger.LedgerSequence())
+	assert.Equal(t, 0, len(ledger.V0.TxSet.Txs))
+
+	ok, ledger, err = backend.GetLedger(64)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, xdr.Hash{127}, ledger.V0.TxProcessing[0].Result.TransactionHash)
+
+	// The checkpoint after the end of the range is not downloaded ahead, and the one that is read is not downloaded again
+	for _, category := range []string{"ledger", "transactions", "results"} {
+		assert.Equal(t, 1, archive.readCount(category, 63))
+		assert.Equal(t, 1, archive.readCount(category, 127))
+		assert.Equal(t, 0, archive.readCount(category, 191))
+	}
+
+	latest, err := backend.GetLatestLedgerSequence()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(255), latest)
+}
+
+func TestArchiveBackendReadsAhead(t *testing.T) {
+	archive := newFakeLedgerArchive()
+	for checkpoint := uint32(63); checkpoint <= 319; checkpoint += checkpointFrequency {
+		archive.addCheckpoint(t, checkpoint)
+	}
+
+	backend := NewArchiveBackend(archive, 319, 2, nil)
+	_, _, err := backend.GetLedger(10)
+	assert.NoError(t, err)
+	for _, checkpoint := range []uint32{127, 191} {
+		<-backend.loads[checkpoint].done
+		assert.Equal(t, 1, archive.readCount("ledger", checkpoint))
+	}
+
+	assert.NotContains(t, backend.loads, uint32(255))
+
+	// Reading the next checkpoint drops the one before it and downloads one more ahead
+	_, _, err = backend.GetLedger(64)
+	assert.NoError(t, err)
+	assert.NotContains(t, backend.loads, uint32(63))
+	assert.Contains(t, backend.loads, uint32(255))
+
+	noReadAhead := NewArchiveBackend(archive, 319, 0, nil)
+	_, _, err = noReadAhead.GetLedger(200)
+	assert.NoError(t, err)
+	assert.Len(t, noReadAhead.loads, 1)
+}
+
+func TestArchiveBackendErrors(t *testing.T) {
+	archive := newFakeLedgerArchive()
+	archive.addCheckpoint(t, 63)
+	archive.addFile(t, "ledger", 127, xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 64}})
+	archive.addCheckpoint(t, 191)
+	archive.fail[historyarchive.CategoryCheckpointPath("results", 191)] = true
+	backend := NewArchiveBackend(archive, 400, 0, nil)
+
+	ok, _, err := backend.GetLedger(300)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, _, err = backend.GetLedger(64)
+	assert.EqualError(t, err, "the history archive is broken: checkpoint 127 only has some of its files")
+
+	_, _, err = backend.GetLedger(128)
+	assert.EqualError(t, err, "could not open the results file of checkpoint 191: could not download results/00/00/00/results-000000bf.xdr.gz")
+
+	// A failed download is tried again the next time that the checkpoint is read
+	archive.fail[historyarchive.CategoryCheckpointPath("results", 191)] = false
+	ok, _, err = backend.GetLedger(128)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, archive.readCount("results", 191))
+}
+
+func TestArchiveBackendSharesCache(t *testing.T) {
+	archive := newFakeLedgerArchive()
+	for checkpoint := uint32(63); checkpoint <= 255; checkpoint += checkpointFrequency {
+		archive.addCheckpoint(t, checkpoint)
+	}
+
+	cache := NewCheckpointCache(2)
+	first := NewArchiveBackend(archive, 255, 0, cache)
+	for _, ledger := range []uint32{10, 100} {
+		_, _, err := first.GetLedger(ledger)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, first.Close())
+
+	// A second backend takes the checkpoints that the first one downloaded from the cache
+	second := NewArchiveBackend(archive, 255, 0, cache)
+	for _, ledger := range []uint32{10, 100} {
+		ok, meta, err := second.GetLedger(ledger)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, ledger, meta.LedgerSequence())
+	}
+
+	assert.Equal(t, 1, archive.readCount("ledger", 63))
+	assert.Equal(t, 1, archive.readCount("ledger", 127))
+
+	// The cache is full, so the next checkpoint replaces the one that was used least recently
+	_, _, err := second.GetLedger(150)
+	assert.NoError(t, err)
+	assert.NotContains(t, cache.loads, uint32(63))
+	assert.Contains(t, cache.loads, uint32(127))
+	assert.Contains(t, cache.loads, uint32(191))
+
+	// Checkpoints that the archive does not have yet are not kept
+	_, _, err = second.GetLedger(300)
+	assert.NoError(t, err)
+	assert.NotContains(t, cache.loads, uint32(319))
+}
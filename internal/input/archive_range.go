@@ -0,0 +1,192 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+const checkpointFrequency = 64
+
+/*
+	ArchiveRange is the history that a history archive holds. Archives of the public network start at the genesis checkpoint, but mirrors
+	and the archives of private networks may be pruned, and only keep the checkpoints from EarliestCheckpoint to the latest one. The
+	ledgers of a checkpoint are stored with it, so the archive holds the ledgers from EarliestLedger to LatestLedger.
+*/
+type ArchiveRange struct {
+	EarliestCheckpoint uint32
+	LatestLedger       uint32
+}
+
+// EarliestLedger returns the first ledger of the earliest checkpoint in the archive
+func (r ArchiveRange) EarliestLedger() uint32 {
+	if r.EarliestCheckpoint < checkpointFrequency {
+		return 1
+	}
+
+	return r.EarliestCheckpoint - checkpointFrequency + 1
+}
+
+// Clamp moves the bounds of the range [start, end] into the ledgers that the archive holds. If none of the range is in the archive, an error is returned
+func (r ArchiveRange) Clamp(start, end uint32) (uint32, uint32, error) {
+	if end < r.EarliestLedger() || start > r.LatestLedger {
+		return 0, 0, fmt.Errorf("the range [%d, %d] is outside of the ledgers in the history archive [%d, %d]", start, end, r.EarliestLedger(), r.LatestLedger)
+	}
+
+	if start < r.EarliestLedger() {
+		start = r.EarliestLedger()
+	}
+
+	if end > r.LatestLedger {
+		end = r.LatestLedger
+	}
+
+	return start, end, nil
+}
+
+// checkpointArchive is the part of a history archive that is needed to find out which checkpoints it holds
+type checkpointArchive interface {
+	CategoryCheckpointExists(cat string, chk uint32) (bool, error)
+}
+
+/*
+	findEarliestCheckpoint searches for the earliest checkpoint that has its ledgers in the archive. Archives are pruned from the start, so
+	the checkpoints that remain are contiguous and end at the latest one, and a binary search needs about 20 requests for the public
+	network.
+*/
+func findEarliestCheckpoint(archive checkpointArchive, latestNum uint32) (uint32, error) {
+	if latestNum < checkpointFrequency-1 {
+		return 0, fmt.Errorf("the history archive does not have any checkpoints yet (latest ledger is %d)", latestNum)
+	}
+
+	latestCheckpoint := utils.GetMostRecentCheckpoint(latestNum)
+	exists, err := archive.CategoryCheckpointExists("ledger", latestCheckpoint)
+	if err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, fmt.Errorf("the history archive does not have the ledgers of its latest checkpoint %d", latestCheckpoint)
+	}
+
+	// Checkpoint i is the ledger 64*i + 63; the search keeps the invariant that checkpoint high exists
+	low, high := uint32(0), (latestCheckpoint+1)/checkpointFrequency-1
+	for low < high {
+		middle := low + (high-low)/2
+		exists, err := archive.CategoryCheckpointExists("ledger", middle*checkpointFrequency+checkpointFrequency-1)
+		if err != nil {
+			return 0, err
+		}
+
+		if exists {
+			high = middle
+		} else {
+			low = middle + 1
+		}
+	}
+
+	return low*checkpointFrequency + checkpointFrequency - 1, nil
+}
+
+// earliestCheckpoint caches the earliest checkpoint of the archive, so that it is only searched for once per run
+var earliestCheckpoint struct {
+	sync.Mutex
+	checkpoint uint32
+}
+
+// getArchiveRange finds the history that the archive holds, given its latest ledger
+func getArchiveRange(latestNum uint32) (ArchiveRange, error) {
+	archive, err := historyarchive.Connect(
+		archiveStellarURL,
+		historyarchive.ConnectOptions{Context: context.Background()},
+	)
+	if err != nil {
+		return ArchiveRange{}, err
+	}
+
+	return archiveRangeOf(archive, latestNum)
+}
+
+// archiveRangeOf finds the history that the archive holds, searching for its earliest checkpoint unless it was found before in this run
+func archiveRangeOf(archive checkpointArchive, latestNum uint32) (ArchiveRange, error) {
+	earliestCheckpoint.Lock()
+	defer earliestCheckpoint.Unlock()
+
+	if earliestCheckpoint.checkpoint == 0 {
+		checkpoint, err := findEarliestCheckpoint(archive, latestNum)
+		if err != nil {
+			return ArchiveRange{}, fmt.Errorf("could not find the earliest checkpoint in the history archive: %v", err)
+		}
+
+		earliestCheckpoint.checkpoint = checkpoint
+	}
+
+	return ArchiveRange{EarliestCheckpoint: earliestCheckpoint.checkpoint, LatestLedger: latestNum}, nil
+}
+
+/*
+	archiveRangeFrom returns a part of the history that the archive holds which starts at or before start, which is enough to validate a
+	range that starts there. Archives are pruned from the start, so if the checkpoint of start exists, every checkpoint after it does too,
+	and a single request replaces the search for the earliest checkpoint. The search is only run when the archive has been pruned past
+	start, so that the error can name the ledgers that it holds. The earliest checkpoint is used without requests if it was found before,
+	and if start is not a ledger of the archive the range cannot be valid, so only its latest ledger is returned.
+*/
+func archiveRangeFrom(archive checkpointArchive, start, latestNum uint32) (ArchiveRange, error) {
+	earliestCheckpoint.Lock()
+	known := earliestCheckpoint.checkpoint
+	earliestCheckpoint.Unlock()
+
+	if known != 0 || start == 0 || start > latestNum {
+		return ArchiveRange{EarliestCheckpoint: known, LatestLedger: latestNum}, nil
+	}
+
+	checkpoint := utils.GetMostRecentCheckpoint(start + checkpointFrequency - 1)
+	exists, err := archive.CategoryCheckpointExists("ledger", checkpoint)
+	if err != nil {
+		return ArchiveRange{}, fmt.Errorf("could not check if the history archive holds checkpoint %d: %v", checkpoint, err)
+	}
+
+	if exists {
+		return ArchiveRange{EarliestCheckpoint: checkpoint, LatestLedger: latestNum}, nil
+	}
+
+	return archiveRangeOf(archive, latestNum)
+}
+
+// GetArchiveRange returns the range of ledgers that the history archive holds
+func GetArchiveRange() (ArchiveRange, error) {
+	latestNum, err := getLatestLedgerNumber()
+	if err != nil {
+		return ArchiveRange{}, err
+	}
+
+	return getArchiveRange(latestNum)
+}
+
+// ClampLedgerRange moves the bounds of the range [start, end] into the ledgers that the history archive holds
+func ClampLedgerRange(start, end uint32) (uint32, uint32, error) {
+	available, err := GetArchiveRange()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return available.Clamp(start, end)
+}
+
+// CheckpointsInRange returns every step-th checkpoint from the checkpoint that contains start to the checkpoint that contains end
+func CheckpointsInRange(start, end, step uint32) []uint32 {
+	if step == 0 {
+		step = 1
+	}
+
+	checkpoints := []uint32{}
+	last := utils.GetMostRecentCheckpoint(end + checkpointFrequency - 1)
+	for checkpoint := utils.GetMostRecentCheckpoint(start + checkpointFrequency - 1); checkpoint <= last; checkpoint += step * checkpointFrequency {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints
+}
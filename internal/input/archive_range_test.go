@@ -0,0 +1,213 @@
+package input
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prunedArchive holds the ledgers of the checkpoints from earliest to latest
+type prunedArchive struct {
+	earliest, latest uint32
+	requests         int
+}
+
+func (a *prunedArchive) CategoryCheckpointExists(cat string, chk uint32) (bool, error) {
+	a.requests++
+	if cat != "ledger" || (chk+1)%checkpointFrequency != 0 {
+		return false, fmt.Errorf("unexpected request for %s checkpoint %d", cat, chk)
+	}
+
+	return chk >= a.earliest && chk <= a.latest, nil
+}
+
+func TestFindEarliestCheckpoint(t *testing.T) {
+	type functionInput struct {
+		archive   *prunedArchive
+		latestNum uint32
+	}
+	type functionOutput struct {
+		checkpoint uint32
+		err        error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{&prunedArchive{earliest: 63, latest: 32485375}, 32485375},
+			functionOutput{63, nil},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 32000063, latest: 32485375}, 32485375},
+			functionOutput{32000063, nil},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 127, latest: 127}, 127},
+			functionOutput{127, nil},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 63, latest: 63}, 63},
+			functionOutput{63, nil},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 63, latest: 127}, 191},
+			functionOutput{0, fmt.Errorf("the history archive does not have the ledgers of its latest checkpoint 191")},
+		},
+		{
+			functionInput{&prunedArchive{}, 10},
+			functionOutput{0, fmt.Errorf("the history archive does not have any checkpoints yet (latest ledger is 10)")},
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := findEarliestCheckpoint(test.input.archive, test.input.latestNum)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.checkpoint, actual)
+		assert.LessOrEqual(t, test.input.archive.requests, 21)
+	}
+}
+
+func TestArchiveRangeFrom(t *testing.T) {
+	type functionInput struct {
+		archive          *prunedArchive
+		start, latestNum uint32
+		known            uint32
+	}
+	type functionOutput struct {
+		available ArchiveRange
+		requests  int
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{&prunedArchive{earliest: 63, latest: 32485375}, 32000000, 32485375, 0},
+			functionOutput{ArchiveRange{EarliestCheckpoint: 32000063, LatestLedger: 32485375}, 1},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 32000063, latest: 32485375}, 1000, 32485375, 0},
+			functionOutput{ArchiveRange{EarliestCheckpoint: 32000063, LatestLedger: 32485375}, 21},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 63, latest: 32485375}, 1000, 32485375, 63},
+			functionOutput{ArchiveRange{EarliestCheckpoint: 63, LatestLedger: 32485375}, 0},
+		},
+		{
+			functionInput{&prunedArchive{earliest: 63, latest: 1023}, 2000, 1023, 0},
+			functionOutput{ArchiveRange{LatestLedger: 1023}, 0},
+		},
+	}
+
+	for _, test := range tests {
+		earliestCheckpoint.checkpoint = test.input.known
+		actual, err := archiveRangeFrom(test.input.archive, test.input.start, test.input.latestNum)
+		assert.NoError(t, err)
+		assert.Equal(t, test.output.available, actual)
+		assert.Equal(t, test.output.requests, test.input.archive.requests)
+	}
+
+	earliestCheckpoint.checkpoint = 0
+}
+
+func TestValidateLedgerRange(t *testing.T) {
+	type functionInput struct {
+		start, end uint32
+		available  ArchiveRange
+	}
+	type functionOutput struct {
+		err error
+	}
+
+	full := ArchiveRange{EarliestCheckpoint: 63, LatestLedger: 1023}
+	pruned := ArchiveRange{EarliestCheckpoint: 511, LatestLedger: 1023}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{1, 1023, full}, functionOutput{nil}},
+		{functionInput{448, 500, pruned}, functionOutput{nil}},
+		{
+			functionInput{1, 500, pruned},
+			functionOutput{fmt.Errorf("Start sequence number is before the earliest ledger in the history archive (1 < 448). The archive only holds ledgers 448 to 1023")},
+		},
+		{
+			functionInput{500, 1024, pruned},
+			functionOutput{fmt.Errorf("Latest sequence number is less than end sequence number (1023 < 1024)")},
+		},
+		{
+			functionInput{0, 500, full},
+			functionOutput{fmt.Errorf("Start sequence number equal to 0. There is no ledger 0 (genesis ledger is ledger 1)")},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.output.err, validateLedgerRange(test.input.start, test.input.end, test.input.available))
+	}
+}
+
+func TestClampRange(t *testing.T) {
+	type functionInput struct {
+		start, end uint32
+		available  ArchiveRange
+	}
+	type functionOutput struct {
+		start, end uint32
+		err        error
+	}
+
+	pruned := ArchiveRange{EarliestCheckpoint: 511, LatestLedger: 1023}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{500, 600, pruned}, functionOutput{500, 600, nil}},
+		{functionInput{1, 600, pruned}, functionOutput{448, 600, nil}},
+		{functionInput{1, 5000, pruned}, functionOutput{448, 1023, nil}},
+		{functionInput{1, 1, ArchiveRange{EarliestCheckpoint: 63, LatestLedger: 63}}, functionOutput{1, 1, nil}},
+		{
+			functionInput{1, 447, pruned},
+			functionOutput{0, 0, fmt.Errorf("the range [1, 447] is outside of the ledgers in the history archive [448, 1023]")},
+		},
+		{
+			functionInput{1024, 2000, pruned},
+			functionOutput{0, 0, fmt.Errorf("the range [1024, 2000] is outside of the ledgers in the history archive [448, 1023]")},
+		},
+	}
+
+	for _, test := range tests {
+		start, end, err := test.input.available.Clamp(test.input.start, test.input.end)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.start, start)
+		assert.Equal(t, test.output.end, end)
+	}
+}
+
+func TestCheckpointsInRange(t *testing.T) {
+	type functionInput struct {
+		start, end, step uint32
+	}
+	type functionOutput struct {
+		checkpoints []uint32
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{1, 1, 1}, functionOutput{[]uint32{63}}},
+		{functionInput{63, 64, 1}, functionOutput{[]uint32{63, 127}}},
+		{functionInput{100, 300, 1}, functionOutput{[]uint32{127, 191, 255, 319}}},
+		{functionInput{100, 300, 2}, functionOutput{[]uint32{127, 255}}},
+		{functionInput{100, 300, 0}, functionOutput{[]uint32{127, 191, 255, 319}}},
+		{functionInput{300, 100, 1}, functionOutput{[]uint32{}}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.output.checkpoints, CheckpointsInRange(test.input.start, test.input.end, test.input.step))
+	}
+}
@@ -2,6 +2,7 @@ package input
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/stellar/stellar-etl/internal/utils"
 
@@ -13,36 +14,83 @@ import (
 
 var archiveStellarURL = "http://history.stellar.org/prd/core-live/core_live_001"
 
+// StateSnapshot is the state of the ledger entries at a checkpoint, as it is stored in the bucket list of the checkpoint
+type StateSnapshot struct {
+	Checkpoint uint32
+	Header     xdr.LedgerHeader
+	Changes    []ingestio.Change
+}
+
 // GetEntriesFromGenesis returns a slice of ledger entries of the specified type for the ledgers starting from the genesis ledger and ending at end (inclusive)
 func GetEntriesFromGenesis(end uint32, entryType xdr.LedgerEntryType) ([]ingestio.Change, error) {
+	archive, checkpointSeq, err := connectToCheckpoint(end)
+	if err != nil {
+		return []ingestio.Change{}, err
+	}
+
+	return readBucketList(archive, checkpointSeq, entryType)
+}
+
+// GetStateSnapshot reads the ledger entries of the specified types from the bucket list at the checkpoint that contains end, in a single pass. The header of the checkpoint ledger is returned with them
+func GetStateSnapshot(end uint32, entryTypes ...xdr.LedgerEntryType) (StateSnapshot, error) {
+	archive, checkpointSeq, err := connectToCheckpoint(end)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+
+	header, err := archive.GetLedgerHeader(checkpointSeq)
+	if err != nil {
+		return StateSnapshot{}, fmt.Errorf("could not read the header of checkpoint %d: %v", checkpointSeq, err)
+	}
+
+	changes, err := readBucketList(archive, checkpointSeq, entryTypes...)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+
+	return StateSnapshot{Checkpoint: checkpointSeq, Header: header.Header, Changes: changes}, nil
+}
+
+// connectToCheckpoint connects to the history archive and finds the checkpoint that contains end, checking that the archive holds it
+func connectToCheckpoint(end uint32) (*historyarchive.Archive, uint32, error) {
 	archive, err := historyarchive.Connect(
 		archiveStellarURL,
 		historyarchive.ConnectOptions{Context: context.Background()},
 	)
 	if err != nil {
-		return []ingestio.Change{}, err
+		return nil, 0, err
 	}
 
 	historyAdapter := adapters.MakeHistoryArchiveAdapter(archive)
 	latestNum, err := historyAdapter.GetLatestLedgerSequence()
 	if err != nil {
-		return []ingestio.Change{}, err
+		return nil, 0, err
 	}
 
-	err = validateLedgerRange(1, end, latestNum)
+	available, err := archiveRangeFrom(archive, end, latestNum)
 	if err != nil {
-		return []ingestio.Change{}, err
+		return nil, 0, err
+	}
+
+	err = validateLedgerRange(available.EarliestLedger(), end, available)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	checkpointSeq, err := utils.GetCheckpointNum(end, latestNum)
 	if err != nil {
-		return []ingestio.Change{}, err
+		return nil, 0, err
 	}
 
-	return readBucketList(archive, checkpointSeq, entryType)
+	// The bucket list of a checkpoint is only in the archive if the checkpoint is
+	if checkpointSeq < available.EarliestCheckpoint {
+		return nil, 0, fmt.Errorf("Checkpoint %d is before the earliest checkpoint in the history archive (%d)", checkpointSeq, available.EarliestCheckpoint)
+	}
+
+	return archive, checkpointSeq, nil
 }
 
-func readBucketList(archive *historyarchive.Archive, checkpointSeq uint32, entryType xdr.LedgerEntryType) ([]ingestio.Change, error) {
+func readBucketList(archive *historyarchive.Archive, checkpointSeq uint32, entryTypes ...xdr.LedgerEntryType) ([]ingestio.Change, error) {
 	changeReader, err := ingestio.MakeSingleLedgerStateReader(context.Background(), archive, checkpointSeq)
 	defer changeReader.Close()
 	if err != nil {
@@ -60,8 +108,11 @@ func readBucketList(archive *historyarchive.Archive, checkpointSeq uint32, entry
 			return []ingestio.Change{}, err
 		}
 
-		if change.Type == entryType {
-			entrySlice = append(entrySlice, change)
+		for _, entryType := range entryTypes {
+			if change.Type == entryType {
+				entrySlice = append(entrySlice, change)
+				break
+			}
 		}
 	}
 
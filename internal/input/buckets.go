@@ -0,0 +1,194 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/adapters"
+	"github.com/stellar/go/xdr"
+)
+
+// BucketKey is the key of a ledger entry in a bucket. Live is false for the tombstones that buckets hold for deleted entries
+type BucketKey struct {
+	Key  string
+	Type xdr.LedgerEntryType
+	Live bool
+}
+
+// Bucket holds the keys of the entries in a bucket, and the size of its uncompressed XDR
+type Bucket struct {
+	Hash string
+	Size int64
+	Keys []BucketKey
+}
+
+// BucketListSnapshot is the bucket list of a checkpoint. The buckets are ordered from the newest to the oldest, so an entry in a bucket
+// shadows the entries with the same key in the buckets after it
+type BucketListSnapshot struct {
+	Checkpoint uint32
+	Header     xdr.LedgerHeader
+	Buckets    []Bucket
+}
+
+// bucketArchive is the part of a history archive that the bucket list reader uses
+type bucketArchive interface {
+	GetCheckpointHAS(checkpoint uint32) (historyarchive.HistoryArchiveState, error)
+	GetLedgerHeader(ledger uint32) (xdr.LedgerHeaderHistoryEntry, error)
+	GetXdrStreamForHash(hash historyarchive.Hash) (*historyarchive.XdrStream, error)
+}
+
+/*
+	BucketListReader reads the bucket lists of checkpoints from the history archive. Buckets are immutable and named by their hash, and
+	most of the buckets of a checkpoint are also in the bucket list of the next one, so the keys of each bucket are cached and only the
+	buckets that changed since the last checkpoint are downloaded. Buckets that are no longer in the bucket list are dropped from the cache.
+*/
+type BucketListReader struct {
+	archive   bucketArchive
+	available ArchiveRange
+	cache     map[historyarchive.Hash]Bucket
+	// Downloads is the number of buckets that were read from the archive, and Reuses is the number of buckets that were taken from the cache
+	Downloads int
+	Reuses    int
+}
+
+// NewBucketListReader connects to the history archive and creates a reader for the bucket lists of the checkpoints that it holds
+func NewBucketListReader() (*BucketListReader, error) {
+	archive, err := historyarchive.Connect(
+		archiveStellarURL,
+		historyarchive.ConnectOptions{Context: context.Background()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	latestNum, err := adapters.MakeHistoryArchiveAdapter(archive).GetLatestLedgerSequence()
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := getArchiveRange(latestNum)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBucketListReader(archive, available), nil
+}
+
+func newBucketListReader(archive bucketArchive, available ArchiveRange) *BucketListReader {
+	return &BucketListReader{archive: archive, available: available, cache: map[historyarchive.Hash]Bucket{}}
+}
+
+// Read returns the bucket list of the checkpoint, along with the header of the checkpoint ledger
+func (r *BucketListReader) Read(checkpoint uint32) (BucketListSnapshot, error) {
+	if checkpoint < r.available.EarliestCheckpoint || checkpoint > r.available.LatestLedger {
+		return BucketListSnapshot{}, fmt.Errorf("checkpoint %d is not in the history archive, which holds the checkpoints from %d to %d",
+			checkpoint, r.available.EarliestCheckpoint, r.available.LatestLedger)
+	}
+
+	has, err := r.archive.GetCheckpointHAS(checkpoint)
+	if err != nil {
+		return BucketListSnapshot{}, fmt.Errorf("could not read the history archive state of checkpoint %d: %v", checkpoint, err)
+	}
+
+	header, err := r.archive.GetLedgerHeader(checkpoint)
+	if err != nil {
+		return BucketListSnapshot{}, fmt.Errorf("could not read the header of checkpoint %d: %v", checkpoint, err)
+	}
+
+	snapshot := BucketListSnapshot{Checkpoint: checkpoint, Header: header.Header, Buckets: []Bucket{}}
+	inList := map[historyarchive.Hash]bool{}
+	for _, level := range has.CurrentBuckets {
+		// The output of a pending merge is not part of the state yet, so only the curr and snap buckets of each level are read
+		for _, hexHash := range []string{level.Curr, level.Snap} {
+			hash, err := historyarchive.DecodeHash(hexHash)
+			if err != nil {
+				return BucketListSnapshot{}, fmt.Errorf("could not decode bucket hash %s of checkpoint %d: %v", hexHash, checkpoint, err)
+			}
+
+			if hash.IsZero() {
+				continue
+			}
+
+			bucket, cached := r.cache[hash]
+			if cached {
+				r.Reuses++
+			} else {
+				bucket, err = readBucket(r.archive, hash)
+				if err != nil {
+					return BucketListSnapshot{}, fmt.Errorf("could not read bucket %s of checkpoint %d: %v", hexHash, checkpoint, err)
+				}
+
+				r.cache[hash] = bucket
+				r.Downloads++
+			}
+
+			inList[hash] = true
+			snapshot.Buckets = append(snapshot.Buckets, bucket)
+		}
+	}
+
+	for hash := range r.cache {
+		if !inList[hash] {
+			delete(r.cache, hash)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// readBucket reads the keys of the entries in a bucket and checks its hash. The metadata entry of the bucket is skipped
+func readBucket(archive bucketArchive, hash historyarchive.Hash) (Bucket, error) {
+	stream, err := archive.GetXdrStreamForHash(hash)
+	if err != nil {
+		return Bucket{}, err
+	}
+
+	// Closing the stream checks that the bucket hashes to its name
+	stream.SetExpectedHash(hash)
+
+	bucket := Bucket{Hash: hash.String(), Keys: []BucketKey{}}
+	for {
+		var entry xdr.BucketEntry
+		err := stream.ReadOne(&entry)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			stream.Close()
+			return Bucket{}, err
+		}
+
+		var key xdr.LedgerKey
+		live := true
+		switch entry.Type {
+		case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
+			key = entry.LiveEntry.LedgerKey()
+		case xdr.BucketEntryTypeDeadentry:
+			key = entry.MustDeadEntry()
+			live = false
+		case xdr.BucketEntryTypeMetaentry:
+			continue
+		default:
+			stream.Close()
+			return Bucket{}, fmt.Errorf("unknown bucket entry type %d", entry.Type)
+		}
+
+		compressed, err := key.MarshalBinaryCompress()
+		if err != nil {
+			stream.Close()
+			return Bucket{}, err
+		}
+
+		bucket.Keys = append(bucket.Keys, BucketKey{Key: string(compressed), Type: key.Type, Live: live})
+	}
+
+	bucket.Size = stream.BytesRead()
+	if err := stream.Close(); err != nil {
+		return Bucket{}, err
+	}
+
+	return bucket, nil
+}
@@ -0,0 +1,125 @@
+package input
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBucketArchive serves bucket lists and buckets from memory, and counts the buckets that are read
+type fakeBucketArchive struct {
+	states  map[uint32]historyarchive.HistoryArchiveState
+	buckets map[historyarchive.Hash][]byte
+	reads   int
+}
+
+func (a *fakeBucketArchive) GetCheckpointHAS(checkpoint uint32) (historyarchive.HistoryArchiveState, error) {
+	return a.states[checkpoint], nil
+}
+
+func (a *fakeBucketArchive) GetLedgerHeader(ledger uint32) (xdr.LedgerHeaderHistoryEntry, error) {
+	return xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(ledger)}}, nil
+}
+
+func (a *fakeBucketArchive) GetXdrStreamForHash(hash historyarchive.Hash) (*historyarchive.XdrStream, error) {
+	a.reads++
+	return historyarchive.NewXdrStream(ioutil.NopCloser(bytes.NewReader(a.buckets[hash]))), nil
+}
+
+// addBucket stores the entries as a bucket, framed like the records of a bucket file, and returns the hash that names it
+func (a *fakeBucketArchive) addBucket(t *testing.T, entries ...xdr.BucketEntry) historyarchive.Hash {
+	var data bytes.Buffer
+	for _, entry := range entries {
+		raw, err := entry.MarshalBinary()
+		assert.NoError(t, err)
+		binary.Write(&data, binary.BigEndian, uint32(len(raw))|0x80000000)
+		data.Write(raw)
+	}
+
+	hash := historyarchive.Hash(sha256.Sum256(data.Bytes()))
+	a.buckets[hash] = data.Bytes()
+	return hash
+}
+
+func bucketListState(levels ...historyarchive.Hash) historyarchive.HistoryArchiveState {
+	state := historyarchive.HistoryArchiveState{}
+	for i := range state.CurrentBuckets {
+		state.CurrentBuckets[i].Curr = historyarchive.Hash{}.String()
+		state.CurrentBuckets[i].Snap = historyarchive.Hash{}.String()
+	}
+
+	for i, hash := range levels {
+		if i%2 == 0 {
+			state.CurrentBuckets[i/2].Curr = hash.String()
+		} else {
+			state.CurrentBuckets[i/2].Snap = hash.String()
+		}
+	}
+
+	return state
+}
+
+func liveAccount(address string) xdr.BucketEntry {
+	entry := xdr.LedgerEntry{Data: xdr.LedgerEntryData{
+		Type:    xdr.LedgerEntryTypeAccount,
+		Account: &xdr.AccountEntry{AccountId: xdr.MustAddress(address)},
+	}}
+	return xdr.BucketEntry{Type: xdr.BucketEntryTypeLiveentry, LiveEntry: &entry}
+}
+
+func deadAccount(address string) xdr.BucketEntry {
+	key := xdr.LedgerKey{Type: xdr.LedgerEntryTypeAccount, Account: &xdr.LedgerKeyAccount{AccountId: xdr.MustAddress(address)}}
+	return xdr.BucketEntry{Type: xdr.BucketEntryTypeDeadentry, DeadEntry: &key}
+}
+
+func TestBucketListReader(t *testing.T) {
+	first, second := keypair.MustRandom().Address(), keypair.MustRandom().Address()
+	archive := &fakeBucketArchive{states: map[uint32]historyarchive.HistoryArchiveState{}, buckets: map[historyarchive.Hash][]byte{}}
+	meta := xdr.BucketEntry{Type: xdr.BucketEntryTypeMetaentry, MetaEntry: &xdr.BucketMetadata{LedgerVersion: 13}}
+	newest := archive.addBucket(t, meta, deadAccount(first))
+	older := archive.addBucket(t, meta, liveAccount(first), liveAccount(second))
+	oldest := archive.addBucket(t, meta, liveAccount(first))
+	next := archive.addBucket(t, meta, liveAccount(second))
+	archive.states[127] = bucketListState(newest, older, oldest)
+	archive.states[191] = bucketListState(next, newest, oldest)
+
+	firstKey, err := deadAccount(first).MustDeadEntry().MarshalBinaryCompress()
+	assert.NoError(t, err)
+	reader := newBucketListReader(archive, ArchiveRange{EarliestCheckpoint: 127, LatestLedger: 191})
+	snapshot, err := reader.Read(127)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(127), snapshot.Checkpoint)
+	assert.Equal(t, xdr.Uint32(127), snapshot.Header.LedgerSeq)
+	assert.Len(t, snapshot.Buckets, 3)
+	assert.Equal(t, newest.String(), snapshot.Buckets[0].Hash)
+	assert.Equal(t, []BucketKey{{Key: string(firstKey), Type: xdr.LedgerEntryTypeAccount, Live: false}}, snapshot.Buckets[0].Keys)
+	assert.Equal(t, int64(len(archive.buckets[newest])), snapshot.Buckets[0].Size)
+	assert.Len(t, snapshot.Buckets[1].Keys, 2)
+	assert.Equal(t, 3, reader.Downloads)
+
+	// The buckets that the checkpoints share are only read once, and the bucket that left the bucket list is dropped from the cache
+	snapshot, err = reader.Read(191)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{next.String(), newest.String(), oldest.String()}, []string{snapshot.Buckets[0].Hash, snapshot.Buckets[1].Hash, snapshot.Buckets[2].Hash})
+	assert.Equal(t, 4, reader.Downloads)
+	assert.Equal(t, 2, reader.Reuses)
+	assert.Equal(t, 4, archive.reads)
+	assert.Len(t, reader.cache, 3)
+
+	_, err = reader.Read(63)
+	assert.Equal(t, fmt.Errorf("checkpoint 63 is not in the history archive, which holds the checkpoints from 127 to 191"), err)
+
+	// A bucket whose contents do not hash to its name is rejected
+	archive.buckets[next] = archive.buckets[older]
+	reader = newBucketListReader(archive, ArchiveRange{EarliestCheckpoint: 127, LatestLedger: 191})
+	_, err = reader.Read(191)
+	assert.EqualError(t, err, fmt.Sprintf("could not read bucket %s of checkpoint 191: Stream hash does not match expected hash!", next.String()))
+}
@@ -1,9 +1,12 @@
 package input
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"time"
 
+	"github.com/stellar/go/historyarchive"
 	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/network"
@@ -15,12 +18,109 @@ import (
 
 const password = network.PublicNetworkPassphrase
 
-// ChangeBatch represents the changes in a batch of ledgers represented by the range [BatchStart, BatchEnd)
+// ChangeBatch represents the changes in a batch of ledgers represented by the range [BatchStart, BatchEnd). Err is set instead when the changes of
+// the batch could not be read, and no batches follow it
 type ChangeBatch struct {
-	Changes    []ingestio.Change
+	Changes []ingestio.Change
+	// Sources holds the source of each change. Changes to the same entry are compacted into one, which has the source of the last of them
+	Sources    []transform.ChangeSource
 	BatchStart uint32
 	BatchEnd   uint32
 	Type       xdr.LedgerEntryType
+	Err        error
+}
+
+// changeCache compacts changes like ingestio.LedgerEntryChangeCache, and keeps the source of the last change to each entry
+type changeCache struct {
+	changes *ingestio.LedgerEntryChangeCache
+	sources map[string]transform.ChangeSource
+}
+
+func newChangeCache() *changeCache {
+	return &changeCache{changes: ingestio.NewLedgerEntryChangeCache(), sources: map[string]transform.ChangeSource{}}
+}
+
+// changeKey returns the ledger key of the entry that the change is for, in the encoding that ingestio.LedgerEntryChangeCache uses
+func changeKey(change ingestio.Change) (string, error) {
+	entry := change.Post
+	if entry == nil {
+		entry = change.Pre
+	}
+
+	return entry.LedgerKey().MarshalBinaryBase64()
+}
+
+// add compacts the change into the cache. Changes that do not apply to the entries in the cache are ignored
+func (c *changeCache) add(change ingestio.Change, source transform.ChangeSource) {
+	if err := c.changes.AddChange(change); err != nil {
+		return
+	}
+
+	if key, err := changeKey(change); err == nil {
+		c.sources[key] = source
+	}
+}
+
+// batch returns the compacted changes as a batch of the range [start, end)
+func (c *changeCache) batch(start, end uint32, entryType xdr.LedgerEntryType) ChangeBatch {
+	changes := c.changes.GetChanges()
+	sources := make([]transform.ChangeSource, 0, len(changes))
+	for _, change := range changes {
+		key, _ := changeKey(change)
+		sources = append(sources, c.sources[key])
+	}
+
+	return ChangeBatch{Changes: changes, Sources: sources, BatchStart: start, BatchEnd: end, Type: entryType}
+}
+
+/*
+	ledgerChanges returns the changes in a ledger in the order that ingestio.LedgerChangeReader reads them: the fee changes of every transaction,
+	then the changes that every transaction made, and then the changes of protocol upgrades. The changes come with their sources, which
+	LedgerChangeReader does not report.
+*/
+func ledgerChanges(ledger xdr.LedgerCloseMeta) ([]ingestio.Change, []transform.ChangeSource, error) {
+	closedAt, err := utils.TimePointToUTCTimeStamp(ledger.V0.LedgerHeader.Header.ScpValue.CloseTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := []ingestio.Change{}
+	sources := []transform.ChangeSource{}
+	addChanges := func(newChanges []ingestio.Change, transactionHash string) {
+		for _, change := range newChanges {
+			changes = append(changes, change)
+			sources = append(sources, transform.ChangeSource{LedgerSequence: ledger.LedgerSequence(), ClosedAt: closedAt, TransactionHash: transactionHash})
+		}
+	}
+
+	transactions := make([]ingestio.LedgerTransaction, 0, len(ledger.V0.TxProcessing))
+	for i, processing := range ledger.V0.TxProcessing {
+		transactions = append(transactions, ingestio.LedgerTransaction{
+			Index:      uint32(i + 1),
+			Result:     processing.Result,
+			Meta:       processing.TxApplyProcessing,
+			FeeChanges: processing.FeeProcessing,
+		})
+	}
+
+	for _, transaction := range transactions {
+		addChanges(transaction.GetFeeChanges(), utils.HashToHexString(transaction.Result.TransactionHash))
+	}
+
+	for _, transaction := range transactions {
+		transactionChanges, err := transaction.GetChanges()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read the changes of transaction %d: %v", transaction.Index, err)
+		}
+
+		addChanges(transactionChanges, utils.HashToHexString(transaction.Result.TransactionHash))
+	}
+
+	for _, upgrade := range ledger.V0.UpgradesProcessing {
+		addChanges(ingestio.GetChangesFromLedgerEntryChanges(upgrade.Changes), "")
+	}
+
+	return changes, sources, nil
 }
 
 func getLatestLedgerNumber() (uint32, error) {
@@ -62,7 +162,20 @@ func PrepareCaptiveCore(execPath, configPath string, start, end uint32) (*ledger
 			return &ledgerbackend.CaptiveStellarCore{}, err
 		}
 
-		err = validateLedgerRange(start, end, latest)
+		archive, err := historyarchive.Connect(
+			archiveStellarURL,
+			historyarchive.ConnectOptions{Context: context.Background()},
+		)
+		if err != nil {
+			return &ledgerbackend.CaptiveStellarCore{}, err
+		}
+
+		available, err := archiveRangeFrom(archive, start, latest)
+		if err != nil {
+			return &ledgerbackend.CaptiveStellarCore{}, err
+		}
+
+		err = validateLedgerRange(start, end, available)
 		if err != nil {
 			return &ledgerbackend.CaptiveStellarCore{}, err
 		}
@@ -146,62 +259,15 @@ func addLedgerChangesToCache(changeReader *ingestio.LedgerChangeReader, accCache
 }
 
 // exportBatch gets the changes from the ledgers in the range [batchStart, batchEnd), compacts them, and sends them to the proper channels
-func exportBatch(batchStart, batchEnd uint32, core *ledgerbackend.CaptiveStellarCore, accChannel, offChannel, trustChannel chan ChangeBatch, logger *log.Entry) {
-	accChanges := ingestio.NewLedgerEntryChangeCache()
-	offChanges := ingestio.NewLedgerEntryChangeCache()
-	trustChanges := ingestio.NewLedgerEntryChangeCache()
-	for seq := batchStart; seq < batchEnd; {
-		latestLedger, err := core.GetLatestLedgerSequence()
-		if err != nil {
-			logger.Error("unable to get the lastest ledger sequence: ", err)
-		}
-
-		// if this ledger is available, we process its changes and move on to the next ledger by incrementing seq.
-		// Otherwise, nothing is incremented and we try again on the next iteration of the loop
-		if seq <= latestLedger {
-			changeReader, err := ingestio.NewLedgerChangeReader(core, password, seq)
-			if err != nil {
-				logger.Error(fmt.Sprintf("unable to create change reader for ledger %d: ", seq), err)
-			}
-
-			err = addLedgerChangesToCache(changeReader, accChanges, offChanges, trustChanges)
-			if err != nil {
-				logger.Error(fmt.Sprintf("unable to read changes from ledger %d: ", seq), err)
-			}
-
-			changeReader.Close()
-			seq++
-		}
-
-	}
-
-	accBatch := ChangeBatch{
-		Changes:    accChanges.GetChanges(),
-		BatchStart: batchStart,
-		BatchEnd:   batchEnd,
-		Type:       xdr.LedgerEntryTypeAccount,
-	}
-	sendBatchToChannels(accBatch, accChannel, nil, nil)
-
-	offBatch := ChangeBatch{
-		Changes:    offChanges.GetChanges(),
-		BatchStart: batchStart,
-		BatchEnd:   batchEnd,
-		Type:       xdr.LedgerEntryTypeOffer,
-	}
-	sendBatchToChannels(offBatch, nil, offChannel, nil)
-
-	trustBatch := ChangeBatch{
-		Changes:    trustChanges.GetChanges(),
-		BatchStart: batchStart,
-		BatchEnd:   batchEnd,
-		Type:       xdr.LedgerEntryTypeTrustline,
-	}
-	sendBatchToChannels(trustBatch, nil, nil, trustChannel)
+func exportBatch(batchStart, batchEnd uint32, core ledgerbackend.LedgerBackend, channels CombinedChannels, logger *log.Entry) error {
+	_, err := exportCombinedBatch(batchStart, batchEnd, 0, core, channels, nil, logger)
+	return err
 }
 
-// StreamChanges runs a goroutine that reads in ledgers, processes the changes, and send the changes to the channel matching their type
-func StreamChanges(core *ledgerbackend.CaptiveStellarCore, start, end, batchSize uint32, accChannel, offChannel, trustChannel chan ChangeBatch, logger *log.Entry) {
+// StreamChanges runs a goroutine that reads in ledgers, processes the changes, and send the changes to the channel matching their type.
+// If a ledger cannot be read, a batch with the error is sent to each channel and the export stops
+func StreamChanges(core ledgerbackend.LedgerBackend, start, end, batchSize uint32, accChannel, offChannel, trustChannel chan ChangeBatch, logger *log.Entry) {
+	channels := CombinedChannels{Accounts: accChannel, Offers: offChannel, Trustlines: trustChannel}
 	if end != 0 {
 		totalBatches := uint32(math.Ceil(float64(end-start+1) / float64(batchSize)))
 		for currentBatch := uint32(0); currentBatch < totalBatches; currentBatch++ {
@@ -211,13 +277,20 @@ func StreamChanges(core *ledgerbackend.CaptiveStellarCore, start, end, batchSize
 				batchEnd = end + 1
 			}
 
-			exportBatch(batchStart, batchEnd, core, accChannel, offChannel, trustChannel, logger)
+			if err := exportBatch(batchStart, batchEnd, core, channels, logger); err != nil {
+				sendErrorToChannels(err, batchStart, batchEnd, channels)
+				return
+			}
 		}
 	} else {
 		batchStart := start
 		batchEnd := batchStart + batchSize
 		for {
-			exportBatch(batchStart, batchEnd, core, accChannel, offChannel, trustChannel, logger)
+			if err := exportBatch(batchStart, batchEnd, core, channels, logger); err != nil {
+				sendErrorToChannels(err, batchStart, batchEnd, channels)
+				return
+			}
+
 			batchStart = batchEnd
 			batchEnd = batchStart + batchSize
 		}
@@ -227,7 +300,8 @@ func StreamChanges(core *ledgerbackend.CaptiveStellarCore, start, end, batchSize
 }
 
 // ReceiveChanges reads in the ledger entries from the provided channels, transforms them, and adds them to the slice with the other transformed entries.
-func ReceiveChanges(accChannel, offChannel, trustChannel chan ChangeBatch, strictExport bool, logger *log.Entry) ([]transform.AccountOutput, []transform.OfferOutput, []transform.TrustlineOutput) {
+// If strictExport is set, an error is returned for the first entry that cannot be transformed
+func ReceiveChanges(accChannel, offChannel, trustChannel chan ChangeBatch, strictExport bool, logger *log.Entry) ([]transform.AccountOutput, []transform.OfferOutput, []transform.TrustlineOutput, error) {
 	transformedAccounts := make([]transform.AccountOutput, 0)
 	transformedOffers := make([]transform.OfferOutput, 0)
 	transformedTrustlines := make([]transform.TrustlineOutput, 0)
@@ -241,13 +315,17 @@ func ReceiveChanges(accChannel, offChannel, trustChannel chan ChangeBatch, stric
 				break
 			}
 
+			if batch.Err != nil {
+				return nil, nil, nil, batch.Err
+			}
+
 			for _, change := range batch.Changes {
 				acc, err := transform.TransformAccount(change)
 				if err != nil {
 					entry, _, _ := utils.ExtractEntryFromChange(change)
-					errorMsg := fmt.Sprintf("error transforming account entry last updated at: %d", entry.LastModifiedLedgerSeq)
+					errorMsg := fmt.Sprintf("error transforming account entry last updated at %d: ", entry.LastModifiedLedgerSeq)
 					if strictExport {
-						logger.Fatal(errorMsg, err)
+						return nil, nil, nil, fmt.Errorf("%s%v", errorMsg, err)
 					} else {
 						logger.Warning(errorMsg, err)
 						continue
@@ -265,13 +343,17 @@ func ReceiveChanges(accChannel, offChannel, trustChannel chan ChangeBatch, stric
 				break
 			}
 
+			if batch.Err != nil {
+				return nil, nil, nil, batch.Err
+			}
+
 			for _, change := range batch.Changes {
 				offer, err := transform.TransformOffer(change)
 				if err != nil {
 					entry, _, _ := utils.ExtractEntryFromChange(change)
-					errorMsg := fmt.Sprintf("error transforming offer entry last updated at: %d", entry.LastModifiedLedgerSeq)
+					errorMsg := fmt.Sprintf("error transforming offer entry last updated at %d: ", entry.LastModifiedLedgerSeq)
 					if strictExport {
-						logger.Fatal(errorMsg, err)
+						return nil, nil, nil, fmt.Errorf("%s%v", errorMsg, err)
 					} else {
 						logger.Warning(errorMsg, err)
 						continue
@@ -289,13 +371,17 @@ func ReceiveChanges(accChannel, offChannel, trustChannel chan ChangeBatch, stric
 				break
 			}
 
+			if batch.Err != nil {
+				return nil, nil, nil, batch.Err
+			}
+
 			for _, change := range batch.Changes {
 				trust, err := transform.TransformTrustline(change)
 				if err != nil {
 					entry, _, _ := utils.ExtractEntryFromChange(change)
-					errorMsg := fmt.Sprintf("error transforming trustline entry last updated at: %d", entry.LastModifiedLedgerSeq)
+					errorMsg := fmt.Sprintf("error transforming trustline entry last updated at %d: ", entry.LastModifiedLedgerSeq)
 					if strictExport {
-						logger.Fatal(errorMsg, err)
+						return nil, nil, nil, fmt.Errorf("%s%v", errorMsg, err)
 					} else {
 						logger.Warning(errorMsg, err)
 						continue
@@ -319,5 +405,61 @@ func ReceiveChanges(accChannel, offChannel, trustChannel chan ChangeBatch, stric
 		}
 	}
 
-	return transformedAccounts, transformedOffers, transformedTrustlines
+	return transformedAccounts, transformedOffers, transformedTrustlines, nil
+}
+
+/*
+	ReceiveChangeEnvelopes reads in the next batch from each of the provided channels that is not nil, and converts the changes into the envelopes
+	of change events. Every envelope of the batches has the same processing time. If strictExport is set, an error is returned for the first change
+	that cannot be converted.
+*/
+func ReceiveChangeEnvelopes(accChannel, offChannel, trustChannel chan ChangeBatch, strictExport bool, logger *log.Entry) (accounts, offers, trustlines []transform.ChangeEnvelope, err error) {
+	processedAt := time.Now()
+	receive := func(channel chan ChangeBatch, entryName string) ([]transform.ChangeEnvelope, error) {
+		envelopes := make([]transform.ChangeEnvelope, 0)
+		if channel == nil {
+			return envelopes, nil
+		}
+
+		batch, ok := <-channel
+		if !ok {
+			return envelopes, nil
+		}
+
+		if batch.Err != nil {
+			return nil, batch.Err
+		}
+
+		for i, change := range batch.Changes {
+			envelope, err := transform.TransformChangeEnvelope(change, batch.Sources[i], processedAt)
+			if err != nil {
+				errorMsg := fmt.Sprintf("error transforming %s change in ledger %d: ", entryName, batch.Sources[i].LedgerSequence)
+				if strictExport {
+					return nil, fmt.Errorf("%s%v", errorMsg, err)
+				} else {
+					logger.Warning(errorMsg, err)
+					continue
+				}
+			}
+
+			envelopes = append(envelopes, envelope)
+		}
+
+		return envelopes, nil
+	}
+
+	// The batches are sent in this order, so they are received in it as well
+	if accounts, err = receive(accChannel, "account"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if offers, err = receive(offChannel, "offer"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if trustlines, err = receive(trustChannel, "trustline"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return
 }
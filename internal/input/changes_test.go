@@ -1,12 +1,16 @@
 package input
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/transform"
 )
 
 func TestSendBatchToChannel(t *testing.T) {
@@ -122,3 +126,115 @@ func wrapLedgerEntry(entry xdr.LedgerEntry) ChangeBatch {
 		Type:    entry.Data.Type,
 	}
 }
+
+func makeAccountEntry(balance xdr.Int64) xdr.LedgerEntry {
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type:    xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{AccountId: xdr.MustAddress("GCEODJVUUVYVFD5KT4TOEDTMXQ76OPFOQC2EMYYMLPXQCUVPOB6XRWPQ"), Balance: balance},
+		},
+	}
+}
+
+func TestLedgerChanges(t *testing.T) {
+	type functionInput struct {
+		ledger xdr.LedgerCloseMeta
+	}
+	type functionOutput struct {
+		changes []ingestio.Change
+		sources []transform.ChangeSource
+		err     error
+	}
+
+	before, afterFee, afterTransaction, afterUpgrade := makeAccountEntry(100), makeAccountEntry(90), makeAccountEntry(50), makeAccountEntry(60)
+	hash := xdr.Hash{0xab}
+	makeLedger := func(metaVersion int32) xdr.LedgerCloseMeta {
+		return xdr.LedgerCloseMeta{
+			V: 0,
+			V0: &xdr.LedgerCloseMetaV0{
+				LedgerHeader: xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 30, ScpValue: xdr.StellarValue{CloseTime: 1594272522}}},
+				TxProcessing: []xdr.TransactionResultMeta{
+					{
+						Result: xdr.TransactionResultPair{TransactionHash: hash},
+						FeeProcessing: xdr.LedgerEntryChanges{
+							{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: &before},
+							{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &afterFee},
+						},
+						TxApplyProcessing: xdr.TransactionMeta{
+							V: metaVersion,
+							V1: &xdr.TransactionMetaV1{TxChanges: xdr.LedgerEntryChanges{
+								{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: &afterFee},
+								{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &afterTransaction},
+							}},
+						},
+					},
+				},
+				UpgradesProcessing: []xdr.UpgradeEntryMeta{
+					{Changes: xdr.LedgerEntryChanges{
+						{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: &afterTransaction},
+						{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &afterUpgrade},
+					}},
+				},
+			},
+		}
+	}
+
+	closedAt := time.Date(2020, 7, 9, 5, 28, 42, 0, time.UTC)
+	transactionSource := transform.ChangeSource{LedgerSequence: 30, ClosedAt: closedAt, TransactionHash: "ab" + strings.Repeat("00", 31)}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{makeLedger(1)},
+			functionOutput{
+				[]ingestio.Change{
+					{Type: xdr.LedgerEntryTypeAccount, Pre: &before, Post: &afterFee},
+					{Type: xdr.LedgerEntryTypeAccount, Pre: &afterFee, Post: &afterTransaction},
+					{Type: xdr.LedgerEntryTypeAccount, Pre: &afterTransaction, Post: &afterUpgrade},
+				},
+				[]transform.ChangeSource{transactionSource, transactionSource, {LedgerSequence: 30, ClosedAt: closedAt}},
+				nil,
+			},
+		},
+		{
+			functionInput{makeLedger(0)},
+			functionOutput{nil, nil, fmt.Errorf("unable to read the changes of transaction 1: TransactionMeta.V=0 not supported")},
+		},
+	}
+
+	for _, test := range tests {
+		changes, sources, err := ledgerChanges(test.input.ledger)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.changes, changes)
+		assert.Equal(t, test.output.sources, sources)
+	}
+}
+
+func TestChangeCache(t *testing.T) {
+	created, updated, offer := makeAccountEntry(100), makeAccountEntry(50), *makeOfferEntry(1, 100)
+	firstSource := transform.ChangeSource{LedgerSequence: 10, TransactionHash: "first"}
+	secondSource := transform.ChangeSource{LedgerSequence: 11, TransactionHash: "second"}
+
+	cache := newChangeCache()
+	cache.add(ingestio.Change{Type: xdr.LedgerEntryTypeAccount, Post: &created}, firstSource)
+	cache.add(ingestio.Change{Type: xdr.LedgerEntryTypeAccount, Pre: &created, Post: &updated}, secondSource)
+	// A change that does not apply to the cached entry is ignored, along with its source
+	cache.add(ingestio.Change{Type: xdr.LedgerEntryTypeAccount, Post: &updated}, firstSource)
+	batch := cache.batch(10, 12, xdr.LedgerEntryTypeAccount)
+	assert.Equal(t, ChangeBatch{
+		Changes:    []ingestio.Change{{Type: xdr.LedgerEntryTypeAccount, Post: &updated}},
+		Sources:    []transform.ChangeSource{secondSource},
+		BatchStart: 10,
+		BatchEnd:   12,
+		Type:       xdr.LedgerEntryTypeAccount,
+	}, batch)
+
+	// Entries that are created and removed within the batch are left out
+	cache = newChangeCache()
+	cache.add(ingestio.Change{Type: xdr.LedgerEntryTypeOffer, Post: &offer}, firstSource)
+	cache.add(ingestio.Change{Type: xdr.LedgerEntryTypeOffer, Pre: &offer}, secondSource)
+	batch = cache.batch(10, 12, xdr.LedgerEntryTypeOffer)
+	assert.Empty(t, batch.Changes)
+	assert.Empty(t, batch.Sources)
+}
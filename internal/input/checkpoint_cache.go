@@ -0,0 +1,63 @@
+package input
+
+import "sync"
+
+/*
+	CheckpointCache keeps the checkpoints that archive backends download, so that backends which read the same ledgers one after another,
+	like the steps of a pipeline, download each checkpoint once. It holds up to size checkpoints, and drops the one that was used least
+	recently to make room for another. Downloads that fail or find no checkpoint are not kept. It is safe for concurrent use.
+*/
+type CheckpointCache struct {
+	lock  sync.Mutex
+	size  int
+	loads map[uint32]*checkpointLoad
+	used  map[uint32]uint64
+	clock uint64
+}
+
+// NewCheckpointCache creates a cache that holds up to size checkpoints
+func NewCheckpointCache(size int) *CheckpointCache {
+	return &CheckpointCache{size: size, loads: map[uint32]*checkpointLoad{}, used: map[uint32]uint64{}}
+}
+
+// load returns the download of the checkpoint. If the cache does not hold it, the download is started with start and kept
+func (c *CheckpointCache) load(checkpoint uint32, start func() *checkpointLoad) *checkpointLoad {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.clock++
+	if load, ok := c.loads[checkpoint]; ok {
+		c.used[checkpoint] = c.clock
+		return load
+	}
+
+	load := start()
+	if c.size <= 0 {
+		return load
+	}
+
+	if len(c.loads) >= c.size {
+		oldest, oldestUse := uint32(0), c.clock
+		for cached, use := range c.used {
+			if use < oldestUse {
+				oldest, oldestUse = cached, use
+			}
+		}
+
+		delete(c.loads, oldest)
+		delete(c.used, oldest)
+	}
+
+	c.loads[checkpoint] = load
+	c.used[checkpoint] = c.clock
+	return load
+}
+
+// drop removes the download of the checkpoint from the cache, unless it has been replaced by another download since
+func (c *CheckpointCache) drop(checkpoint uint32, load *checkpointLoad) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.loads[checkpoint] == load {
+		delete(c.loads, checkpoint)
+		delete(c.used, checkpoint)
+	}
+}
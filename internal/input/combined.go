@@ -0,0 +1,178 @@
+package input
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/transform"
+)
+
+// CombinedChannels holds the channels that StreamChangesAndOrderbooks sends batches to. Channels for data types that are not exported are nil
+type CombinedChannels struct {
+	Accounts   chan ChangeBatch
+	Offers     chan ChangeBatch
+	Trustlines chan ChangeBatch
+	Orderbooks chan OrderbookBatch
+}
+
+// readLedgerChanges reads all the changes in a ledger along with their sources, waiting until the ledger is available in the backend
+func readLedgerChanges(core ledgerbackend.LedgerBackend, seq uint32, logger *log.Entry) ([]ingestio.Change, []transform.ChangeSource, error) {
+	for {
+		err := waitForLedger(core, seq)
+		if err == nil {
+			break
+		}
+
+		logger.Error("unable to get the lastest ledger sequence: ", err)
+		time.Sleep(corePollInterval)
+	}
+
+	ok, ledger, err := core.GetLedger(seq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read ledger %d: %v", seq, err)
+	}
+
+	if !ok {
+		return nil, nil, fmt.Errorf("ledger %d is not available in the backend", seq)
+	}
+
+	changes, sources, err := ledgerChanges(ledger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read changes from ledger %d: %v", seq, err)
+	}
+
+	return changes, sources, nil
+}
+
+// applyOfferChanges returns the orderbook after the offer changes have been applied to it
+func applyOfferChanges(orderbook []ingestio.Change, changes []ingestio.Change) []ingestio.Change {
+	orderbookCache := ingestio.NewLedgerEntryChangeCache()
+	for _, offer := range orderbook {
+		orderbookCache.AddChange(offer)
+	}
+
+	for _, change := range changes {
+		if change.Type == xdr.LedgerEntryTypeOffer {
+			orderbookCache.AddChange(change)
+		}
+	}
+
+	return orderbookCache.GetChanges()
+}
+
+// exportCombinedBatch reads the changes in the range [batchStart, batchEnd) once. The changes are compacted into change batches, and are also
+// used to keep the orderbook up to date. The batches are sent to the channels that are not nil. The changes of the checkpoint ledger are already
+// part of the initial orderbook, so they are not applied to it again. If a ledger cannot be read, nothing is sent and the error is returned
+func exportCombinedBatch(batchStart, batchEnd, checkpointSeq uint32, core ledgerbackend.LedgerBackend, channels CombinedChannels, orderbook []ingestio.Change, logger *log.Entry) ([]ingestio.Change, error) {
+	accChanges := newChangeCache()
+	offChanges := newChangeCache()
+	trustChanges := newChangeCache()
+	orderbooks := make(map[uint32][]ingestio.Change)
+	for seq := batchStart; seq < batchEnd; seq++ {
+		changes, sources, err := readLedgerChanges(core, seq, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, change := range changes {
+			switch change.Type {
+			case xdr.LedgerEntryTypeAccount:
+				accChanges.add(change, sources[i])
+			case xdr.LedgerEntryTypeOffer:
+				offChanges.add(change, sources[i])
+			case xdr.LedgerEntryTypeTrustline:
+				trustChanges.add(change, sources[i])
+			}
+		}
+
+		if channels.Orderbooks != nil {
+			if seq > checkpointSeq {
+				orderbook = applyOfferChanges(orderbook, changes)
+			}
+
+			orderbooks[seq] = orderbook
+		}
+	}
+
+	sendBatchToChannels(accChanges.batch(batchStart, batchEnd, xdr.LedgerEntryTypeAccount), channels.Accounts, nil, nil)
+	sendBatchToChannels(offChanges.batch(batchStart, batchEnd, xdr.LedgerEntryTypeOffer), nil, channels.Offers, nil)
+	sendBatchToChannels(trustChanges.batch(batchStart, batchEnd, xdr.LedgerEntryTypeTrustline), nil, nil, channels.Trustlines)
+	if channels.Orderbooks != nil {
+		channels.Orderbooks <- OrderbookBatch{BatchStart: batchStart, BatchEnd: batchEnd, Orderbooks: orderbooks}
+	}
+
+	return orderbook, nil
+}
+
+// sendErrorToChannels sends a batch with the error to each of the channels that is not nil, so that the receivers stop the export
+func sendErrorToChannels(err error, batchStart, batchEnd uint32, channels CombinedChannels) {
+	for _, channel := range []chan ChangeBatch{channels.Accounts, channels.Offers, channels.Trustlines} {
+		if channel != nil {
+			channel <- ChangeBatch{BatchStart: batchStart, BatchEnd: batchEnd, Err: err}
+		}
+	}
+
+	if channels.Orderbooks != nil {
+		channels.Orderbooks <- OrderbookBatch{BatchStart: batchStart, BatchEnd: batchEnd, Err: err}
+	}
+}
+
+/*
+	StreamChangesAndOrderbooks exports the ledger entry changes and the orderbooks between start and end using a single pass over the ledgers in core.
+	The orderbook is the state of the offers at the checkpoint ledger checkpointSeq, and core has to be prepared from that checkpoint so that the
+	orderbook can be brought up to date before the first batch. If end is 0, then it exports in an unbounded fashion. If a ledger cannot be read,
+	a batch with the error is sent to each channel and the export stops.
+*/
+func StreamChangesAndOrderbooks(core ledgerbackend.LedgerBackend, checkpointSeq, start, end, batchSize uint32, orderbook []ingestio.Change, channels CombinedChannels, logger *log.Entry) {
+	if channels.Orderbooks != nil {
+		for seq := checkpointSeq + 1; seq < start; seq++ {
+			changes, _, err := readLedgerChanges(core, seq, logger)
+			if err != nil {
+				sendErrorToChannels(err, 0, 0, channels)
+				return
+			}
+
+			orderbook = applyOfferChanges(orderbook, changes)
+		}
+	}
+
+	if end != 0 {
+		totalBatches := uint32(math.Ceil(float64(end-start+1) / float64(batchSize)))
+		for currentBatch := uint32(0); currentBatch < totalBatches; currentBatch++ {
+			batchStart := start + currentBatch*batchSize
+			batchEnd := batchStart + batchSize
+			if batchEnd > end+1 {
+				batchEnd = end + 1
+			}
+
+			var err error
+			if orderbook, err = exportCombinedBatch(batchStart, batchEnd, checkpointSeq, core, channels, orderbook, logger); err != nil {
+				sendErrorToChannels(err, batchStart, batchEnd, channels)
+				return
+			}
+		}
+	} else {
+		batchStart := start
+		batchEnd := batchStart + batchSize
+		for {
+			var err error
+			if orderbook, err = exportCombinedBatch(batchStart, batchEnd, checkpointSeq, core, channels, orderbook, logger); err != nil {
+				sendErrorToChannels(err, batchStart, batchEnd, channels)
+				return
+			}
+
+			batchStart = batchEnd
+			batchEnd = batchStart + batchSize
+		}
+	}
+
+	closeChannels(channels.Accounts, channels.Offers, channels.Trustlines)
+	if channels.Orderbooks != nil {
+		close(channels.Orderbooks)
+	}
+}
@@ -0,0 +1,78 @@
+package input
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+)
+
+func makeOfferEntry(offerID xdr.Int64, amount xdr.Int64) *xdr.LedgerEntry {
+	return &xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeOffer,
+			Offer: &xdr.OfferEntry{
+				SellerId: xdr.MustAddress(keypair.MustRandom().Address()),
+				OfferId:  offerID,
+				Amount:   amount,
+			},
+		},
+	}
+}
+
+func TestApplyOfferChanges(t *testing.T) {
+	firstOffer := makeOfferEntry(1, 100)
+	secondOffer := makeOfferEntry(2, 200)
+	orderbook := []ingestio.Change{
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: firstOffer},
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: secondOffer},
+	}
+
+	updatedFirstOffer := *firstOffer
+	updatedOfferEntry := *firstOffer.Data.Offer
+	updatedOfferEntry.Amount = 50
+	updatedFirstOffer.Data.Offer = &updatedOfferEntry
+	thirdOffer := makeOfferEntry(3, 300)
+
+	changes := []ingestio.Change{
+		{Type: xdr.LedgerEntryTypeOffer, Pre: firstOffer, Post: &updatedFirstOffer},
+		{Type: xdr.LedgerEntryTypeOffer, Pre: secondOffer, Post: nil},
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: thirdOffer},
+		{Type: xdr.LedgerEntryTypeAccount, Pre: nil, Post: &xdr.LedgerEntry{
+			Data: xdr.LedgerEntryData{
+				Type:    xdr.LedgerEntryTypeAccount,
+				Account: &xdr.AccountEntry{AccountId: xdr.MustAddress(keypair.MustRandom().Address())},
+			},
+		}},
+	}
+
+	updated := applyOfferChanges(orderbook, changes)
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].Post.Data.Offer.OfferId < updated[j].Post.Data.Offer.OfferId
+	})
+
+	assert.Equal(t, []ingestio.Change{
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: &updatedFirstOffer},
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: thirdOffer},
+	}, updated)
+
+	// The orderbook that was passed in is left untouched, since earlier ledgers in the batch still refer to it
+	assert.Equal(t, xdr.Int64(100), orderbook[0].Post.Data.Offer.Amount)
+}
+
+func TestStreamChangesAndOrderbooksError(t *testing.T) {
+	// The backend has already moved past the first ledger of the range, so it cannot be read
+	backend := &sequentialBackend{next: 5}
+	channels := CombinedChannels{Accounts: make(chan ChangeBatch), Orderbooks: make(chan OrderbookBatch)}
+	go StreamChangesAndOrderbooks(backend, 2, 3, 10, 4, nil, channels, nil)
+
+	_, _, _, err := ReceiveChanges(channels.Accounts, nil, nil, true, nil)
+	assert.EqualError(t, err, "unable to read ledger 3: requested ledger 3 is not the next ledger (5)")
+
+	_, err = ReceiveParsedOrderbooks(channels.Orderbooks, true, nil)
+	assert.EqualError(t, err, "unable to read ledger 3: requested ledger 3 is not the next ledger (5)")
+}
@@ -0,0 +1,399 @@
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+)
+
+// corePollInterval is how long the daemon waits before asking an unbounded captive core instance for a ledger that was not ready yet
+const corePollInterval = time.Second
+
+// minLedgerPollInterval is how long waitForLedger first waits before asking the backend for its latest ledger again
+const minLedgerPollInterval = 50 * time.Millisecond
+
+// waitForLedger blocks until the backend has the ledger with the provided sequence number. The time between requests for the latest ledger
+// doubles from minLedgerPollInterval up to corePollInterval, so that waiting on core or on a core daemon does not keep the process and the socket busy
+func waitForLedger(core ledgerbackend.LedgerBackend, seq uint32) error {
+	interval := minLedgerPollInterval
+	for {
+		latestLedger, err := core.GetLatestLedgerSequence()
+		if err != nil {
+			return err
+		}
+
+		if seq <= latestLedger {
+			return nil
+		}
+
+		time.Sleep(interval)
+		interval *= 2
+		if interval > corePollInterval {
+			interval = corePollInterval
+		}
+	}
+}
+
+// CoreDaemon reads ledgers from a single captive core instance and shares them with any number of exporters, which connect to it through a socket
+// using a SocketBackend. Ledgers are kept until every connected exporter has moved past them. In order to bound memory usage, the daemon stops
+// reading from core while the slowest exporter is retain ledgers behind the latest ledger that was read.
+type CoreDaemon struct {
+	core       ledgerbackend.LedgerBackend
+	start, end uint32
+	retain     uint32
+	logger     *log.Entry
+
+	lock sync.Mutex
+	// cond is signalled whenever a ledger is read, or an exporter moves, connects, or disconnects
+	cond *sync.Cond
+	// ledgers holds the marshalled ledgers in the range [first, latest]
+	ledgers       map[uint32][]byte
+	first, latest uint32
+	// cursors holds the earliest ledger that each connected exporter may still request
+	cursors    map[uint64]uint32
+	nextCursor uint64
+	done       bool
+	err        error
+}
+
+// DaemonLedgerReply is the reply that the daemon sends for a ledger request. Meta holds the XDR encoding of the ledger close meta, and is empty if
+// the ledger has not been read from core yet.
+type DaemonLedgerReply struct {
+	Found bool
+	Meta  []byte
+}
+
+// NewCoreDaemon creates a daemon that shares the ledgers in the range [start, end] that are read from the prepared core. The range is unbounded when end = 0
+func NewCoreDaemon(core ledgerbackend.LedgerBackend, start, end, retain uint32, logger *log.Entry) (*CoreDaemon, error) {
+	if start == 0 {
+		return nil, fmt.Errorf("Start sequence number equal to 0. There is no ledger 0 (genesis ledger is ledger 1)")
+	}
+
+	if retain == 0 {
+		return nil, fmt.Errorf("the daemon must retain at least 1 ledger")
+	}
+
+	daemon := &CoreDaemon{
+		core:    core,
+		start:   start,
+		end:     end,
+		retain:  retain,
+		logger:  logger,
+		ledgers: make(map[uint32][]byte),
+		first:   start,
+		latest:  start - 1,
+		cursors: make(map[uint64]uint32),
+	}
+	daemon.cond = sync.NewCond(&daemon.lock)
+	return daemon, nil
+}
+
+// Run reads ledgers from core until the end of the range is reached. In unbounded mode, it runs until core returns an error.
+func (d *CoreDaemon) Run() error {
+	for seq := d.start; d.end == 0 || seq <= d.end; {
+		d.waitForExporters(seq)
+
+		ok, ledger, err := d.core.GetLedger(seq)
+		if err != nil {
+			err = fmt.Errorf("unable to read ledger %d from stellar-core: %v", seq, err)
+			d.finish(err)
+			return err
+		}
+
+		// Unbounded ranges do not block until the ledger is ready, so we try again after some time
+		if !ok {
+			time.Sleep(corePollInterval)
+			continue
+		}
+
+		meta, err := ledger.MarshalBinary()
+		if err != nil {
+			err = fmt.Errorf("unable to marshal ledger %d: %v", seq, err)
+			d.finish(err)
+			return err
+		}
+
+		d.addLedger(seq, meta)
+		seq++
+	}
+
+	d.finish(nil)
+	return nil
+}
+
+// Serve accepts exporter connections on the listener until it is closed. Each connection is served in its own goroutine
+func (d *CoreDaemon) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go d.serveConn(conn)
+	}
+}
+
+func (d *CoreDaemon) serveConn(conn net.Conn) {
+	session := &coreSession{daemon: d}
+	server := rpc.NewServer()
+	if err := server.RegisterName("CoreDaemon", session); err != nil {
+		d.logger.Error("unable to register the core daemon service: ", err)
+		conn.Close()
+		return
+	}
+
+	// ServeConn returns when the exporter disconnects, at which point the ledgers it was holding can be released
+	server.ServeConn(conn)
+	session.release()
+}
+
+func (d *CoreDaemon) waitForExporters(seq uint32) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for seq >= d.lowestNeeded()+d.retain {
+		d.cond.Wait()
+	}
+}
+
+// lowestNeeded returns the earliest ledger that has to be kept. If no exporters are connected, the first ledgers are kept so that exporters can start
+// from the beginning of the range. The lock must be held by the caller
+func (d *CoreDaemon) lowestNeeded() uint32 {
+	if len(d.cursors) == 0 {
+		return d.first
+	}
+
+	lowest := d.latest + 1
+	for _, cursor := range d.cursors {
+		if cursor < lowest {
+			lowest = cursor
+		}
+	}
+
+	return lowest
+}
+
+// evict removes the ledgers that no connected exporter may request anymore. The lock must be held by the caller
+func (d *CoreDaemon) evict() {
+	if len(d.cursors) == 0 {
+		return
+	}
+
+	lowest := d.lowestNeeded()
+	for d.first < lowest && d.first <= d.latest {
+		delete(d.ledgers, d.first)
+		d.first++
+	}
+
+	d.cond.Broadcast()
+}
+
+func (d *CoreDaemon) addLedger(seq uint32, meta []byte) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.ledgers[seq] = meta
+	d.latest = seq
+	d.evict()
+	d.cond.Broadcast()
+}
+
+func (d *CoreDaemon) finish(err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.done = true
+	d.err = err
+	d.cond.Broadcast()
+}
+
+// coreSession is the service that the daemon provides to a single exporter connection
+type coreSession struct {
+	daemon     *CoreDaemon
+	cursorID   uint64
+	registered bool
+}
+
+// PrepareRange registers the exporter at the start of the JSON encoded range. Ledgers from the start of the range onwards are kept until the exporter reads them
+func (s *coreSession) PrepareRange(encodedRange []byte, reply *bool) error {
+	// The fields of ledgerbackend.Range are unexported, so the start of the range is read from its JSON encoding
+	var ledgerRange struct {
+		From uint32 `json:"from"`
+	}
+	if err := json.Unmarshal(encodedRange, &ledgerRange); err != nil {
+		return fmt.Errorf("unable to decode ledger range: %v", err)
+	}
+
+	d := s.daemon
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	from := ledgerRange.From
+	if from < d.first {
+		return fmt.Errorf("ledger %d is no longer kept by the core daemon; the earliest available ledger is %d", from, d.first)
+	}
+
+	if d.end != 0 && from > d.end {
+		return fmt.Errorf("ledger %d is after the end of the core daemon range (%d)", from, d.end)
+	}
+
+	if !s.registered {
+		s.cursorID = d.nextCursor
+		s.registered = true
+		d.nextCursor++
+	}
+
+	d.cursors[s.cursorID] = from
+	d.evict()
+	*reply = true
+	return nil
+}
+
+// GetLatestLedgerSequence returns the latest ledger that the daemon has read from core
+func (s *coreSession) GetLatestLedgerSequence(_ bool, reply *uint32) error {
+	d := s.daemon
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+
+	*reply = d.latest
+	return nil
+}
+
+// GetLedger returns the ledger with the provided sequence number. Since the exporter will not request earlier ledgers, they may be evicted
+func (s *coreSession) GetLedger(seq uint32, reply *DaemonLedgerReply) error {
+	d := s.daemon
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+
+	if seq < d.first {
+		return fmt.Errorf("ledger %d is no longer kept by the core daemon; the earliest available ledger is %d", seq, d.first)
+	}
+
+	if seq > d.latest {
+		if d.done {
+			return fmt.Errorf("ledger %d is after the end of the core daemon range (%d)", seq, d.end)
+		}
+
+		*reply = DaemonLedgerReply{Found: false}
+		return nil
+	}
+
+	if s.registered {
+		d.cursors[s.cursorID] = seq
+		d.evict()
+	}
+
+	*reply = DaemonLedgerReply{Found: true, Meta: d.ledgers[seq]}
+	return nil
+}
+
+func (s *coreSession) release() {
+	if !s.registered {
+		return
+	}
+
+	d := s.daemon
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.cursors, s.cursorID)
+	s.registered = false
+	d.evict()
+	d.cond.Broadcast()
+}
+
+// SocketBackend is a ledger backend that reads ledgers from a CoreDaemon
+type SocketBackend struct {
+	client *rpc.Client
+}
+
+var _ ledgerbackend.LedgerBackend = (*SocketBackend)(nil)
+
+// NewSocketBackend connects to the core daemon listening on the unix socket at the provided path
+func NewSocketBackend(socketPath string) (*SocketBackend, error) {
+	client, err := rpc.Dial("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to the core daemon at %s: %v", socketPath, err)
+	}
+
+	return &SocketBackend{client: client}, nil
+}
+
+// PrepareSocketBackend connects to the core daemon and registers the start of the range with it. Like PrepareCaptiveCore, the range is unbounded when end = 0
+func PrepareSocketBackend(socketPath string, start, end uint32) (*SocketBackend, error) {
+	backend, err := NewSocketBackend(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ledgerRange := ledgerbackend.UnboundedRange(start)
+	if end != 0 {
+		ledgerRange = ledgerbackend.BoundedRange(start, end)
+	}
+
+	err = backend.PrepareRange(ledgerRange)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	return backend, nil
+}
+
+// GetLatestLedgerSequence returns the latest ledger that the daemon has read from core
+func (b *SocketBackend) GetLatestLedgerSequence() (uint32, error) {
+	var seq uint32
+	err := b.client.Call("CoreDaemon.GetLatestLedgerSequence", true, &seq)
+	return seq, err
+}
+
+// GetLedger returns the ledger with the provided sequence number. The first returned value is false if the daemon has not read the ledger yet
+func (b *SocketBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	var reply DaemonLedgerReply
+	err := b.client.Call("CoreDaemon.GetLedger", sequence, &reply)
+	if err != nil {
+		return false, xdr.LedgerCloseMeta{}, err
+	}
+
+	if !reply.Found {
+		return false, xdr.LedgerCloseMeta{}, nil
+	}
+
+	var ledger xdr.LedgerCloseMeta
+	err = xdr.SafeUnmarshal(reply.Meta, &ledger)
+	if err != nil {
+		return false, xdr.LedgerCloseMeta{}, fmt.Errorf("unable to unmarshal ledger %d: %v", sequence, err)
+	}
+
+	return true, ledger, nil
+}
+
+// PrepareRange registers the start of the range with the daemon, so that the ledgers in the range are kept until they are read
+func (b *SocketBackend) PrepareRange(ledgerRange ledgerbackend.Range) error {
+	encodedRange, err := json.Marshal(ledgerRange)
+	if err != nil {
+		return err
+	}
+
+	var prepared bool
+	return b.client.Call("CoreDaemon.PrepareRange", encodedRange, &prepared)
+}
+
+// IsPrepared returns true, since the daemon prepares core before accepting connections
+func (b *SocketBackend) IsPrepared(ledgerRange ledgerbackend.Range) (bool, error) {
+	return true, nil
+}
+
+// Close disconnects from the daemon, which releases the ledgers that were kept for this backend
+func (b *SocketBackend) Close() error {
+	return b.client.Close()
+}
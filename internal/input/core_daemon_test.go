@@ -0,0 +1,193 @@
+package input
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+// sequentialBackend mimics a bounded captive core instance: ledgers can only be read once, and in order
+type sequentialBackend struct {
+	next uint32
+}
+
+func (b *sequentialBackend) GetLatestLedgerSequence() (uint32, error) {
+	return b.next, nil
+}
+
+func (b *sequentialBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	if sequence != b.next {
+		return false, xdr.LedgerCloseMeta{}, fmt.Errorf("requested ledger %d is not the next ledger (%d)", sequence, b.next)
+	}
+
+	b.next++
+	return true, makeDaemonTestLedger(sequence), nil
+}
+
+func (b *sequentialBackend) PrepareRange(ledgerRange ledgerbackend.Range) error {
+	return nil
+}
+
+func (b *sequentialBackend) IsPrepared(ledgerRange ledgerbackend.Range) (bool, error) {
+	return true, nil
+}
+
+func (b *sequentialBackend) Close() error {
+	return nil
+}
+
+func makeDaemonTestLedger(seq uint32) xdr.LedgerCloseMeta {
+	return xdr.LedgerCloseMeta{
+		V: 0,
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(seq)},
+			},
+		},
+	}
+}
+
+func startTestDaemon(t *testing.T, start, end, retain uint32) (string, func()) {
+	folder, err := ioutil.TempDir("", "core-daemon")
+	assert.NoError(t, err)
+
+	daemon, err := NewCoreDaemon(&sequentialBackend{next: start}, start, end, retain, log.New())
+	assert.NoError(t, err)
+
+	socketPath := filepath.Join(folder, "core.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.NoError(t, err)
+
+	go daemon.Serve(listener)
+	go daemon.Run()
+
+	return socketPath, func() {
+		listener.Close()
+		os.RemoveAll(folder)
+	}
+}
+
+// readDaemonLedgers reads the ledgers in the range [start, end] the same way that the change exporter does, waiting for ledgers that are not read yet
+func readDaemonLedgers(backend ledgerbackend.LedgerBackend, start, end uint32) ([]uint32, error) {
+	read := []uint32{}
+	for seq := start; seq <= end; {
+		latest, err := backend.GetLatestLedgerSequence()
+		if err != nil {
+			return read, err
+		}
+
+		if seq > latest {
+			continue
+		}
+
+		ok, ledger, err := backend.GetLedger(seq)
+		if err != nil {
+			return read, err
+		}
+
+		if ok {
+			read = append(read, ledger.LedgerSequence())
+			seq++
+		}
+	}
+
+	return read, nil
+}
+
+func TestCoreDaemonSharesLedgers(t *testing.T) {
+	socketPath, stop := startTestDaemon(t, 2, 11, 3)
+	defer stop()
+
+	first, err := PrepareSocketBackend(socketPath, 2, 11)
+	assert.NoError(t, err)
+	defer first.Close()
+
+	second, err := PrepareSocketBackend(socketPath, 5, 11)
+	assert.NoError(t, err)
+	defer second.Close()
+
+	type readResult struct {
+		ledgers []uint32
+		err     error
+	}
+
+	var group sync.WaitGroup
+	results := make([]readResult, 2)
+	for i, reader := range []struct {
+		backend    *SocketBackend
+		start, end uint32
+	}{{first, 2, 11}, {second, 5, 11}} {
+		group.Add(1)
+		go func(i int, backend *SocketBackend, start, end uint32) {
+			defer group.Done()
+			ledgers, err := readDaemonLedgers(backend, start, end)
+			results[i] = readResult{ledgers, err}
+		}(i, reader.backend, reader.start, reader.end)
+	}
+
+	group.Wait()
+	assert.Equal(t, readResult{[]uint32{2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, nil}, results[0])
+	assert.Equal(t, readResult{[]uint32{5, 6, 7, 8, 9, 10, 11}, nil}, results[1])
+}
+
+func TestCoreDaemonEvictsReadLedgers(t *testing.T) {
+	socketPath, stop := startTestDaemon(t, 2, 11, 3)
+	defer stop()
+
+	backend, err := PrepareSocketBackend(socketPath, 2, 11)
+	assert.NoError(t, err)
+	defer backend.Close()
+
+	ledgers, err := readDaemonLedgers(backend, 2, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{2, 3, 4, 5, 6}, ledgers)
+
+	_, _, err = backend.GetLedger(3)
+	assert.EqualError(t, err, "ledger 3 is no longer kept by the core daemon; the earliest available ledger is 6")
+
+	_, err = PrepareSocketBackend(socketPath, 4, 11)
+	assert.EqualError(t, err, "ledger 4 is no longer kept by the core daemon; the earliest available ledger is 6")
+}
+
+// closingBackend closes a ledger each time that it is asked for its latest ledger, and fails once it has closed failAfter ledgers
+type closingBackend struct {
+	sequentialBackend
+	polls     int
+	failAfter uint32
+}
+
+func (b *closingBackend) GetLatestLedgerSequence() (uint32, error) {
+	b.polls++
+	if b.failAfter != 0 && b.next >= b.failAfter {
+		return 0, fmt.Errorf("core exited")
+	}
+
+	b.next++
+	return b.next, nil
+}
+
+func TestWaitForLedger(t *testing.T) {
+	backend := &closingBackend{}
+	startTime := time.Now()
+	assert.NoError(t, waitForLedger(backend, 3))
+	assert.Equal(t, 3, backend.polls)
+	// Two waits of minLedgerPollInterval and twice that
+	assert.True(t, time.Since(startTime) >= 3*minLedgerPollInterval)
+
+	assert.NoError(t, waitForLedger(backend, 2))
+	assert.Equal(t, 4, backend.polls)
+
+	failing := &closingBackend{failAfter: 1}
+	assert.EqualError(t, waitForLedger(failing, 5), "core exited")
+	assert.Equal(t, 2, failing.polls)
+}
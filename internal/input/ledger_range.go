@@ -2,6 +2,8 @@ package input
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/stellar/go/ingest/ledgerbackend"
@@ -25,42 +27,145 @@ type graph struct {
 	EndPoint   graphPoint
 }
 
+// LedgerRange is a range of ledgers along with the close times (in UTC) of its first and last ledgers
+type LedgerRange struct {
+	Start          int64
+	End            int64
+	StartCloseTime time.Time
+	EndCloseTime   time.Time
+}
+
 const avgCloseTime = time.Second * 5 // average time to close a stellar ledger
 
-// GetLedgerRange calculates the ledger range that spans the provided date range
-func GetLedgerRange(startTime, endTime time.Time) (int64, int64, error) {
+// timeLayouts are the layouts that ParseTime accepts, in the order that they are tried. RFC3339Nano also accepts times without fractional seconds
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+/*
+	ParseTime parses a time that is either in an RFC3339 format (with a Z suffix or a numeric offset, and optional fractional seconds),
+	a date in the format YYYY-MM-DD, or a unix timestamp in seconds. Times and dates without an offset are treated as UTC.
+*/
+func ParseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC(), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("parsing time %q: expected an RFC3339 time, a date in the format YYYY-MM-DD, or a unix timestamp", value)
+}
+
+/*
+	GetLedgerRange calculates the ledger range that spans the provided date range. By default, the range starts with the ledger that
+	closed on or directly after startTime, and ends with the ledger that closed on or directly after endTime, so it is the smallest range
+	that covers the time period. If exclusiveStart is set, the range starts with the first ledger that closed after startTime. If
+	exclusiveEnd is set, the range ends with the last ledger that closed before endTime.
+*/
+func GetLedgerRange(startTime, endTime time.Time, exclusiveStart, exclusiveEnd bool) (LedgerRange, error) {
 	startTime = startTime.UTC()
 	endTime = endTime.UTC()
 
 	if startTime.After(endTime) {
-		return 0, 0, fmt.Errorf("start time must be less than or equal to the end time")
+		return LedgerRange{}, fmt.Errorf("start time must be less than or equal to the end time")
 	}
 
 	graph, err := createNewGraph()
 	if err != nil {
-		return 0, 0, err
+		return LedgerRange{}, err
 	}
 
 	defer graph.close()
 
+	requestedEndTime := endTime
 	err = graph.limitLedgerRange(&startTime, &endTime)
 	if err != nil {
-		return 0, 0, err
+		return LedgerRange{}, err
+	}
+
+	// Close times have a resolution of one second, so the first ledger that closed after startTime is the first one that closed a second later
+	if exclusiveStart {
+		startTime = startTime.Truncate(time.Second).Add(time.Second)
+		if startTime.After(graph.EndPoint.CloseTime) {
+			return LedgerRange{}, fmt.Errorf("no ledger closed after the start time")
+		}
 	}
 
 	// Ledger sequence 2 is the start ledger because the genesis ledger (ledger 1), has a close time of 0 in Unix time.
 	// The second ledger has a valid close time that matches with the network start time.
 	startLedger, err := graph.findLedgerForDate(2, startTime, map[int64]struct{}{})
 	if err != nil {
-		return 0, 0, err
+		return LedgerRange{}, err
 	}
 
 	endLedger, err := graph.findLedgerForDate(2, endTime, map[int64]struct{}{})
 	if err != nil {
-		return 0, 0, err
+		return LedgerRange{}, err
 	}
 
-	return startLedger, endLedger, nil
+	if exclusiveEnd {
+		endPoint, err := graph.getGraphPoint(endLedger)
+		if err != nil {
+			return LedgerRange{}, err
+		}
+
+		// The end ledger can only have closed before the requested end time if the end time is after the most recent ledger
+		if !endPoint.CloseTime.Before(requestedEndTime) {
+			endLedger--
+		}
+	}
+
+	if endLedger < startLedger {
+		return LedgerRange{}, fmt.Errorf("no ledgers closed between %v and %v", startTime, endTime)
+	}
+
+	return graph.getLedgerRange(startLedger, endLedger)
+}
+
+// GetLedgerCloseTimes returns the close times of the first and last ledgers in the range [startLedger, endLedger]
+func GetLedgerCloseTimes(startLedger, endLedger int64) (LedgerRange, error) {
+	if startLedger > endLedger {
+		return LedgerRange{}, fmt.Errorf("start ledger must be less than or equal to the end ledger")
+	}
+
+	archive, err := utils.CreateBackend()
+	if err != nil {
+		return LedgerRange{}, err
+	}
+
+	graph := graph{Backend: archive}
+	defer graph.close()
+
+	return graph.getLedgerRange(startLedger, endLedger)
+}
+
+// getLedgerRange creates a LedgerRange from the provided sequence numbers by looking up the close times of both ledgers
+func (g graph) getLedgerRange(startLedger, endLedger int64) (LedgerRange, error) {
+	startPoint, err := g.getGraphPoint(startLedger)
+	if err != nil {
+		return LedgerRange{}, err
+	}
+
+	endPoint, err := g.getGraphPoint(endLedger)
+	if err != nil {
+		return LedgerRange{}, err
+	}
+
+	return LedgerRange{
+		Start:          startPoint.Seq,
+		End:            endPoint.Seq,
+		StartCloseTime: startPoint.CloseTime,
+		EndCloseTime:   endPoint.CloseTime,
+	}, nil
 }
 
 func (g graph) close() {
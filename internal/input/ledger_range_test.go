@@ -0,0 +1,67 @@
+package input
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	type functionInput struct {
+		value string
+	}
+	type functionOutput struct {
+		time time.Time
+		err  error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{"2019-09-13T23:00:00+00:00"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"2019-09-13T18:00:00-05:00"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"2019-09-13T23:00:00Z"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"2019-09-13T23:00:00.250Z"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 250000000, time.UTC), nil},
+		},
+		{
+			functionInput{"2019-09-13T23:00:00"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"2019-09-13 23:00:00"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"2019-09-13"},
+			functionOutput{time.Date(2019, 9, 13, 0, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"1568415600"},
+			functionOutput{time.Date(2019, 9, 13, 23, 0, 0, 0, time.UTC), nil},
+		},
+		{
+			functionInput{"2016 01 01 4:33"},
+			functionOutput{time.Time{}, fmt.Errorf(`parsing time "2016 01 01 4:33": expected an RFC3339 time, a date in the format YYYY-MM-DD, or a unix timestamp`)},
+		},
+	}
+
+	for _, test := range tests {
+		actualTime, actualError := ParseTime(test.input.value)
+		assert.Equal(t, test.output.err, actualError)
+		assert.True(t, test.output.time.Equal(actualTime), "expected %v, got %v", test.output.time, actualTime)
+	}
+}
@@ -1,13 +1,16 @@
 package input
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/xdr"
-	"github.com/stellar/stellar-etl/internal/utils"
 )
 
-func validateLedgerRange(start, end, latestNum uint32) error {
+// validateLedgerRange checks that the range [start, end] is a valid range of ledgers that the history archive holds
+func validateLedgerRange(start, end uint32, available ArchiveRange) error {
 	if start == 0 {
 		return fmt.Errorf("Start sequence number equal to 0. There is no ledger 0 (genesis ledger is ledger 1)")
 	}
@@ -20,45 +23,74 @@ func validateLedgerRange(start, end, latestNum uint32) error {
 		return fmt.Errorf("End sequence number is less than start (%d < %d)", end, start)
 	}
 
-	if latestNum < start {
-		return fmt.Errorf("Latest sequence number is less than start sequence number (%d < %d)", latestNum, start)
+	if available.LatestLedger < start {
+		return fmt.Errorf("Latest sequence number is less than start sequence number (%d < %d)", available.LatestLedger, start)
 	}
 
-	if latestNum < end {
-		return fmt.Errorf("Latest sequence number is less than end sequence number (%d < %d)", latestNum, end)
+	if available.LatestLedger < end {
+		return fmt.Errorf("Latest sequence number is less than end sequence number (%d < %d)", available.LatestLedger, end)
+	}
+
+	if start < available.EarliestLedger() {
+		return fmt.Errorf("Start sequence number is before the earliest ledger in the history archive (%d < %d). The archive only holds ledgers %d to %d",
+			start, available.EarliestLedger(), available.EarliestLedger(), available.LatestLedger)
 	}
 
 	return nil
 }
 
-// GetLedgers returns a slice of ledger close metas for the ledgers in the provided range (inclusive on both ends)
-func GetLedgers(start, end uint32, limit int64) ([]xdr.LedgerCloseMeta, error) {
-	backend, err := utils.CreateBackend()
+// PrepareArchiveBackend creates a history archive backend that downloads readAhead checkpoints ahead, and checks that the archive holds the range [start, end].
+// The checkpoints are shared through the cache if it is not nil
+func PrepareArchiveBackend(start, end, readAhead uint32, cache *CheckpointCache) (ledgerbackend.LedgerBackend, error) {
+	archive, err := historyarchive.Connect(
+		archiveStellarURL,
+		historyarchive.ConnectOptions{Context: context.Background()},
+	)
 	if err != nil {
-		return []xdr.LedgerCloseMeta{}, err
+		return nil, err
 	}
 
-	defer backend.Close()
+	backend := NewArchiveBackend(archive, end, readAhead, cache)
 
 	latestNum, err := backend.GetLatestLedgerSequence()
 	if err != nil {
-		return []xdr.LedgerCloseMeta{}, err
+		backend.Close()
+		return nil, err
 	}
 
-	err = validateLedgerRange(start, end, latestNum)
+	available, err := archiveRangeFrom(archive, start, latestNum)
 	if err != nil {
-		return []xdr.LedgerCloseMeta{}, err
+		backend.Close()
+		return nil, err
 	}
 
+	err = validateLedgerRange(start, end, available)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	return backend, nil
+}
+
+// GetLedgers returns a slice of ledger close metas for the ledgers in the provided range (inclusive on both ends), which are read from the backend. If verify is set, each ledger is checked against the hashes in its header before it is returned
+func GetLedgers(backend ledgerbackend.LedgerBackend, start, end uint32, limit int64, verify bool) ([]xdr.LedgerCloseMeta, error) {
 	metaSlice := []xdr.LedgerCloseMeta{}
 	for seq := start; seq <= end; seq++ {
 		ok, ledger, err := backend.GetLedger(seq)
+		if err != nil {
+			return []xdr.LedgerCloseMeta{}, err
+		}
+
 		if !ok {
 			return []xdr.LedgerCloseMeta{}, fmt.Errorf("Ledger %d does not exist in the history archives", seq)
 		}
 
-		if err != nil {
-			return []xdr.LedgerCloseMeta{}, err
+		if verify {
+			err = VerifyLedger(ledger)
+			if err != nil {
+				return []xdr.LedgerCloseMeta{}, err
+			}
 		}
 
 		metaSlice = append(metaSlice, ledger)
@@ -0,0 +1,240 @@
+package input
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+)
+
+// metaStreamPollInterval is how long a followed metadata stream waits before reading again after it reached the end of the data written so far
+const metaStreamPollInterval = time.Second
+
+/*
+	MetaStreamBackend is a ledger backend that reads the ledger close metas that stellar-core writes to its metadata output stream
+	(METADATA_OUTPUT_STREAM). Each ledger is a length-prefixed XDR frame, and the ledgers are in order, so they can only be requested in
+	increasing order. A bounded stream fails once its data ends. A followed stream waits for stellar-core to write more ledgers instead, like
+	tail -f, so reading blocks until the requested ledger has been written.
+*/
+type MetaStreamBackend struct {
+	reader io.Reader
+	closer io.Closer
+
+	// current is the latest ledger that was read from the stream. consumed is set once it has been returned by GetLedger
+	current  xdr.LedgerCloseMeta
+	read     bool
+	consumed bool
+	ended    bool
+}
+
+var _ ledgerbackend.LedgerBackend = (*MetaStreamBackend)(nil)
+
+// followReader waits for more data when the underlying reader reaches its end, instead of returning io.EOF
+type followReader struct {
+	reader io.Reader
+}
+
+func (r followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.reader.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+
+		time.Sleep(metaStreamPollInterval)
+	}
+}
+
+// NewMetaStreamBackend creates a backend that reads the ledgers from stream. If follow is set, the backend waits for more data at the end of the stream
+func NewMetaStreamBackend(stream io.ReadCloser, follow bool) *MetaStreamBackend {
+	var reader io.Reader = stream
+	if follow {
+		reader = followReader{reader: stream}
+	}
+
+	return &MetaStreamBackend{reader: bufio.NewReader(reader), closer: stream}
+}
+
+// OpenMetaStream opens the metadata stream at location, which is the path of a file or named pipe, or fd:N for an inherited file descriptor
+func OpenMetaStream(location string, follow bool) (*MetaStreamBackend, error) {
+	if strings.HasPrefix(location, "fd:") {
+		fd, err := strconv.ParseUint(strings.TrimPrefix(location, "fd:"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid file descriptor", location)
+		}
+
+		file := os.NewFile(uintptr(fd), location)
+		if file == nil {
+			return nil, fmt.Errorf("%s is not a valid file descriptor", location)
+		}
+
+		return NewMetaStreamBackend(file, follow), nil
+	}
+
+	// Opening a named pipe blocks until stellar-core opens it for writing
+	file, err := os.Open(location)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the metadata stream: %v", err)
+	}
+
+	return NewMetaStreamBackend(file, follow), nil
+}
+
+// PrepareMetaStream opens the metadata stream at location and skips to the start of the range. Like PrepareCaptiveCore, the range is unbounded
+// when end = 0, in which case the stream is followed
+func PrepareMetaStream(location string, start, end uint32) (*MetaStreamBackend, error) {
+	backend, err := OpenMetaStream(location, end == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = backend.skipTo(start)
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+
+	return backend, nil
+}
+
+// next reads the next ledger of the stream. At the end of a bounded stream, it returns io.EOF
+func (b *MetaStreamBackend) next() error {
+	if b.ended {
+		return io.EOF
+	}
+
+	var header uint32
+	err := binary.Read(b.reader, binary.BigEndian, &header)
+	if err == io.EOF {
+		b.ended = true
+		return io.EOF
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read the frame header %s: %v", b.position(), err)
+	}
+
+	if header&0x80000000 == 0 {
+		return fmt.Errorf("malformed frame header %s", b.position())
+	}
+
+	frame := make([]byte, header&0x7fffffff)
+	_, err = io.ReadFull(b.reader, frame)
+	if err != nil {
+		return fmt.Errorf("unable to read the frame %s: %v", b.position(), err)
+	}
+
+	var ledger xdr.LedgerCloseMeta
+	err = xdr.SafeUnmarshal(frame, &ledger)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal the frame %s: %v", b.position(), err)
+	}
+
+	if b.read && ledger.LedgerSequence() != b.current.LedgerSequence()+1 {
+		return fmt.Errorf("the metadata stream skips from ledger %d to ledger %d", b.current.LedgerSequence(), ledger.LedgerSequence())
+	}
+
+	b.current, b.read, b.consumed = ledger, true, false
+	return nil
+}
+
+// position describes where the next frame is in the stream, for error messages
+func (b *MetaStreamBackend) position() string {
+	if !b.read {
+		return "at the start of the metadata stream"
+	}
+
+	return fmt.Sprintf("after ledger %d", b.current.LedgerSequence())
+}
+
+// skipTo reads the stream until the current ledger is the ledger with the provided sequence number
+func (b *MetaStreamBackend) skipTo(seq uint32) error {
+	for !b.read || b.current.LedgerSequence() < seq {
+		err := b.next()
+		if err == io.EOF {
+			if !b.read {
+				return fmt.Errorf("the metadata stream ended before ledger %d; it has no ledgers", seq)
+			}
+
+			return fmt.Errorf("the metadata stream ended at ledger %d, before ledger %d", b.current.LedgerSequence(), seq)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.current.LedgerSequence() > seq {
+		return fmt.Errorf("ledger %d is not in the metadata stream, which is at ledger %d", seq, b.current.LedgerSequence())
+	}
+
+	return nil
+}
+
+// GetLatestLedgerSequence returns the latest ledger that was read from the stream. Once that ledger has been returned by GetLedger, the next
+// ledger is read first, which blocks until it has been written if the stream is followed. At the end of a bounded stream, an error is returned
+func (b *MetaStreamBackend) GetLatestLedgerSequence() (uint32, error) {
+	if !b.read || b.consumed {
+		err := b.next()
+		if err == io.EOF && !b.read {
+			return 0, fmt.Errorf("the metadata stream has no ledgers")
+		}
+
+		if err == io.EOF {
+			return 0, fmt.Errorf("the metadata stream ended at ledger %d", b.current.LedgerSequence())
+		}
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return b.current.LedgerSequence(), nil
+}
+
+// GetLedger returns the ledger with the provided sequence number. Earlier ledgers of the stream are skipped
+func (b *MetaStreamBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	err := b.skipTo(sequence)
+	if err != nil {
+		return false, xdr.LedgerCloseMeta{}, err
+	}
+
+	b.consumed = true
+	return true, b.current, nil
+}
+
+// PrepareRange skips the ledgers of the stream that are before the start of the range
+func (b *MetaStreamBackend) PrepareRange(ledgerRange ledgerbackend.Range) error {
+	// The fields of ledgerbackend.Range are unexported, so the start of the range is read from its JSON encoding
+	encodedRange, err := json.Marshal(ledgerRange)
+	if err != nil {
+		return err
+	}
+
+	var decodedRange struct {
+		From uint32 `json:"from"`
+	}
+	if err := json.Unmarshal(encodedRange, &decodedRange); err != nil {
+		return fmt.Errorf("unable to decode ledger range: %v", err)
+	}
+
+	return b.skipTo(decodedRange.From)
+}
+
+// IsPrepared returns true once a ledger has been read from the stream
+func (b *MetaStreamBackend) IsPrepared(ledgerRange ledgerbackend.Range) (bool, error) {
+	return b.read, nil
+}
+
+// Close closes the stream
+func (b *MetaStreamBackend) Close() error {
+	return b.closer.Close()
+}
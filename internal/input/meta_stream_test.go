@@ -0,0 +1,135 @@
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeMetaStream(t *testing.T, sequences ...uint32) []byte {
+	var stream bytes.Buffer
+	for _, seq := range sequences {
+		ledger := xdr.LedgerCloseMeta{
+			V:  0,
+			V0: &xdr.LedgerCloseMetaV0{LedgerHeader: xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(seq)}}},
+		}
+
+		err := xdr.MarshalFramed(&stream, ledger)
+		assert.NoError(t, err)
+	}
+
+	return stream.Bytes()
+}
+
+func TestMetaStreamGetLedger(t *testing.T) {
+	type functionInput struct {
+		stream    []byte
+		sequences []uint32
+	}
+	type functionOutput struct {
+		read []uint32
+		err  error
+	}
+
+	stream := makeMetaStream(t, 5, 6, 7)
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{stream, []uint32{5, 6, 7}}, functionOutput{[]uint32{5, 6, 7}, nil}},
+		{functionInput{stream, []uint32{6, 6, 7}}, functionOutput{[]uint32{6, 6, 7}, nil}},
+		{
+			functionInput{stream, []uint32{4}},
+			functionOutput{[]uint32{}, fmt.Errorf("ledger 4 is not in the metadata stream, which is at ledger 5")},
+		},
+		{
+			functionInput{stream, []uint32{7, 6}},
+			functionOutput{[]uint32{7}, fmt.Errorf("ledger 6 is not in the metadata stream, which is at ledger 7")},
+		},
+		{
+			functionInput{stream, []uint32{6, 8}},
+			functionOutput{[]uint32{6}, fmt.Errorf("the metadata stream ended at ledger 7, before ledger 8")},
+		},
+		{
+			functionInput{[]byte{}, []uint32{1}},
+			functionOutput{[]uint32{}, fmt.Errorf("the metadata stream ended before ledger 1; it has no ledgers")},
+		},
+		{
+			functionInput{makeMetaStream(t, 5, 7), []uint32{7}},
+			functionOutput{[]uint32{}, fmt.Errorf("the metadata stream skips from ledger 5 to ledger 7")},
+		},
+		{
+			functionInput{stream[:len(stream)-2], []uint32{7}},
+			functionOutput{[]uint32{}, fmt.Errorf("unable to read the frame after ledger 6: unexpected EOF")},
+		},
+		{
+			functionInput{append([]byte{0, 0, 0, 4}, stream...), []uint32{5}},
+			functionOutput{[]uint32{}, fmt.Errorf("malformed frame header at the start of the metadata stream")},
+		},
+	}
+
+	for _, test := range tests {
+		backend := NewMetaStreamBackend(ioutil.NopCloser(bytes.NewReader(test.input.stream)), false)
+		read := []uint32{}
+		var err error
+		for _, seq := range test.input.sequences {
+			var ok bool
+			var ledger xdr.LedgerCloseMeta
+			ok, ledger, err = backend.GetLedger(seq)
+			if err != nil {
+				break
+			}
+
+			assert.True(t, ok)
+			read = append(read, ledger.LedgerSequence())
+		}
+
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.read, read)
+	}
+}
+
+func TestMetaStreamFollow(t *testing.T) {
+	file, err := ioutil.TempFile("", "meta-stream")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	_, err = file.Write(makeMetaStream(t, 10, 11))
+	assert.NoError(t, err)
+
+	backend, err := PrepareMetaStream(file.Name(), 11, 0)
+	assert.NoError(t, err)
+	defer backend.Close()
+
+	latest, err := backend.GetLatestLedgerSequence()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(11), latest)
+
+	_, ledger, err := backend.GetLedger(11)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(11), ledger.LedgerSequence())
+
+	// The next ledger is written while the backend waits for it, in two parts so that the backend reads a partial frame first
+	frame := makeMetaStream(t, 12)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		file.Write(frame[:6])
+		time.Sleep(100 * time.Millisecond)
+		file.Write(frame[6:])
+	}()
+
+	latest, err = backend.GetLatestLedgerSequence()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(12), latest)
+
+	_, ledger, err = backend.GetLedger(12)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(12), ledger.LedgerSequence())
+}
@@ -1,45 +1,41 @@
 package input
 
 import (
+	"time"
+
 	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/xdr"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
 
 // OperationTransformInput is a representation of the input for the TransformOperation function
 type OperationTransformInput struct {
-	Operation      xdr.Operation
-	OperationIndex int32
-	Transaction    ingestio.LedgerTransaction
-	LedgerSeqNum   int32
+	Operation       xdr.Operation
+	OperationIndex  int32
+	Transaction     ingestio.LedgerTransaction
+	LedgerSeqNum    int32
+	LedgerCloseTime time.Time
 }
 
-// GetOperations returns a slice of operations for the ledgers in the provided range (inclusive on both ends)
-func GetOperations(start, end uint32, limit int64) ([]OperationTransformInput, error) {
-	backend, err := utils.CreateBackend()
-	if err != nil {
-		return []OperationTransformInput{}, err
-	}
-
-	defer backend.Close()
-
-	latestNum, err := backend.GetLatestLedgerSequence()
-	if err != nil {
-		return []OperationTransformInput{}, err
-	}
-
-	err = validateLedgerRange(start, end, latestNum)
-	if err != nil {
-		return []OperationTransformInput{}, err
+// GetOperations returns a slice of operations for the ledgers in the provided range (inclusive on both ends), which are read from the backend. If verify is set, each ledger is checked against the hashes in its header as it is read
+func GetOperations(backend ledgerbackend.LedgerBackend, start, end uint32, limit int64, verify bool) ([]OperationTransformInput, error) {
+	opSlice := []OperationTransformInput{}
+	if verify {
+		backend = verifyingBackend{backend}
 	}
 
-	opSlice := []OperationTransformInput{}
 	for seq := start; seq <= end; seq++ {
 		txReader, err := ingestio.NewLedgerTransactionReader(backend, publicPassword, seq)
 		if err != nil {
 			return []OperationTransformInput{}, err
 		}
 
+		closeTime, err := utils.TimePointToUTCTimeStamp(txReader.GetHeader().Header.ScpValue.CloseTime)
+		if err != nil {
+			return []OperationTransformInput{}, err
+		}
+
 		for int64(len(opSlice)) < limit || limit < 0 {
 			tx, err := txReader.Read()
 			if err == ingestio.EOF {
@@ -48,10 +44,11 @@ func GetOperations(start, end uint32, limit int64) ([]OperationTransformInput, e
 
 			for index, op := range tx.Envelope.Operations() {
 				opSlice = append(opSlice, OperationTransformInput{
-					Operation:      op,
-					OperationIndex: int32(index),
-					Transaction:    tx,
-					LedgerSeqNum:   int32(seq),
+					Operation:       op,
+					OperationIndex:  int32(index),
+					Transaction:     tx,
+					LedgerSeqNum:    int32(seq),
+					LedgerCloseTime: closeTime,
 				})
 
 				if int64(len(opSlice)) >= limit && limit >= 0 {
@@ -1,10 +1,10 @@
 package input
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"sync"
+	"time"
 
 	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/ingest/ledgerbackend"
@@ -13,33 +13,34 @@ import (
 	"github.com/stellar/stellar-etl/internal/utils"
 )
 
-// OrderbookBatch represents a batch of orderbooks
+// OrderbookBatch represents a batch of orderbooks. Err is set instead when the orderbooks of the batch could not be read, and no batches follow it
 type OrderbookBatch struct {
 	BatchStart uint32
 	BatchEnd   uint32
 	Orderbooks map[uint32][]ingestio.Change
+	Err        error
 }
 
 // OrderbookParser handles parsing orderbooks
 type OrderbookParser struct {
-	Events            [][]byte
-	Markets           [][]byte
+	Events            []transform.FactOfferEvent
+	Markets           []transform.DimMarket
 	SeenMarketHashes  map[uint64]bool
-	Offers            [][]byte
+	Offers            []transform.DimOffer
 	SeenOfferHashes   map[uint64]bool
-	Accounts          [][]byte
+	Accounts          []transform.DimAccount
 	SeenAccountHashes map[uint64]bool
 	Logger            *log.Entry
 	Strict            bool
 }
 
-func (o *OrderbookParser) convertOffer(allConvertedOffers []transform.NormalizedOfferOutput, index int, offer ingestio.Change, seq uint32, wg *sync.WaitGroup) {
+func (o *OrderbookParser) convertOffer(allConvertedOffers []transform.NormalizedOfferOutput, errs []error, index int, offer ingestio.Change, seq uint32, wg *sync.WaitGroup) {
 	defer wg.Done()
 	transformed, err := transform.TransformOfferNormalized(offer, seq)
 	if err != nil {
-		errorMsg := fmt.Sprintf("error json marshalling offer #%d in ledger sequence number #%d", index, seq)
+		errorMsg := fmt.Sprintf("error encoding offer #%d in ledger sequence number #%d: ", index, seq)
 		if o.Strict {
-			o.Logger.Fatal(errorMsg, err)
+			errs[index] = fmt.Errorf("%s%v", errorMsg, err)
 		} else {
 			o.Logger.Warning(errorMsg, err)
 		}
@@ -50,146 +51,109 @@ func (o *OrderbookParser) convertOffer(allConvertedOffers []transform.Normalized
 
 func NewOrderbookParser(strictExport bool, logger *log.Entry) OrderbookParser {
 	return OrderbookParser{
-		Events:            make([][]byte, 0),
-		Markets:           make([][]byte, 0),
+		Events:            make([]transform.FactOfferEvent, 0),
+		Markets:           make([]transform.DimMarket, 0),
 		SeenMarketHashes:  make(map[uint64]bool),
-		Offers:            make([][]byte, 0),
+		Offers:            make([]transform.DimOffer, 0),
 		SeenOfferHashes:   make(map[uint64]bool),
-		Accounts:          make([][]byte, 0),
+		Accounts:          make([]transform.DimAccount, 0),
 		SeenAccountHashes: make(map[uint64]bool),
 		Logger:            logger,
 		Strict:            strictExport,
 	}
 }
 
-func (o *OrderbookParser) parseOrderbook(orderbook []ingestio.Change, seq uint32) {
+// parseOrderbook converts the offers of the orderbook, and keeps them along with the markets, accounts, and events that have not been seen yet.
+// If the parser is strict, an error is returned for the first offer that cannot be converted
+func (o *OrderbookParser) parseOrderbook(orderbook []ingestio.Change, seq uint32) error {
 	var group sync.WaitGroup
 	allConverted := make([]transform.NormalizedOfferOutput, len(orderbook))
+	errs := make([]error, len(orderbook))
 	for i, v := range orderbook {
 		group.Add(1)
-		go o.convertOffer(allConverted, i, v, seq, &group)
+		go o.convertOffer(allConverted, errs, i, v, seq, &group)
 	}
 
 	group.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 
 	for _, converted := range allConverted {
 		if _, exists := o.SeenMarketHashes[converted.Market.ID]; !exists {
 			o.SeenMarketHashes[converted.Market.ID] = true
-			marshalledMarket, err := json.Marshal(converted.Market)
-			if err != nil {
-				errorMsg := fmt.Sprintf("error json marshalling market for offer: %d", converted.Offer.HorizonID)
-				if o.Strict {
-					o.Logger.Fatal(errorMsg, err)
-				} else {
-					o.Logger.Warning(errorMsg, err)
-					continue
-				}
-			}
-
-			o.Markets = append(o.Markets, marshalledMarket)
+			o.Markets = append(o.Markets, converted.Market)
 		}
 
 		if _, exists := o.SeenAccountHashes[converted.Account.ID]; !exists {
 			o.SeenAccountHashes[converted.Account.ID] = true
-			marshalledAccount, err := json.Marshal(converted.Account)
-			if err != nil {
-				errorMsg := fmt.Sprintf("error json marshalling account for offer: %d", converted.Offer.HorizonID)
-				if o.Strict {
-					o.Logger.Fatal(errorMsg, err)
-				} else {
-					o.Logger.Warning(errorMsg, err)
-					continue
-				}
-			}
-
-			o.Accounts = append(o.Accounts, marshalledAccount)
+			o.Accounts = append(o.Accounts, converted.Account)
 		}
 
 		if _, exists := o.SeenOfferHashes[converted.Offer.DimOfferID]; !exists {
 			o.SeenOfferHashes[converted.Offer.DimOfferID] = true
-			marshalledOffer, err := json.Marshal(converted.Offer)
-			if err != nil {
-				errorMsg := fmt.Sprintf("error json marshalling offer: %d", converted.Offer.HorizonID)
-				if o.Strict {
-					o.Logger.Fatal(errorMsg, err)
-				} else {
-					o.Logger.Warning(errorMsg, err)
-					continue
-				}
-			}
-
-			o.Offers = append(o.Offers, marshalledOffer)
-
+			o.Offers = append(o.Offers, converted.Offer)
 		}
 
-		marshalledEvent, err := json.Marshal(converted.Event)
-		if err != nil {
-			errorMsg := fmt.Sprintf("error json marshalling event for offer: %d", converted.Offer.HorizonID)
-			if o.Strict {
-				o.Logger.Fatal(errorMsg, err)
-			} else {
-				o.Logger.Warning(errorMsg, err)
-				continue
-			}
-		} else {
-			o.Events = append(o.Events, marshalledEvent)
-		}
+		o.Events = append(o.Events, converted.Event)
 	}
+
+	return nil
 }
 
 // GetOfferChanges gets the offer changes that ocurred between the firstSeq ledger and nextSeq ledger
-func GetOfferChanges(core *ledgerbackend.CaptiveStellarCore, firstSeq, nextSeq uint32) (*ingestio.LedgerEntryChangeCache, error) {
+func GetOfferChanges(core ledgerbackend.LedgerBackend, firstSeq, nextSeq uint32) (*ingestio.LedgerEntryChangeCache, error) {
 	offChanges := ingestio.NewLedgerEntryChangeCache()
 
-	for seq := firstSeq; seq <= nextSeq; {
-		latestLedger, err := core.GetLatestLedgerSequence()
+	for seq := firstSeq; seq <= nextSeq; seq++ {
+		err := waitForLedger(core, seq)
 		if err != nil {
-			return nil, fmt.Errorf(fmt.Sprintf("unable to get latest ledger at ledger %d: ", seq), err)
+			return nil, fmt.Errorf("unable to get latest ledger at ledger %d: %v", seq, err)
 		}
 
-		// if this ledger is available, we can read its changes and move on to the next ledger by incrementing seq.
-		// Otherwise, nothing is incremented and we try again on the next iteration of the loop
-		if seq <= latestLedger {
-			changeReader, err := ingestio.NewLedgerChangeReader(core, password, seq)
-			if err != nil {
-				return nil, fmt.Errorf(fmt.Sprintf("unable to create change reader for ledger %d: ", seq), err)
-			}
-
-			err = addLedgerChangesToCache(changeReader, nil, offChanges, nil)
-			if err != nil {
-				return nil, fmt.Errorf(fmt.Sprintf("unable to read changes from ledger %d: ", seq), err)
-			}
+		changeReader, err := ingestio.NewLedgerChangeReader(core, password, seq)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create change reader for ledger %d: %v", seq, err)
+		}
 
-			changeReader.Close()
-			seq++
+		err = addLedgerChangesToCache(changeReader, nil, offChanges, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read changes from ledger %d: %v", seq, err)
 		}
+
+		changeReader.Close()
 	}
 
 	return offChanges, nil
 }
 
-func exportOrderbookBatch(batchStart, batchEnd uint32, core *ledgerbackend.CaptiveStellarCore, orderbookChan chan OrderbookBatch, startOrderbook []ingestio.Change, logger *log.Entry) {
+// exportOrderbookBatch sends the orderbooks of the ledgers in the range [batchStart, batchEnd) to the channel, starting from the orderbook at ledger
+// orderbookSeq, and returns the orderbook at the last ledger of the batch. If they cannot be read, an error is returned and nothing is sent
+func exportOrderbookBatch(batchStart, batchEnd, orderbookSeq uint32, core ledgerbackend.LedgerBackend, orderbookChan chan OrderbookBatch, orderbook []ingestio.Change, logger *log.Entry) ([]ingestio.Change, error) {
 	batchMap := make(map[uint32][]ingestio.Change)
-	batchMap[batchStart] = make([]ingestio.Change, len(startOrderbook))
-	copy(batchMap[batchStart], startOrderbook)
+	for seq := batchStart; seq < batchEnd; seq++ {
+		// if the ledger sequence cannot be read, we wait before trying again
+		for {
+			err := waitForLedger(core, seq)
+			if err == nil {
+				break
+			}
 
-	prevSeq := batchStart
-	curSeq := batchStart + 1
-	for curSeq < batchEnd {
-		latestLedger, err := core.GetLatestLedgerSequence()
-		if err != nil {
 			logger.Error("unable to get the lastest ledger sequence: ", err)
+			time.Sleep(corePollInterval)
 		}
 
-		// if this ledger is available, we process its changes and move on to the next ledger by incrementing seq.
-		// Otherwise, nothing is incremented and we try again on the next iteration of the loop
-		if curSeq <= latestLedger {
-			UpdateOrderbook(prevSeq, curSeq, startOrderbook, core, logger)
-			batchMap[curSeq] = make([]ingestio.Change, len(startOrderbook))
-			copy(batchMap[curSeq], startOrderbook)
-			prevSeq = curSeq
-			curSeq++
+		var err error
+		orderbook, err = UpdateOrderbook(orderbookSeq, seq, orderbook, core)
+		if err != nil {
+			return nil, err
 		}
+
+		// The updated orderbook is a new slice, so the orderbooks of earlier ledgers are left untouched
+		batchMap[seq] = orderbook
+		orderbookSeq = seq
 	}
 
 	batch := OrderbookBatch{
@@ -199,32 +163,40 @@ func exportOrderbookBatch(batchStart, batchEnd uint32, core *ledgerbackend.Capti
 	}
 
 	orderbookChan <- batch
+	return orderbook, nil
 }
 
-// UpdateOrderbook updates an orderbook at ledger start to its state at ledger end
-func UpdateOrderbook(start, end uint32, orderbook []ingestio.Change, core *ledgerbackend.CaptiveStellarCore, logger *log.Entry) {
+// UpdateOrderbook returns the orderbook at ledger end, given the orderbook at ledger start. The changes of ledger start are already part of the orderbook,
+// so only the ledgers after it are read
+func UpdateOrderbook(start, end uint32, orderbook []ingestio.Change, core ledgerbackend.LedgerBackend) ([]ingestio.Change, error) {
 	if start > end {
-		logger.Fatalf("unable to update orderbook start ledger %d is after end %d: ", start, end)
+		return nil, fmt.Errorf("unable to update orderbook start ledger %d is after end %d", start, end)
 	}
 
-	changeCache, err := GetOfferChanges(core, start, end)
-	if err != nil {
-		logger.Fatal(fmt.Sprintf("unable to get offer changes between ledger %d and %d: ", start, end), err)
+	if start == end {
+		return orderbook, nil
 	}
 
-	for _, change := range orderbook {
-		changeCache.AddChange(change)
+	changeCache, err := GetOfferChanges(core, start+1, end)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get offer changes between ledger %d and %d: %v", start, end, err)
 	}
 
-	orderbook = changeCache.GetChanges()
+	return applyOfferChanges(orderbook, changeCache.GetChanges()), nil
 }
 
-// StreamOrderbooks exports all the batches of orderbooks between start and end to the orderbookChannel. If end is 0, then it exports in an unbounded fashion
-func StreamOrderbooks(core *ledgerbackend.CaptiveStellarCore, start, end, batchSize uint32, orderbookChannel chan OrderbookBatch, startOrderbook []ingestio.Change, logger *log.Entry) {
+// StreamOrderbooks exports all the batches of orderbooks between start and end to the orderbookChannel. If end is 0, then it exports in an unbounded fashion.
+// If the orderbooks of a batch cannot be read, a batch with the error is sent and the export stops
+func StreamOrderbooks(core ledgerbackend.LedgerBackend, start, end, batchSize uint32, orderbookChannel chan OrderbookBatch, startOrderbook []ingestio.Change, logger *log.Entry) {
 	// The initial orderbook is at the checkpoint sequence, not the start of the range, so it needs to be updated
 	checkpointSeq := utils.GetMostRecentCheckpoint(start)
-	UpdateOrderbook(checkpointSeq, start, startOrderbook, core, logger)
+	orderbook, err := UpdateOrderbook(checkpointSeq, start, startOrderbook, core)
+	if err != nil {
+		orderbookChannel <- OrderbookBatch{Err: err}
+		return
+	}
 
+	orderbookSeq := start
 	if end != 0 {
 		totalBatches := uint32(math.Ceil(float64(end-start+1) / float64(batchSize)))
 		for currentBatch := uint32(0); currentBatch < totalBatches; currentBatch++ {
@@ -234,21 +206,32 @@ func StreamOrderbooks(core *ledgerbackend.CaptiveStellarCore, start, end, batchS
 				batchEnd = end + 1
 			}
 
-			exportOrderbookBatch(batchStart, batchEnd, core, orderbookChannel, startOrderbook, logger)
+			if orderbook, err = exportOrderbookBatch(batchStart, batchEnd, orderbookSeq, core, orderbookChannel, orderbook, logger); err != nil {
+				orderbookChannel <- OrderbookBatch{BatchStart: batchStart, BatchEnd: batchEnd, Err: err}
+				return
+			}
+
+			orderbookSeq = batchEnd - 1
 		}
 	} else {
 		batchStart := start
 		batchEnd := batchStart + batchSize
 		for {
-			exportOrderbookBatch(batchStart, batchEnd, core, orderbookChannel, startOrderbook, logger)
+			if orderbook, err = exportOrderbookBatch(batchStart, batchEnd, orderbookSeq, core, orderbookChannel, orderbook, logger); err != nil {
+				orderbookChannel <- OrderbookBatch{BatchStart: batchStart, BatchEnd: batchEnd, Err: err}
+				return
+			}
+
+			orderbookSeq = batchEnd - 1
 			batchStart = batchEnd
 			batchEnd = batchStart + batchSize
 		}
 	}
 }
 
-// ReceiveParsedOrderbooks reads a batch from the orderbookChannel, parses it using an orderbook parser, and returns the parser.
-func ReceiveParsedOrderbooks(orderbookChannel chan OrderbookBatch, strictExport bool, logger *log.Entry) *OrderbookParser {
+// ReceiveParsedOrderbooks reads a batch from the orderbookChannel, parses it using an orderbook parser, and returns the parser. If the batch could not be
+// read, or the parser is strict and cannot parse it, an error is returned
+func ReceiveParsedOrderbooks(orderbookChannel chan OrderbookBatch, strictExport bool, logger *log.Entry) (*OrderbookParser, error) {
 	batchParser := NewOrderbookParser(strictExport, logger)
 	batchRead := false
 	for {
@@ -260,8 +243,14 @@ func ReceiveParsedOrderbooks(orderbookChannel chan OrderbookBatch, strictExport
 				break
 			}
 
+			if batch.Err != nil {
+				return nil, batch.Err
+			}
+
 			for seq, orderbook := range batch.Orderbooks {
-				batchParser.parseOrderbook(orderbook, seq)
+				if err := batchParser.parseOrderbook(orderbook, seq); err != nil {
+					return nil, err
+				}
 			}
 
 			batchRead = true
@@ -273,5 +262,5 @@ func ReceiveParsedOrderbooks(orderbookChannel chan OrderbookBatch, strictExport
 		}
 	}
 
-	return &batchParser
+	return &batchParser, nil
 }
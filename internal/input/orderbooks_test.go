@@ -0,0 +1,93 @@
+package input
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+)
+
+// upgradeBackend serves ledgers whose only changes are the upgrade changes in the map
+type upgradeBackend struct {
+	latest  uint32
+	changes map[uint32]xdr.LedgerEntryChanges
+}
+
+func (b *upgradeBackend) GetLatestLedgerSequence() (uint32, error) {
+	return b.latest, nil
+}
+
+func (b *upgradeBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	changes, ok := b.changes[sequence]
+	if !ok {
+		return false, xdr.LedgerCloseMeta{}, fmt.Errorf("ledger %d should not be read", sequence)
+	}
+
+	return true, xdr.LedgerCloseMeta{
+		V: 0,
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader:       xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: xdr.Uint32(sequence)}},
+			UpgradesProcessing: []xdr.UpgradeEntryMeta{{Changes: changes}},
+		},
+	}, nil
+}
+
+func (b *upgradeBackend) PrepareRange(ledgerRange ledgerbackend.Range) error {
+	return nil
+}
+
+func (b *upgradeBackend) IsPrepared(ledgerRange ledgerbackend.Range) (bool, error) {
+	return true, nil
+}
+
+func (b *upgradeBackend) Close() error {
+	return nil
+}
+
+func TestUpdateOrderbook(t *testing.T) {
+	firstOffer, secondOffer := makeOfferEntry(1, 100), makeOfferEntry(2, 200)
+	updatedFirstOffer := *firstOffer
+	updatedOfferEntry := *firstOffer.Data.Offer
+	updatedOfferEntry.Amount = 50
+	updatedFirstOffer.Data.Offer = &updatedOfferEntry
+
+	orderbook := []ingestio.Change{
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: firstOffer},
+		{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: secondOffer},
+	}
+
+	// The orderbook is at ledger 10, so only ledgers 11 and 12 are read
+	backend := &upgradeBackend{latest: 12, changes: map[uint32]xdr.LedgerEntryChanges{
+		11: {
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: firstOffer},
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &updatedFirstOffer},
+		},
+		12: {
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: secondOffer},
+			{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &xdr.LedgerKey{
+				Type:  xdr.LedgerEntryTypeOffer,
+				Offer: &xdr.LedgerKeyOffer{SellerId: secondOffer.Data.Offer.SellerId, OfferId: 2},
+			}},
+		},
+	}}
+
+	unchanged, err := UpdateOrderbook(10, 10, orderbook, backend)
+	assert.NoError(t, err)
+	assert.Equal(t, orderbook, unchanged)
+
+	updated, err := UpdateOrderbook(10, 12, orderbook, backend)
+	assert.NoError(t, err)
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].Post.Data.Offer.OfferId < updated[j].Post.Data.Offer.OfferId
+	})
+
+	assert.Equal(t, []ingestio.Change{{Type: xdr.LedgerEntryTypeOffer, Pre: nil, Post: &updatedFirstOffer}}, updated)
+
+	_, err = UpdateOrderbook(12, 10, orderbook, backend)
+	assert.EqualError(t, err, "unable to update orderbook start ledger 12 is after end 10")
+}
@@ -0,0 +1,247 @@
+package input
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+const (
+	// maxRecordedLedgers is the number of exported ledgers that the export state remembers
+	maxRecordedLedgers = 64
+
+	// resetTolerance is how far the exported ledgers may be ahead of the history archive, which publishes a checkpoint some time after it closes
+	resetTolerance = 4 * checkpointFrequency
+)
+
+// ExportedLedger is a ledger that has been exported, and the hash that it had when it was exported
+type ExportedLedger struct {
+	Sequence uint32 `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+/*
+	ExportState is kept in a state file next to the output of an export. It holds the namespace that the output is written to, and the
+	hashes of the most recently exported ledgers, which identify the chain that they came from. Test networks are reset from time to
+	time, after which the same ledger numbers belong to a different chain.
+*/
+type ExportState struct {
+	Namespace string           `json:"namespace"`
+	Ledgers   []ExportedLedger `json:"ledgers"`
+}
+
+// LoadExportState reads the export state from the file at path. If the file does not exist, an empty state is returned
+func LoadExportState(path string) (ExportState, error) {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ExportState{}, nil
+	}
+
+	if err != nil {
+		return ExportState{}, err
+	}
+
+	var state ExportState
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return ExportState{}, fmt.Errorf("could not parse the state file %s: %v", path, err)
+	}
+
+	return state, nil
+}
+
+// Save writes the export state to the file at path. The state is written to a temporary file that replaces the old one, so a crash never leaves a partial state file
+func (s ExportState) Save(path string) error {
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(contents); err != nil {
+		temp.Close()
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// Record adds an exported ledger to the state. Only the most recent ledgers are kept
+func (s *ExportState) Record(sequence uint32, hash string) {
+	s.Ledgers = append(s.Ledgers, ExportedLedger{Sequence: sequence, Hash: hash})
+	if len(s.Ledgers) > maxRecordedLedgers {
+		s.Ledgers = s.Ledgers[len(s.Ledgers)-maxRecordedLedgers:]
+	}
+}
+
+// oldest returns the exported ledger with the lowest sequence number. The state must have ledgers
+func (s ExportState) oldest() ExportedLedger {
+	oldest := s.Ledgers[0]
+	for _, ledger := range s.Ledgers {
+		if ledger.Sequence < oldest.Sequence {
+			oldest = ledger
+		}
+	}
+
+	return oldest
+}
+
+// NetworkResetError is returned when the ledgers in the history archive are not the ones that were exported
+type NetworkResetError struct {
+	Sequence     uint32
+	ExportedHash string
+	ArchiveHash  string
+	LatestLedger uint32
+}
+
+func (e *NetworkResetError) Error() string {
+	if e.ArchiveHash == "" {
+		return fmt.Sprintf("network reset detected: ledger %d was exported, but the latest ledger in the history archive is %d", e.Sequence, e.LatestLedger)
+	}
+
+	return fmt.Sprintf("network reset detected: ledger %d was exported with hash %s, but the history archive has hash %s", e.Sequence, e.ExportedHash, e.ArchiveHash)
+}
+
+// ledgerHeaderArchive is the part of a history archive that is needed to compare exported ledgers with it
+type ledgerHeaderArchive interface {
+	GetLedgerHeader(ledger uint32) (xdr.LedgerHeaderHistoryEntry, error)
+}
+
+/*
+	verifyExportedLedgers compares the newest exported ledger that the archive holds with the header in the archive. Ledgers up to after
+	have already been verified and are skipped. The sequence number of the newest verified ledger is returned. If none of the exported
+	ledgers are in the archive yet, they can only be checked once it catches up, unless the archive is so far behind that it must have
+	been reset.
+*/
+func verifyExportedLedgers(state ExportState, after uint32, archive ledgerHeaderArchive, available ArchiveRange) (uint32, error) {
+	if len(state.Ledgers) == 0 {
+		return after, nil
+	}
+
+	oldest := state.oldest()
+	if available.LatestLedger+resetTolerance < oldest.Sequence {
+		return after, &NetworkResetError{Sequence: oldest.Sequence, ExportedHash: oldest.Hash, LatestLedger: available.LatestLedger}
+	}
+
+	var newest *ExportedLedger
+	for i, ledger := range state.Ledgers {
+		if ledger.Sequence < available.EarliestLedger() || ledger.Sequence > available.LatestLedger {
+			continue
+		}
+
+		if newest == nil || ledger.Sequence > newest.Sequence {
+			newest = &state.Ledgers[i]
+		}
+	}
+
+	if newest == nil || newest.Sequence <= after {
+		return after, nil
+	}
+
+	header, err := archive.GetLedgerHeader(newest.Sequence)
+	if err != nil {
+		return after, fmt.Errorf("could not read the header of ledger %d from the history archive: %v", newest.Sequence, err)
+	}
+
+	archiveHash := utils.HashToHexString(header.Hash)
+	if archiveHash != newest.Hash {
+		return after, &NetworkResetError{Sequence: newest.Sequence, ExportedHash: newest.Hash, ArchiveHash: archiveHash, LatestLedger: available.LatestLedger}
+	}
+
+	return newest.Sequence, nil
+}
+
+// ExportVerifier compares exported ledgers with the history archive, over a single connection to it that is reused for every check
+type ExportVerifier struct {
+	archive *historyarchive.Archive
+}
+
+// NewExportVerifier connects to the history archive
+func NewExportVerifier() (*ExportVerifier, error) {
+	archive, err := historyarchive.Connect(
+		archiveStellarURL,
+		historyarchive.ConnectOptions{Context: context.Background()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportVerifier{archive: archive}, nil
+}
+
+// Verify checks that the exported ledgers in the state are still the ones in the history archive. If the network has been reset, a *NetworkResetError is returned
+func (v *ExportVerifier) Verify(state ExportState, after uint32) (uint32, error) {
+	if len(state.Ledgers) == 0 {
+		return after, nil
+	}
+
+	has, err := v.archive.GetRootHAS()
+	if err != nil {
+		return after, err
+	}
+
+	available, err := archiveRangeFrom(v.archive, state.oldest().Sequence, has.CurrentLedger)
+	if err != nil {
+		return after, err
+	}
+
+	return verifyExportedLedgers(state, after, v.archive, available)
+}
+
+// HashRecorder is a ledger backend that remembers the hashes of the ledgers that are read from the backend that it wraps
+type HashRecorder struct {
+	ledgerbackend.LedgerBackend
+	lock   sync.Mutex
+	hashes map[uint32]string
+}
+
+// NewHashRecorder wraps the backend in a HashRecorder
+func NewHashRecorder(backend ledgerbackend.LedgerBackend) *HashRecorder {
+	return &HashRecorder{LedgerBackend: backend, hashes: map[uint32]string{}}
+}
+
+// GetLedger reads the ledger from the wrapped backend and records its hash
+func (r *HashRecorder) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	ok, meta, err := r.LedgerBackend.GetLedger(sequence)
+	if ok && err == nil {
+		if v0, isV0 := meta.GetV0(); isV0 {
+			r.lock.Lock()
+			r.hashes[sequence] = utils.HashToHexString(v0.LedgerHeader.Hash)
+			r.lock.Unlock()
+		}
+	}
+
+	return ok, meta, err
+}
+
+// TakeHash returns the hash of the ledger with the provided sequence number, and forgets the hashes of that ledger and the ones before it
+func (r *HashRecorder) TakeHash(sequence uint32) (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	hash, ok := r.hashes[sequence]
+	for seq := range r.hashes {
+		if seq <= sequence {
+			delete(r.hashes, seq)
+		}
+	}
+
+	return hash, ok
+}
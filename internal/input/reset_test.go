@@ -0,0 +1,120 @@
+package input
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// headerArchive holds ledgers whose hashes are their sequence numbers
+type headerArchive struct {
+	requests []uint32
+}
+
+func (a *headerArchive) GetLedgerHeader(ledger uint32) (xdr.LedgerHeaderHistoryEntry, error) {
+	a.requests = append(a.requests, ledger)
+	return xdr.LedgerHeaderHistoryEntry{Hash: xdr.Hash{byte(ledger >> 8), byte(ledger)}}, nil
+}
+
+func archiveHash(ledger uint32) string {
+	return utils.HashToHexString(xdr.Hash{byte(ledger >> 8), byte(ledger)})
+}
+
+func TestVerifyExportedLedgers(t *testing.T) {
+	type functionInput struct {
+		ledgers   []ExportedLedger
+		after     uint32
+		available ArchiveRange
+	}
+	type functionOutput struct {
+		verified uint32
+		requests []uint32
+		err      error
+	}
+
+	available := ArchiveRange{EarliestCheckpoint: 63, LatestLedger: 1023}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{nil, 0, available},
+			functionOutput{0, nil, nil},
+		},
+		{
+			functionInput{[]ExportedLedger{{500, archiveHash(500)}, {600, archiveHash(600)}, {1100, "ahead"}}, 0, available},
+			functionOutput{600, []uint32{600}, nil},
+		},
+		{
+			functionInput{[]ExportedLedger{{500, archiveHash(500)}, {600, archiveHash(600)}}, 600, available},
+			functionOutput{600, nil, nil},
+		},
+		{
+			functionInput{[]ExportedLedger{{1100, "ahead"}, {1200, "ahead"}}, 0, available},
+			functionOutput{0, nil, nil},
+		},
+		{
+			functionInput{[]ExportedLedger{{500, archiveHash(500)}, {600, "old chain"}}, 0, available},
+			functionOutput{0, []uint32{600}, &NetworkResetError{Sequence: 600, ExportedHash: "old chain", ArchiveHash: archiveHash(600), LatestLedger: 1023}},
+		},
+		{
+			functionInput{[]ExportedLedger{{400000, "old chain"}, {400064, "old chain"}}, 0, available},
+			functionOutput{0, nil, &NetworkResetError{Sequence: 400000, ExportedHash: "old chain", LatestLedger: 1023}},
+		},
+		{
+			functionInput{[]ExportedLedger{{100, "pruned"}, {700, archiveHash(700)}}, 0, ArchiveRange{EarliestCheckpoint: 511, LatestLedger: 1023}},
+			functionOutput{700, []uint32{700}, nil},
+		},
+	}
+
+	for _, test := range tests {
+		archive := &headerArchive{}
+		verified, err := verifyExportedLedgers(ExportState{Ledgers: test.input.ledgers}, test.input.after, archive, test.input.available)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.verified, verified)
+		assert.Equal(t, test.output.requests, archive.requests)
+	}
+}
+
+func TestNetworkResetError(t *testing.T) {
+	err := &NetworkResetError{Sequence: 600, ExportedHash: "aa", ArchiveHash: "bb", LatestLedger: 1023}
+	assert.EqualError(t, err, "network reset detected: ledger 600 was exported with hash aa, but the history archive has hash bb")
+
+	err = &NetworkResetError{Sequence: 400000, ExportedHash: "aa", LatestLedger: 1023}
+	assert.EqualError(t, err, "network reset detected: ledger 400000 was exported, but the latest ledger in the history archive is 1023")
+}
+
+func TestExportState(t *testing.T) {
+	folder, err := ioutil.TempDir("", "export-state")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	path := filepath.Join(folder, "state.json")
+	state, err := LoadExportState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ExportState{}, state)
+
+	state.Namespace = "reset-20201201T103015Z"
+	for seq := uint32(1); seq <= maxRecordedLedgers+10; seq++ {
+		state.Record(seq*64-1, fmt.Sprintf("hash-%d", seq))
+	}
+
+	assert.Len(t, state.Ledgers, maxRecordedLedgers)
+	assert.Equal(t, ExportedLedger{Sequence: 11*64 - 1, Hash: "hash-11"}, state.Ledgers[0])
+	assert.NoError(t, state.Save(path))
+
+	loaded, err := LoadExportState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded)
+
+	files, err := ioutil.ReadDir(folder)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
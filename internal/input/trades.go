@@ -6,6 +6,7 @@ import (
 	"github.com/stellar/stellar-etl/internal/toid"
 
 	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/xdr"
 	"github.com/stellar/stellar-etl/internal/utils"
 )
@@ -18,26 +19,13 @@ type TradeTransformInput struct {
 	OperationHistoryID int64
 }
 
-// GetTrades returns a slice of trades for the ledgers in the provided range (inclusive on both ends)
-func GetTrades(start, end uint32, limit int64) ([]TradeTransformInput, error) {
-	backend, err := utils.CreateBackend()
-	if err != nil {
-		return []TradeTransformInput{}, err
-	}
-
-	defer backend.Close()
-
-	latestNum, err := backend.GetLatestLedgerSequence()
-	if err != nil {
-		return []TradeTransformInput{}, err
-	}
-
-	err = validateLedgerRange(start, end, latestNum)
-	if err != nil {
-		return []TradeTransformInput{}, err
+// GetTrades returns a slice of trades for the ledgers in the provided range (inclusive on both ends), which are read from the backend. If verify is set, each ledger is checked against the hashes in its header as it is read
+func GetTrades(backend ledgerbackend.LedgerBackend, start, end uint32, limit int64, verify bool) ([]TradeTransformInput, error) {
+	tradeSlice := []TradeTransformInput{}
+	if verify {
+		backend = verifyingBackend{backend}
 	}
 
-	tradeSlice := []TradeTransformInput{}
 	for seq := start; seq <= end; seq++ {
 		txReader, err := ingestio.NewLedgerTransactionReader(backend, publicPassword, seq)
 		if err != nil {
@@ -2,9 +2,9 @@ package input
 
 import (
 	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/network"
 	"github.com/stellar/go/xdr"
-	"github.com/stellar/stellar-etl/internal/utils"
 )
 
 // LedgerTransformInput is a representation of the input for the TransformTransaction function
@@ -15,26 +15,13 @@ type LedgerTransformInput struct {
 
 var publicPassword = network.PublicNetworkPassphrase
 
-// GetTransactions returns a slice of ledger close metas for the ledgers in the provided range (inclusive on both ends)
-func GetTransactions(start, end uint32, limit int64) ([]LedgerTransformInput, error) {
-	backend, err := utils.CreateBackend()
-	if err != nil {
-		return []LedgerTransformInput{}, err
-	}
-
-	defer backend.Close()
-
-	latestNum, err := backend.GetLatestLedgerSequence()
-	if err != nil {
-		return []LedgerTransformInput{}, err
-	}
-
-	err = validateLedgerRange(start, end, latestNum)
-	if err != nil {
-		return []LedgerTransformInput{}, err
+// GetTransactions returns a slice of ledger close metas for the ledgers in the provided range (inclusive on both ends), which are read from the backend. If verify is set, each ledger is checked against the hashes in its header as it is read
+func GetTransactions(backend ledgerbackend.LedgerBackend, start, end uint32, limit int64, verify bool) ([]LedgerTransformInput, error) {
+	txSlice := []LedgerTransformInput{}
+	if verify {
+		backend = verifyingBackend{backend}
 	}
 
-	txSlice := []LedgerTransformInput{}
 	for seq := start; seq <= end; seq++ {
 		txReader, err := ingestio.NewLedgerTransactionReader(backend, publicPassword, seq)
 		if err != nil {
@@ -0,0 +1,111 @@
+package input
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// VerifyLedger recomputes the transaction set hash and the transaction result set hash of the ledger, and returns an error if either
+// of them differs from the hash stored in the ledger header
+func VerifyLedger(ledger xdr.LedgerCloseMeta) error {
+	header := ledger.V0.LedgerHeader.Header
+	seq := uint32(header.LedgerSeq)
+
+	txSetHash, err := HashTransactionSet(header.PreviousLedgerHash, ledger.V0.TxSet.Txs)
+	if err != nil {
+		return fmt.Errorf("could not hash transaction set of ledger %d: %v", seq, err)
+	}
+
+	if txSetHash != header.ScpValue.TxSetHash {
+		return fmt.Errorf("transaction set hash mismatch in ledger %d: header has %s but the transaction set hashes to %s",
+			seq, utils.HashToHexString(header.ScpValue.TxSetHash), utils.HashToHexString(txSetHash))
+	}
+
+	resultSetHash, err := hashTransactionResultSet(ledger.V0.TxProcessing)
+	if err != nil {
+		return fmt.Errorf("could not hash transaction result set of ledger %d: %v", seq, err)
+	}
+
+	if resultSetHash != header.TxSetResultHash {
+		return fmt.Errorf("transaction result set hash mismatch in ledger %d: header has %s but the result set hashes to %s",
+			seq, utils.HashToHexString(header.TxSetResultHash), utils.HashToHexString(resultSetHash))
+	}
+
+	return nil
+}
+
+// verifyingBackend is a ledger backend that verifies each ledger that is read from the backend that it wraps, so that ledgers are checked without being read twice
+type verifyingBackend struct {
+	ledgerbackend.LedgerBackend
+}
+
+// GetLedger reads the ledger from the wrapped backend and verifies it
+func (b verifyingBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	ok, ledger, err := b.LedgerBackend.GetLedger(sequence)
+	if err != nil || !ok {
+		return ok, ledger, err
+	}
+
+	if err := VerifyLedger(ledger); err != nil {
+		return false, xdr.LedgerCloseMeta{}, err
+	}
+
+	return true, ledger, nil
+}
+
+/*
+	HashTransactionSet follows the way stellar-core hashes transaction sets: the hash of the previous ledger is followed by the XDR of each
+	transaction envelope, with the envelopes ordered by their own hashes. The previous ledger hash is taken from the header, since the
+	history archives do not store transaction sets for ledgers without transactions.
+*/
+func HashTransactionSet(previousLedgerHash xdr.Hash, envelopes []xdr.TransactionEnvelope) (xdr.Hash, error) {
+	type hashedEnvelope struct {
+		hash [sha256.Size]byte
+		raw  []byte
+	}
+
+	hashed := make([]hashedEnvelope, 0, len(envelopes))
+	for _, envelope := range envelopes {
+		raw, err := envelope.MarshalBinary()
+		if err != nil {
+			return xdr.Hash{}, err
+		}
+
+		hashed = append(hashed, hashedEnvelope{hash: sha256.Sum256(raw), raw: raw})
+	}
+
+	sort.Slice(hashed, func(i, j int) bool {
+		return bytes.Compare(hashed[i].hash[:], hashed[j].hash[:]) < 0
+	})
+
+	hasher := sha256.New()
+	hasher.Write(previousLedgerHash[:])
+	for _, envelope := range hashed {
+		hasher.Write(envelope.raw)
+	}
+
+	var txSetHash xdr.Hash
+	copy(txSetHash[:], hasher.Sum(nil))
+	return txSetHash, nil
+}
+
+// hashTransactionResultSet hashes the XDR of the transaction result set made up of the results of the provided transactions
+func hashTransactionResultSet(txProcessing []xdr.TransactionResultMeta) (xdr.Hash, error) {
+	resultSet := xdr.TransactionResultSet{Results: make([]xdr.TransactionResultPair, 0, len(txProcessing))}
+	for _, meta := range txProcessing {
+		resultSet.Results = append(resultSet.Results, meta.Result)
+	}
+
+	raw, err := resultSet.MarshalBinary()
+	if err != nil {
+		return xdr.Hash{}, err
+	}
+
+	return xdr.Hash(sha256.Sum256(raw)), nil
+}
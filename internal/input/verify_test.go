@@ -0,0 +1,161 @@
+package input
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+func TestVerifyLedger(t *testing.T) {
+	previousLedgerHash := xdr.Hash{1, 2, 3}
+	firstEnvelope := utils.CreateSampleTx(1)
+	secondEnvelope := utils.CreateSampleTx(2)
+	results := []xdr.TransactionResultMeta{
+		makeVerifyTestResult(xdr.Hash{1}, xdr.TransactionResultCodeTxBadSeq),
+		makeVerifyTestResult(xdr.Hash{2}, xdr.TransactionResultCodeTxInsufficientFee),
+	}
+
+	// An empty transaction set hashes to the hash of the previous ledger, and an empty result set is a zero length XDR array
+	emptyTxSetHash := xdr.Hash(sha256.Sum256(previousLedgerHash[:]))
+	emptyResultSetHash := xdr.Hash(sha256.Sum256([]byte{0, 0, 0, 0}))
+
+	txSetHash := makeTxSetHash(t, previousLedgerHash, firstEnvelope, secondEnvelope)
+	resultSetHash, err := hashTransactionResultSet(results)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		input   xdr.LedgerCloseMeta
+		wantErr error
+	}{
+		{
+			name:    "empty ledger",
+			input:   makeVerifyTestLedger(previousLedgerHash, emptyTxSetHash, emptyResultSetHash, nil, nil),
+			wantErr: nil,
+		},
+		{
+			name:    "transactions in apply order",
+			input:   makeVerifyTestLedger(previousLedgerHash, txSetHash, resultSetHash, []xdr.TransactionEnvelope{secondEnvelope, firstEnvelope}, results),
+			wantErr: nil,
+		},
+		{
+			name:    "transactions in hash order",
+			input:   makeVerifyTestLedger(previousLedgerHash, txSetHash, resultSetHash, []xdr.TransactionEnvelope{firstEnvelope, secondEnvelope}, results),
+			wantErr: nil,
+		},
+		{
+			name:  "missing transaction",
+			input: makeVerifyTestLedger(previousLedgerHash, txSetHash, resultSetHash, []xdr.TransactionEnvelope{firstEnvelope}, results),
+			wantErr: fmt.Errorf("transaction set hash mismatch in ledger 10: header has %s but the transaction set hashes to %s",
+				utils.HashToHexString(txSetHash), utils.HashToHexString(makeTxSetHash(t, previousLedgerHash, firstEnvelope))),
+		},
+		{
+			name:  "tampered result",
+			input: makeVerifyTestLedger(previousLedgerHash, txSetHash, resultSetHash, []xdr.TransactionEnvelope{firstEnvelope, secondEnvelope}, results[:1]),
+			wantErr: fmt.Errorf("transaction result set hash mismatch in ledger 10: header has %s but the result set hashes to %s",
+				utils.HashToHexString(resultSetHash), utils.HashToHexString(mustHashResults(t, results[:1]))),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, VerifyLedger(tt.input))
+		})
+	}
+}
+
+// countingBackend serves a single ledger and counts the times that it is read
+type countingBackend struct {
+	ledgerbackend.LedgerBackend
+	ledger xdr.LedgerCloseMeta
+	reads  int
+}
+
+func (b *countingBackend) GetLedger(sequence uint32) (bool, xdr.LedgerCloseMeta, error) {
+	b.reads++
+	return sequence == b.ledger.LedgerSequence(), b.ledger, nil
+}
+
+func TestGetTransactionsVerifiesLedgerThatIsRead(t *testing.T) {
+	previousLedgerHash := xdr.Hash{1, 2, 3}
+	emptyTxSetHash := xdr.Hash(sha256.Sum256(previousLedgerHash[:]))
+	emptyResultSetHash := xdr.Hash(sha256.Sum256([]byte{0, 0, 0, 0}))
+
+	backend := &countingBackend{ledger: makeVerifyTestLedger(previousLedgerHash, emptyTxSetHash, emptyResultSetHash, nil, nil)}
+	_, err := GetTransactions(backend, 10, 10, -1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, backend.reads)
+
+	tampered := &countingBackend{ledger: makeVerifyTestLedger(previousLedgerHash, xdr.Hash{}, emptyResultSetHash, nil, nil)}
+	_, err = GetTransactions(tampered, 10, 10, -1, true)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "transaction set hash mismatch in ledger 10")
+	assert.Equal(t, 1, tampered.reads)
+}
+
+func makeVerifyTestLedger(previousLedgerHash, txSetHash, resultSetHash xdr.Hash, envelopes []xdr.TransactionEnvelope, results []xdr.TransactionResultMeta) xdr.LedgerCloseMeta {
+	return xdr.LedgerCloseMeta{
+		V: 0,
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{
+				Header: xdr.LedgerHeader{
+					LedgerSeq:          10,
+					PreviousLedgerHash: previousLedgerHash,
+					ScpValue:           xdr.StellarValue{TxSetHash: txSetHash},
+					TxSetResultHash:    resultSetHash,
+				},
+			},
+			TxSet:        xdr.TransactionSet{PreviousLedgerHash: previousLedgerHash, Txs: envelopes},
+			TxProcessing: results,
+		},
+	}
+}
+
+func makeVerifyTestResult(txHash xdr.Hash, code xdr.TransactionResultCode) xdr.TransactionResultMeta {
+	return xdr.TransactionResultMeta{
+		Result: xdr.TransactionResultPair{
+			TransactionHash: txHash,
+			Result: xdr.TransactionResult{
+				FeeCharged: 100,
+				Result:     xdr.TransactionResultResult{Code: code},
+			},
+		},
+	}
+}
+
+// makeTxSetHash hashes the envelopes independently of HashTransactionSet by ordering them by hand
+func makeTxSetHash(t *testing.T, previousLedgerHash xdr.Hash, envelopes ...xdr.TransactionEnvelope) xdr.Hash {
+	raws := [][]byte{}
+	for _, envelope := range envelopes {
+		raw, err := envelope.MarshalBinary()
+		assert.NoError(t, err)
+		raws = append(raws, raw)
+	}
+
+	if len(raws) == 2 {
+		firstHash, secondHash := sha256.Sum256(raws[0]), sha256.Sum256(raws[1])
+		if bytes.Compare(firstHash[:], secondHash[:]) > 0 {
+			raws[0], raws[1] = raws[1], raws[0]
+		}
+	}
+
+	contents := append([]byte{}, previousLedgerHash[:]...)
+	for _, raw := range raws {
+		contents = append(contents, raw...)
+	}
+
+	return xdr.Hash(sha256.Sum256(contents))
+}
+
+func mustHashResults(t *testing.T, results []xdr.TransactionResultMeta) xdr.Hash {
+	hash, err := hashTransactionResultSet(results)
+	assert.NoError(t, err)
+	return hash
+}
@@ -0,0 +1,394 @@
+package output
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+const (
+	avroNamespace = "org.stellar.etl"
+	avroMagic     = "Obj\x01"
+	// A block is written once it holds avroBlockRows rows or avroBlockBytes bytes, whichever comes first
+	avroBlockRows  = 1000
+	avroBlockBytes = 1 << 20
+)
+
+var (
+	avroNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	avroTimeType    = reflect.TypeOf(time.Time{})
+)
+
+// avroEncoder appends the Avro binary encoding of a value to the buffer
+type avroEncoder func(buf *bytes.Buffer, value reflect.Value)
+
+// avroRowEncoder appends the Avro binary encoding of a row, with its derived columns, to the buffer. Rows that do not match the schema are not encoded
+type avroRowEncoder func(buf *bytes.Buffer, row interface{}) error
+
+type avroField struct {
+	Name string      `json:"name"`
+	Type interface{} `json:"type"`
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Doc       string      `json:"doc,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+type avroMap struct {
+	Type   string      `json:"type"`
+	Values interface{} `json:"values"`
+}
+
+type avroLogical struct {
+	Type        string `json:"type"`
+	LogicalType string `json:"logicalType"`
+	Precision   int    `json:"precision,omitempty"`
+	Scale       int    `json:"scale,omitempty"`
+}
+
+// avroSchemaBuilder derives Avro schemas and encoders from Go types. Named records are defined the first time they are used, and
+// referenced by name afterwards, as the Avro specification requires
+type avroSchemaBuilder struct {
+	records map[reflect.Type]avroEncoder
+}
+
+/*
+	build returns the schema and the encoder of a Go type. Structs become records whose field names are taken from the json tags, so that
+	Avro and JSON output share field names. Slices become arrays, maps with string keys become maps, and pointers become unions with null.
+	Timestamps use the timestamp-micros logical type. Avro has no unsigned types, so unsigned integers up to 32 bits are stored in the next
+	larger signed type, and uint64 values are stored as decimals with a precision of 20 digits.
+*/
+func (b *avroSchemaBuilder) build(goType reflect.Type) (interface{}, avroEncoder, error) {
+	if goType == avroTimeType {
+		return avroLogical{Type: "long", LogicalType: "timestamp-micros"}, func(buf *bytes.Buffer, value reflect.Value) {
+			writeAvroLong(buf, value.Interface().(time.Time).UnixNano()/int64(time.Microsecond))
+		}, nil
+	}
+
+	switch goType.Kind() {
+	case reflect.Bool:
+		return "boolean", func(buf *bytes.Buffer, value reflect.Value) {
+			if value.Bool() {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return "int", func(buf *bytes.Buffer, value reflect.Value) { writeAvroLong(buf, value.Int()) }, nil
+	case reflect.Uint8, reflect.Uint16:
+		return "int", func(buf *bytes.Buffer, value reflect.Value) { writeAvroLong(buf, int64(value.Uint())) }, nil
+	case reflect.Int, reflect.Int64:
+		return "long", func(buf *bytes.Buffer, value reflect.Value) { writeAvroLong(buf, value.Int()) }, nil
+	case reflect.Uint32:
+		return "long", func(buf *bytes.Buffer, value reflect.Value) { writeAvroLong(buf, int64(value.Uint())) }, nil
+	case reflect.Uint, reflect.Uint64:
+		return avroLogical{Type: "bytes", LogicalType: "decimal", Precision: 20}, func(buf *bytes.Buffer, value reflect.Value) {
+			writeAvroBytes(buf, avroUnsignedDecimal(value.Uint()))
+		}, nil
+	case reflect.Float32:
+		return "float", func(buf *bytes.Buffer, value reflect.Value) {
+			binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(value.Float())))
+		}, nil
+	case reflect.Float64:
+		return "double", func(buf *bytes.Buffer, value reflect.Value) {
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(value.Float()))
+		}, nil
+	case reflect.String:
+		return "string", func(buf *bytes.Buffer, value reflect.Value) { writeAvroBytes(buf, []byte(value.String())) }, nil
+	case reflect.Slice, reflect.Array:
+		if goType.Elem().Kind() == reflect.Uint8 {
+			return "bytes", func(buf *bytes.Buffer, value reflect.Value) { writeAvroBytes(buf, value.Bytes()) }, nil
+		}
+
+		itemSchema, itemEncoder, err := b.build(goType.Elem())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return avroArray{Type: "array", Items: itemSchema}, func(buf *bytes.Buffer, value reflect.Value) {
+			if value.Len() > 0 {
+				writeAvroLong(buf, int64(value.Len()))
+				for i := 0; i < value.Len(); i++ {
+					itemEncoder(buf, value.Index(i))
+				}
+			}
+
+			writeAvroLong(buf, 0)
+		}, nil
+	case reflect.Map:
+		if goType.Key().Kind() != reflect.String {
+			return nil, nil, fmt.Errorf("map keys have to be strings, not %s", goType.Key())
+		}
+
+		valueSchema, valueEncoder, err := b.build(goType.Elem())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return avroMap{Type: "map", Values: valueSchema}, func(buf *bytes.Buffer, value reflect.Value) {
+			keys := value.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			if len(keys) > 0 {
+				writeAvroLong(buf, int64(len(keys)))
+				for _, key := range keys {
+					writeAvroBytes(buf, []byte(key.String()))
+					valueEncoder(buf, value.MapIndex(key))
+				}
+			}
+
+			writeAvroLong(buf, 0)
+		}, nil
+	case reflect.Ptr:
+		elemSchema, elemEncoder, err := b.build(goType.Elem())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []interface{}{"null", elemSchema}, func(buf *bytes.Buffer, value reflect.Value) {
+			if value.IsNil() {
+				writeAvroLong(buf, 0)
+				return
+			}
+
+			writeAvroLong(buf, 1)
+			elemEncoder(buf, value.Elem())
+		}, nil
+	case reflect.Struct:
+		return b.buildRecord(goType)
+	default:
+		return nil, nil, fmt.Errorf("values of type %s cannot be encoded in Avro", goType)
+	}
+}
+
+func (b *avroSchemaBuilder) buildRecord(goType reflect.Type) (interface{}, avroEncoder, error) {
+	if encoder, defined := b.records[goType]; defined {
+		return avroNamespace + "." + goType.Name(), encoder, nil
+	}
+
+	if goType.Name() == "" {
+		return nil, nil, fmt.Errorf("anonymous structs cannot be encoded in Avro")
+	}
+
+	record := avroRecord{Type: "record", Name: goType.Name(), Namespace: avroNamespace, Fields: []avroField{}}
+	fieldIndexes := []int{}
+	fieldEncoders := []avroEncoder{}
+
+	// The encoder is registered before the fields are built, so that the record is complete by the time any field refers to it
+	b.records[goType] = func(buf *bytes.Buffer, value reflect.Value) {
+		for i, encoder := range fieldEncoders {
+			encoder(buf, value.Field(fieldIndexes[i]))
+		}
+	}
+
+	for _, field := range utils.JSONFields(goType) {
+		if !avroNamePattern.MatchString(field.Name) {
+			return nil, nil, fmt.Errorf("field %s of %s is not a valid Avro name", field.Name, goType)
+		}
+
+		fieldSchema, fieldEncoder, err := b.build(field.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not encode field %s of %s: %v", field.Name, goType, err)
+		}
+
+		record.Fields = append(record.Fields, avroField{Name: field.Name, Type: fieldSchema})
+		fieldIndexes = append(fieldIndexes, field.Index)
+		fieldEncoders = append(fieldEncoders, fieldEncoder)
+	}
+
+	return record, b.records[goType], nil
+}
+
+/*
+	buildAvroSchema returns the JSON schema and the encoder of the rows of a dataset. The derived columns of the example row are added as
+	fields after the fields of the row, with the types of their values.
+*/
+func buildAvroSchema(dataset string, exampleRow interface{}) ([]byte, avroRowEncoder, error) {
+	base, names, values := SplitRow(exampleRow)
+	rowType := reflect.TypeOf(base)
+	if rowType == nil || rowType.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("rows of the %s dataset have to be structs", dataset)
+	}
+
+	builder := &avroSchemaBuilder{records: map[reflect.Type]avroEncoder{}}
+	schema, encoder, err := builder.build(rowType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := schema.(avroRecord)
+	record.Doc = fmt.Sprintf("Rows of the %s dataset exported by stellar-etl", dataset)
+	derivedTypes := make([]reflect.Type, len(names))
+	derivedEncoders := make([]avroEncoder, len(names))
+	for i, name := range names {
+		if !avroNamePattern.MatchString(name) {
+			return nil, nil, fmt.Errorf("derived column %s of %s is not a valid Avro name", name, dataset)
+		}
+
+		derivedTypes[i] = reflect.TypeOf(values[i])
+		if derivedTypes[i] == nil {
+			return nil, nil, fmt.Errorf("derived column %s of %s has no type", name, dataset)
+		}
+
+		fieldSchema, fieldEncoder, err := builder.build(derivedTypes[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not encode derived column %s of %s: %v", name, dataset, err)
+		}
+
+		record.Fields = append(record.Fields, avroField{Name: name, Type: fieldSchema})
+		derivedEncoders[i] = fieldEncoder
+	}
+
+	marshalled, err := json.Marshal(record)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Rows are checked against the schema before anything is encoded, so that a row that does not match leaves no partial record behind
+	return marshalled, func(buf *bytes.Buffer, row interface{}) error {
+		base, _, values := SplitRow(row)
+		if reflect.TypeOf(base) != rowType {
+			return fmt.Errorf("row of type %T cannot be written to an Avro file of %s", base, rowType)
+		}
+
+		if len(values) != len(derivedTypes) {
+			return fmt.Errorf("row with %d derived columns cannot be written to an Avro file with %d", len(values), len(derivedTypes))
+		}
+
+		for i, value := range values {
+			if reflect.TypeOf(value) != derivedTypes[i] {
+				return fmt.Errorf("derived column %s has a value of type %T instead of %s", names[i], value, derivedTypes[i])
+			}
+		}
+
+		encoder(buf, reflect.ValueOf(base))
+		for i, value := range values {
+			derivedEncoders[i](buf, reflect.ValueOf(value))
+		}
+
+		return nil
+	}, nil
+}
+
+// AvroSchema returns the Avro schema of the rows of a dataset, which is derived from the type of exampleRow
+func AvroSchema(dataset string, exampleRow interface{}) (string, error) {
+	schema, _, err := buildAvroSchema(dataset, exampleRow)
+	return string(schema), err
+}
+
+func writeAvroLong(buf *bytes.Buffer, value int64) {
+	var encoded [binary.MaxVarintLen64]byte
+	length := binary.PutVarint(encoded[:], value)
+	buf.Write(encoded[:length])
+}
+
+// avroUnsignedDecimal returns the shortest big endian two's complement encoding of the value, which is how Avro stores decimals
+func avroUnsignedDecimal(value uint64) []byte {
+	var encoded [9]byte
+	binary.BigEndian.PutUint64(encoded[1:], value)
+	start := 0
+	for start < len(encoded)-1 && encoded[start] == 0 && encoded[start+1] < 0x80 {
+		start++
+	}
+
+	return encoded[start:]
+}
+
+func writeAvroBytes(buf *bytes.Buffer, value []byte) {
+	writeAvroLong(buf, int64(len(value)))
+	buf.Write(value)
+}
+
+// avroWriter writes rows as an Avro object container file. The header with the schema is written when the writer is created, and rows are
+// written in blocks that are separated by the sync marker of the file. Blocks are not compressed
+type avroWriter struct {
+	out    io.Writer
+	encode avroRowEncoder
+	sync   [16]byte
+	block  bytes.Buffer
+	count  int64
+}
+
+func newAvroWriter(out io.Writer, dataset string, exampleRow interface{}) (*avroWriter, error) {
+	schema, encoder, err := buildAvroSchema(dataset, exampleRow)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &avroWriter{out: out, encode: encoder}
+	if _, err := rand.Read(writer.sync[:]); err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	header.WriteString(avroMagic)
+	writeAvroLong(&header, 2)
+	writeAvroBytes(&header, []byte("avro.schema"))
+	writeAvroBytes(&header, schema)
+	writeAvroBytes(&header, []byte("avro.codec"))
+	writeAvroBytes(&header, []byte("null"))
+	writeAvroLong(&header, 0)
+	header.Write(writer.sync[:])
+	if _, err := out.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (w *avroWriter) Write(row interface{}) error {
+	if err := w.encode(&w.block, row); err != nil {
+		return err
+	}
+
+	w.count++
+	if w.count >= avroBlockRows || w.block.Len() >= avroBlockBytes {
+		return w.flush()
+	}
+
+	return nil
+}
+
+func (w *avroWriter) flush() error {
+	if w.count == 0 {
+		return nil
+	}
+
+	var block bytes.Buffer
+	writeAvroLong(&block, w.count)
+	writeAvroLong(&block, int64(w.block.Len()))
+	block.Write(w.block.Bytes())
+	block.Write(w.sync[:])
+	w.block.Reset()
+	w.count = 0
+
+	_, err := w.out.Write(block.Bytes())
+	return err
+}
+
+func (w *avroWriter) Flush() error {
+	return w.flush()
+}
+
+func (w *avroWriter) Close() error {
+	return w.flush()
+}
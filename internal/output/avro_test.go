@@ -0,0 +1,241 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type avroTestAsset struct {
+	Code string `json:"code"`
+}
+
+type avroTestRow struct {
+	ID       uint32          `json:"id"`
+	Amount   float64         `json:"amount"`
+	ClosedAt time.Time       `json:"closed_at"`
+	Path     []avroTestAsset `json:"path"`
+	Other    avroTestAsset   `json:"other"`
+	Flags    []int32         `json:"flags"`
+	Ok       bool            `json:"ok"`
+}
+
+const avroTestSchema = `{"type":"record","name":"avroTestRow","namespace":"org.stellar.etl","doc":"Rows of the test dataset exported by stellar-etl","fields":[` +
+	`{"name":"id","type":"long"},{"name":"amount","type":"double"},{"name":"closed_at","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+	`{"name":"path","type":{"type":"array","items":{"type":"record","name":"avroTestAsset","namespace":"org.stellar.etl","fields":[{"name":"code","type":"string"}]}}},` +
+	`{"name":"other","type":"org.stellar.etl.avroTestAsset"},{"name":"flags","type":{"type":"array","items":"int"}},{"name":"ok","type":"boolean"}]}`
+
+func TestAvroSchema(t *testing.T) {
+	type functionInput struct {
+		exampleRow interface{}
+	}
+	type functionOutput struct {
+		schema string
+		err    error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{avroTestRow{}},
+			functionOutput{avroTestSchema, nil},
+		},
+		{
+			functionInput{struct {
+				Values map[string]*int64 `json:"values"`
+			}{}},
+			functionOutput{"", fmt.Errorf("anonymous structs cannot be encoded in Avro")},
+		},
+		{
+			functionInput{avroTestAsset{}},
+			functionOutput{`{"type":"record","name":"avroTestAsset","namespace":"org.stellar.etl","doc":"Rows of the test dataset exported by stellar-etl",` +
+				`"fields":[{"name":"code","type":"string"}]}`, nil},
+		},
+	}
+
+	for _, test := range tests {
+		actualSchema, actualError := AvroSchema("test", test.input.exampleRow)
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.schema, actualSchema)
+	}
+}
+
+func TestAvroWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(AvroFormat, "", &out, "test", avroTestRow{})
+	assert.NoError(t, err)
+
+	header := out.Len()
+	assert.Equal(t, "Obj\x01", out.String()[:4])
+	assert.Contains(t, out.String(), avroTestSchema)
+	assert.Contains(t, out.String(), "avro.codec\x08null")
+	sync := append([]byte{}, out.Bytes()[header-16:]...)
+
+	row := avroTestRow{ID: 3, Amount: 1.5, ClosedAt: time.Unix(1, 0), Path: []avroTestAsset{{"XLM"}}, Other: avroTestAsset{"A"}, Flags: []int32{}, Ok: true}
+	assert.NoError(t, writer.Write(row))
+
+	// Rows are buffered until the block is full or the writer is closed
+	assert.Equal(t, header, out.Len())
+	assert.NoError(t, writer.Close())
+
+	expectedBlock := []byte{
+		0x02, 0x2c, // one row of 22 bytes
+		0x06,                                           // id
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // amount
+		0x80, 0x89, 0x7a, // closed_at in microseconds
+		0x02, 0x06, 'X', 'L', 'M', 0x00, // path
+		0x02, 'A', // other
+		0x00, // flags
+		0x01, // ok
+	}
+	assert.Equal(t, append(expectedBlock, sync...), out.Bytes()[header:])
+
+	assert.EqualError(t, writer.Write(avroTestAsset{}), "row of type output.avroTestAsset cannot be written to an Avro file of output.avroTestRow")
+}
+
+type avroRoundTripRow struct {
+	ID       uint64            `json:"id"`
+	Sequence uint32            `json:"sequence"`
+	Balance  int64             `json:"balance"`
+	Flags    uint8             `json:"flags"`
+	ClosedAt time.Time         `json:"closed_at"`
+	Memo     *string           `json:"memo"`
+	Path     []avroTestAsset   `json:"path"`
+	Other    avroTestAsset     `json:"other"`
+	Signers  map[string]uint32 `json:"signers"`
+	Hash     []byte            `json:"hash"`
+	Price    float32           `json:"price"`
+}
+
+func TestAvroWriterRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(AvroFormat, "", &out, "test", avroRoundTripRow{})
+	assert.NoError(t, err)
+
+	memo := "deposit"
+	closedAt := time.Date(2020, 12, 1, 23, 59, 55, 123456000, time.UTC)
+	rows := []avroRoundTripRow{
+		{ID: math.MaxUint64, Sequence: math.MaxUint32, Balance: math.MinInt64, Flags: math.MaxUint8, ClosedAt: closedAt, Memo: &memo,
+			Path: []avroTestAsset{{"XLM"}, {"USD"}}, Other: avroTestAsset{"A"}, Signers: map[string]uint32{"GA": 1, "GB": 2}, Hash: []byte{1, 2}, Price: 0.5},
+		{ID: 1 << 63, Sequence: 1 << 31, Balance: math.MaxInt64},
+		{ID: 127},
+	}
+
+	// Enough rows are written for the file to have more than one block
+	for i := 0; i <= avroBlockRows; i++ {
+		assert.NoError(t, writer.Write(rows[i%len(rows)]))
+	}
+
+	assert.NoError(t, writer.Close())
+
+	reader, err := goavro.NewOCFReader(bytes.NewReader(out.Bytes()))
+	assert.NoError(t, err)
+
+	decimal := func(value string) *big.Rat {
+		rat, _ := new(big.Rat).SetString(value)
+		return rat
+	}
+
+	expected := []map[string]interface{}{
+		{"id": decimal("18446744073709551615"), "sequence": int64(math.MaxUint32), "balance": int64(math.MinInt64), "flags": int32(math.MaxUint8),
+			"closed_at": closedAt, "memo": map[string]interface{}{"string": "deposit"},
+			"path":  []interface{}{map[string]interface{}{"code": "XLM"}, map[string]interface{}{"code": "USD"}},
+			"other": map[string]interface{}{"code": "A"}, "signers": map[string]interface{}{"GA": int64(1), "GB": int64(2)},
+			"hash": []byte{1, 2}, "price": float32(0.5)},
+		{"id": decimal("9223372036854775808"), "sequence": int64(1 << 31), "balance": int64(math.MaxInt64), "flags": int32(0),
+			"memo": nil,
+			"path": []interface{}{}, "other": map[string]interface{}{"code": ""}, "signers": map[string]interface{}{}, "hash": []byte{}, "price": float32(0)},
+		{"id": decimal("127")},
+	}
+
+	count := 0
+	for reader.Scan() {
+		decoded, err := reader.Read()
+		assert.NoError(t, err)
+		want := expected[count%len(expected)]
+		record := decoded.(map[string]interface{})
+		for name, value := range want {
+			if rat, ok := value.(*big.Rat); ok {
+				assert.Equal(t, 0, rat.Cmp(record[name].(*big.Rat)), name)
+				continue
+			}
+
+			if wantTime, ok := value.(time.Time); ok {
+				assert.True(t, wantTime.Equal(record[name].(time.Time)), name)
+				continue
+			}
+
+			assert.Equal(t, value, record[name], name)
+		}
+
+		count++
+	}
+
+	assert.NoError(t, reader.Err())
+	assert.Equal(t, avroBlockRows+1, count)
+}
+
+// derivedTestRow adds derived columns to a row, like the rows of the expr package
+type derivedTestRow struct {
+	row    interface{}
+	names  []string
+	values []interface{}
+}
+
+func (r derivedTestRow) BaseRow() interface{} {
+	return r.row
+}
+
+func (r derivedTestRow) DerivedColumns() ([]string, []interface{}) {
+	return r.names, r.values
+}
+
+func TestAvroWriterDerivedColumns(t *testing.T) {
+	names := []string{"notional", "large"}
+	exampleRow := derivedTestRow{avroTestAsset{}, names, []interface{}{float64(0), false}}
+	schema, err := AvroSchema("test", exampleRow)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"record","name":"avroTestAsset","namespace":"org.stellar.etl","doc":"Rows of the test dataset exported by stellar-etl",`+
+		`"fields":[{"name":"code","type":"string"},{"name":"notional","type":"double"},{"name":"large","type":"boolean"}]}`, schema)
+
+	var out bytes.Buffer
+	writer, err := NewWriter(AvroFormat, "", &out, "test", exampleRow)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(derivedTestRow{avroTestAsset{"XLM"}, names, []interface{}{12.5, true}}))
+	assert.EqualError(t, writer.Write(avroTestAsset{"XLM"}), "row with 0 derived columns cannot be written to an Avro file with 2")
+	assert.EqualError(t, writer.Write(derivedTestRow{avroTestAsset{"XLM"}, names, []interface{}{int64(1), true}}), "derived column notional has a value of type int64 instead of float64")
+	assert.NoError(t, writer.Close())
+
+	reader, err := goavro.NewOCFReader(bytes.NewReader(out.Bytes()))
+	assert.NoError(t, err)
+	assert.True(t, reader.Scan())
+	decoded, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"code": "XLM", "notional": 12.5, "large": true}, decoded)
+	assert.False(t, reader.Scan())
+
+	_, err = AvroSchema("test", derivedTestRow{avroTestAsset{}, []string{"bad-name"}, []interface{}{""}})
+	assert.EqualError(t, err, "derived column bad-name of test is not a valid Avro name")
+}
+
+func TestJSONWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(JSONFormat, "", &out, "test", avroTestAsset{})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(avroTestAsset{"XLM"}))
+	assert.NoError(t, writer.Write(avroTestAsset{"USD"}))
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, "{\"code\":\"XLM\"}\n{\"code\":\"USD\"}\n", out.String())
+	assert.EqualError(t, writer.Write(avroTestAsset{"EUR"}), "the writer is closed")
+
+	_, err = NewWriter("csv", "", &out, "test", avroTestAsset{})
+	assert.EqualError(t, err, "unknown output format csv; the supported formats are json, avro, debezium, es-bulk, horizon")
+}
@@ -0,0 +1,273 @@
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/transform"
+)
+
+var benchmarkCloseTime = time.Date(2020, 12, 1, 10, 30, 15, 0, time.UTC)
+
+// benchmarkRows holds a typical row of each dataset
+var benchmarkRows = []struct {
+	dataset string
+	row     interface{}
+}{
+	{transform.LedgersDataset, transform.LedgerOutput{
+		Sequence:                   32485321,
+		LedgerHash:                 "26932dc4d84b5fabe9ae744cb43ce4c6daccf98c86a991b2a14945b1adac4d59",
+		PreviousLedgerHash:         "f63c15d0eaf48afbd751a4c4dfade54a3448053c47c5a71d622668ae0cc2a208",
+		LedgerHeader:               "AAAADnwr7EiasKkjdzdVcuWiAdF5T3iBC5OLSC7XeHNE07fqPS4XRJahEk4XtAhm+b5YuPgDwdWr6G3i6YBHWkRYPvIAAAAAX8Yedg==",
+		TransactionCount:           152,
+		OperationCount:             512,
+		SuccessfulTransactionCount: 140,
+		FailedTransactionCount:     12,
+		TxSetOperationCount:        "531",
+		ClosedAt:                   benchmarkCloseTime,
+		TotalCoins:                 1054439020873472865,
+		FeePool:                    18153766209161,
+		BaseFee:                    100,
+		BaseReserve:                5000000,
+		MaxTxSetSize:               1000,
+		ProtocolVersion:            15,
+		LedgerID:                   139522509289996288,
+	}},
+	{transform.TransactionsDataset, transform.TransactionOutput{
+		TransactionHash:  "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+		LedgerSequence:   32485321,
+		ApplicationOrder: 12,
+		Account:          "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+		AccountSequence:  112351890582290871,
+		MaxFee:           90000,
+		FeeCharged:       300,
+		OperationCount:   3,
+		CreatedAt:        benchmarkCloseTime,
+		MemoType:         "MemoTypeText",
+		Memo:             "deposit for order 1234",
+		TimeBounds:       "[0, 1606818675)",
+		Successful:       true,
+		TransactionID:    139522509290000384,
+	}},
+	{transform.OperationsDataset, transform.OperationOutput{
+		SourceAccount:    "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+		Type:             2,
+		ApplicationOrder: 1,
+		OperationDetails: transform.Details{
+			Account:           "GBT4YAEGJQ5YSFUMNKX6BPBUOCPNAIOFAVZOF6MIME2CECBMEIUXFZZN",
+			Amount:            643.0738,
+			AssetCode:         "USDT",
+			AssetIssuer:       "GCQTGZQQ5G4PTM2GL7CDIFKUBIPEC52BROAQIAPW53XBRJVN6ZJVTG6V",
+			AssetType:         "credit_alphanum4",
+			From:              "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+			Path:              []transform.AssetOutput{{AssetType: "native"}, {AssetCode: "BTC", AssetIssuer: "GATEMHCCKCY67ZUCKTROYN24ZYT5GK4EQZ65JJLDHKHRUZI3EUEKMTCH", AssetType: "credit_alphanum4"}},
+			PriceR:            transform.Price{Numerator: 1, Denominator: 3},
+			SourceAmount:      0.1,
+			SourceAssetType:   "native",
+			SourceMax:         1000,
+			To:                "GBT4YAEGJQ5YSFUMNKX6BPBUOCPNAIOFAVZOF6MIME2CECBMEIUXFZZN",
+			SetFlags:          []int32{},
+			SetFlagsString:    []string{},
+			ClearFlags:        []int32{},
+			ClearFlagsString:  []string{},
+			SourceAssetIssuer: "",
+		},
+		TransactionID: 139522509290000384,
+		OperationID:   139522509290000385,
+	}},
+	{transform.TradesDataset, transform.TradeOutput{
+		Order:                 0,
+		LedgerClosedAt:        benchmarkCloseTime,
+		OfferID:               169134453,
+		BaseAccountAddress:    "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+		BaseAssetType:         "native",
+		BaseAmount:            1000000000,
+		CounterAccountAddress: "GBT4YAEGJQ5YSFUMNKX6BPBUOCPNAIOFAVZOF6MIME2CECBMEIUXFZZN",
+		CounterAssetCode:      "USDT",
+		CounterAssetIssuer:    "GCQTGZQQ5G4PTM2GL7CDIFKUBIPEC52BROAQIAPW53XBRJVN6ZJVTG6V",
+		CounterAssetType:      "credit_alphanum4",
+		CounterAmount:         13021000,
+		BaseIsSeller:          true,
+		PriceN:                13021,
+		PriceD:                1000000,
+		BaseOfferID:           169134453,
+		CounterOfferID:        4751222758541361153,
+		HistoryOperationID:    139522509290000385,
+	}},
+	{transform.AccountsDataset, transform.AccountOutput{
+		AccountID:          "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+		Balance:            10959979,
+		SellingLiabilities: 5000000,
+		SequenceNumber:     112351890582290871,
+		NumSubentries:      3,
+		HomeDomain:         "example.com",
+		MasterWeight:       1,
+		ThresholdMedium:    2,
+		ThresholdHigh:      2,
+		LastModifiedLedger: 32485321,
+	}},
+	{transform.OffersDataset, transform.OfferOutput{
+		SellerID:           "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+		OfferID:            169134453,
+		SellingAsset:       "AAAAAA==",
+		BuyingAsset:        "AAAAAVVTRFQAAAAAoTNmEOm4+bNGX8Q0FVQKHkF3QYuBBAH27ucYpq32U5k=",
+		Amount:             1000000000,
+		PriceN:             13021,
+		PriceD:             1000000,
+		Price:              0.013021,
+		LastModifiedLedger: 32485321,
+	}},
+	{transform.TrustlinesDataset, transform.TrustlineOutput{
+		LedgerKey:          "AAAAAQAAAABrWN1saJMLbQMdxbv64j76HsPwu1jCvI2TjUfB37O+cwAAAAFVU0RUAAAAAKEzZhDpuPmzRl/ENBVUCh5Bd0GLgQQB9u7nGKat9lOZ",
+		AccountID:          "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+		AssetCode:          "USDT",
+		AssetIssuer:        "GCQTGZQQ5G4PTM2GL7CDIFKUBIPEC52BROAQIAPW53XBRJVN6ZJVTG6V",
+		AssetType:          1,
+		Balance:            6430738,
+		TrustlineLimit:     9223372036854775807,
+		Flags:              1,
+		LastModifiedLedger: 32485321,
+	}},
+	{"dimMarkets", transform.DimMarket{
+		ID:            6429335934343744395,
+		BaseCode:      "native",
+		CounterCode:   "USDT",
+		CounterIssuer: "GCQTGZQQ5G4PTM2GL7CDIFKUBIPEC52BROAQIAPW53XBRJVN6ZJVTG6V",
+	}},
+	{"dimOffers", transform.DimOffer{
+		HorizonID:     169134453,
+		DimOfferID:    13226741637327925011,
+		MarketID:      6429335934343744395,
+		MakerID:       4268167189990212240,
+		Action:        "b",
+		BaseAmount:    1000000000,
+		CounterAmount: 1302.1,
+		Price:         0.013021,
+	}},
+	{"dimAccounts", transform.DimAccount{
+		ID:      4268167189990212240,
+		Address: "GBVVRXLMNCJQW3IDDXC3X6XCH35B5Q7QXNMMFPENSOGUPQO7WO7HGZPA",
+	}},
+	{"factEvents", transform.FactOfferEvent{
+		LedgerSeq:       32485321,
+		OfferInstanceID: 13226741637327925011,
+	}},
+}
+
+// legacyWrite writes a row the way the exporters did before the output package existed: reflection, a string conversion, and two unbuffered writes
+func legacyWrite(file *os.File, row interface{}) error {
+	marshalled, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	file.Write([]byte(string(marshalled)))
+	_, err = file.WriteString("\n")
+	return err
+}
+
+func benchmarkFile(b *testing.B) (*os.File, func()) {
+	file, err := ioutil.TempFile("", "output-benchmark")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return file, func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+/*
+	BenchmarkWriters measures the throughput of writing the rows of each dataset to a file. The json and avro cases use the writers of this
+	package; legacy-json is the reflection based path that the exporters used before. Throughput is reported in MB/s of JSON output:
+
+		go test -run NONE -bench Writers ./internal/output
+*/
+func BenchmarkWriters(b *testing.B) {
+	for _, benchmark := range benchmarkRows {
+		encoded, err := MarshalJSON(benchmark.row)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		rowSize := int64(len(encoded) + 1)
+		b.Run(benchmark.dataset+"/legacy-json", func(b *testing.B) {
+			file, cleanup := benchmarkFile(b)
+			defer cleanup()
+
+			b.SetBytes(rowSize)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := legacyWrite(file, benchmark.row); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		for _, format := range Formats {
+			// Ledger entries and orderbooks have no Horizon resource, so they cannot be written in the horizon format
+			if _, ok := benchmark.row.(HorizonResource); !ok && format == HorizonFormat {
+				continue
+			}
+
+			b.Run(benchmark.dataset+"/"+format, func(b *testing.B) {
+				file, cleanup := benchmarkFile(b)
+				defer cleanup()
+
+				writer, err := NewWriter(format, "", file, benchmark.dataset, benchmark.row)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.SetBytes(rowSize)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := writer.Write(benchmark.row); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				if err := writer.Close(); err != nil {
+					b.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkEncoders compares the generated JSON encoders with encoding/json, without any I/O
+func BenchmarkEncoders(b *testing.B) {
+	for _, benchmark := range benchmarkRows {
+		encoded, err := MarshalJSON(benchmark.row)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(benchmark.dataset+"/encoding-json", func(b *testing.B) {
+			b.SetBytes(int64(len(encoded)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(benchmark.row); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(benchmark.dataset+"/generated", func(b *testing.B) {
+			scratch := make([]byte, 0, len(encoded))
+			b.SetBytes(int64(len(encoded)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if scratch, err = AppendJSON(scratch[:0], benchmark.row); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// DefaultIndexPattern names the index of each dataset in the es-bulk format. The {dataset} placeholder is replaced with the name of the dataset
+const DefaultIndexPattern = "stellar-{dataset}"
+
+// BulkDocument is implemented by rows that can be written in the es-bulk format. The document ID has to be the same every time the row is exported.
+// Rows with derived columns take the document ID of their base row
+type BulkDocument interface {
+	DocumentID() string
+}
+
+// IndexName returns the name of the index that the rows of the dataset are written to, which is the pattern with the name of the dataset in it, in lower case
+func IndexName(pattern, dataset string) (string, error) {
+	name := strings.ToLower(strings.Replace(pattern, "{dataset}", dataset, -1))
+	if name == "" || name == "." || name == ".." {
+		return "", fmt.Errorf("the index pattern %q gives the invalid index name %q", pattern, name)
+	}
+
+	if strings.ContainsAny(name, "\\/*?\"<>| ,#:{}") || strings.IndexAny(name, "-_+") == 0 {
+		return "", fmt.Errorf("the index pattern %q gives the invalid index name %q", pattern, name)
+	}
+
+	return name, nil
+}
+
+// CheckIndexPattern checks that the pattern gives a valid index name, with the name of a dataset in it
+func CheckIndexPattern(pattern string) error {
+	_, err := IndexName(pattern, "ledgers")
+	return err
+}
+
+// bulkWriter writes each row as a pair of lines in the bulk format of Elasticsearch and OpenSearch: an index action with the index and the ID
+// of the document, and the document itself. Indexing a document with an existing ID replaces it, so exports can be indexed again
+type bulkWriter struct {
+	*jsonWriter
+	action []byte
+}
+
+func newBulkWriter(out io.Writer, dataset string, exampleRow interface{}, indexPattern string) (*bulkWriter, error) {
+	base, _, _ := SplitRow(exampleRow)
+	if _, ok := base.(BulkDocument); !ok {
+		return nil, fmt.Errorf("the rows of the %s dataset have no document ID, so they cannot be written in the %s format", dataset, ESBulkFormat)
+	}
+
+	if indexPattern == "" {
+		indexPattern = DefaultIndexPattern
+	}
+
+	index, err := IndexName(indexPattern, dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedIndex, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+
+	action := append([]byte(`{"index":{"_index":`), encodedIndex...)
+	action = append(action, `,"_id":`...)
+	return &bulkWriter{jsonWriter: newJSONWriter(out), action: action}, nil
+}
+
+func (w *bulkWriter) Write(row interface{}) error {
+	if w.out == nil {
+		return fmt.Errorf("the writer is closed")
+	}
+
+	base, _, _ := SplitRow(row)
+	document, ok := base.(BulkDocument)
+	if !ok {
+		return fmt.Errorf("rows of type %T have no document ID, so they cannot be written in the %s format", base, ESBulkFormat)
+	}
+
+	encodedID, err := json.Marshal(document.DocumentID())
+	if err != nil {
+		return err
+	}
+
+	encoded := append(w.scratch[:0], w.action...)
+	encoded = append(encoded, encodedID...)
+	encoded = append(encoded, "}}\n"...)
+	encoded, err = AppendJSON(encoded, row)
+	if err != nil {
+		return err
+	}
+
+	w.scratch = append(encoded, '\n')
+	_, err = w.out.Write(w.scratch)
+	return err
+}
+
+type indexMapping struct {
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type indexField struct {
+	Type       string                 `json:"type,omitempty"`
+	Format     string                 `json:"format,omitempty"`
+	Enabled    *bool                  `json:"enabled,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+/*
+	mappingOf returns the mapping of a Go type. Structs become objects whose field names are taken from the json tags, like the JSON output,
+	and slices and pointers are mapped like their elements, since every field of an index can hold several values or none. Strings are
+	keywords, so that addresses and hashes are matched exactly. Fields whose type is not known until the row is exported are stored
+	without being indexed.
+*/
+func mappingOf(goType reflect.Type) (indexField, error) {
+	if goType == avroTimeType {
+		return indexField{Type: "date", Format: "strict_date_optional_time"}, nil
+	}
+
+	switch goType.Kind() {
+	case reflect.Bool:
+		return indexField{Type: "boolean"}, nil
+	case reflect.Int8:
+		return indexField{Type: "byte"}, nil
+	case reflect.Int16, reflect.Uint8:
+		return indexField{Type: "short"}, nil
+	case reflect.Int32, reflect.Uint16:
+		return indexField{Type: "integer"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return indexField{Type: "long"}, nil
+	case reflect.Uint, reflect.Uint64:
+		return indexField{Type: "unsigned_long"}, nil
+	case reflect.Float32:
+		return indexField{Type: "float"}, nil
+	case reflect.Float64:
+		return indexField{Type: "double"}, nil
+	case reflect.String:
+		return indexField{Type: "keyword"}, nil
+	case reflect.Slice, reflect.Array:
+		if goType.Elem().Kind() == reflect.Uint8 {
+			return indexField{Type: "binary"}, nil
+		}
+
+		return mappingOf(goType.Elem())
+	case reflect.Ptr:
+		return mappingOf(goType.Elem())
+	case reflect.Interface, reflect.Map:
+		disabled := false
+		return indexField{Type: "object", Enabled: &disabled}, nil
+	case reflect.Struct:
+		properties, err := propertiesOf(goType)
+		if err != nil {
+			return indexField{}, err
+		}
+
+		return indexField{Properties: properties}, nil
+	default:
+		return indexField{}, fmt.Errorf("values of type %s cannot be indexed", goType)
+	}
+}
+
+func propertiesOf(goType reflect.Type) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	for _, field := range utils.JSONFields(goType) {
+		mapping, err := mappingOf(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("could not map field %s of %s: %v", field.Name, goType, err)
+		}
+
+		properties[field.Name] = mapping
+	}
+
+	return properties, nil
+}
+
+// IndexMapping returns the mapping of the index that the rows of a dataset are written to in the es-bulk format, which is derived from the type of exampleRow.
+// The derived columns of the example row are mapped by the types of their values
+func IndexMapping(dataset string, exampleRow interface{}) (string, error) {
+	base, names, values := SplitRow(exampleRow)
+	rowType := reflect.TypeOf(base)
+	if rowType == nil || rowType.Kind() != reflect.Struct {
+		return "", fmt.Errorf("rows of the %s dataset have to be structs", dataset)
+	}
+
+	properties, err := propertiesOf(rowType)
+	if err != nil {
+		return "", err
+	}
+
+	for i, name := range names {
+		if reflect.TypeOf(values[i]) == nil {
+			return "", fmt.Errorf("derived column %s of %s has no type", name, dataset)
+		}
+
+		mapping, err := mappingOf(reflect.TypeOf(values[i]))
+		if err != nil {
+			return "", fmt.Errorf("could not map derived column %s of %s: %v", name, dataset, err)
+		}
+
+		properties[name] = mapping
+	}
+
+	marshalled, err := json.Marshal(map[string]interface{}{"mappings": indexMapping{Properties: properties}})
+	return string(marshalled), err
+}
@@ -0,0 +1,136 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bulkTestRow struct {
+	Code    string      `json:"code"`
+	Key     string      `json:"key"`
+	Details interface{} `json:"details"`
+}
+
+func (r bulkTestRow) DocumentID() string {
+	return r.Key
+}
+
+func TestIndexName(t *testing.T) {
+	type functionInput struct {
+		pattern string
+		dataset string
+	}
+	type functionOutput struct {
+		name string
+		err  error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{DefaultIndexPattern, "transactions"}, functionOutput{"stellar-transactions", nil}},
+		{functionInput{"Pubnet-{dataset}-v2", "dimMarkets"}, functionOutput{"pubnet-dimmarkets-v2", nil}},
+		{functionInput{"operations", "operations"}, functionOutput{"operations", nil}},
+		{functionInput{"_{dataset}", "trades"}, functionOutput{"", fmt.Errorf("the index pattern \"_{dataset}\" gives the invalid index name \"_trades\"")}},
+		{functionInput{"stellar {dataset}", "trades"}, functionOutput{"", fmt.Errorf("the index pattern \"stellar {dataset}\" gives the invalid index name \"stellar trades\"")}},
+		{functionInput{"stellar-{set}", "trades"}, functionOutput{"", fmt.Errorf("the index pattern \"stellar-{set}\" gives the invalid index name \"stellar-{set}\"")}},
+		{functionInput{"", "trades"}, functionOutput{"", fmt.Errorf("the index pattern \"\" gives the invalid index name \"\"")}},
+	}
+
+	for _, test := range tests {
+		actualName, actualError := IndexName(test.input.pattern, test.input.dataset)
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.name, actualName)
+	}
+}
+
+func TestBulkWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(ESBulkFormat, "", &out, "dimMarkets", bulkTestRow{})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(bulkTestRow{"XLM", "1", nil}))
+	assert.NoError(t, writer.Write(bulkTestRow{"USD", "2-\"a\"", []int{1}}))
+	assert.EqualError(t, writer.Write(avroTestAsset{"EUR"}), "rows of type output.avroTestAsset have no document ID, so they cannot be written in the es-bulk format")
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, `{"index":{"_index":"stellar-dimmarkets","_id":"1"}}`+"\n"+`{"code":"XLM","key":"1","details":null}`+"\n"+
+		`{"index":{"_index":"stellar-dimmarkets","_id":"2-\"a\""}}`+"\n"+`{"code":"USD","key":"2-\"a\"","details":[1]}`+"\n", out.String())
+
+	assert.EqualError(t, CheckIndexPattern("stellar/{dataset}"), "the index pattern \"stellar/{dataset}\" gives the invalid index name \"stellar/ledgers\"")
+	assert.NoError(t, CheckIndexPattern("testnet-{dataset}"))
+
+	_, err = NewWriter(ESBulkFormat, "", &out, "assets", avroTestAsset{})
+	assert.EqualError(t, err, "the rows of the assets dataset have no document ID, so they cannot be written in the es-bulk format")
+
+	out.Reset()
+	writer, err = NewWriter(ESBulkFormat, "testnet-{dataset}", &out, "trades", bulkTestRow{})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(bulkTestRow{"XLM", "3", nil}))
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, `{"index":{"_index":"testnet-trades","_id":"3"}}`+"\n"+`{"code":"XLM","key":"3","details":null}`+"\n", out.String())
+
+	// Rows with derived columns are indexed under the document ID of their base row
+	out.Reset()
+	writer, err = NewWriter(ESBulkFormat, "", &out, "trades", derivedTestRow{bulkTestRow{}, []string{"large"}, []interface{}{false}})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(derivedTestRow{bulkTestRow{"XLM", "4", nil}, []string{"large"}, []interface{}{true}}))
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, `{"index":{"_index":"stellar-trades","_id":"4"}}`, strings.SplitN(out.String(), "\n", 2)[0])
+}
+
+func TestIndexMapping(t *testing.T) {
+	type functionInput struct {
+		exampleRow interface{}
+	}
+	type functionOutput struct {
+		mapping string
+		err     error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{avroTestRow{}},
+			functionOutput{`{"mappings":{"properties":{"amount":{"type":"double"},"closed_at":{"type":"date","format":"strict_date_optional_time"},` +
+				`"flags":{"type":"integer"},"id":{"type":"long"},"ok":{"type":"boolean"},"other":{"properties":{"code":{"type":"keyword"}}},` +
+				`"path":{"properties":{"code":{"type":"keyword"}}}}}}`, nil},
+		},
+		{
+			functionInput{bulkTestRow{}},
+			functionOutput{`{"mappings":{"properties":{"code":{"type":"keyword"},"details":{"type":"object","enabled":false},"key":{"type":"keyword"}}}}`, nil},
+		},
+		{
+			functionInput{struct {
+				Fee      int64  `json:"fee"`
+				Sequence uint32 `json:"sequence"`
+				Amount   uint64 `json:"amount"`
+			}{}},
+			functionOutput{`{"mappings":{"properties":{"amount":{"type":"unsigned_long"},"fee":{"type":"long"},"sequence":{"type":"long"}}}}`, nil},
+		},
+		{
+			functionInput{struct {
+				Done chan bool `json:"done"`
+			}{}},
+			functionOutput{"", fmt.Errorf("could not map field done of struct { Done chan bool \"json:\\\"done\\\"\" }: values of type chan bool cannot be indexed")},
+		},
+		{
+			functionInput{derivedTestRow{bulkTestRow{}, []string{"closed_on", "notional"}, []interface{}{time.Time{}, int64(0)}}},
+			functionOutput{`{"mappings":{"properties":{"closed_on":{"type":"date","format":"strict_date_optional_time"},"code":{"type":"keyword"},` +
+				`"details":{"type":"object","enabled":false},"key":{"type":"keyword"},"notional":{"type":"long"}}}}`, nil},
+		},
+		{functionInput{"row"}, functionOutput{"", fmt.Errorf("rows of the test dataset have to be structs")}},
+	}
+
+	for _, test := range tests {
+		actualMapping, actualError := IndexMapping("test", test.input.exampleRow)
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.mapping, actualMapping)
+	}
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"fmt"
+	"io"
+)
+
+// HorizonResource is implemented by rows that can be written in the horizon format. The resource has the same JSON shape as the matching resource of the Horizon API
+type HorizonResource interface {
+	HorizonResource() (interface{}, error)
+}
+
+// horizonWriter writes each row as the JSON of its Horizon resource on its own line, so that parsers of Horizon responses can read the rows unchanged
+type horizonWriter struct {
+	*jsonWriter
+}
+
+func newHorizonWriter(out io.Writer, dataset string, exampleRow interface{}) (*horizonWriter, error) {
+	if _, ok := exampleRow.(HorizonResource); !ok {
+		return nil, fmt.Errorf("the rows of the %s dataset have no Horizon resource, so they cannot be written in the %s format", dataset, HorizonFormat)
+	}
+
+	return &horizonWriter{jsonWriter: newJSONWriter(out)}, nil
+}
+
+func (w *horizonWriter) Write(row interface{}) error {
+	resource, ok := row.(HorizonResource)
+	if !ok {
+		return fmt.Errorf("rows of type %T have no Horizon resource, so they cannot be written in the %s format", row, HorizonFormat)
+	}
+
+	rendered, err := resource.HorizonResource()
+	if err != nil {
+		return err
+	}
+
+	return w.jsonWriter.Write(rendered)
+}
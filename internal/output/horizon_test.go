@@ -0,0 +1,36 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type horizonTestRow struct {
+	ID int64 `json:"id"`
+}
+
+func (r horizonTestRow) HorizonResource() (interface{}, error) {
+	if r.ID < 0 {
+		return nil, fmt.Errorf("row %d has no resource", r.ID)
+	}
+
+	return map[string]interface{}{"id": fmt.Sprint(r.ID), "paging_token": fmt.Sprint(r.ID)}, nil
+}
+
+func TestHorizonWriter(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(HorizonFormat, "", &out, "operations", horizonTestRow{})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(horizonTestRow{12}))
+	assert.EqualError(t, writer.Write(horizonTestRow{-1}), "row -1 has no resource")
+	assert.EqualError(t, writer.Write(avroTestAsset{"EUR"}), "rows of type output.avroTestAsset have no Horizon resource, so they cannot be written in the horizon format")
+	assert.NoError(t, writer.Write(horizonTestRow{13}))
+	assert.NoError(t, writer.Close())
+	assert.Equal(t, `{"id":"12","paging_token":"12"}`+"\n"+`{"id":"13","paging_token":"13"}`+"\n", out.String())
+
+	_, err = NewWriter(HorizonFormat, "", &out, "accounts", avroTestAsset{})
+	assert.EqualError(t, err, "the rows of the accounts dataset have no Horizon resource, so they cannot be written in the horizon format")
+}
@@ -0,0 +1,176 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Output is written in chunks of writeBufferSize bytes, instead of with one system call per row
+const writeBufferSize = 256 * 1024
+
+// The output formats that exported rows can be written in. The debezium format is JSON whose rows are the envelopes of change events, so only
+// exports of ledger entry changes can be written in it. The es-bulk format is the bulk indexing format of Elasticsearch and OpenSearch. The horizon
+// format is JSON whose rows are the resources of the Horizon API, so only ledgers, transactions, operations, and trades can be written in it
+const (
+	JSONFormat     = "json"
+	AvroFormat     = "avro"
+	DebeziumFormat = "debezium"
+	ESBulkFormat   = "es-bulk"
+	HorizonFormat  = "horizon"
+)
+
+// Formats lists every supported output format
+var Formats = []string{JSONFormat, AvroFormat, DebeziumFormat, ESBulkFormat, HorizonFormat}
+
+// Writer encodes exported rows in an output format and writes them to the underlying writer
+type Writer interface {
+	// Write encodes a single row. Rows have to be of the same type as the example row that the writer was created with
+	Write(row interface{}) error
+	// Flush writes out any buffered rows, so that the rows written so far can be read from the underlying writer
+	Flush() error
+	// Close flushes any buffered rows. It does not close the underlying writer
+	Close() error
+}
+
+// NewWriter creates a writer for the format. The dataset names the rows in formats that embed a schema or an index, and the schema is derived
+// from exampleRow. The es-bulk format names its indexes with indexPattern, or with DefaultIndexPattern if it is empty
+func NewWriter(format, indexPattern string, out io.Writer, dataset string, exampleRow interface{}) (Writer, error) {
+	switch format {
+	case JSONFormat, DebeziumFormat:
+		return newJSONWriter(out), nil
+	case AvroFormat:
+		return newAvroWriter(out, dataset, exampleRow)
+	case ESBulkFormat:
+		return newBulkWriter(out, dataset, exampleRow, indexPattern)
+	case HorizonFormat:
+		return newHorizonWriter(out, dataset, exampleRow)
+	default:
+		return nil, fmt.Errorf("unknown output format %s; the supported formats are %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Extension returns the file extension for output files in the format
+func Extension(format string) string {
+	if format == AvroFormat {
+		return ".avro"
+	}
+
+	return ".txt"
+}
+
+// SupportsAppend reports whether rows in the format can be appended to an existing output file. Formats with a file header have to start a new file
+func SupportsAppend(format string) bool {
+	return format != AvroFormat
+}
+
+// JSONAppender is implemented by rows that can encode themselves as JSON without reflection. The output structs of the transform package
+// implement it with generated code
+type JSONAppender interface {
+	// AppendJSON appends the same encoding that json.Marshal returns to dst
+	AppendJSON(dst []byte) ([]byte, error)
+}
+
+// AppendJSON appends the JSON encoding of the row to dst. Rows that implement JSONAppender encode themselves, and other rows fall back to encoding/json
+func AppendJSON(dst []byte, row interface{}) ([]byte, error) {
+	if appender, ok := row.(JSONAppender); ok {
+		return appender.AppendJSON(dst)
+	}
+
+	marshalled, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(dst, marshalled...), nil
+}
+
+// DerivedRow is implemented by rows that add derived columns to a row of a dataset, like the rows of the expr package. Formats and tables
+// with a schema add the derived columns after the columns of the row
+type DerivedRow interface {
+	// BaseRow returns the row of the dataset, without the derived columns
+	BaseRow() interface{}
+	// DerivedColumns returns the names and the values of the derived columns
+	DerivedColumns() ([]string, []interface{})
+}
+
+// SplitRow returns the row of the dataset and the names and values of the derived columns of a row. Rows without derived columns are returned as they are
+func SplitRow(row interface{}) (interface{}, []string, []interface{}) {
+	if derived, ok := row.(DerivedRow); ok {
+		names, values := derived.DerivedColumns()
+		return derived.BaseRow(), names, values
+	}
+
+	return row, nil, nil
+}
+
+// MarshalJSON returns the JSON encoding of the row, like json.Marshal, but without reflection for rows that implement JSONAppender
+func MarshalJSON(row interface{}) ([]byte, error) {
+	return AppendJSON(nil, row)
+}
+
+// Buffered writers are reused across output files, since the changes and orderbook commands create several files for every batch
+var bufferedWriters = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewWriterSize(nil, writeBufferSize)
+	},
+}
+
+func getBufferedWriter(out io.Writer) *bufio.Writer {
+	buffered := bufferedWriters.Get().(*bufio.Writer)
+	buffered.Reset(out)
+	return buffered
+}
+
+func putBufferedWriter(buffered *bufio.Writer) {
+	buffered.Reset(nil)
+	bufferedWriters.Put(buffered)
+}
+
+// jsonWriter writes each row as a JSON object on its own line. Rows are encoded into a reused scratch buffer and written through a buffered
+// writer, so the underlying writer only sees large writes
+type jsonWriter struct {
+	out     *bufio.Writer
+	scratch []byte
+}
+
+func newJSONWriter(out io.Writer) *jsonWriter {
+	return &jsonWriter{out: getBufferedWriter(out), scratch: make([]byte, 0, 4096)}
+}
+
+func (w *jsonWriter) Write(row interface{}) error {
+	if w.out == nil {
+		return fmt.Errorf("the writer is closed")
+	}
+
+	encoded, err := AppendJSON(w.scratch[:0], row)
+	if err != nil {
+		return err
+	}
+
+	w.scratch = append(encoded, '\n')
+	_, err = w.out.Write(w.scratch)
+	return err
+}
+
+func (w *jsonWriter) Flush() error {
+	if w.out == nil {
+		return nil
+	}
+
+	return w.out.Flush()
+}
+
+func (w *jsonWriter) Close() error {
+	if w.out == nil {
+		return nil
+	}
+
+	err := w.out.Flush()
+	putBufferedWriter(w.out)
+	w.out = nil
+	return err
+}
@@ -0,0 +1,310 @@
+/*
+	Package pipeline runs a sequence of stellar-etl commands that is declared in a YAML file. Each step runs one command with its arguments,
+	after the steps that it depends on have succeeded. Arguments can refer to the shared parameters of the pipeline as ${name}, and to the
+	outputs of earlier steps as ${step.output}; the outputs of a step are the fields of the JSON summary that its command prints last.
+*/
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Step runs one command. Arguments are flag names without dashes; true booleans become bare flags, and lists repeat the flag for each item
+type Step struct {
+	Name      string                 `yaml:"name"`
+	Command   string                 `yaml:"command"`
+	Args      map[string]interface{} `yaml:"args"`
+	DependsOn []string               `yaml:"depends_on"`
+	// Retries overrides the number of retries of the pipeline for this step
+	Retries *int `yaml:"retries"`
+}
+
+/*
+	Pipeline is the content of a pipeline file. Params are shared parameters that arguments can refer to. Args are shared arguments, which
+	are passed to every step whose command has the flag, unless the step sets the flag itself. A failed step is run again up to Retries times,
+	RetryDelay apart.
+*/
+type Pipeline struct {
+	Name       string                 `yaml:"name"`
+	Params     map[string]string      `yaml:"params"`
+	Args       map[string]interface{} `yaml:"args"`
+	Retries    int                    `yaml:"retries"`
+	RetryDelay string                 `yaml:"retry_delay"`
+	Report     string                 `yaml:"report"`
+	Steps      []Step                 `yaml:"steps"`
+}
+
+// Runner runs the commands of a pipeline
+type Runner interface {
+	// Flags returns the names of the flags of the command, or an error if there is no such command
+	Flags(command string) (map[string]bool, error)
+	// Run runs the command with the arguments, and returns the JSON summary that it printed last, if any
+	Run(command string, args []string) (map[string]interface{}, error)
+}
+
+// Load reads a pipeline file. Unknown fields are errors, so that misspelled keys are not silently ignored
+func Load(path string) (Pipeline, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	pipeline := Pipeline{}
+	if err := yaml.UnmarshalStrict(content, &pipeline); err != nil {
+		return Pipeline{}, fmt.Errorf("could not decode pipeline file %s: %v", path, err)
+	}
+
+	return pipeline, nil
+}
+
+var referencePattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// references returns the names of the steps whose outputs the value refers to
+func references(value string) []string {
+	steps := []string{}
+	for _, match := range referencePattern.FindAllStringSubmatch(value, -1) {
+		if dot := strings.Index(match[1], "."); dot >= 0 {
+			steps = append(steps, match[1][:dot])
+		}
+	}
+
+	return steps
+}
+
+// argValues returns the values of an argument as strings. Lists have one value per item
+func argValues(name string, value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case []interface{}:
+		values := []string{}
+		for _, item := range value {
+			itemValues, err := argValues(name, item)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(itemValues) != 1 {
+				return nil, fmt.Errorf("argument %s: lists cannot be nested", name)
+			}
+
+			values = append(values, itemValues...)
+		}
+
+		return values, nil
+	case string:
+		return []string{value}, nil
+	case bool:
+		return []string{strconv.FormatBool(value)}, nil
+	case int:
+		return []string{strconv.Itoa(value)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(value, 'f', -1, 64)}, nil
+	case nil:
+		return nil, fmt.Errorf("argument %s has no value", name)
+	default:
+		return nil, fmt.Errorf("argument %s: values have to be strings, numbers, booleans, or lists of them, not %v", name, value)
+	}
+}
+
+// stepArgs returns the arguments of the step, including the shared arguments that its command has a flag for
+func (p Pipeline) stepArgs(step Step, flags map[string]bool) map[string]interface{} {
+	args := map[string]interface{}{}
+	for name, value := range p.Args {
+		if flags[name] {
+			args[name] = value
+		}
+	}
+
+	for name, value := range step.Args {
+		args[name] = value
+	}
+
+	return args
+}
+
+/*
+	Order validates the pipeline and returns its steps in the order they run. Every step runs after the steps it depends on, and otherwise
+	in the order of the file. Steps have to have unique names and known commands, their arguments have to be flags of their commands, and
+	they can only refer to the outputs of the steps they depend on.
+*/
+func (p Pipeline) Order(runner Runner) ([]Step, error) {
+	if _, err := p.retryDelay(); err != nil {
+		return nil, err
+	}
+
+	byName := map[string]Step{}
+	for _, step := range p.Steps {
+		if step.Name == "" || strings.Contains(step.Name, ".") {
+			return nil, fmt.Errorf("%q is not a valid step name; names have to be non-empty and cannot contain dots", step.Name)
+		}
+
+		if _, exists := byName[step.Name]; exists {
+			return nil, fmt.Errorf("there are several steps named %s", step.Name)
+		}
+
+		byName[step.Name] = step
+	}
+
+	for _, step := range p.Steps {
+		flags, err := runner.Flags(step.Command)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %v", step.Name, err)
+		}
+
+		dependencies := map[string]bool{}
+		for _, dependency := range step.DependsOn {
+			if _, exists := byName[dependency]; !exists {
+				return nil, fmt.Errorf("step %s depends on the unknown step %s", step.Name, dependency)
+			}
+
+			dependencies[dependency] = true
+		}
+
+		for name, value := range p.stepArgs(step, flags) {
+			if !flags[name] {
+				return nil, fmt.Errorf("step %s: %s has no flag named %s", step.Name, step.Command, name)
+			}
+
+			values, err := argValues(name, value)
+			if err != nil {
+				return nil, fmt.Errorf("step %s: %v", step.Name, err)
+			}
+
+			for _, v := range values {
+				for _, referenced := range references(v) {
+					if !dependencies[referenced] {
+						return nil, fmt.Errorf("step %s refers to the outputs of %s, which it does not depend on", step.Name, referenced)
+					}
+				}
+			}
+		}
+	}
+
+	ordered := []Step{}
+	state := map[string]int{} // 1 while the dependencies of a step are being visited, 2 once the step is ordered
+	var visit func(step Step, path []string) error
+	visit = func(step Step, path []string) error {
+		switch state[step.Name] {
+		case 1:
+			return fmt.Errorf("the steps have a dependency cycle: %s", strings.Join(append(path, step.Name), " -> "))
+		case 2:
+			return nil
+		}
+
+		state[step.Name] = 1
+		for _, dependency := range step.DependsOn {
+			if err := visit(byName[dependency], append(path, step.Name)); err != nil {
+				return err
+			}
+		}
+
+		state[step.Name] = 2
+		ordered = append(ordered, step)
+		return nil
+	}
+
+	for _, step := range p.Steps {
+		if err := visit(step, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+func (p Pipeline) retryDelay() (time.Duration, error) {
+	if p.RetryDelay == "" {
+		return 0, nil
+	}
+
+	delay, err := time.ParseDuration(p.RetryDelay)
+	if err != nil {
+		return 0, fmt.Errorf("invalid retry_delay: %v", err)
+	}
+
+	return delay, nil
+}
+
+// expand replaces the references in the value with the parameters and the outputs of earlier steps
+func expand(value string, params map[string]string, outputs map[string]map[string]string) (string, error) {
+	var err error
+	expanded := referencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		name := reference[2 : len(reference)-1]
+		if dot := strings.Index(name, "."); dot >= 0 {
+			output, ok := outputs[name[:dot]][name[dot+1:]]
+			if !ok && err == nil {
+				err = fmt.Errorf("step %s has no output named %s", name[:dot], name[dot+1:])
+			}
+
+			return output
+		}
+
+		param, ok := params[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("there is no parameter named %s", name)
+		}
+
+		return param
+	})
+
+	return expanded, err
+}
+
+// commandArgs builds the command line arguments of the step, with the references expanded. Arguments are sorted by name, so that the command lines are reproducible
+func commandArgs(args map[string]interface{}, params map[string]string, outputs map[string]map[string]string) ([]string, error) {
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	commandLine := []string{}
+	for _, name := range names {
+		if value, isBool := args[name].(bool); isBool && value {
+			commandLine = append(commandLine, "--"+name)
+			continue
+		}
+
+		values, err := argValues(name, args[name])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, value := range values {
+			expanded, err := expand(value, params, outputs)
+			if err != nil {
+				return nil, fmt.Errorf("argument %s: %v", name, err)
+			}
+
+			commandLine = append(commandLine, "--"+name+"="+expanded)
+		}
+	}
+
+	return commandLine, nil
+}
+
+// outputStrings converts the summary of a step into outputs that arguments can refer to. Strings are used as they are, and other values as JSON
+func outputStrings(summary map[string]interface{}) map[string]string {
+	outputs := map[string]string{}
+	for key, value := range summary {
+		if s, ok := value.(string); ok {
+			outputs[key] = s
+			continue
+		}
+
+		encoded, err := json.Marshal(value)
+		if err == nil {
+			outputs[key] = string(encoded)
+		}
+	}
+
+	return outputs
+}
@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRunner runs commands that fail the number of times in failures, and returns the summaries in summaries
+type fakeRunner struct {
+	failures  map[string]int
+	summaries map[string]map[string]interface{}
+	calls     []string
+}
+
+var fakeFlags = map[string]map[string]bool{
+	"get_ledger_range_from_times": {"start-time": true, "end-time": true, "stdout": true},
+	"export_ledgers":              {"start-ledger": true, "end-ledger": true, "format": true, "sink": true},
+	"check_liabilities":           {"end-ledger": true, "format": true},
+}
+
+func (r *fakeRunner) Flags(command string) (map[string]bool, error) {
+	flags, ok := fakeFlags[command]
+	if !ok {
+		return nil, fmt.Errorf("there is no command named %q", command)
+	}
+
+	return flags, nil
+}
+
+func (r *fakeRunner) Run(command string, args []string) (map[string]interface{}, error) {
+	r.calls = append(r.calls, command+" "+strings.Join(args, " "))
+	if r.failures[command] > 0 {
+		r.failures[command]--
+		return nil, fmt.Errorf("%s failed", command)
+	}
+
+	return r.summaries[command], nil
+}
+
+func TestOrder(t *testing.T) {
+	type functionInput struct {
+		steps []Step
+	}
+	type functionOutput struct {
+		order []string
+		err   error
+	}
+
+	rangeStep := Step{Name: "range", Command: "get_ledger_range_from_times", Args: map[string]interface{}{"stdout": true}}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{[]Step{
+				{Name: "ledgers", Command: "export_ledgers", DependsOn: []string{"range"}, Args: map[string]interface{}{"end-ledger": "${range.end}"}},
+				rangeStep,
+				{Name: "check", Command: "check_liabilities"},
+			}},
+			functionOutput{[]string{"range", "ledgers", "check"}, nil},
+		},
+		{
+			functionInput{[]Step{rangeStep, {Name: "ledgers", Command: "export_ledgers", Args: map[string]interface{}{"end-ledger": "${range.end}"}}}},
+			functionOutput{nil, fmt.Errorf("step ledgers refers to the outputs of range, which it does not depend on")},
+		},
+		{
+			functionInput{[]Step{
+				{Name: "a", Command: "check_liabilities", DependsOn: []string{"b"}},
+				{Name: "b", Command: "check_liabilities", DependsOn: []string{"a"}},
+			}},
+			functionOutput{nil, fmt.Errorf("the steps have a dependency cycle: a -> b -> a")},
+		},
+		{
+			functionInput{[]Step{{Name: "check", Command: "check_liabilities", Args: map[string]interface{}{"start-ledger": 1}}}},
+			functionOutput{nil, fmt.Errorf("step check: check_liabilities has no flag named start-ledger")},
+		},
+		{
+			functionInput{[]Step{{Name: "check", Command: "audit_everything"}}},
+			functionOutput{nil, fmt.Errorf(`step check: there is no command named "audit_everything"`)},
+		},
+		{
+			functionInput{[]Step{rangeStep, rangeStep}},
+			functionOutput{nil, fmt.Errorf("there are several steps named range")},
+		},
+		{
+			functionInput{[]Step{{Name: "check", Command: "check_liabilities", DependsOn: []string{"range"}}}},
+			functionOutput{nil, fmt.Errorf("step check depends on the unknown step range")},
+		},
+	}
+
+	for _, test := range tests {
+		steps, err := Pipeline{Steps: test.input.steps}.Order(&fakeRunner{})
+		assert.Equal(t, test.output.err, err)
+		if err == nil {
+			names := []string{}
+			for _, step := range steps {
+				names = append(names, step.Name)
+			}
+
+			assert.Equal(t, test.output.order, names)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	dir, err := ioutil.TempDir("", "pipeline")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pipeline.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+name: hourly
+params:
+  start: "2021-01-01T00:00:00Z"
+  end: "2021-01-01T01:00:00Z"
+args:
+  format: avro
+  sink: [exported/, "s3://bucket/stellar"]
+retries: 2
+retry_delay: 10s
+steps:
+  - name: range
+    command: get_ledger_range_from_times
+    args: {start-time: "${start}", end-time: "${end}", stdout: true}
+  - name: ledgers
+    command: export_ledgers
+    depends_on: [range]
+    args: {start-ledger: "${range.start}", end-ledger: "${range.end}"}
+  - name: check
+    command: check_liabilities
+    depends_on: [range]
+    retries: 0
+    args: {end-ledger: "${range.end}", format: json}
+`), 0644)
+	assert.NoError(t, err)
+
+	p, err := Load(path)
+	assert.NoError(t, err)
+
+	runner := &fakeRunner{
+		failures:  map[string]int{"export_ledgers": 2, "check_liabilities": 1},
+		summaries: map[string]map[string]interface{}{"get_ledger_range_from_times": {"start": 10, "end": 20}},
+	}
+
+	report, err := p.Run(runner)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"get_ledger_range_from_times --end-time=2021-01-01T01:00:00Z --start-time=2021-01-01T00:00:00Z --stdout",
+		"export_ledgers --end-ledger=20 --format=avro --sink=exported/ --sink=s3://bucket/stellar --start-ledger=10",
+		"export_ledgers --end-ledger=20 --format=avro --sink=exported/ --sink=s3://bucket/stellar --start-ledger=10",
+		"export_ledgers --end-ledger=20 --format=avro --sink=exported/ --sink=s3://bucket/stellar --start-ledger=10",
+		"check_liabilities --end-ledger=20 --format=json",
+	}, runner.calls)
+
+	assert.Equal(t, Failed, report.Status)
+	statuses := []string{}
+	for _, step := range report.Steps {
+		statuses = append(statuses, fmt.Sprintf("%s %s %d", step.Name, step.Status, step.Attempts))
+	}
+
+	assert.Equal(t, []string{"range succeeded 1", "ledgers succeeded 3", "check failed 1"}, statuses)
+	assert.Equal(t, []string{"check_liabilities failed"}, report.Steps[2].Errors)
+}
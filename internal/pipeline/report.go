@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// The statuses of a step in a run report
+const (
+	Succeeded = "succeeded"
+	Failed    = "failed"
+	Skipped   = "skipped"
+)
+
+// StepReport records how a step ran. Skipped steps did not run because a step that they depend on failed
+type StepReport struct {
+	Name       string                 `json:"name"`
+	Command    string                 `json:"command"`
+	Args       []string               `json:"args,omitempty"`
+	Status     string                 `json:"status"`
+	Attempts   int                    `json:"attempts"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+	Seconds    float64                `json:"seconds"`
+	Errors     []string               `json:"errors,omitempty"`
+	Summary    map[string]interface{} `json:"summary,omitempty"`
+}
+
+// Report is the consolidated report of a pipeline run, with the steps in the order they ran
+type Report struct {
+	Pipeline   string            `json:"pipeline"`
+	Params     map[string]string `json:"params,omitempty"`
+	Status     string            `json:"status"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Steps      []StepReport      `json:"steps"`
+}
+
+// Succeeded reports whether every step of the run succeeded
+func (r Report) Succeeded() bool {
+	return r.Status == Succeeded
+}
+
+// Write writes the report to path as indented JSON
+func (r Report) Write(path string) error {
+	encoded, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(encoded, '\n'), 0644)
+}
+
+// sleep waits between the attempts of a step. Tests replace it to run without delays
+var sleep = time.Sleep
+
+/*
+	Run runs the steps of the pipeline in order. A failed step is retried, and if it fails every attempt, the steps that depend on it are
+	skipped while the other steps still run. The report is returned once every step has finished or been skipped; Run only returns an error
+	if the pipeline is invalid, in which case no step runs.
+*/
+func (p Pipeline) Run(runner Runner) (Report, error) {
+	steps, err := p.Order(runner)
+	if err != nil {
+		return Report{}, err
+	}
+
+	delay, _ := p.retryDelay()
+	report := Report{Pipeline: p.Name, Params: p.Params, Status: Succeeded, StartedAt: time.Now().UTC()}
+	outputs := map[string]map[string]string{}
+	failed := map[string]bool{}
+	for _, step := range steps {
+		stepReport := StepReport{Name: step.Name, Command: step.Command}
+		for _, dependency := range step.DependsOn {
+			if failed[dependency] {
+				stepReport.Status = Skipped
+				stepReport.Errors = []string{fmt.Sprintf("step %s did not succeed", dependency)}
+			}
+		}
+
+		if stepReport.Status == Skipped {
+			failed[step.Name] = true
+			report.Status = Failed
+			report.Steps = append(report.Steps, stepReport)
+			continue
+		}
+
+		flags, _ := runner.Flags(step.Command)
+		stepReport = p.runStep(runner, step, flags, outputs, delay)
+		if stepReport.Status == Succeeded {
+			outputs[step.Name] = outputStrings(stepReport.Summary)
+		} else {
+			failed[step.Name] = true
+			report.Status = Failed
+		}
+
+		report.Steps = append(report.Steps, stepReport)
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// runStep runs the step until it succeeds or runs out of retries
+func (p Pipeline) runStep(runner Runner, step Step, flags map[string]bool, outputs map[string]map[string]string, delay time.Duration) StepReport {
+	stepReport := StepReport{Name: step.Name, Command: step.Command, Status: Failed}
+	args, err := commandArgs(p.stepArgs(step, flags), p.Params, outputs)
+	if err != nil {
+		stepReport.Errors = []string{err.Error()}
+		return stepReport
+	}
+
+	stepReport.Args = args
+	retries := p.Retries
+	if step.Retries != nil {
+		retries = *step.Retries
+	}
+
+	startedAt := time.Now().UTC()
+	stepReport.StartedAt = &startedAt
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			sleep(delay)
+		}
+
+		stepReport.Attempts++
+		summary, err := runner.Run(step.Command, args)
+		if err == nil {
+			stepReport.Status = Succeeded
+			stepReport.Summary = summary
+			break
+		}
+
+		stepReport.Errors = append(stepReport.Errors, err.Error())
+	}
+
+	finishedAt := time.Now().UTC()
+	stepReport.FinishedAt = &finishedAt
+	stepReport.Seconds = finishedAt.Sub(startedAt).Seconds()
+	return stepReport
+}
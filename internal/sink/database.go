@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/lib/pq"
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+/*
+	databaseSink loads batches into a PostgreSQL database. Each dataset is stored in a table with the name of the dataset, which is created
+	on the first delivery. The table has one row per exported row, keyed by the ledger range of the batch and the position of the row in it:
+
+		batch_start bigint, batch_end bigint, row_index integer, data jsonb
+
+	A batch is deleted and inserted again in a single transaction, so it is confirmed only when the transaction commits, and delivering it
+	again replaces it.
+*/
+type databaseSink struct {
+	db          *sql.DB
+	description string
+	created     map[string]bool
+}
+
+func newDatabaseSink(location string) (*databaseSink, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse database URL: %v", err)
+	}
+
+	// The connection is opened lazily, so an unreachable database is retried like any other failed delivery
+	db, err := sql.Open("postgres", location)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed.User = nil
+	parsed.RawQuery = ""
+	return &databaseSink{db: db, description: parsed.String(), created: map[string]bool{}}, nil
+}
+
+func (s *databaseSink) createTable(table string) error {
+	if s.created[table] {
+		return nil
+	}
+
+	_, err := s.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		batch_start bigint NOT NULL,
+		batch_end bigint NOT NULL,
+		row_index integer NOT NULL,
+		data jsonb NOT NULL,
+		PRIMARY KEY (batch_start, batch_end, row_index)
+	)`, table))
+	if err != nil {
+		return err
+	}
+
+	s.created[table] = true
+	return nil
+}
+
+func (s *databaseSink) Deliver(batch Batch) error {
+	table := pq.QuoteIdentifier(batch.Dataset)
+	if err := s.createTable(table); err != nil {
+		return fmt.Errorf("could not create table %s: %v", table, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Rolling back a committed transaction has no effect
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE batch_start = $1 AND batch_end = $2", table), batch.Start, batch.End)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(batch.Dataset, "batch_start", "batch_end", "row_index", "data"))
+	if err != nil {
+		return err
+	}
+
+	for i, row := range batch.Rows {
+		data, err := output.MarshalJSON(row)
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+
+		if _, err := stmt.Exec(batch.Start, batch.End, i, string(data)); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// Executing the statement without arguments flushes the copied rows
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *databaseSink) String() string {
+	return s.description
+}
@@ -0,0 +1,129 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+// batchObjectName returns the name of the file or object that holds the batch, relative to the root of the sink
+func batchObjectName(format string, batch Batch) string {
+	return path.Join(batch.Dataset, batch.Name()+output.Extension(format))
+}
+
+// fileSink writes each batch to its own file in a local folder, in a subfolder with the name of the dataset
+type fileSink struct {
+	root         string
+	format       string
+	indexPattern string
+}
+
+func newFileSink(location, format, indexPattern string) (*fileSink, error) {
+	if location == "" {
+		return nil, fmt.Errorf("file sinks need a folder")
+	}
+
+	root, err := filepath.Abs(location)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{root: root, format: format, indexPattern: indexPattern}, nil
+}
+
+// Deliver writes the batch to a temporary file that is then renamed, so the file of the batch is either complete or absent
+func (s *fileSink) Deliver(batch Batch) error {
+	encoded, err := encodeBatch(s.format, s.indexPattern, batch)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(s.root, filepath.FromSlash(batchObjectName(s.format, batch)))
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.Write(encoded); err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	// The data has to reach the disk before the file is confirmed
+	if err := tempFile.Sync(); err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), name)
+}
+
+func (s *fileSink) String() string {
+	return "folder " + s.root
+}
+
+// objectSink writes each batch as one object to an S3 bucket, under the prefix and the name of the dataset
+type objectSink struct {
+	client       *s3.S3
+	bucket       string
+	prefix       string
+	format       string
+	indexPattern string
+}
+
+func newObjectSink(location, format, indexPattern string) (*objectSink, error) {
+	parts := strings.SplitN(strings.TrimPrefix(location, "s3://"), "/", 2)
+	if parts[0] == "" {
+		return nil, fmt.Errorf("S3 location %s does not have a bucket", location)
+	}
+
+	prefix := ""
+	if len(parts) == 2 {
+		prefix = strings.Trim(parts[1], "/")
+	}
+
+	// Credentials and the region are read from the environment and the shared AWS config, like the AWS CLI does
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("could not create an AWS session: %v", err)
+	}
+
+	return &objectSink{client: s3.New(sess), bucket: parts[0], prefix: prefix, format: format, indexPattern: indexPattern}, nil
+}
+
+// Deliver uploads the batch. S3 confirms the upload only once the object is stored, and a new upload replaces the object atomically
+func (s *objectSink) Deliver(batch Batch) error {
+	encoded, err := encodeBatch(s.format, s.indexPattern, batch)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, batchObjectName(s.format, batch))),
+		Body:   bytes.NewReader(encoded),
+	})
+	return err
+}
+
+func (s *objectSink) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.prefix)
+}
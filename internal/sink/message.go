@@ -0,0 +1,96 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+// Kinesis accepts at most 500 records and 5 MB in one request
+const (
+	maxRecordsPerRequest = 500
+	maxBytesPerRequest   = 5 << 20
+)
+
+/*
+	messageSink sends every row of a batch as a JSON record to a Kinesis data stream. All the records of a batch have the partition key
+	dataset/start-end, so they land on the same shard in the order of the batch. A batch is confirmed once the stream has accepted every
+	record. Streams can only be appended to, so records that were accepted before a failed delivery are sent again when it is retried, and
+	consumers should expect duplicates.
+*/
+type messageSink struct {
+	client *kinesis.Kinesis
+	stream string
+}
+
+func newMessageSink(location string) (*messageSink, error) {
+	stream := strings.Trim(strings.TrimPrefix(location, "kinesis://"), "/")
+	if stream == "" {
+		return nil, fmt.Errorf("Kinesis location %s does not have a stream name", location)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("could not create an AWS session: %v", err)
+	}
+
+	return &messageSink{client: kinesis.New(sess), stream: stream}, nil
+}
+
+// batchRequests splits the records of the batch into groups that fit into a single request
+func batchRequests(batch Batch) ([][]*kinesis.PutRecordsRequestEntry, error) {
+	partitionKey := batch.Dataset + "/" + batch.Name()
+	requests := [][]*kinesis.PutRecordsRequestEntry{}
+	current := []*kinesis.PutRecordsRequestEntry{}
+	currentSize := 0
+	for _, row := range batch.Rows {
+		data, err := output.MarshalJSON(row)
+		if err != nil {
+			return nil, err
+		}
+
+		size := len(data) + len(partitionKey)
+		if len(current) == maxRecordsPerRequest || (len(current) > 0 && currentSize+size > maxBytesPerRequest) {
+			requests = append(requests, current)
+			current = []*kinesis.PutRecordsRequestEntry{}
+			currentSize = 0
+		}
+
+		current = append(current, &kinesis.PutRecordsRequestEntry{Data: data, PartitionKey: aws.String(partitionKey)})
+		currentSize += size
+	}
+
+	if len(current) > 0 {
+		requests = append(requests, current)
+	}
+
+	return requests, nil
+}
+
+func (s *messageSink) Deliver(batch Batch) error {
+	requests, err := batchRequests(batch)
+	if err != nil {
+		return err
+	}
+
+	for _, records := range requests {
+		response, err := s.client.PutRecords(&kinesis.PutRecordsInput{StreamName: aws.String(s.stream), Records: records})
+		if err != nil {
+			return err
+		}
+
+		if failed := aws.Int64Value(response.FailedRecordCount); failed > 0 {
+			return fmt.Errorf("the stream rejected %d of %d records", failed, len(records))
+		}
+	}
+
+	return nil
+}
+
+func (s *messageSink) String() string {
+	return "kinesis://" + s.stream
+}
@@ -0,0 +1,163 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/output"
+)
+
+const defaultRetryDelay = time.Second
+
+// Batch is a set of exported rows that is delivered to every sink as a unit. The rows of a batch all belong to the same dataset
+type Batch struct {
+	Dataset string
+	Start   uint32
+	End     uint32
+	Rows    []interface{}
+	// ExampleRow determines the schema of the batch, which formats with a schema need even if the batch is empty
+	ExampleRow interface{}
+}
+
+// Name identifies the batch in object names, log messages, and message keys
+func (b Batch) Name() string {
+	return fmt.Sprintf("%d-%d", b.Start, b.End)
+}
+
+// Sink is a destination for exported batches
+type Sink interface {
+	// Deliver writes the batch and returns once the destination has confirmed it. Delivering the same batch again replaces the earlier
+	// delivery, except in sinks that only append, which document this
+	Deliver(batch Batch) error
+	// String describes the sink in log messages without any credentials
+	String() string
+}
+
+/*
+	Open returns the sink for a location. The scheme of the location picks the kind of sink: s3://bucket/prefix is an object store that
+	stores each batch as one object, postgres://user@host/database is a database that loads each dataset into the table with the name of
+	the dataset, and kinesis://stream is a message bus that receives each row as one record. Locations without one of these schemes, or
+	with the file:// scheme, are local folders. The format and the index pattern of the es-bulk format apply to the files and objects; the
+	database and the message bus always store JSON.
+*/
+func Open(location, format, indexPattern string) (Sink, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		return newObjectSink(location, format, indexPattern)
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		return newDatabaseSink(location)
+	case strings.HasPrefix(location, "kinesis://"):
+		return newMessageSink(location)
+	default:
+		return newFileSink(strings.TrimPrefix(location, "file://"), format, indexPattern)
+	}
+}
+
+// encodeBatch returns the rows of the batch in the output format
+func encodeBatch(format, indexPattern string, batch Batch) ([]byte, error) {
+	var encoded bytes.Buffer
+	writer, err := output.NewWriter(format, indexPattern, &encoded, batch.Dataset, batch.ExampleRow)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range batch.Rows {
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// Result is the outcome of delivering a batch to one sink
+type Result struct {
+	Sink     string
+	Required bool
+	Attempts int
+	Err      error
+}
+
+type target struct {
+	sink     Sink
+	required bool
+}
+
+// Fanout delivers each batch to several sinks. Every sink receives the batch concurrently and retries independently of the others, so
+// a slow or failing sink does not hold back the retries of another. A batch is complete once all of the required sinks have confirmed it;
+// optional sinks are delivered to on a best effort basis
+type Fanout struct {
+	targets    []target
+	attempts   int
+	retryDelay time.Duration
+}
+
+// NewFanout creates a fanout without any sinks. Each sink gets up to attempts tries to deliver a batch, with the delay between tries doubling
+// after every failure
+func NewFanout(attempts int) *Fanout {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return &Fanout{attempts: attempts, retryDelay: defaultRetryDelay}
+}
+
+// Add adds a sink to the fanout
+func (f *Fanout) Add(s Sink, required bool) {
+	f.targets = append(f.targets, target{sink: s, required: required})
+}
+
+// Deliver sends the batch to every sink and waits for all of them to finish. The results are in the order that the sinks were added in.
+// The error is not nil if any required sink failed to confirm the batch
+func (f *Fanout) Deliver(batch Batch) ([]Result, error) {
+	results := make([]Result, len(f.targets))
+	var wg sync.WaitGroup
+	for i, t := range f.targets {
+		wg.Add(1)
+		go func(i int, t target) {
+			defer wg.Done()
+			results[i] = f.deliverWithRetries(t, batch)
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	failed := []string{}
+	for _, result := range results {
+		if result.Required && result.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", result.Sink, result.Err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("batch %s of %s was not confirmed by the required sinks: %s", batch.Name(), batch.Dataset, strings.Join(failed, "; "))
+	}
+
+	return results, nil
+}
+
+func (f *Fanout) deliverWithRetries(t target, batch Batch) Result {
+	result := Result{Sink: t.sink.String(), Required: t.required}
+	delay := f.retryDelay
+	for result.Attempts < f.attempts {
+		if result.Attempts > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		result.Attempts++
+		result.Err = t.sink.Deliver(batch)
+		if result.Err == nil {
+			break
+		}
+	}
+
+	return result
+}
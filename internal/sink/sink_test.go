@@ -0,0 +1,154 @@
+package sink
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRow struct {
+	Sequence uint32 `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+// flakySink fails the first failures deliveries and records every batch that it confirmed
+type flakySink struct {
+	name      string
+	failures  int
+	calls     int
+	confirmed []string
+}
+
+func (s *flakySink) Deliver(batch Batch) error {
+	s.calls++
+	if s.calls <= s.failures {
+		return fmt.Errorf("unavailable")
+	}
+
+	s.confirmed = append(s.confirmed, batch.Name())
+	return nil
+}
+
+func (s *flakySink) String() string {
+	return s.name
+}
+
+func makeTestBatch(start, end uint32) Batch {
+	return Batch{
+		Dataset:    "ledgers",
+		Start:      start,
+		End:        end,
+		Rows:       []interface{}{testRow{Sequence: start, Hash: "abc"}, testRow{Sequence: end, Hash: "def"}},
+		ExampleRow: testRow{},
+	}
+}
+
+func TestFanoutDeliver(t *testing.T) {
+	type functionInput struct {
+		required []*flakySink
+		optional []*flakySink
+	}
+	type functionOutput struct {
+		attempts []int
+		err      error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{[]*flakySink{{name: "file"}, {name: "db", failures: 2}}, nil},
+			functionOutput{[]int{1, 3}, nil},
+		},
+		{
+			functionInput{[]*flakySink{{name: "file"}, {name: "db", failures: 3}}, nil},
+			functionOutput{[]int{1, 3}, fmt.Errorf("batch 1-64 of ledgers was not confirmed by the required sinks: db (unavailable)")},
+		},
+		{
+			functionInput{[]*flakySink{{name: "file"}}, []*flakySink{{name: "bus", failures: 5}}},
+			functionOutput{[]int{1, 3}, nil},
+		},
+	}
+
+	for _, test := range tests {
+		fanout := NewFanout(3)
+		fanout.retryDelay = 0
+		for _, s := range test.input.required {
+			fanout.Add(s, true)
+		}
+
+		for _, s := range test.input.optional {
+			fanout.Add(s, false)
+		}
+
+		results, err := fanout.Deliver(makeTestBatch(1, 64))
+		assert.Equal(t, test.output.err, err)
+
+		attempts := []int{}
+		for _, result := range results {
+			attempts = append(attempts, result.Attempts)
+		}
+
+		assert.Equal(t, test.output.attempts, attempts)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	folder, err := ioutil.TempDir("", "file-sink")
+	assert.NoError(t, err)
+	defer os.RemoveAll(folder)
+
+	s, err := Open("file://"+folder, "json", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "folder "+folder, s.String())
+
+	assert.NoError(t, s.Deliver(makeTestBatch(1, 64)))
+	// Delivering the batch again replaces the file
+	assert.NoError(t, s.Deliver(makeTestBatch(1, 64)))
+
+	written, err := ioutil.ReadFile(filepath.Join(folder, "ledgers", "1-64.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"sequence\":1,\"hash\":\"abc\"}\n{\"sequence\":64,\"hash\":\"def\"}\n", string(written))
+
+	files, err := ioutil.ReadDir(filepath.Join(folder, "ledgers"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	avroSink, err := Open(folder, "avro", "")
+	assert.NoError(t, err)
+	assert.NoError(t, avroSink.Deliver(makeTestBatch(65, 128)))
+	written, err = ioutil.ReadFile(filepath.Join(folder, "ledgers", "65-128.avro"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(written), "Obj\x01"))
+}
+
+func TestBatchRequests(t *testing.T) {
+	batch := Batch{Dataset: "operations", Start: 1, End: 64}
+	for i := 0; i < maxRecordsPerRequest+1; i++ {
+		batch.Rows = append(batch.Rows, testRow{Sequence: uint32(i)})
+	}
+
+	requests, err := batchRequests(batch)
+	assert.NoError(t, err)
+	assert.Len(t, requests, 2)
+	assert.Len(t, requests[0], maxRecordsPerRequest)
+	assert.Len(t, requests[1], 1)
+	assert.Equal(t, "operations/1-64", *requests[1][0].PartitionKey)
+	assert.Equal(t, `{"sequence":500,"hash":""}`, string(requests[1][0].Data))
+
+	// Large rows start a new request before the size limit is reached
+	large := Batch{Dataset: "operations", Start: 1, End: 64}
+	for i := 0; i < 3; i++ {
+		large.Rows = append(large.Rows, testRow{Hash: strings.Repeat("a", maxBytesPerRequest/2)})
+	}
+
+	requests, err = batchRequests(large)
+	assert.NoError(t, err)
+	assert.Len(t, requests, 3)
+}
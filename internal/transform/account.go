@@ -9,24 +9,27 @@ import (
 
 //TransformAccount converts an account from the history archive ingestion system into a form suitable for BigQuery
 func TransformAccount(ledgerChange ingestio.Change) (AccountOutput, error) {
+	errorContext := TransformError{Dataset: AccountsDataset}
 	ledgerEntry, outputDeleted, err := utils.ExtractEntryFromChange(ledgerChange)
 	if err != nil {
-		return AccountOutput{}, err
+		return AccountOutput{}, errorContext.wrap(DecodeFailure, err)
 	}
 
+	errorContext.LedgerSequence = uint32(ledgerEntry.LastModifiedLedgerSeq)
 	accountEntry, accountFound := ledgerEntry.Data.GetAccount()
 	if !accountFound {
-		return AccountOutput{}, fmt.Errorf("Could not extract account data from ledger entry; actual type is %s", ledgerEntry.Data.Type)
+		return AccountOutput{}, errorContext.wrap(UnsupportedType, fmt.Errorf("Could not extract account data from ledger entry; actual type is %s", ledgerEntry.Data.Type))
 	}
 
 	outputID, err := accountEntry.AccountId.GetAddress()
 	if err != nil {
-		return AccountOutput{}, err
+		return AccountOutput{}, errorContext.wrap(DecodeFailure, err)
 	}
 
+	errorContext.EntryKey = outputID
 	outputBalance := int64(accountEntry.Balance)
 	if outputBalance < 0 {
-		return AccountOutput{}, fmt.Errorf("Balance is negative (%d) for account: %s", outputBalance, outputID)
+		return AccountOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Balance is negative (%d) for account: %s", outputBalance, outputID))
 	}
 
 	//The V1 struct is the first version of the extender from accountEntry. It contains information on liabilities, and in the future
@@ -37,17 +40,17 @@ func TransformAccount(ledgerChange ingestio.Change) (AccountOutput, error) {
 		liabilities := accountExtensionInfo.Liabilities
 		outputBuyingLiabilities, outputSellingLiabilities = int64(liabilities.Buying), int64(liabilities.Selling)
 		if outputBuyingLiabilities < 0 {
-			return AccountOutput{}, fmt.Errorf("The buying liabilities count is negative (%d) for account: %s", outputBuyingLiabilities, outputID)
+			return AccountOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The buying liabilities count is negative (%d) for account: %s", outputBuyingLiabilities, outputID))
 		}
 
 		if outputSellingLiabilities < 0 {
-			return AccountOutput{}, fmt.Errorf("The selling liabilities count is negative (%d) for account: %s", outputSellingLiabilities, outputID)
+			return AccountOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The selling liabilities count is negative (%d) for account: %s", outputSellingLiabilities, outputID))
 		}
 	}
 
 	outputSequenceNumber := int64(accountEntry.SeqNum)
 	if outputSequenceNumber < 0 {
-		return AccountOutput{}, fmt.Errorf("Account sequence number is negative (%d) for account: %s", outputSequenceNumber, outputID)
+		return AccountOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Account sequence number is negative (%d) for account: %s", outputSequenceNumber, outputID))
 	}
 
 	outputNumSubentries := uint32(accountEntry.NumSubEntries)
@@ -57,7 +60,7 @@ func TransformAccount(ledgerChange ingestio.Change) (AccountOutput, error) {
 	if inflationDestAccountID != nil {
 		outputInflationDest, err = inflationDestAccountID.GetAddress()
 		if err != nil {
-			return AccountOutput{}, err
+			return AccountOutput{}, errorContext.wrap(DecodeFailure, err)
 		}
 	}
 
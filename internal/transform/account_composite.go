@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"fmt"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// AccountSubentries counts the trustlines, offers, and data entries that an account owns
+type AccountSubentries struct {
+	Trustlines  uint32
+	Offers      uint32
+	DataEntries uint32
+}
+
+// CountAccountSubentries counts the subentries of each account in the changes of a bucket list snapshot, keyed by account address. Changes of other types are ignored
+func CountAccountSubentries(changes []ingestio.Change) (map[string]AccountSubentries, error) {
+	counts := map[string]AccountSubentries{}
+	for _, change := range changes {
+		ledgerEntry, deleted, err := utils.ExtractEntryFromChange(change)
+		if err != nil {
+			return nil, err
+		}
+
+		if deleted {
+			continue
+		}
+
+		var owner xdr.AccountId
+		switch ledgerEntry.Data.Type {
+		case xdr.LedgerEntryTypeTrustline:
+			owner = ledgerEntry.Data.MustTrustLine().AccountId
+		case xdr.LedgerEntryTypeOffer:
+			owner = ledgerEntry.Data.MustOffer().SellerId
+		case xdr.LedgerEntryTypeData:
+			owner = ledgerEntry.Data.MustData().AccountId
+		default:
+			continue
+		}
+
+		address, err := owner.GetAddress()
+		if err != nil {
+			return nil, err
+		}
+
+		count := counts[address]
+		switch ledgerEntry.Data.Type {
+		case xdr.LedgerEntryTypeTrustline:
+			count.Trustlines++
+		case xdr.LedgerEntryTypeOffer:
+			count.Offers++
+		case xdr.LedgerEntryTypeData:
+			count.DataEntries++
+		}
+
+		counts[address] = count
+	}
+
+	return counts, nil
+}
+
+/*
+	TransformAccountComposite converts an account into a row with the breakdown of its subentries, which are looked up in subentries by
+	the address of the account, and the reserve that they require. The reserve follows stellar-core: two base reserves for the account, and
+	one for each subentry and each entry that the account sponsors, less one for each of its entries that another account sponsors.
+*/
+func TransformAccountComposite(ledgerChange ingestio.Change, subentries map[string]AccountSubentries, baseReserve uint32) (AccountCompositeOutput, error) {
+	errorContext := TransformError{Dataset: AccountCompositesDataset}
+	account, err := TransformAccount(ledgerChange)
+	if err != nil {
+		return AccountCompositeOutput{}, errorContext.wrap(InvalidData, err)
+	}
+
+	// TransformAccount has already checked that the change holds an account entry
+	ledgerEntry, _, _ := utils.ExtractEntryFromChange(ledgerChange)
+	accountEntry := ledgerEntry.Data.MustAccount()
+	errorContext.LedgerSequence = account.LastModifiedLedger
+	errorContext.EntryKey = account.AccountID
+
+	numSponsored := uint32(accountEntry.NumSponsored())
+	numSponsoring := uint32(accountEntry.NumSponsoring())
+	reserveEntries := 2 + int64(account.NumSubentries) + int64(numSponsoring) - int64(numSponsored)
+	if reserveEntries < 0 {
+		return AccountCompositeOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The account sponsors fewer entries than are sponsored for it (%d sponsoring, %d sponsored) for account: %s", numSponsoring, numSponsored, account.AccountID))
+	}
+
+	totalReserve := reserveEntries * int64(baseReserve)
+	count := subentries[account.AccountID]
+	transformedComposite := AccountCompositeOutput{
+		AccountID:            account.AccountID,
+		Balance:              account.Balance,
+		BuyingLiabilities:    account.BuyingLiabilities,
+		SellingLiabilities:   account.SellingLiabilities,
+		SequenceNumber:       account.SequenceNumber,
+		NumSubentries:        account.NumSubentries,
+		InflationDestination: account.InflationDestination,
+		Flags:                account.Flags,
+		HomeDomain:           account.HomeDomain,
+		MasterWeight:         account.MasterWeight,
+		ThresholdLow:         account.ThresholdLow,
+		ThresholdMedium:      account.ThresholdMedium,
+		ThresholdHigh:        account.ThresholdHigh,
+		LastModifiedLedger:   account.LastModifiedLedger,
+		Deleted:              account.Deleted,
+		NumTrustlines:        count.Trustlines,
+		NumOffers:            count.Offers,
+		NumDataEntries:       count.DataEntries,
+		NumSigners:           uint32(len(accountEntry.Signers)),
+		NumSponsored:         numSponsored,
+		NumSponsoring:        numSponsoring,
+		BaseReserve:          baseReserve,
+		TotalReserve:         totalReserve,
+		AvailableBalance:     account.Balance - totalReserve - account.SellingLiabilities,
+	}
+	return transformedComposite, nil
+}
@@ -0,0 +1,146 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func wrapStateEntry(data xdr.LedgerEntryData) ingestio.Change {
+	return ingestio.Change{Type: data.Type, Post: &xdr.LedgerEntry{LastModifiedLedgerSeq: 100, Data: data}}
+}
+
+func TestCountAccountSubentries(t *testing.T) {
+	type functionInput struct {
+		changes []ingestio.Change
+	}
+	type functionOutput struct {
+		counts map[string]AccountSubentries
+		err    error
+	}
+
+	trustline := xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeTrustline, TrustLine: &xdr.TrustLineEntry{AccountId: testAccount1ID, Asset: ethAsset}}
+	removedTrustline := ingestio.Change{Type: xdr.LedgerEntryTypeTrustline, Pre: &xdr.LedgerEntry{Data: trustline}}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{[]ingestio.Change{
+				wrapStateEntry(trustline),
+				wrapStateEntry(trustline),
+				wrapStateEntry(xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeOffer, Offer: &xdr.OfferEntry{SellerId: testAccount1ID}}),
+				wrapStateEntry(xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeData, Data: &xdr.DataEntry{AccountId: testAccount2ID, DataName: "name"}}),
+				wrapStateEntry(xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeAccount, Account: &xdr.AccountEntry{AccountId: testAccount3ID}}),
+				removedTrustline,
+			}},
+			functionOutput{map[string]AccountSubentries{
+				testAccount1Address: {Trustlines: 2, Offers: 1},
+				testAccount2Address: {DataEntries: 1},
+			}, nil},
+		},
+		{
+			functionInput{[]ingestio.Change{wrapStateEntry(xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeOffer, Offer: &xdr.OfferEntry{SellerId: xdr.AccountId{Type: 1}}})}},
+			functionOutput{nil, fmt.Errorf("Unknown account id type: %v", xdr.PublicKeyType(1))},
+		},
+	}
+
+	for _, test := range tests {
+		counts, err := CountAccountSubentries(test.input.changes)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.counts, counts)
+	}
+}
+
+func TestTransformAccountComposite(t *testing.T) {
+	type functionInput struct {
+		account     xdr.AccountEntry
+		subentries  map[string]AccountSubentries
+		baseReserve uint32
+	}
+	type functionOutput struct {
+		output AccountCompositeOutput
+		err    error
+	}
+
+	sponsoredAccount := xdr.AccountEntry{
+		AccountId:     testAccount1ID,
+		Balance:       100000000,
+		NumSubEntries: 4,
+		Thresholds:    xdr.Thresholds([4]byte{1, 0, 0, 0}),
+		Signers:       []xdr.Signer{{Key: xdr.SignerKey{Type: xdr.SignerKeyTypeSignerKeyTypeEd25519, Ed25519: &xdr.Uint256{}}, Weight: 1}},
+		Ext: xdr.AccountEntryExt{
+			V: 1,
+			V1: &xdr.AccountEntryExtensionV1{
+				Liabilities: xdr.Liabilities{Buying: 1000, Selling: 1500},
+				Ext: xdr.AccountEntryExtensionV1Ext{
+					V:  2,
+					V2: &xdr.AccountEntryExtensionV2{NumSponsored: 2, NumSponsoring: 1, SignerSponsoringIDs: []xdr.SponsorshipDescriptor{nil}},
+				},
+			},
+		},
+	}
+
+	oversponsoredAccount := xdr.AccountEntry{
+		AccountId: testAccount1ID,
+		Ext: xdr.AccountEntryExt{
+			V: 1,
+			V1: &xdr.AccountEntryExtensionV1{
+				Ext: xdr.AccountEntryExtensionV1Ext{V: 2, V2: &xdr.AccountEntryExtensionV2{NumSponsored: 3}},
+			},
+		},
+	}
+
+	subentries := map[string]AccountSubentries{testAccount1Address: {Trustlines: 2, Offers: 1, DataEntries: 1}}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{sponsoredAccount, subentries, 5000000},
+			functionOutput{AccountCompositeOutput{
+				AccountID:          testAccount1Address,
+				Balance:            100000000,
+				BuyingLiabilities:  1000,
+				SellingLiabilities: 1500,
+				NumSubentries:      4,
+				MasterWeight:       1,
+				LastModifiedLedger: 100,
+				NumTrustlines:      2,
+				NumOffers:          1,
+				NumDataEntries:     1,
+				NumSigners:         1,
+				NumSponsored:       2,
+				NumSponsoring:      1,
+				BaseReserve:        5000000,
+				TotalReserve:       25000000,
+				AvailableBalance:   74998500,
+			}, nil},
+		},
+		{
+			functionInput{oversponsoredAccount, subentries, 5000000},
+			functionOutput{AccountCompositeOutput{}, &TransformError{
+				Category: InvalidData, Dataset: AccountCompositesDataset, LedgerSequence: 100, EntryKey: testAccount1Address,
+				Err: fmt.Errorf("The account sponsors fewer entries than are sponsored for it (0 sponsoring, 3 sponsored) for account: %s", testAccount1Address),
+			}},
+		},
+		{
+			functionInput{xdr.AccountEntry{AccountId: testAccount1ID, Balance: -1}, subentries, 5000000},
+			functionOutput{AccountCompositeOutput{}, &TransformError{
+				Category: InvalidData, Dataset: AccountCompositesDataset, LedgerSequence: 100, EntryKey: testAccount1Address,
+				Err: fmt.Errorf("Balance is negative (-1) for account: %s", testAccount1Address),
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		account := test.input.account
+		change := wrapStateEntry(xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeAccount, Account: &account})
+		output, err := TransformAccountComposite(change, test.input.subentries, test.input.baseReserve)
+		assert.Equal(t, test.output.err, err)
+		assert.Equal(t, test.output.output, output)
+	}
+}
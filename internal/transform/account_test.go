@@ -30,49 +30,49 @@ func TestTransformAccount(t *testing.T) {
 					},
 				},
 			},
-			AccountOutput{}, fmt.Errorf("Could not extract account data from ledger entry; actual type is LedgerEntryTypeOffer"),
+			AccountOutput{}, &TransformError{Category: UnsupportedType, Dataset: AccountsDataset, Err: fmt.Errorf("Could not extract account data from ledger entry; actual type is LedgerEntryTypeOffer")},
 		},
 		{
 			wrapAccountEntry(xdr.AccountEntry{
 				AccountId: genericAccountID,
 				Balance:   -1,
 			}, 0),
-			AccountOutput{}, fmt.Errorf("Balance is negative (-1) for account: %s", genericAccountAddress),
+			AccountOutput{}, &TransformError{Category: InvalidData, Dataset: AccountsDataset, EntryKey: genericAccountAddress, Err: fmt.Errorf("Balance is negative (-1) for account: %s", genericAccountAddress)},
 		},
 		{
 			wrapAccountEntry(xdr.AccountEntry{
 				AccountId: genericAccountID,
 				Ext: xdr.AccountEntryExt{
 					V: 1,
-					V1: &xdr.AccountEntryV1{
+					V1: &xdr.AccountEntryExtensionV1{
 						Liabilities: xdr.Liabilities{
 							Buying: -1,
 						},
 					},
 				},
 			}, 0),
-			AccountOutput{}, fmt.Errorf("The buying liabilities count is negative (-1) for account: %s", genericAccountAddress),
+			AccountOutput{}, &TransformError{Category: InvalidData, Dataset: AccountsDataset, EntryKey: genericAccountAddress, Err: fmt.Errorf("The buying liabilities count is negative (-1) for account: %s", genericAccountAddress)},
 		},
 		{
 			wrapAccountEntry(xdr.AccountEntry{
 				AccountId: genericAccountID,
 				Ext: xdr.AccountEntryExt{
 					V: 1,
-					V1: &xdr.AccountEntryV1{
+					V1: &xdr.AccountEntryExtensionV1{
 						Liabilities: xdr.Liabilities{
 							Selling: -2,
 						},
 					},
 				},
 			}, 0),
-			AccountOutput{}, fmt.Errorf("The selling liabilities count is negative (-2) for account: %s", genericAccountAddress),
+			AccountOutput{}, &TransformError{Category: InvalidData, Dataset: AccountsDataset, EntryKey: genericAccountAddress, Err: fmt.Errorf("The selling liabilities count is negative (-2) for account: %s", genericAccountAddress)},
 		},
 		{
 			wrapAccountEntry(xdr.AccountEntry{
 				AccountId: genericAccountID,
 				SeqNum:    -3,
 			}, 0),
-			AccountOutput{}, fmt.Errorf("Account sequence number is negative (-3) for account: %s", genericAccountAddress),
+			AccountOutput{}, &TransformError{Category: InvalidData, Dataset: AccountsDataset, EntryKey: genericAccountAddress, Err: fmt.Errorf("Account sequence number is negative (-3) for account: %s", genericAccountAddress)},
 		},
 		{
 			hardCodedInput,
@@ -116,7 +116,7 @@ func makeAccountTestInput() ingestio.Change {
 				Thresholds:    xdr.Thresholds([4]byte{2, 1, 3, 5}),
 				Ext: xdr.AccountEntryExt{
 					V: 1,
-					V1: &xdr.AccountEntryV1{
+					V1: &xdr.AccountEntryExtensionV1{
 						Liabilities: xdr.Liabilities{
 							Buying:  1000,
 							Selling: 1500,
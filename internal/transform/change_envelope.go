@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"fmt"
+	"time"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/xdr"
+)
+
+// The ops of change events, as Debezium names them
+const (
+	CreateOp = "c"
+	UpdateOp = "u"
+	DeleteOp = "d"
+)
+
+/*
+	TransformChangeEnvelope converts a change to an account, offer, or trustline into the envelope of a Debezium change event. The before and
+	after images are the rows that the transform of the entry type returns for the entry before and after the change, and are nil when the
+	entry did not exist. The source says where the change was made, and processedAt is the time of the export, which becomes ts_ms.
+*/
+func TransformChangeEnvelope(ledgerChange ingestio.Change, source ChangeSource, processedAt time.Time) (ChangeEnvelope, error) {
+	var transformEntry func(ingestio.Change) (interface{}, error)
+	switch ledgerChange.Type {
+	case xdr.LedgerEntryTypeAccount:
+		transformEntry = func(change ingestio.Change) (interface{}, error) { return TransformAccount(change) }
+	case xdr.LedgerEntryTypeOffer:
+		transformEntry = func(change ingestio.Change) (interface{}, error) { return TransformOffer(change) }
+	case xdr.LedgerEntryTypeTrustline:
+		transformEntry = func(change ingestio.Change) (interface{}, error) { return TransformTrustline(change) }
+	default:
+		return ChangeEnvelope{}, categorize(UnsupportedType, fmt.Errorf("Changes to ledger entries of type %s cannot be converted into change events", ledgerChange.Type))
+	}
+
+	var op string
+	switch {
+	case ledgerChange.Pre == nil && ledgerChange.Post != nil:
+		op = CreateOp
+	case ledgerChange.Pre != nil && ledgerChange.Post != nil:
+		op = UpdateOp
+	case ledgerChange.Pre != nil && ledgerChange.Post == nil:
+		op = DeleteOp
+	default:
+		return ChangeEnvelope{}, categorize(DecodeFailure, fmt.Errorf("Received change with both pre and post nil"))
+	}
+
+	// Each image is transformed as if it were the current state of the entry, so that neither of them is marked as deleted
+	var before, after interface{}
+	var err error
+	if ledgerChange.Pre != nil {
+		before, err = transformEntry(ingestio.Change{Type: ledgerChange.Type, Post: ledgerChange.Pre})
+		if err != nil {
+			return ChangeEnvelope{}, err
+		}
+	}
+
+	if ledgerChange.Post != nil {
+		after, err = transformEntry(ingestio.Change{Type: ledgerChange.Type, Post: ledgerChange.Post})
+		if err != nil {
+			return ChangeEnvelope{}, err
+		}
+	}
+
+	transformedEnvelope := ChangeEnvelope{
+		Before: before,
+		After:  after,
+		Op:     op,
+		Source: source,
+		TsMs:   processedAt.UnixNano() / int64(time.Millisecond),
+	}
+	return transformedEnvelope, nil
+}
@@ -0,0 +1,72 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformChangeEnvelope(t *testing.T) {
+	type functionInput struct {
+		change ingestio.Change
+	}
+	type functionOutput struct {
+		envelope ChangeEnvelope
+		err      error
+	}
+
+	source := ChangeSource{
+		LedgerSequence:  30705279,
+		ClosedAt:        time.Date(2020, 7, 9, 5, 28, 42, 0, time.UTC),
+		TransactionHash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+	}
+	processedAt := time.Date(2020, 7, 9, 5, 30, 0, 250000000, time.UTC)
+
+	deletedAccount := makeAccountTestOutput()
+	deletedAccount.Deleted = false
+	trustline := makeTrustlineTestInput().Post
+	negativeAccount := makeAccountTestInput()
+	negativeAccount.Post, negativeAccount.Pre = negativeAccount.Pre, nil
+	negativeAccount.Post.Data.Account.Balance = -1
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{makeAccountTestInput()},
+			functionOutput{ChangeEnvelope{Before: deletedAccount, Op: DeleteOp, Source: source, TsMs: 1594272600250}, nil},
+		},
+		{
+			functionInput{ingestio.Change{Type: xdr.LedgerEntryTypeTrustline, Post: trustline}},
+			functionOutput{ChangeEnvelope{After: makeTrustlineTestOutput(), Op: CreateOp, Source: source, TsMs: 1594272600250}, nil},
+		},
+		{
+			functionInput{ingestio.Change{Type: xdr.LedgerEntryTypeTrustline, Pre: trustline, Post: trustline}},
+			functionOutput{ChangeEnvelope{Before: makeTrustlineTestOutput(), After: makeTrustlineTestOutput(), Op: UpdateOp, Source: source, TsMs: 1594272600250}, nil},
+		},
+		{
+			functionInput{ingestio.Change{Type: xdr.LedgerEntryTypeData, Post: &xdr.LedgerEntry{Data: xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeData}}}},
+			functionOutput{ChangeEnvelope{}, &TransformError{
+				Category: UnsupportedType,
+				Err:      fmt.Errorf("Changes to ledger entries of type LedgerEntryTypeData cannot be converted into change events"),
+			}},
+		},
+		{
+			functionInput{negativeAccount},
+			functionOutput{ChangeEnvelope{}, &TransformError{
+				Category: InvalidData, Dataset: AccountsDataset, LedgerSequence: 30705278, EntryKey: testAccount1Address,
+				Err: fmt.Errorf("Balance is negative (-1) for account: %s", testAccount1Address),
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		actualOutput, actualError := TransformChangeEnvelope(test.input.change, source, processedAt)
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.envelope, actualOutput)
+	}
+}
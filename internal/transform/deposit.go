@@ -0,0 +1,334 @@
+package transform
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/toid"
+	"github.com/stellar/stellar-etl/internal/utils"
+)
+
+// The memo statuses of deposits. Deposits to accounts that expect no particular memos are unchecked, unless their memo is malformed
+const (
+	MemoMatched   = "matched"
+	MemoUnmatched = "unmatched"
+	MemoMissing   = "missing"
+	MemoMalformed = "malformed"
+	MemoUnchecked = "unchecked"
+)
+
+// DepositAccounts maps each deposit account to the memos that the deposits to it are expected to carry
+type DepositAccounts map[string]map[string]bool
+
+/*
+	ParseDepositAccounts reads a list of deposit accounts. Each line holds the address of an account, optionally followed by the memos that
+	deposits to the account are expected to carry, separated by whitespace. An account can be listed on several lines, and its memos are
+	combined. Blank lines and lines that start with # are skipped.
+*/
+func ParseDepositAccounts(r io.Reader) (DepositAccounts, error) {
+	accounts := DepositAccounts{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		address := fields[0]
+		if !strkey.IsValidEd25519PublicKey(address) {
+			return nil, fmt.Errorf("line %d: %s is not the address of an account", lineNumber, address)
+		}
+
+		memos, ok := accounts[address]
+		if !ok {
+			memos = map[string]bool{}
+			accounts[address] = memos
+		}
+
+		for _, memo := range fields[1:] {
+			memos[memo] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("the list has no deposit accounts")
+	}
+
+	return accounts, nil
+}
+
+// malformedTextMemo reports whether a text memo is one that deposits cannot be credited with automatically: it is empty, is not valid UTF-8, or has surrounding whitespace or control characters
+func malformedTextMemo(memo string) bool {
+	return memo == "" || !utf8.ValidString(memo) || strings.TrimSpace(memo) != memo || strings.IndexFunc(memo, unicode.IsControl) >= 0
+}
+
+/*
+	depositMemoStatus checks the memo of a deposit against the memos that its account expects. A memo that is expected is matched, even if
+	it would be malformed otherwise, and hash and return memos are matched in hex regardless of case. A deposit without a memo to an account
+	that expects memos is missing its memo, and any other memo that the account does not expect is unmatched.
+*/
+func depositMemoStatus(expected map[string]bool, memoType xdr.MemoType, memo string) string {
+	if memoType == xdr.MemoTypeMemoNone {
+		if len(expected) == 0 {
+			return MemoUnchecked
+		}
+
+		return MemoMissing
+	}
+
+	if expected[memo] {
+		return MemoMatched
+	}
+
+	if (memoType == xdr.MemoTypeMemoHash || memoType == xdr.MemoTypeMemoReturn) && (expected[strings.ToLower(memo)] || expected[strings.ToUpper(memo)]) {
+		return MemoMatched
+	}
+
+	if memoType == xdr.MemoTypeMemoText && malformedTextMemo(memo) {
+		return MemoMalformed
+	}
+
+	if len(expected) == 0 {
+		return MemoUnchecked
+	}
+
+	return MemoUnmatched
+}
+
+// depositMemo returns the type and the contents of the memo of a deposit. Unlike in the transactions, hash and return memos are written in hex, which is how exchanges hand them out
+func depositMemo(memo xdr.Memo) (string, string) {
+	switch memo.Type {
+	case xdr.MemoTypeMemoHash:
+		hash := memo.MustHash()
+		return memo.Type.String(), hex.EncodeToString(hash[:])
+	case xdr.MemoTypeMemoReturn:
+		hash := memo.MustRetHash()
+		return memo.Type.String(), hex.EncodeToString(hash[:])
+	default:
+		return extractMemo(memo)
+	}
+}
+
+// muxedID returns the ID of a muxed account in decimal, or an empty string if the account is not muxed
+func muxedID(account xdr.MuxedAccount) string {
+	if account.Type != xdr.CryptoKeyTypeKeyTypeMuxedEd25519 {
+		return ""
+	}
+
+	return strconv.FormatUint(uint64(account.MustMed25519().Id), 10)
+}
+
+/*
+	TransformDeposits returns the deposits to the deposit accounts in a transaction, with the memo of the transaction checked against the
+	memos that the accounts expect. The ID of a muxed account stands in for an ID memo, so deposits to a muxed account in transactions
+	without a memo are checked with its ID. Failed transactions credit no accounts, so they have no deposits.
+*/
+func TransformDeposits(transaction ingestio.LedgerTransaction, lhe xdr.LedgerHeaderHistoryEntry, accounts DepositAccounts) ([]DepositOutput, error) {
+	ledgerSequence := uint32(lhe.Header.LedgerSeq)
+	transactionHash := utils.HashToHexString(transaction.Result.TransactionHash)
+	errorContext := TransformError{
+		Dataset:          DepositsDataset,
+		LedgerSequence:   ledgerSequence,
+		TransactionIndex: int32(transaction.Index),
+		EntryKey:         transactionHash,
+	}
+
+	if !transaction.Result.Successful() {
+		return []DepositOutput{}, nil
+	}
+
+	closedAt, err := utils.TimePointToUTCTimeStamp(lhe.Header.ScpValue.CloseTime)
+	if err != nil {
+		return []DepositOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("for ledger %d; transaction %d: %v", ledgerSequence, transaction.Index, err))
+	}
+
+	memo := transaction.Envelope.Memo()
+	memoType, memoContents := depositMemo(memo)
+	deposits := []DepositOutput{}
+	for index, operation := range transaction.Envelope.Operations() {
+		deposit, ok, err := extractDeposit(operation, int32(index), transaction, accounts)
+		if err != nil {
+			operationContext := errorContext
+			operationContext.OperationIndex = int32(index)
+			return []DepositOutput{}, operationContext.wrap(DecodeFailure, fmt.Errorf("for operation %d of transaction %d in ledger %d: %v", index, transaction.Index, ledgerSequence, err))
+		}
+
+		if !ok {
+			continue
+		}
+
+		deposit.MemoType = memoType
+		deposit.Memo = memoContents
+		deposit.MemoStatus = depositMemoStatus(accounts[deposit.Account], memo.Type, memoContents)
+		if memo.Type == xdr.MemoTypeMemoNone && deposit.AccountMuxedID != "" {
+			deposit.MemoStatus = depositMemoStatus(accounts[deposit.Account], xdr.MemoTypeMemoId, deposit.AccountMuxedID)
+		}
+		deposit.TransactionHash = transactionHash
+		deposit.LedgerSequence = ledgerSequence
+		deposit.ClosedAt = closedAt
+		deposit.OperationID = toid.New(int32(ledgerSequence), int32(transaction.Index), int32(index)).ToInt64()
+		deposits = append(deposits, deposit)
+	}
+
+	return deposits, nil
+}
+
+// setAsset sets the asset fields of the deposit
+func (d *DepositOutput) setAsset(asset xdr.Asset) error {
+	return asset.Extract(&d.AssetType, &d.AssetCode, &d.AssetIssuer)
+}
+
+// paymentDestination returns the account that a payment or a path payment credits, and false for other operations
+func paymentDestination(body xdr.OperationBody) (xdr.MuxedAccount, bool) {
+	switch body.Type {
+	case xdr.OperationTypePayment:
+		return body.MustPaymentOp().Destination, true
+	case xdr.OperationTypePathPaymentStrictReceive:
+		return body.MustPathPaymentStrictReceiveOp().Destination, true
+	case xdr.OperationTypePathPaymentStrictSend:
+		return body.MustPathPaymentStrictSendOp().Destination, true
+	default:
+		return xdr.MuxedAccount{}, false
+	}
+}
+
+// extractDeposit returns the deposit that the operation makes, and false if the operation does not credit a deposit account
+func extractDeposit(operation xdr.Operation, operationIndex int32, transaction ingestio.LedgerTransaction, accounts DepositAccounts) (DepositOutput, bool, error) {
+	source := getOperationSourceAccount(operation, transaction)
+	sourceAccount, err := utils.GetAccountAddressFromMuxedAccount(source)
+	if err != nil {
+		return DepositOutput{}, false, err
+	}
+
+	deposit := DepositOutput{From: sourceAccount, OperationType: operations.TypeNames[operation.Body.Type]}
+	if operation.Body.Type == xdr.OperationTypeClaimClaimableBalance {
+		if _, ok := accounts[sourceAccount]; !ok {
+			return DepositOutput{}, false, nil
+		}
+
+		deposit.AccountMuxedID = muxedID(source)
+		return extractClaim(operation.Body.MustClaimClaimableBalanceOp(), operationIndex, transaction, deposit)
+	}
+
+	destination, ok := paymentDestination(operation.Body)
+	if !ok {
+		return DepositOutput{}, false, nil
+	}
+
+	deposit.Account, err = utils.GetAccountAddressFromMuxedAccount(destination)
+	if err != nil {
+		return DepositOutput{}, false, err
+	}
+
+	deposit.AccountMuxedID = muxedID(destination)
+
+	if _, ok := accounts[deposit.Account]; !ok {
+		return DepositOutput{}, false, nil
+	}
+
+	switch operation.Body.Type {
+	case xdr.OperationTypePayment:
+		op := operation.Body.MustPaymentOp()
+		deposit.Amount = utils.ConvertStroopValueToReal(op.Amount)
+		err = deposit.setAsset(op.Asset)
+
+	case xdr.OperationTypePathPaymentStrictReceive:
+		op := operation.Body.MustPathPaymentStrictReceiveOp()
+		deposit.Amount = utils.ConvertStroopValueToReal(op.DestAmount)
+		err = deposit.setAsset(op.DestAsset)
+
+	case xdr.OperationTypePathPaymentStrictSend:
+		// The amount that a strict send path payment delivers is only known from its result
+		results, ok := transaction.Result.OperationResults()
+		if !ok || int(operationIndex) >= len(results) || results[operationIndex].Tr == nil {
+			return DepositOutput{}, false, fmt.Errorf("could not access the result of the path payment")
+		}
+
+		result, ok := results[operationIndex].Tr.GetPathPaymentStrictSendResult()
+		if !ok {
+			return DepositOutput{}, false, fmt.Errorf("could not access the result of the path payment")
+		}
+
+		deposit.Amount = utils.ConvertStroopValueToReal(result.DestAmount())
+		err = deposit.setAsset(operation.Body.MustPathPaymentStrictSendOp().DestAsset)
+	}
+
+	if err != nil {
+		return DepositOutput{}, false, err
+	}
+
+	return deposit, true, nil
+}
+
+/*
+	extractClaim returns the deposit that a claim of a claimable balance makes to the claimer. The claim only names the balance, so its asset
+	and amount are taken from the balance that the transaction meta shows being removed, and the sender is the sponsor of the balance, which
+	is usually the account that created it. The history archives have no transaction meta, so without it only the balance ID is known, and
+	the deposit is marked as incomplete.
+*/
+func extractClaim(op xdr.ClaimClaimableBalanceOp, operationIndex int32, transaction ingestio.LedgerTransaction, deposit DepositOutput) (DepositOutput, bool, error) {
+	balanceID, err := xdr.MarshalHex(op.BalanceId)
+	if err != nil {
+		return DepositOutput{}, false, err
+	}
+
+	deposit.Account = deposit.From
+	deposit.From = ""
+	deposit.BalanceID = balanceID
+	// stellar-core had moved past version 0 of the transaction meta long before claimable balances were added, so the meta of a claim is only version 0 if it is missing.
+	// Claims whose meta does not remove the claimed balance are marked as incomplete as well, since their amount, asset, and sender are unknown
+	deposit.Incomplete = true
+	if transaction.Meta.V == 0 {
+		return deposit, true, nil
+	}
+
+	changes, err := transaction.GetOperationChanges(uint32(operationIndex))
+	if err != nil {
+		return DepositOutput{}, false, err
+	}
+
+	for _, change := range changes {
+		if change.Type != xdr.LedgerEntryTypeClaimableBalance || change.Pre == nil || change.Post != nil {
+			continue
+		}
+
+		balance := change.Pre.Data.MustClaimableBalance()
+		removedID, err := xdr.MarshalHex(balance.BalanceId)
+		if err != nil {
+			return DepositOutput{}, false, err
+		}
+
+		if removedID != balanceID {
+			continue
+		}
+
+		deposit.Incomplete = false
+		deposit.Amount = utils.ConvertStroopValueToReal(balance.Amount)
+		if sponsor := change.Pre.SponsoringID(); sponsor != nil {
+			deposit.From = (*xdr.AccountId)(sponsor).Address()
+		}
+
+		err = deposit.setAsset(balance.Asset)
+		if err != nil {
+			return DepositOutput{}, false, err
+		}
+	}
+
+	return deposit, true, nil
+}
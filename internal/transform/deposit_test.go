@@ -0,0 +1,398 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	ingestio "github.com/stellar/go/ingest/io"
+	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDepositAccounts(t *testing.T) {
+	type functionInput struct {
+		list string
+	}
+	type functionOutput struct {
+		accounts DepositAccounts
+		err      error
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{"# exchange deposit accounts\n" + testAccount1Address + " 1001 1002\n\n" + testAccount2Address + "\n" + testAccount1Address + "\t1003\n"},
+			functionOutput{DepositAccounts{
+				testAccount1Address: {"1001": true, "1002": true, "1003": true},
+				testAccount2Address: {},
+			}, nil},
+		},
+		{
+			functionInput{testAccount1Address + "\nGABC 1001\n"},
+			functionOutput{nil, fmt.Errorf("line 2: GABC is not the address of an account")},
+		},
+		{
+			functionInput{"# no accounts yet\n\n"},
+			functionOutput{nil, fmt.Errorf("the list has no deposit accounts")},
+		},
+	}
+
+	for _, test := range tests {
+		actualAccounts, actualError := ParseDepositAccounts(strings.NewReader(test.input.list))
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.accounts, actualAccounts)
+	}
+}
+
+func TestDepositMemoStatus(t *testing.T) {
+	type functionInput struct {
+		expected map[string]bool
+		memoType xdr.MemoType
+		memo     string
+	}
+	type functionOutput struct {
+		status string
+	}
+
+	expected := map[string]bool{"1001": true, " padded": true}
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{expected, xdr.MemoTypeMemoText, "1001"}, functionOutput{MemoMatched}},
+		{functionInput{expected, xdr.MemoTypeMemoId, "1001"}, functionOutput{MemoMatched}},
+		{functionInput{expected, xdr.MemoTypeMemoText, " padded"}, functionOutput{MemoMatched}},
+		{functionInput{expected, xdr.MemoTypeMemoText, "1002"}, functionOutput{MemoUnmatched}},
+		{functionInput{map[string]bool{"ABCD": true}, xdr.MemoTypeMemoHash, "abcd"}, functionOutput{MemoMatched}},
+		{functionInput{map[string]bool{"ABCD": true}, xdr.MemoTypeMemoText, "abcd"}, functionOutput{MemoUnmatched}},
+		{functionInput{expected, xdr.MemoTypeMemoNone, ""}, functionOutput{MemoMissing}},
+		{functionInput{expected, xdr.MemoTypeMemoText, "1001 "}, functionOutput{MemoMalformed}},
+		{functionInput{map[string]bool{}, xdr.MemoTypeMemoText, "10\n01"}, functionOutput{MemoMalformed}},
+		{functionInput{map[string]bool{}, xdr.MemoTypeMemoText, "\xff"}, functionOutput{MemoMalformed}},
+		{functionInput{map[string]bool{}, xdr.MemoTypeMemoText, "1001"}, functionOutput{MemoUnchecked}},
+		{functionInput{map[string]bool{}, xdr.MemoTypeMemoNone, ""}, functionOutput{MemoUnchecked}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.output.status, depositMemoStatus(test.input.expected, test.input.memoType, test.input.memo))
+	}
+}
+
+func TestTransformDeposits(t *testing.T) {
+	type functionInput struct {
+		transaction ingestio.LedgerTransaction
+		accounts    DepositAccounts
+	}
+	type functionOutput struct {
+		deposits []DepositOutput
+		err      error
+	}
+
+	hardCodedTransaction, hardCodedLedgerHeader := makeDepositTestInput()
+	hardCodedOutput := makeDepositTestOutput()
+	accounts := DepositAccounts{
+		testAccount1Address: {"1001": true},
+		testAccount4Address: {},
+	}
+
+	failedTransaction := hardCodedTransaction
+	failedTransaction.Result.Result.Result.Code = xdr.TransactionResultCodeTxFailed
+
+	missingResultTransaction := hardCodedTransaction
+	missingResultTransaction.Result = utils.CreateSampleResultMeta(true, 2).Result
+
+	claimedTransaction := hardCodedTransaction
+	claimedTransaction.Meta = makeDepositTestClaimMeta()
+	claimedOutput := makeDepositTestOutput()
+	claimedOutput[2].From = testAccount2Address
+	claimedOutput[2].Amount = 25
+	claimedOutput[2].AssetType = "native"
+	claimedOutput[2].Incomplete = false
+
+	// A claim whose meta does not remove the claimed balance is as incomplete as a claim without meta
+	unremovedTransaction := hardCodedTransaction
+	unremovedTransaction.Meta = makeDepositTestClaimMeta()
+	unremovedTransaction.Meta.V2.Operations[3] = xdr.OperationMeta{}
+	otherBalanceTransaction := hardCodedTransaction
+	otherBalanceTransaction.Meta = makeDepositTestClaimMeta()
+	otherBalance := *otherBalanceTransaction.Meta.V2.Operations[3].Changes[0].State
+	otherEntry := *otherBalance.Data.ClaimableBalance
+	otherEntry.BalanceId = xdr.ClaimableBalanceId{Type: xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0, V0: &xdr.Hash{0xff}}
+	otherBalance.Data.ClaimableBalance = &otherEntry
+	otherKey := otherBalance.LedgerKey()
+	otherBalanceTransaction.Meta.V2.Operations[3] = xdr.OperationMeta{Changes: xdr.LedgerEntryChanges{
+		xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: &otherBalance},
+		xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &otherKey},
+	}}
+
+	// Hash memos are written in hex, and a muxed destination keeps its ID, which stands in for a memo when the transaction has none
+	hashMemo := xdr.Hash{0xab, 0xcd}
+	hashTransaction := hardCodedTransaction
+	hashEnvelope := *hardCodedTransaction.Envelope.V1
+	hashEnvelope.Tx.Memo = xdr.Memo{Type: xdr.MemoTypeMemoHash, Hash: &hashMemo}
+	hashEnvelope.Tx.Operations = hashEnvelope.Tx.Operations[:1]
+	hashTransaction.Envelope.V1 = &hashEnvelope
+	hashOutput := makeDepositTestOutput()[:1]
+	hashOutput[0].MemoType = "MemoTypeMemoHash"
+	hashOutput[0].Memo = "abcd" + strings.Repeat("0", 60)
+	hashOutput[0].MemoStatus = MemoMatched
+
+	muxedTransaction := hardCodedTransaction
+	muxedEnvelope := *hardCodedTransaction.Envelope.V1
+	muxedEnvelope.Tx.Memo = xdr.Memo{Type: xdr.MemoTypeMemoNone}
+	muxedPayment := *muxedEnvelope.Tx.Operations[0].Body.PaymentOp
+	muxedPayment.Destination = xdr.MuxedAccount{
+		Type:     xdr.CryptoKeyTypeKeyTypeMuxedEd25519,
+		Med25519: &xdr.MuxedAccountMed25519{Id: 1001, Ed25519: *testAccount1.Ed25519},
+	}
+	muxedEnvelope.Tx.Operations = []xdr.Operation{{Body: xdr.OperationBody{Type: xdr.OperationTypePayment, PaymentOp: &muxedPayment}}}
+	muxedTransaction.Envelope.V1 = &muxedEnvelope
+	muxedOutput := makeDepositTestOutput()[:1]
+	muxedOutput[0].AccountMuxedID = "1001"
+	muxedOutput[0].MemoType = "MemoTypeMemoNone"
+	muxedOutput[0].Memo = ""
+	muxedOutput[0].MemoStatus = MemoMatched
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{hardCodedTransaction, accounts},
+			functionOutput{hardCodedOutput, nil},
+		},
+		{
+			functionInput{claimedTransaction, accounts},
+			functionOutput{claimedOutput, nil},
+		},
+		{
+			functionInput{unremovedTransaction, accounts},
+			functionOutput{hardCodedOutput, nil},
+		},
+		{
+			functionInput{otherBalanceTransaction, accounts},
+			functionOutput{hardCodedOutput, nil},
+		},
+		{
+			functionInput{hashTransaction, DepositAccounts{testAccount1Address: {"ABCD" + strings.Repeat("0", 60): true}}},
+			functionOutput{hashOutput, nil},
+		},
+		{
+			functionInput{muxedTransaction, accounts},
+			functionOutput{muxedOutput, nil},
+		},
+		{
+			functionInput{hardCodedTransaction, DepositAccounts{testAccount3Address: {}}},
+			functionOutput{[]DepositOutput{}, nil},
+		},
+		{
+			functionInput{failedTransaction, accounts},
+			functionOutput{[]DepositOutput{}, nil},
+		},
+		{
+			functionInput{missingResultTransaction, accounts},
+			functionOutput{[]DepositOutput{}, fmt.Errorf("for operation 2 of transaction 1 in ledger 30521816: could not access the result of the path payment")},
+		},
+	}
+
+	for _, test := range tests {
+		actualOutput, actualError := TransformDeposits(test.input.transaction, hardCodedLedgerHeader, test.input.accounts)
+		if test.output.err != nil {
+			assert.EqualError(t, actualError, test.output.err.Error())
+			assert.Equal(t, DecodeFailure, CategoryOf(actualError))
+		} else {
+			assert.NoError(t, actualError)
+		}
+
+		assert.Equal(t, test.output.deposits, actualOutput)
+	}
+}
+
+var depositTestBalanceID = xdr.ClaimableBalanceId{
+	Type: xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0,
+	V0:   &xdr.Hash{1, 2, 3},
+}
+
+func makeDepositTestInput() (transaction ingestio.LedgerTransaction, historyHeader xdr.LedgerHeaderHistoryEntry) {
+	transaction = genericLedgerTransaction
+	envelope := genericBumpOperationEnvelope
+	envelope.Tx.SourceAccount = testAccount3
+	envelope.Tx.Memo = xdr.MemoText("1001")
+	envelope.Tx.Operations = []xdr.Operation{
+		xdr.Operation{
+			SourceAccount: nil,
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypePayment,
+				PaymentOp: &xdr.PaymentOp{
+					Destination: testAccount1,
+					Asset:       usdtAsset,
+					Amount:      3505000000,
+				},
+			},
+		},
+		xdr.Operation{
+			SourceAccount: nil,
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypePayment,
+				PaymentOp: &xdr.PaymentOp{
+					Destination: testAccount2,
+					Asset:       nativeAsset,
+					Amount:      10000000,
+				},
+			},
+		},
+		xdr.Operation{
+			SourceAccount: &testAccount2,
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypePathPaymentStrictSend,
+				PathPaymentStrictSendOp: &xdr.PathPaymentStrictSendOp{
+					SendAsset:   usdtAsset,
+					SendAmount:  1000000,
+					Destination: testAccount1,
+					DestAsset:   nativeAsset,
+					DestMin:     1,
+				},
+			},
+		},
+		xdr.Operation{
+			SourceAccount: &testAccount4,
+			Body: xdr.OperationBody{
+				Type:                    xdr.OperationTypeClaimClaimableBalance,
+				ClaimClaimableBalanceOp: &xdr.ClaimClaimableBalanceOp{BalanceId: depositTestBalanceID},
+			},
+		},
+		xdr.Operation{
+			SourceAccount: nil,
+			Body: xdr.OperationBody{
+				Type: xdr.OperationTypePathPaymentStrictReceive,
+				PathPaymentStrictReceiveOp: &xdr.PathPaymentStrictReceiveOp{
+					SendAsset:   nativeAsset,
+					SendMax:     100000000,
+					Destination: testAccount1,
+					DestAsset:   ethAsset,
+					DestAmount:  20000000,
+				},
+			},
+		},
+	}
+	transaction.Envelope.V1 = &envelope
+
+	transaction.Result = utils.CreateSampleResultMeta(true, 5).Result
+	results, _ := transaction.Result.OperationResults()
+	results[2].Tr = &xdr.OperationResultTr{
+		Type: xdr.OperationTypePathPaymentStrictSend,
+		PathPaymentStrictSendResult: &xdr.PathPaymentStrictSendResult{
+			Code: xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendSuccess,
+			Success: &xdr.PathPaymentStrictSendResultSuccess{
+				Last: xdr.SimplePaymentResult{Destination: testAccount1ID, Asset: nativeAsset, Amount: 4200000},
+			},
+		},
+	}
+
+	historyHeader = xdr.LedgerHeaderHistoryEntry{
+		Header: xdr.LedgerHeader{
+			LedgerSeq: 30521816,
+			ScpValue:  xdr.StellarValue{CloseTime: 1594586912},
+		},
+	}
+	return
+}
+
+func makeDepositTestClaimMeta() xdr.TransactionMeta {
+	balance := xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeClaimableBalance,
+			ClaimableBalance: &xdr.ClaimableBalanceEntry{
+				BalanceId: depositTestBalanceID,
+				Asset:     nativeAsset,
+				Amount:    250000000,
+			},
+		},
+		Ext: xdr.LedgerEntryExt{
+			V:  1,
+			V1: &xdr.LedgerEntryExtensionV1{SponsoringId: &testAccount2ID},
+		},
+	}
+	key := balance.LedgerKey()
+	claimChanges := xdr.LedgerEntryChanges{
+		xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: &balance},
+		xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &key},
+	}
+
+	return xdr.TransactionMeta{
+		V: 2,
+		V2: &xdr.TransactionMetaV2{
+			Operations: []xdr.OperationMeta{{}, {}, {}, {Changes: claimChanges}, {}},
+		},
+	}
+}
+
+func makeDepositTestOutput() []DepositOutput {
+	closedAt, _ := utils.TimePointToUTCTimeStamp(1594586912)
+	transactionHash := utils.HashToHexString(genericLedgerTransaction.Result.TransactionHash)
+	return []DepositOutput{
+		DepositOutput{
+			Account:         testAccount1Address,
+			From:            testAccount3Address,
+			OperationType:   "payment",
+			Amount:          350.5,
+			AssetCode:       usdtAssetOutput.AssetCode,
+			AssetIssuer:     usdtAssetOutput.AssetIssuer,
+			AssetType:       usdtAssetOutput.AssetType,
+			MemoType:        "MemoTypeMemoText",
+			Memo:            "1001",
+			MemoStatus:      MemoMatched,
+			TransactionHash: transactionHash,
+			LedgerSequence:  30521816,
+			ClosedAt:        closedAt,
+			OperationID:     131090201534533632,
+		},
+		DepositOutput{
+			Account:         testAccount1Address,
+			From:            testAccount2Address,
+			OperationType:   "path_payment_strict_send",
+			Amount:          0.42,
+			AssetType:       "native",
+			MemoType:        "MemoTypeMemoText",
+			Memo:            "1001",
+			MemoStatus:      MemoMatched,
+			TransactionHash: transactionHash,
+			LedgerSequence:  30521816,
+			ClosedAt:        closedAt,
+			OperationID:     131090201534533634,
+		},
+		DepositOutput{
+			Account:         testAccount4Address,
+			OperationType:   "claim_claimable_balance",
+			BalanceID:       "00000000010203" + strings.Repeat("0", 58),
+			Incomplete:      true,
+			MemoType:        "MemoTypeMemoText",
+			Memo:            "1001",
+			MemoStatus:      MemoUnchecked,
+			TransactionHash: transactionHash,
+			LedgerSequence:  30521816,
+			ClosedAt:        closedAt,
+			OperationID:     131090201534533635,
+		},
+		DepositOutput{
+			Account:         testAccount1Address,
+			From:            testAccount3Address,
+			OperationType:   "path_payment_strict_receive",
+			Amount:          2,
+			AssetCode:       "ETH",
+			AssetIssuer:     testAccount3Address,
+			AssetType:       "credit_alphanum4",
+			MemoType:        "MemoTypeMemoText",
+			Memo:            "1001",
+			MemoStatus:      MemoMatched,
+			TransactionHash: transactionHash,
+			LedgerSequence:  30521816,
+			ClosedAt:        closedAt,
+			OperationID:     131090201534533636,
+		},
+	}
+}
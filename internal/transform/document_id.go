@@ -0,0 +1,85 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+	entryDocumentID returns the document ID of the state of a ledger entry, which is the key of the entry followed by the ledger that last
+	modified it. Document IDs are deterministic, so that indexing an export again overwrites the documents of the earlier run instead of
+	duplicating them. Rows of deleted entries get a suffix, since a removal is reported with the state that the entry had before it.
+*/
+func entryDocumentID(key string, lastModifiedLedger uint32, deleted bool) string {
+	id := fmt.Sprintf("%s-%d", key, lastModifiedLedger)
+	if deleted {
+		id += "-deleted"
+	}
+
+	return id
+}
+
+// DocumentID returns the TOID of the ledger
+func (l LedgerOutput) DocumentID() string {
+	return strconv.FormatInt(l.LedgerID, 10)
+}
+
+// DocumentID returns the TOID of the transaction
+func (t TransactionOutput) DocumentID() string {
+	return strconv.FormatInt(t.TransactionID, 10)
+}
+
+// DocumentID returns the TOID of the operation
+func (o OperationOutput) DocumentID() string {
+	return strconv.FormatInt(o.OperationID, 10)
+}
+
+// DocumentID returns the TOID of the operation that made the trade, followed by the order of the trade within the operation
+func (t TradeOutput) DocumentID() string {
+	return fmt.Sprintf("%d-%d", t.HistoryOperationID, t.Order)
+}
+
+// DocumentID returns the TOID of the operation that credited the deposit account
+func (d DepositOutput) DocumentID() string {
+	return strconv.FormatInt(d.OperationID, 10)
+}
+
+// DocumentID returns the address of the account, followed by the ledger that last modified it
+func (a AccountOutput) DocumentID() string {
+	return entryDocumentID(a.AccountID, a.LastModifiedLedger, a.Deleted)
+}
+
+// DocumentID returns the address of the account, followed by the ledger that last modified it
+func (a AccountCompositeOutput) DocumentID() string {
+	return entryDocumentID(a.AccountID, a.LastModifiedLedger, a.Deleted)
+}
+
+// DocumentID returns the ID of the offer, followed by the ledger that last modified it
+func (o OfferOutput) DocumentID() string {
+	return entryDocumentID(strconv.FormatInt(o.OfferID, 10), o.LastModifiedLedger, o.Deleted)
+}
+
+// DocumentID returns the ledger key of the trustline, followed by the ledger that last modified it
+func (t TrustlineOutput) DocumentID() string {
+	return entryDocumentID(t.LedgerKey, t.LastModifiedLedger, t.Deleted)
+}
+
+// DocumentID returns the ID of the account in the dim_accounts table
+func (a DimAccount) DocumentID() string {
+	return strconv.FormatUint(a.ID, 10)
+}
+
+// DocumentID returns the ID of the offer in the dim_offers table
+func (o DimOffer) DocumentID() string {
+	return strconv.FormatUint(o.DimOfferID, 10)
+}
+
+// DocumentID returns the ID of the market in the dim_markets table
+func (m DimMarket) DocumentID() string {
+	return strconv.FormatUint(m.ID, 10)
+}
+
+// DocumentID returns the ledger of the event, followed by the offer instance that it refers to
+func (e FactOfferEvent) DocumentID() string {
+	return fmt.Sprintf("%d-%d", e.LedgerSeq, e.OfferInstanceID)
+}
@@ -0,0 +1,39 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentID(t *testing.T) {
+	type functionInput struct {
+		row interface{ DocumentID() string }
+	}
+	type functionOutput struct {
+		id string
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{LedgerOutput{LedgerID: 131880821527052288}}, functionOutput{"131880821527052288"}},
+		{functionInput{TransactionOutput{TransactionID: 131880821527056384}}, functionOutput{"131880821527056384"}},
+		{functionInput{OperationOutput{OperationID: 131880821527056385}}, functionOutput{"131880821527056385"}},
+		{functionInput{TradeOutput{HistoryOperationID: 131880821527056385, Order: 2}}, functionOutput{"131880821527056385-2"}},
+		{functionInput{DepositOutput{OperationID: 131880821527056385}}, functionOutput{"131880821527056385"}},
+		{functionInput{AccountOutput{AccountID: testAccount1Address, LastModifiedLedger: 30705278}}, functionOutput{testAccount1Address + "-30705278"}},
+		{functionInput{AccountCompositeOutput{AccountID: testAccount1Address, LastModifiedLedger: 30705278, Deleted: true}}, functionOutput{testAccount1Address + "-30705278-deleted"}},
+		{functionInput{OfferOutput{OfferID: 260678439, LastModifiedLedger: 30715263}}, functionOutput{"260678439-30715263"}},
+		{functionInput{TrustlineOutput{LedgerKey: "AAAAAQ==", LastModifiedLedger: 24229503, Deleted: true}}, functionOutput{"AAAAAQ==-24229503-deleted"}},
+		{functionInput{DimAccount{ID: 4}}, functionOutput{"4"}},
+		{functionInput{DimOffer{DimOfferID: 5}}, functionOutput{"5"}},
+		{functionInput{DimMarket{ID: 6}}, functionOutput{"6"}},
+		{functionInput{FactOfferEvent{LedgerSeq: 30715263, OfferInstanceID: 5}}, functionOutput{"30715263-5"}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.output.id, test.input.row.DocumentID())
+	}
+}
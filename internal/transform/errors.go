@@ -0,0 +1,154 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCategory classifies the reason that a transformation failed
+type ErrorCategory string
+
+const (
+	// InvalidData is used when the input decodes correctly but holds values that are not allowed, like negative balances
+	InvalidData ErrorCategory = "invalid_data"
+	// UnsupportedType is used when the input is of a type that the transform does not handle, like an unknown operation type
+	UnsupportedType ErrorCategory = "unsupported_type"
+	// DecodeFailure is used when a value cannot be read from the input, like a missing union arm or an unparseable address
+	DecodeFailure ErrorCategory = "decode_failure"
+	// Serialization is used when a value cannot be encoded into its output form
+	Serialization ErrorCategory = "serialization"
+)
+
+// ErrorCategories lists every category that a TransformError can have
+var ErrorCategories = []ErrorCategory{InvalidData, UnsupportedType, DecodeFailure, Serialization}
+
+// Dataset names used to label transform errors; they match the object names used by the export commands
+const (
+	LedgersDataset           = "ledgers"
+	TransactionsDataset      = "transactions"
+	OperationsDataset        = "operations"
+	TradesDataset            = "trades"
+	AccountsDataset          = "accounts"
+	AccountCompositesDataset = "account_composites"
+	OffersDataset            = "offers"
+	TrustlinesDataset        = "trustlines"
+	OrderbooksDataset        = "orderbooks"
+	DepositsDataset          = "deposits"
+)
+
+// TransformError is the error returned by the transform functions. It records why the transform failed and where the failing input came from.
+// Fields that do not apply to the dataset, or that were not known when the error occurred, are left as zero values.
+type TransformError struct {
+	Category         ErrorCategory
+	Dataset          string
+	LedgerSequence   uint32
+	TransactionIndex int32
+	OperationIndex   int32
+	EntryKey         string
+	Err              error
+}
+
+// Error returns the message of the underlying error so that log output is unaffected by the categorization
+func (e *TransformError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// wrap returns a copy of the error context with the provided category and error. If err is already a TransformError, its category is kept and
+// any location information it has takes precedence over the context. The dataset of the context is kept, since it names the dataset being exported.
+func (e TransformError) wrap(category ErrorCategory, err error) *TransformError {
+	if inner, ok := err.(*TransformError); ok {
+		category = inner.Category
+		err = inner.Err
+		if e.Dataset == "" {
+			e.Dataset = inner.Dataset
+		}
+
+		if inner.LedgerSequence != 0 {
+			e.LedgerSequence = inner.LedgerSequence
+		}
+
+		if inner.TransactionIndex != 0 {
+			e.TransactionIndex = inner.TransactionIndex
+		}
+
+		if inner.OperationIndex != 0 {
+			e.OperationIndex = inner.OperationIndex
+		}
+
+		if inner.EntryKey != "" {
+			e.EntryKey = inner.EntryKey
+		}
+	}
+
+	e.Category = category
+	e.Err = err
+	return &e
+}
+
+// categorize labels an error with a category but no location information. It is used by helpers that do not know where their input came from;
+// the exported transform functions add the location when they wrap the error.
+func categorize(category ErrorCategory, err error) *TransformError {
+	return &TransformError{Category: category, Err: err}
+}
+
+// NewSerializationError creates a TransformError for a failure to encode the output of a transform, like a JSON encoding error in an exporter
+func NewSerializationError(dataset string, err error) *TransformError {
+	return TransformError{Dataset: dataset}.wrap(Serialization, err)
+}
+
+// NewInvalidDataError creates a TransformError for output that has values which are not allowed, like a derived column that divides by zero
+func NewInvalidDataError(dataset string, err error) *TransformError {
+	return TransformError{Dataset: dataset}.wrap(InvalidData, err)
+}
+
+// CategoryOf returns the category of the provided error. Errors that are not TransformErrors are reported as decode failures, since they
+// come from reading the input.
+func CategoryOf(err error) ErrorCategory {
+	var transformErr *TransformError
+	if errors.As(err, &transformErr) {
+		return transformErr.Category
+	}
+
+	return DecodeFailure
+}
+
+// ParseErrorCategories converts a list of category names into categories, returning an error if any of the names are unknown
+func ParseErrorCategories(names []string) ([]ErrorCategory, error) {
+	categories := make([]ErrorCategory, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, category := range ErrorCategories {
+			if string(category) == name {
+				categories = append(categories, category)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown error category %q; valid categories are %s", name, joinCategories(ErrorCategories))
+		}
+	}
+
+	return categories, nil
+}
+
+func joinCategories(categories []ErrorCategory) string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = string(category)
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,102 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryOf(t *testing.T) {
+	type categoryTest struct {
+		input      error
+		wantOutput ErrorCategory
+	}
+
+	tests := []categoryTest{
+		{
+			input:      &TransformError{Category: InvalidData, Err: fmt.Errorf("negative balance")},
+			wantOutput: InvalidData,
+		},
+		{
+			input:      fmt.Errorf("could not transform: %w", &TransformError{Category: UnsupportedType, Err: fmt.Errorf("unknown type")}),
+			wantOutput: UnsupportedType,
+		},
+		{
+			input:      fmt.Errorf("plain error"),
+			wantOutput: DecodeFailure,
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.wantOutput, CategoryOf(test.input))
+	}
+}
+
+func TestWrapTransformError(t *testing.T) {
+	type wrapTest struct {
+		context    TransformError
+		category   ErrorCategory
+		input      error
+		wantOutput *TransformError
+	}
+
+	tests := []wrapTest{
+		{
+			context:    TransformError{Dataset: OperationsDataset, LedgerSequence: 10, EntryKey: "1"},
+			category:   InvalidData,
+			input:      fmt.Errorf("plain error"),
+			wantOutput: &TransformError{Category: InvalidData, Dataset: OperationsDataset, LedgerSequence: 10, EntryKey: "1", Err: fmt.Errorf("plain error")},
+		},
+		{
+			context:    TransformError{Dataset: TradesDataset, LedgerSequence: 10, TransactionIndex: 2, EntryKey: "1"},
+			category:   DecodeFailure,
+			input:      &TransformError{Category: UnsupportedType, Dataset: OperationsDataset, OperationIndex: 3, EntryKey: "2", Err: fmt.Errorf("inner error")},
+			wantOutput: &TransformError{Category: UnsupportedType, Dataset: TradesDataset, LedgerSequence: 10, TransactionIndex: 2, OperationIndex: 3, EntryKey: "2", Err: fmt.Errorf("inner error")},
+		},
+		{
+			context:    TransformError{},
+			category:   DecodeFailure,
+			input:      &TransformError{Category: Serialization, Dataset: LedgersDataset, Err: fmt.Errorf("inner error")},
+			wantOutput: &TransformError{Category: Serialization, Dataset: LedgersDataset, Err: fmt.Errorf("inner error")},
+		},
+	}
+
+	for _, test := range tests {
+		actualOutput := test.context.wrap(test.category, test.input)
+		assert.Equal(t, test.wantOutput, actualOutput)
+		assert.Equal(t, test.input.Error(), actualOutput.Error())
+	}
+}
+
+func TestParseErrorCategories(t *testing.T) {
+	type parseTest struct {
+		input      []string
+		wantOutput []ErrorCategory
+		wantErr    error
+	}
+
+	tests := []parseTest{
+		{
+			input:      []string{"invalid_data", " serialization", ""},
+			wantOutput: []ErrorCategory{InvalidData, Serialization},
+			wantErr:    nil,
+		},
+		{
+			input:      []string{},
+			wantOutput: []ErrorCategory{},
+			wantErr:    nil,
+		},
+		{
+			input:      []string{"decode_failure", "bad_category"},
+			wantOutput: nil,
+			wantErr:    fmt.Errorf("unknown error category \"bad_category\"; valid categories are invalid_data, unsupported_type, decode_failure, serialization"),
+		},
+	}
+
+	for _, test := range tests {
+		actualOutput, actualError := ParseErrorCategories(test.input)
+		assert.Equal(t, test.wantErr, actualError)
+		assert.Equal(t, test.wantOutput, actualOutput)
+	}
+}
@@ -0,0 +1,313 @@
+package transform
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/xdr"
+)
+
+// horizonMemoTypes maps the memo types of the transaction output to the names that Horizon gives them
+var horizonMemoTypes = map[string]string{
+	xdr.MemoTypeMemoNone.String():   "none",
+	xdr.MemoTypeMemoText.String():   "text",
+	xdr.MemoTypeMemoId.String():     "id",
+	xdr.MemoTypeMemoHash.String():   "hash",
+	xdr.MemoTypeMemoReturn.String(): "return",
+}
+
+// horizonAmount formats an amount in stroops like Horizon, as a decimal string with 7 digits after the point
+func horizonAmount(stroops int64) string {
+	return amount.StringFromInt64(stroops)
+}
+
+// horizonSignatures returns the signatures of a transaction, which Horizon shows as an empty list rather than null when there are none
+func horizonSignatures(signatures []string) []string {
+	if signatures == nil {
+		return []string{}
+	}
+
+	return signatures
+}
+
+// horizonTime formats a time bound like Horizon, as an RFC 3339 timestamp in UTC
+func horizonTime(unixTime int64) string {
+	return time.Unix(unixTime, 0).UTC().Format(time.RFC3339)
+}
+
+// horizonOptional returns a pointer to the value, or nil if it is 0, for the fields that Horizon leaves out when an operation does not set them
+func horizonOptional(value uint32) *int {
+	if value == 0 {
+		return nil
+	}
+
+	converted := int(value)
+	return &converted
+}
+
+func horizonFlags(flags []int32) []int {
+	converted := make([]int, 0, len(flags))
+	for _, flag := range flags {
+		converted = append(converted, int(flag))
+	}
+
+	return converted
+}
+
+func horizonPath(path []AssetOutput) []base.Asset {
+	converted := make([]base.Asset, 0, len(path))
+	for _, asset := range path {
+		converted = append(converted, base.Asset{Type: asset.AssetType, Code: asset.AssetCode, Issuer: asset.AssetIssuer})
+	}
+
+	return converted
+}
+
+// HorizonResource returns the ledger as a ledger resource of Horizon, whose paging token is the TOID of the ledger
+func (l LedgerOutput) HorizonResource() (interface{}, error) {
+	txSetOperationCount, err := strconv.ParseInt(l.TxSetOperationCount, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the tx set operation count of ledger %d: %v", l.Sequence, err)
+	}
+
+	failedTransactionCount := l.FailedTransactionCount
+	txSetOperationCount32 := int32(txSetOperationCount)
+	return horizon.Ledger{
+		ID:                         l.LedgerHash,
+		PT:                         strconv.FormatInt(l.LedgerID, 10),
+		Hash:                       l.LedgerHash,
+		PrevHash:                   l.PreviousLedgerHash,
+		Sequence:                   int32(l.Sequence),
+		SuccessfulTransactionCount: l.SuccessfulTransactionCount,
+		FailedTransactionCount:     &failedTransactionCount,
+		OperationCount:             l.OperationCount,
+		TxSetOperationCount:        &txSetOperationCount32,
+		ClosedAt:                   l.ClosedAt,
+		TotalCoins:                 amount.StringFromInt64(l.TotalCoins),
+		FeePool:                    amount.StringFromInt64(l.FeePool),
+		BaseFee:                    int32(l.BaseFee),
+		BaseReserve:                int32(l.BaseReserve),
+		MaxTxSetSize:               int32(l.MaxTxSetSize),
+		ProtocolVersion:            int32(l.ProtocolVersion),
+		HeaderXDR:                  l.LedgerHeader,
+	}, nil
+}
+
+/*
+	HorizonResource returns the transaction as a transaction resource of Horizon, whose paging token is the TOID of the transaction. The
+	transaction output does not keep the XDR of the meta, so those fields are empty. Fee bump transactions are shown like Horizon shows
+	them by their outer hash, with the fee account, the fee, and the signatures of the fee bump, and the inner transaction in its own field.
+*/
+func (t TransactionOutput) HorizonResource() (interface{}, error) {
+	resource := horizon.Transaction{
+		ID:              t.TransactionHash,
+		PT:              strconv.FormatInt(t.TransactionID, 10),
+		Successful:      t.Successful,
+		Hash:            t.TransactionHash,
+		Ledger:          int32(t.LedgerSequence),
+		LedgerCloseTime: t.CreatedAt,
+		Account:         t.Account,
+		AccountSequence: strconv.FormatInt(t.AccountSequence, 10),
+		FeeAccount:      t.FeeAccount,
+		FeeCharged:      t.FeeCharged,
+		MaxFee:          int64(t.MaxFee),
+		OperationCount:  t.OperationCount,
+		EnvelopeXdr:     t.EnvelopeXDR,
+		ResultXdr:       t.ResultXDR,
+		MemoType:        horizonMemoTypes[t.MemoType],
+		Memo:            t.Memo,
+		Signatures:      horizonSignatures(t.Signatures),
+	}
+
+	if t.InnerTransactionHash != "" {
+		resource.MaxFee = t.FeeBumpFee
+		resource.Signatures = horizonSignatures(t.FeeBumpSignatures)
+		resource.FeeBumpTransaction = &horizon.FeeBumpTransaction{Hash: t.TransactionHash, Signatures: resource.Signatures}
+		resource.InnerTransaction = &horizon.InnerTransaction{
+			Hash:       t.InnerTransactionHash,
+			Signatures: horizonSignatures(t.Signatures),
+			MaxFee:     int64(t.MaxFee),
+		}
+	}
+
+	if t.TimeBounds != "" {
+		var minTime, maxTime int64
+		_, err := fmt.Sscanf(t.TimeBounds, "[%d, %d)", &minTime, &maxTime)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the time bounds %s of transaction %s: %v", t.TimeBounds, t.TransactionHash, err)
+		}
+
+		resource.ValidAfter = horizonTime(minTime)
+		// A max time of 0 means that the transaction is valid forever
+		if maxTime != 0 {
+			resource.ValidBefore = horizonTime(maxTime)
+		}
+	}
+
+	return resource, nil
+}
+
+/*
+	HorizonResource returns the operation as an operation resource of Horizon, with the details of the operation in the fields that Horizon
+	gives its type. Amounts are formatted as decimal strings like Horizon does. The details do not record whether set options set a weight
+	or threshold to 0 or left it unchanged, so weights and thresholds of 0 are left out, except for the weight of a signer.
+*/
+func (o OperationOutput) HorizonResource() (interface{}, error) {
+	operationType := xdr.OperationType(o.Type)
+	typeName, ok := operations.TypeNames[operationType]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %d of operation %d", o.Type, o.OperationID)
+	}
+
+	id := strconv.FormatInt(o.OperationID, 10)
+	resourceBase := operations.Base{
+		ID:                    id,
+		PT:                    id,
+		TransactionSuccessful: o.TransactionSuccessful,
+		SourceAccount:         o.SourceAccount,
+		Type:                  typeName,
+		TypeI:                 o.Type,
+		LedgerCloseTime:       o.LedgerClosedAt,
+		TransactionHash:       o.TransactionHash,
+	}
+
+	details := o.OperationDetails
+	asset := base.Asset{Type: details.AssetType, Code: details.AssetCode, Issuer: details.AssetIssuer}
+	payment := operations.Payment{Base: resourceBase, Asset: asset, From: details.From, To: details.To, Amount: horizonAmount(details.AmountStroops)}
+	offer := operations.Offer{
+		Base:               resourceBase,
+		Amount:             horizonAmount(details.AmountStroops),
+		PriceR:             base.Price{N: details.PriceR.Numerator, D: details.PriceR.Denominator},
+		BuyingAssetType:    details.BuyingAssetType,
+		BuyingAssetCode:    details.BuyingAssetCode,
+		BuyingAssetIssuer:  details.BuyingAssetIssuer,
+		SellingAssetType:   details.SellingAssetType,
+		SellingAssetCode:   details.SellingAssetCode,
+		SellingAssetIssuer: details.SellingAssetIssuer,
+	}
+
+	switch operationType {
+	case xdr.OperationTypeCreateAccount:
+		return operations.CreateAccount{
+			Base:            resourceBase,
+			StartingBalance: horizonAmount(details.StartingBalanceStroops),
+			Funder:          details.Funder,
+			Account:         details.Account,
+		}, nil
+	case xdr.OperationTypePayment:
+		return payment, nil
+	case xdr.OperationTypePathPaymentStrictReceive:
+		return operations.PathPayment{
+			Payment:           payment,
+			Path:              horizonPath(details.Path),
+			SourceAmount:      horizonAmount(details.SourceAmountStroops),
+			SourceMax:         horizonAmount(details.SourceMaxStroops),
+			SourceAssetType:   details.SourceAssetType,
+			SourceAssetCode:   details.SourceAssetCode,
+			SourceAssetIssuer: details.SourceAssetIssuer,
+		}, nil
+	case xdr.OperationTypePathPaymentStrictSend:
+		return operations.PathPaymentStrictSend{
+			Payment:           payment,
+			Path:              horizonPath(details.Path),
+			SourceAmount:      horizonAmount(details.SourceAmountStroops),
+			DestinationMin:    details.DestinationMin,
+			SourceAssetType:   details.SourceAssetType,
+			SourceAssetCode:   details.SourceAssetCode,
+			SourceAssetIssuer: details.SourceAssetIssuer,
+		}, nil
+	case xdr.OperationTypeManageSellOffer, xdr.OperationTypeManageBuyOffer, xdr.OperationTypeCreatePassiveSellOffer:
+		if details.PriceR.Denominator == 0 {
+			return nil, fmt.Errorf("the price of operation %d has a denominator of 0", o.OperationID)
+		}
+
+		offer.Price = big.NewRat(int64(details.PriceR.Numerator), int64(details.PriceR.Denominator)).FloatString(7)
+		switch operationType {
+		case xdr.OperationTypeManageSellOffer:
+			return operations.ManageSellOffer{Offer: offer, OfferID: details.OfferID}, nil
+		case xdr.OperationTypeManageBuyOffer:
+			return operations.ManageBuyOffer{Offer: offer, OfferID: details.OfferID}, nil
+		default:
+			return operations.CreatePassiveSellOffer{Offer: offer}, nil
+		}
+	case xdr.OperationTypeSetOptions:
+		resource := operations.SetOptions{
+			Base:            resourceBase,
+			HomeDomain:      details.HomeDomain,
+			InflationDest:   details.InflationDest,
+			MasterKeyWeight: horizonOptional(details.MasterKeyWeight),
+			SetFlags:        horizonFlags(details.SetFlags),
+			SetFlagsS:       details.SetFlagsString,
+			ClearFlags:      horizonFlags(details.ClearFlags),
+			ClearFlagsS:     details.ClearFlagsString,
+			LowThreshold:    horizonOptional(details.LowThreshold),
+			MedThreshold:    horizonOptional(details.MedThreshold),
+			HighThreshold:   horizonOptional(details.HighThreshold),
+		}
+
+		if details.SignerKey != "" {
+			signerWeight := int(details.SignerWeight)
+			resource.SignerKey = details.SignerKey
+			resource.SignerWeight = &signerWeight
+		}
+
+		return resource, nil
+	case xdr.OperationTypeChangeTrust:
+		return operations.ChangeTrust{
+			Base:    resourceBase,
+			Asset:   asset,
+			Limit:   horizonAmount(details.LimitStroops),
+			Trustee: details.Trustee,
+			Trustor: details.Trustor,
+		}, nil
+	case xdr.OperationTypeAllowTrust:
+		return operations.AllowTrust{
+			Base:      resourceBase,
+			Asset:     asset,
+			Trustee:   details.Trustee,
+			Trustor:   details.Trustor,
+			Authorize: details.Authorize,
+		}, nil
+	case xdr.OperationTypeAccountMerge:
+		return operations.AccountMerge{Base: resourceBase, Account: details.Account, Into: details.Into}, nil
+	case xdr.OperationTypeInflation:
+		return operations.Inflation{Base: resourceBase}, nil
+	case xdr.OperationTypeManageData:
+		return operations.ManageData{Base: resourceBase, Name: details.Name, Value: details.Value}, nil
+	case xdr.OperationTypeBumpSequence:
+		return operations.BumpSequence{Base: resourceBase, BumpTo: details.BumpTo}, nil
+	default:
+		return nil, fmt.Errorf("operations of type %s have no Horizon resource", typeName)
+	}
+}
+
+// HorizonResource returns the trade as a trade resource of Horizon, whose ID and paging token are the TOID of the operation followed by the order of the trade
+func (t TradeOutput) HorizonResource() (interface{}, error) {
+	id := fmt.Sprintf("%d-%d", t.HistoryOperationID, t.Order)
+	return horizon.Trade{
+		ID:                 id,
+		PT:                 id,
+		LedgerCloseTime:    t.LedgerClosedAt,
+		OfferID:            strconv.FormatInt(t.OfferID, 10),
+		BaseOfferID:        strconv.FormatInt(t.BaseOfferID, 10),
+		BaseAccount:        t.BaseAccountAddress,
+		BaseAmount:         amount.StringFromInt64(t.BaseAmount),
+		BaseAssetType:      t.BaseAssetType,
+		BaseAssetCode:      t.BaseAssetCode,
+		BaseAssetIssuer:    t.BaseAssetIssuer,
+		CounterOfferID:     strconv.FormatInt(t.CounterOfferID, 10),
+		CounterAccount:     t.CounterAccountAddress,
+		CounterAmount:      amount.StringFromInt64(t.CounterAmount),
+		CounterAssetType:   t.CounterAssetType,
+		CounterAssetCode:   t.CounterAssetCode,
+		CounterAssetIssuer: t.CounterAssetIssuer,
+		BaseIsSeller:       t.BaseIsSeller,
+		Price:              &horizon.Price{N: int32(t.PriceN), D: int32(t.PriceD)},
+	}, nil
+}
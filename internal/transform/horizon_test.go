@@ -0,0 +1,184 @@
+package transform
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHorizonResource(t *testing.T) {
+	type functionInput struct {
+		row interface {
+			HorizonResource() (interface{}, error)
+		}
+	}
+	type functionOutput struct {
+		resource interface{}
+		err      error
+	}
+
+	closedAt := time.Date(2020, 12, 1, 10, 30, 15, 0, time.UTC)
+	failedTransactionCount, txSetOperationCount := int32(1), int32(12)
+	highThreshold := 5
+	operationBase := operations.Base{
+		ID:                    "131880821527056385",
+		PT:                    "131880821527056385",
+		TransactionSuccessful: true,
+		SourceAccount:         testAccount1Address,
+		LedgerCloseTime:       closedAt,
+		TransactionHash:       "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+	}
+	operation := OperationOutput{
+		SourceAccount:         testAccount1Address,
+		OperationID:           131880821527056385,
+		TransactionHash:       "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+		TransactionSuccessful: true,
+		LedgerClosedAt:        closedAt,
+	}
+	withDetails := func(operationType int32, details Details) OperationOutput {
+		withType := operation
+		withType.Type = operationType
+		withType.OperationDetails = details
+		return withType
+	}
+	baseOf := func(typeName string, operationType int32) operations.Base {
+		withType := operationBase
+		withType.Type = typeName
+		withType.TypeI = operationType
+		return withType
+	}
+
+	offerOperation := withDetails(3, Details{
+		OfferID:            260678439,
+		Amount:             2.5,
+		AmountStroops:      25000000,
+		Price:              0.1,
+		PriceR:             Price{Numerator: 1, Denominator: 3},
+		BuyingAssetType:    "native",
+		SellingAssetType:   "credit_alphanum4",
+		SellingAssetCode:   "USD",
+		SellingAssetIssuer: testAccount3Address,
+	})
+	zeroPriceOperation := offerOperation
+	zeroPriceOperation.OperationDetails.PriceR = Price{}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{LedgerOutput{
+				Sequence: 30715263, LedgerHash: "26932dc4d84b5fabe9ae744cb43ce4c6daccf98c86a991b2a14945b1adac4d59", PreviousLedgerHash: "f63c15d0eaf48afbd751a4c4dfade54a3448053c47c5a71d622668ae0cc2a208",
+				LedgerHeader: "AAAAAA==", SuccessfulTransactionCount: 2, FailedTransactionCount: 1, OperationCount: 10, TxSetOperationCount: "12", ClosedAt: closedAt,
+				TotalCoins: 1054439020873472865, FeePool: 18153766209161, BaseFee: 100, BaseReserve: 5000000, MaxTxSetSize: 1000, ProtocolVersion: 13, LedgerID: 131920279262314496,
+			}},
+			functionOutput{horizon.Ledger{
+				ID: "26932dc4d84b5fabe9ae744cb43ce4c6daccf98c86a991b2a14945b1adac4d59", PT: "131920279262314496", Hash: "26932dc4d84b5fabe9ae744cb43ce4c6daccf98c86a991b2a14945b1adac4d59",
+				PrevHash: "f63c15d0eaf48afbd751a4c4dfade54a3448053c47c5a71d622668ae0cc2a208", Sequence: 30715263, SuccessfulTransactionCount: 2, FailedTransactionCount: &failedTransactionCount,
+				OperationCount: 10, TxSetOperationCount: &txSetOperationCount, ClosedAt: closedAt, TotalCoins: "105443902087.3472865", FeePool: "1815376.6209161",
+				BaseFee: 100, BaseReserve: 5000000, MaxTxSetSize: 1000, ProtocolVersion: 13, HeaderXDR: "AAAAAA==",
+			}, nil},
+		},
+		{
+			functionInput{LedgerOutput{Sequence: 30715263, TxSetOperationCount: ""}},
+			functionOutput{nil, fmt.Errorf("could not parse the tx set operation count of ledger 30715263: strconv.ParseInt: parsing \"\": invalid syntax")},
+		},
+		{
+			functionInput{TransactionOutput{
+				TransactionHash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", LedgerSequence: 30715263, Account: testAccount1Address, AccountSequence: 112351890582290871,
+				MaxFee: 90000, FeeCharged: 300, OperationCount: 1, CreatedAt: closedAt, MemoType: "MemoTypeMemoText", Memo: "HL5aCgozQHIW7sSc5XdcfmR", TimeBounds: "[0, 1594272628)",
+				Successful: false, TransactionID: 131920279262318592, FeeAccount: testAccount1Address, EnvelopeXDR: "AAAAAg==", ResultXDR: "AAAAAA==", Signatures: []string{"AQID"},
+			}},
+			functionOutput{horizon.Transaction{
+				ID: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", PT: "131920279262318592", Successful: false, Hash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+				Ledger: 30715263, LedgerCloseTime: closedAt, Account: testAccount1Address, AccountSequence: "112351890582290871", FeeAccount: testAccount1Address, FeeCharged: 300, MaxFee: 90000,
+				OperationCount: 1, EnvelopeXdr: "AAAAAg==", ResultXdr: "AAAAAA==", MemoType: "text", Memo: "HL5aCgozQHIW7sSc5XdcfmR", Signatures: []string{"AQID"},
+				ValidAfter: "1970-01-01T00:00:00Z", ValidBefore: "2020-07-09T05:30:28Z",
+			}, nil},
+		},
+		{
+			functionInput{TransactionOutput{
+				TransactionHash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", Account: testAccount1Address, MaxFee: 90000, FeeCharged: 400, MemoType: "MemoTypeMemoNone",
+				TransactionID: 131920279262318592, FeeAccount: testAccount2Address, Signatures: []string{"AQID"}, FeeBumpFee: 200000, FeeBumpSignatures: []string{"BAUG"},
+				InnerTransactionHash: "0102000000000000000000000000000000000000000000000000000000000000",
+			}},
+			functionOutput{horizon.Transaction{
+				ID: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", PT: "131920279262318592", Hash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+				Account: testAccount1Address, AccountSequence: "0", FeeAccount: testAccount2Address, FeeCharged: 400, MaxFee: 200000, MemoType: "none", Signatures: []string{"BAUG"},
+				FeeBumpTransaction: &horizon.FeeBumpTransaction{Hash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", Signatures: []string{"BAUG"}},
+				InnerTransaction:   &horizon.InnerTransaction{Hash: "0102000000000000000000000000000000000000000000000000000000000000", Signatures: []string{"AQID"}, MaxFee: 90000},
+			}, nil},
+		},
+		{
+			functionInput{TransactionOutput{TransactionHash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", MemoType: "MemoTypeMemoNone", TimeBounds: "[1594272628, 0)"}},
+			functionOutput{horizon.Transaction{
+				ID: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb", PT: "0", Hash: "a87fef5eeb260269c380f2de456aad72b59bb315aaac777860456e09dac0bafb",
+				AccountSequence: "0", MemoType: "none", Signatures: []string{}, ValidAfter: "2020-07-09T05:30:28Z",
+			}, nil},
+		},
+		{
+			// The amount is formatted from the stroops, since the float loses the last digits of large amounts
+			functionInput{withDetails(1, Details{From: testAccount1Address, To: testAccount2Address, Amount: 922337203685.4775807, AmountStroops: 9223372036854775807, AssetType: "native"})},
+			functionOutput{operations.Payment{
+				Base: baseOf("payment", 1), Asset: base.Asset{Type: "native"}, From: testAccount1Address, To: testAccount2Address, Amount: "922337203685.4775807",
+			}, nil},
+		},
+		{
+			functionInput{offerOperation},
+			functionOutput{operations.ManageSellOffer{
+				Offer: operations.Offer{
+					Base: baseOf("manage_sell_offer", 3), Amount: "2.5000000", Price: "0.3333333", PriceR: base.Price{N: 1, D: 3}, BuyingAssetType: "native",
+					SellingAssetType: "credit_alphanum4", SellingAssetCode: "USD", SellingAssetIssuer: testAccount3Address,
+				},
+				OfferID: 260678439,
+			}, nil},
+		},
+		{
+			functionInput{zeroPriceOperation},
+			functionOutput{nil, fmt.Errorf("the price of operation 131880821527056385 has a denominator of 0")},
+		},
+		{
+			functionInput{withDetails(5, Details{SignerKey: testAccount2Address, SignerWeight: 0, HighThreshold: 5, SetFlags: []int32{1}, SetFlagsString: []string{"auth_required"}})},
+			functionOutput{operations.SetOptions{
+				Base: baseOf("set_options", 5), SignerKey: testAccount2Address, SignerWeight: new(int), HighThreshold: &highThreshold,
+				SetFlags: []int{1}, SetFlagsS: []string{"auth_required"}, ClearFlags: []int{},
+			}, nil},
+		},
+		{
+			functionInput{withDetails(11, Details{BumpTo: "100"})},
+			functionOutput{operations.BumpSequence{Base: baseOf("bump_sequence", 11), BumpTo: "100"}, nil},
+		},
+		{
+			functionInput{withDetails(15, Details{})},
+			functionOutput{nil, fmt.Errorf("operations of type claim_claimable_balance have no Horizon resource")},
+		},
+		{
+			functionInput{withDetails(30, Details{})},
+			functionOutput{nil, fmt.Errorf("unknown type 30 of operation 131880821527056385")},
+		},
+		{
+			functionInput{TradeOutput{
+				Order: 1, LedgerClosedAt: closedAt, OfferID: 260678439, BaseAccountAddress: testAccount1Address, BaseAssetType: "native", BaseAmount: 13300347,
+				CounterAccountAddress: testAccount3Address, CounterAssetCode: "USD", CounterAssetIssuer: testAccount4Address, CounterAssetType: "credit_alphanum4",
+				CounterAmount: 12634, BaseIsSeller: true, PriceN: 12634, PriceD: 13300347, BaseOfferID: 4611686018427387905, CounterOfferID: 260678439, HistoryOperationID: 131880821527056385,
+			}},
+			functionOutput{horizon.Trade{
+				ID: "131880821527056385-1", PT: "131880821527056385-1", LedgerCloseTime: closedAt, OfferID: "260678439", BaseOfferID: "4611686018427387905",
+				BaseAccount: testAccount1Address, BaseAmount: "1.3300347", BaseAssetType: "native", CounterOfferID: "260678439", CounterAccount: testAccount3Address,
+				CounterAmount: "0.0012634", CounterAssetType: "credit_alphanum4", CounterAssetCode: "USD", CounterAssetIssuer: testAccount4Address, BaseIsSeller: true,
+				Price: &horizon.Price{N: 12634, D: 13300347},
+			}, nil},
+		},
+	}
+
+	for _, test := range tests {
+		actualResource, actualError := test.input.row.HorizonResource()
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.resource, actualResource)
+	}
+}
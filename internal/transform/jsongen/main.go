@@ -0,0 +1,239 @@
+/*
+	jsongen generates the AppendJSON methods of the output structs in the transform package, which encode rows without the reflection
+	that encoding/json uses. It is run with go generate from the transform package:
+
+		go generate ./internal/transform
+
+	The structs are inspected with reflection when the generator runs, so the generated file has to be regenerated whenever an output
+	struct changes. TestAppendJSON in the transform package fails if it is out of date.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stellar/stellar-etl/internal/transform"
+)
+
+// rootTypes are the rows of the exported datasets. The structs that they contain get AppendJSON methods as well
+var rootTypes = []interface{}{
+	transform.LedgerOutput{},
+	transform.TransactionOutput{},
+	transform.OperationOutput{},
+	transform.TradeOutput{},
+	transform.DepositOutput{},
+	transform.AccountOutput{},
+	transform.AccountCompositeOutput{},
+	transform.OfferOutput{},
+	transform.TrustlineOutput{},
+	transform.DimMarket{},
+	transform.DimOffer{},
+	transform.DimAccount{},
+	transform.FactOfferEvent{},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type generator struct {
+	body      bytes.Buffer
+	generated map[reflect.Type]bool
+	pending   []reflect.Type
+	usesErr   bool
+	loopDepth int
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+// literal appends a constant piece of JSON to dst
+func (g *generator) literal(text string) {
+	if len(text) == 1 {
+		g.printf("dst = append(dst, %s)\n", strconv.QuoteRune(rune(text[0])))
+		return
+	}
+
+	g.printf("dst = append(dst, %s...)\n", strconv.Quote(text))
+}
+
+// trimBlankLine removes the blank line at the end of the generated code, so that blocks do not end with one
+func (g *generator) trimBlankLine() {
+	if bytes.HasSuffix(g.body.Bytes(), []byte("\n\n")) {
+		g.body.Truncate(g.body.Len() - 1)
+	}
+}
+
+func (g *generator) check() {
+	g.usesErr = true
+	g.printf("if err != nil {\nreturn nil, err\n}\n\n")
+}
+
+// value generates the code that appends the JSON encoding of the expression, which has the given type
+func (g *generator) value(expr string, valueType reflect.Type) error {
+	if valueType == timeType {
+		g.printf("dst, err = appendJSONTime(dst, %s)\n", expr)
+		g.check()
+		return nil
+	}
+
+	switch valueType.Kind() {
+	case reflect.String:
+		g.printf("dst = appendJSONString(dst, string(%s))\n", expr)
+	case reflect.Bool:
+		g.printf("dst = strconv.AppendBool(dst, bool(%s))\n", expr)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		g.printf("dst = strconv.AppendInt(dst, int64(%s), 10)\n", expr)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		g.printf("dst = strconv.AppendUint(dst, uint64(%s), 10)\n", expr)
+	case reflect.Float32, reflect.Float64:
+		g.printf("dst, err = appendJSONFloat(dst, float64(%s), %d)\n", expr, valueType.Bits())
+		g.check()
+	case reflect.Struct:
+		if valueType.PkgPath() != reflect.TypeOf(transform.LedgerOutput{}).PkgPath() || valueType.Name() == "" {
+			return fmt.Errorf("struct %s is not a named struct of the transform package", valueType)
+		}
+
+		if !g.generated[valueType] {
+			g.generated[valueType] = true
+			g.pending = append(g.pending, valueType)
+		}
+
+		g.printf("dst, err = %s.AppendJSON(dst)\n", expr)
+		g.check()
+	case reflect.Slice:
+		if valueType.Elem().Kind() == reflect.Uint8 {
+			return fmt.Errorf("byte slices are not supported")
+		}
+
+		item := fmt.Sprintf("item%d", g.loopDepth)
+		g.loopDepth++
+		g.printf("if %s == nil {\n", expr)
+		g.literal("null")
+		g.printf("} else {\n")
+		g.literal("[")
+		g.printf("for i, %s := range %s {\nif i > 0 {\n", item, expr)
+		g.literal(",")
+		g.printf("}\n\n")
+		if err := g.value(item, valueType.Elem()); err != nil {
+			return err
+		}
+
+		g.trimBlankLine()
+		g.printf("}\n\n")
+		g.literal("]")
+		g.printf("}\n\n")
+		g.loopDepth--
+	default:
+		return fmt.Errorf("values of kind %s are not supported", valueType.Kind())
+	}
+
+	return nil
+}
+
+// method generates the AppendJSON method of a struct. The fields are encoded in order, with the names from their json tags
+func (g *generator) method(structType reflect.Type) error {
+	g.body.Reset()
+	g.usesErr = false
+
+	separator := "{"
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if field.Anonymous {
+			return fmt.Errorf("embedded field %s of %s is not supported", field.Name, structType)
+		}
+
+		tagParts := strings.Split(field.Tag.Get("json"), ",")
+		name := tagParts[0]
+		if name == "-" && len(tagParts) == 1 {
+			continue
+		}
+
+		if len(tagParts) > 1 {
+			return fmt.Errorf("field %s of %s has json tag options, which are not supported", field.Name, structType)
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+
+		g.literal(separator + string(key) + ":")
+		separator = ","
+		if err := g.value("o."+field.Name, field.Type); err != nil {
+			return fmt.Errorf("field %s of %s: %v", field.Name, structType, err)
+		}
+	}
+
+	if separator == "{" {
+		g.literal("{}")
+	} else {
+		g.literal("}")
+	}
+
+	fields := g.body.String()
+	g.body.Reset()
+	g.printf("// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json\n")
+	g.printf("func (o %s) AppendJSON(dst []byte) ([]byte, error) {\n", structType.Name())
+	if g.usesErr {
+		g.printf("var err error\n")
+	}
+
+	g.printf("%sreturn dst, nil\n}\n\n", fields)
+	return nil
+}
+
+func main() {
+	outputPath := flag.String("output", "schema_json.go", "Path of the generated file")
+	flag.Parse()
+
+	g := &generator{generated: map[reflect.Type]bool{}}
+	for _, row := range rootTypes {
+		rowType := reflect.TypeOf(row)
+		if !g.generated[rowType] {
+			g.generated[rowType] = true
+			g.pending = append(g.pending, rowType)
+		}
+	}
+
+	var methods bytes.Buffer
+	for len(g.pending) > 0 {
+		next := g.pending[0]
+		g.pending = g.pending[1:]
+		if err := g.method(next); err != nil {
+			log.Fatal(err)
+		}
+
+		methods.Write(g.body.Bytes())
+		g.body.Reset()
+	}
+
+	var file bytes.Buffer
+	file.WriteString("// Code generated by jsongen. DO NOT EDIT.\n\npackage transform\n\nimport \"strconv\"\n\n")
+	file.Write(methods.Bytes())
+	formatted, err := format.Source(file.Bytes())
+	if err != nil {
+		log.Fatalf("could not format the generated code: %v", err)
+	}
+
+	if err := ioutil.WriteFile(*outputPath, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
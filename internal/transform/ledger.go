@@ -12,15 +12,17 @@ import (
 
 //TransformLedger converts a ledger from the history archive ingestion system into a form suitable for BigQuery
 func TransformLedger(inputLedgerMeta xdr.LedgerCloseMeta) (LedgerOutput, error) {
+	errorContext := TransformError{Dataset: LedgersDataset}
 	ledger, ok := inputLedgerMeta.GetV0()
 	if !ok {
-		return LedgerOutput{}, fmt.Errorf("Could not access the v0 information for given ledger")
+		return LedgerOutput{}, errorContext.wrap(UnsupportedType, fmt.Errorf("Could not access the v0 information for given ledger"))
 	}
 
 	ledgerHeaderHistory := ledger.LedgerHeader
 	ledgerHeader := ledgerHeaderHistory.Header
 
 	outputSequence := uint32(ledgerHeader.LedgerSeq)
+	errorContext.LedgerSequence = outputSequence
 
 	outputLedgerID := toid.New(int32(outputSequence), 0, 0).ToInt64()
 
@@ -29,27 +31,27 @@ func TransformLedger(inputLedgerMeta xdr.LedgerCloseMeta) (LedgerOutput, error)
 
 	outputLedgerHeader, err := xdr.MarshalBase64(ledgerHeader)
 	if err != nil {
-		return LedgerOutput{}, fmt.Errorf("for ledger %d (ledger id=%d): %v", outputSequence, outputLedgerID, err)
+		return LedgerOutput{}, errorContext.wrap(Serialization, fmt.Errorf("for ledger %d (ledger id=%d): %v", outputSequence, outputLedgerID, err))
 	}
 
 	outputTransactionCount, outputOperationCount, outputSuccessfulCount, outputFailedCount, outputTxSetOperationCount, err := extractCounts(ledger)
 	if err != nil {
-		return LedgerOutput{}, fmt.Errorf("for ledger %d (ledger id=%d): %v", outputSequence, outputLedgerID, err)
+		return LedgerOutput{}, errorContext.wrap(CategoryOf(err), fmt.Errorf("for ledger %d (ledger id=%d): %v", outputSequence, outputLedgerID, err))
 	}
 
 	outputCloseTime, err := utils.TimePointToUTCTimeStamp(ledgerHeader.ScpValue.CloseTime)
 	if err != nil {
-		return LedgerOutput{}, fmt.Errorf("for ledger %d (ledger id=%d): %v", outputSequence, outputLedgerID, err)
+		return LedgerOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("for ledger %d (ledger id=%d): %v", outputSequence, outputLedgerID, err))
 	}
 
 	outputTotalCoins := int64(ledgerHeader.TotalCoins)
 	if outputTotalCoins < 0 {
-		return LedgerOutput{}, fmt.Errorf("The total number of coins (%d) is negative for ledger %d (ledger id=%d)", outputTotalCoins, outputSequence, outputLedgerID)
+		return LedgerOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The total number of coins (%d) is negative for ledger %d (ledger id=%d)", outputTotalCoins, outputSequence, outputLedgerID))
 	}
 
 	outputFeePool := int64(ledgerHeader.FeePool)
 	if outputFeePool < 0 {
-		return LedgerOutput{}, fmt.Errorf("The fee pool (%d) is negative for ledger %d (ledger id=%d)", outputFeePool, outputSequence, outputLedgerID)
+		return LedgerOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The fee pool (%d) is negative for ledger %d (ledger id=%d)", outputFeePool, outputSequence, outputLedgerID))
 	}
 
 	outputBaseFee := uint32(ledgerHeader.BaseFee)
@@ -58,7 +60,7 @@ func TransformLedger(inputLedgerMeta xdr.LedgerCloseMeta) (LedgerOutput, error)
 
 	outputMaxTxSetSize := uint32(ledgerHeader.MaxTxSetSize)
 	if int64(outputMaxTxSetSize) < int64(outputTransactionCount) {
-		return LedgerOutput{}, fmt.Errorf("The transaction count is greater than the maximum transaction set size (%d > %d) for ledger %d (ledger id=%d)", outputTransactionCount, outputMaxTxSetSize, outputSequence, outputLedgerID)
+		return LedgerOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The transaction count is greater than the maximum transaction set size (%d > %d) for ledger %d (ledger id=%d)", outputTransactionCount, outputMaxTxSetSize, outputSequence, outputLedgerID))
 	}
 
 	outputProtocolVersion := uint32(ledgerHeader.LedgerVersion)
@@ -90,7 +92,7 @@ func extractCounts(lcm xdr.LedgerCloseMetaV0) (transactionCount int32, operation
 	results := lcm.TxProcessing
 	txCount := len(transactions)
 	if txCount != len(results) {
-		err = fmt.Errorf("The number of transactions and results are different (%d != %d)", txCount, len(results))
+		err = categorize(InvalidData, fmt.Errorf("The number of transactions and results are different (%d != %d)", txCount, len(results)))
 		return
 	}
 
@@ -104,7 +106,7 @@ func extractCounts(lcm xdr.LedgerCloseMetaV0) (transactionCount int32, operation
 		if results[i].Result.Successful() {
 			operationResults, ok := results[i].Result.OperationResults()
 			if !ok {
-				err = fmt.Errorf("Could not access operation results for result %d", i)
+				err = categorize(DecodeFailure, fmt.Errorf("Could not access operation results for result %d", i))
 				return
 			}
 
@@ -29,21 +29,21 @@ func TestTransformLedger(t *testing.T) {
 				TotalCoins: -1,
 			}),
 			LedgerOutput{},
-			fmt.Errorf("The total number of coins (-1) is negative for ledger 0 (ledger id=0)"),
+			&TransformError{Category: InvalidData, Dataset: LedgersDataset, Err: fmt.Errorf("The total number of coins (-1) is negative for ledger 0 (ledger id=0)")},
 		},
 		{
 			wrapLedgerHeader(xdr.LedgerHeader{
 				FeePool: -1,
 			}),
 			LedgerOutput{},
-			fmt.Errorf("The fee pool (-1) is negative for ledger 0 (ledger id=0)"),
+			&TransformError{Category: InvalidData, Dataset: LedgersDataset, Err: fmt.Errorf("The fee pool (-1) is negative for ledger 0 (ledger id=0)")},
 		},
 		{
 			wrapLedgerHeaderWithTransactions(xdr.LedgerHeader{
 				MaxTxSetSize: 0,
 			}, 2),
 			LedgerOutput{},
-			fmt.Errorf("for ledger 0 (ledger id=0): The number of transactions and results are different (2 != 0)"),
+			&TransformError{Category: InvalidData, Dataset: LedgersDataset, Err: fmt.Errorf("for ledger 0 (ledger id=0): The number of transactions and results are different (2 != 0)")},
 		},
 		{
 			hardCodedLedger,
@@ -90,11 +90,22 @@ func makeLedgerTestOutput() (output LedgerOutput, err error) {
 	return
 }
 
+// makeLedgerTestTx creates a sample transaction with operationCount copies of its BumpSequence operation
+func makeLedgerTestTx(sequence int64, operationCount int) xdr.TransactionEnvelope {
+	envelope := utils.CreateSampleTx(sequence)
+	operation := envelope.V1.Tx.Operations[0]
+	for i := 1; i < operationCount; i++ {
+		envelope.V1.Tx.Operations = append(envelope.V1.Tx.Operations, operation)
+	}
+
+	return envelope
+}
+
 func makeLedgerTestInput() (lcm xdr.LedgerCloseMeta, err error) {
 	hardCodedTxSet := xdr.TransactionSet{
 		Txs: []xdr.TransactionEnvelope{
-			utils.CreateSampleTx(0),
-			utils.CreateSampleTx(1),
+			makeLedgerTestTx(0, 3),
+			makeLedgerTestTx(1, 10),
 		},
 	}
 	hardCodedTxProcessing := []xdr.TransactionResultMeta{
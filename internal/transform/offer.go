@@ -2,6 +2,7 @@ package transform
 
 import (
 	"fmt"
+	"strconv"
 
 	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/xdr"
@@ -10,53 +11,56 @@ import (
 
 //TransformOffer converts an account from the history archive ingestion system into a form suitable for BigQuery
 func TransformOffer(ledgerChange ingestio.Change) (OfferOutput, error) {
+	errorContext := TransformError{Dataset: OffersDataset}
 	ledgerEntry, outputDeleted, err := utils.ExtractEntryFromChange(ledgerChange)
 	if err != nil {
-		return OfferOutput{}, err
+		return OfferOutput{}, errorContext.wrap(DecodeFailure, err)
 	}
 
+	errorContext.LedgerSequence = uint32(ledgerEntry.LastModifiedLedgerSeq)
 	offerEntry, offerFound := ledgerEntry.Data.GetOffer()
 	if !offerFound {
-		return OfferOutput{}, fmt.Errorf("Could not extract offer data from ledger entry; actual type is %s", ledgerEntry.Data.Type)
+		return OfferOutput{}, errorContext.wrap(UnsupportedType, fmt.Errorf("Could not extract offer data from ledger entry; actual type is %s", ledgerEntry.Data.Type))
 	}
 
 	outputSellerID, err := offerEntry.SellerId.GetAddress()
 	if err != nil {
-		return OfferOutput{}, err
+		return OfferOutput{}, errorContext.wrap(DecodeFailure, err)
 	}
 
 	outputOfferID := int64(offerEntry.OfferId)
+	errorContext.EntryKey = strconv.FormatInt(outputOfferID, 10)
 	if outputOfferID < 0 {
-		return OfferOutput{}, fmt.Errorf("OfferID is negative (%d) for offer from account: %s", outputOfferID, outputSellerID)
+		return OfferOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("OfferID is negative (%d) for offer from account: %s", outputOfferID, outputSellerID))
 	}
 
 	outputSellingAsset, err := xdr.MarshalBase64(offerEntry.Selling)
 	if err != nil {
-		return OfferOutput{}, err
+		return OfferOutput{}, errorContext.wrap(Serialization, err)
 	}
 
 	outputBuyingAsset, err := xdr.MarshalBase64(offerEntry.Buying)
 	if err != nil {
-		return OfferOutput{}, err
+		return OfferOutput{}, errorContext.wrap(Serialization, err)
 	}
 
 	outputAmount := int64(offerEntry.Amount)
 	if outputAmount < 0 {
-		return OfferOutput{}, fmt.Errorf("Amount is negative (%d) for offer %d", outputAmount, outputOfferID)
+		return OfferOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Amount is negative (%d) for offer %d", outputAmount, outputOfferID))
 	}
 
 	outputPriceN := int32(offerEntry.Price.N)
 	if outputPriceN < 0 {
-		return OfferOutput{}, fmt.Errorf("Price numerator is negative (%d) for offer %d", outputPriceN, outputOfferID)
+		return OfferOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Price numerator is negative (%d) for offer %d", outputPriceN, outputOfferID))
 	}
 
 	outputPriceD := int32(offerEntry.Price.D)
 	if outputPriceD == 0 {
-		return OfferOutput{}, fmt.Errorf("Price denominator is 0 for offer %d", outputOfferID)
+		return OfferOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Price denominator is 0 for offer %d", outputOfferID))
 	}
 
 	if outputPriceD < 0 {
-		return OfferOutput{}, fmt.Errorf("Price denominator is negative (%d) for offer %d", outputPriceD, outputOfferID)
+		return OfferOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Price denominator is negative (%d) for offer %d", outputPriceD, outputOfferID))
 	}
 
 	var outputPrice float64
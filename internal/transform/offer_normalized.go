@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"sort"
+	"strconv"
 	"strings"
 
 	ingestio "github.com/stellar/go/ingest/io"
@@ -12,34 +13,35 @@ import (
 
 // TransformOfferNormalized converts an offer into a normalized form, allowing it to be stored as part of the historical orderbook dataset
 func TransformOfferNormalized(ledgerChange ingestio.Change, ledgerSeq uint32) (NormalizedOfferOutput, error) {
-
+	errorContext := TransformError{Dataset: OrderbooksDataset, LedgerSequence: ledgerSeq}
 	transformed, err := TransformOffer(ledgerChange)
 	if err != nil {
-		return NormalizedOfferOutput{}, err
+		return NormalizedOfferOutput{}, errorContext.wrap(CategoryOf(err), err)
 	}
 
+	errorContext.EntryKey = strconv.FormatInt(transformed.OfferID, 10)
 	if transformed.Deleted {
-		return NormalizedOfferOutput{}, fmt.Errorf("offer %d is deleted", transformed.OfferID)
+		return NormalizedOfferOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("offer %d is deleted", transformed.OfferID))
 	}
 
 	err = modifyOfferAsset(ledgerChange, &transformed)
 	if err != nil {
-		return NormalizedOfferOutput{}, err
+		return NormalizedOfferOutput{}, errorContext.wrap(CategoryOf(err), err)
 	}
 
 	outputMarket, err := extractDimMarket(transformed)
 	if err != nil {
-		return NormalizedOfferOutput{}, err
+		return NormalizedOfferOutput{}, errorContext.wrap(CategoryOf(err), err)
 	}
 
 	outputAccount, err := extractDimAccount(transformed)
 	if err != nil {
-		return NormalizedOfferOutput{}, err
+		return NormalizedOfferOutput{}, errorContext.wrap(Serialization, err)
 	}
 
 	outputOffer, err := extractDimOffer(transformed, outputMarket.ID, outputAccount.ID)
 	if err != nil {
-		return NormalizedOfferOutput{}, err
+		return NormalizedOfferOutput{}, errorContext.wrap(Serialization, err)
 	}
 
 	return NormalizedOfferOutput{
@@ -62,7 +64,7 @@ func modifyOfferAsset(ledgerChange ingestio.Change, transformed *OfferOutput) er
 
 	offerEntry, offerFound := ledgerEntry.Data.GetOffer()
 	if !offerFound {
-		return fmt.Errorf("Could not extract offer data from ledger entry; actual type is %s", ledgerEntry.Data.Type)
+		return categorize(UnsupportedType, fmt.Errorf("Could not extract offer data from ledger entry; actual type is %s", ledgerEntry.Data.Type))
 	}
 
 	var sellType, sellCode, sellIssuer string
@@ -106,7 +108,7 @@ func extractDimMarket(offer OfferOutput) (DimMarket, error) {
 
 	fnvHasher := fnv.New64a()
 	if _, err := fnvHasher.Write([]byte(strings.Join(assets, "/"))); err != nil {
-		return DimMarket{}, err
+		return DimMarket{}, categorize(Serialization, err)
 	}
 
 	hash := fnvHasher.Sum64()
@@ -115,11 +117,11 @@ func extractDimMarket(offer OfferOutput) (DimMarket, error) {
 	buySplit := strings.Split(assets[1], ":")
 
 	if len(sellSplit) < 2 {
-		return DimMarket{}, fmt.Errorf("unable to get sell code and issuer for offer %d", offer.OfferID)
+		return DimMarket{}, categorize(InvalidData, fmt.Errorf("unable to get sell code and issuer for offer %d", offer.OfferID))
 	}
 
 	if len(buySplit) < 2 {
-		return DimMarket{}, fmt.Errorf("unable to get buy code and issuer for offer %d", offer.OfferID)
+		return DimMarket{}, categorize(InvalidData, fmt.Errorf("unable to get buy code and issuer for offer %d", offer.OfferID))
 	}
 
 	baseCode, baseIssuer := sellSplit[0], sellSplit[1]
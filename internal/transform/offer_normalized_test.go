@@ -44,7 +44,7 @@ func TestTransformOfferNormalized(t *testing.T) {
 				Post: nil,
 			}, 100},
 			wantOutput: NormalizedOfferOutput{},
-			wantErr:    fmt.Errorf("offer 0 is deleted"),
+			wantErr:    &TransformError{Category: InvalidData, Dataset: OrderbooksDataset, LedgerSequence: 100, EntryKey: "0", Err: fmt.Errorf("offer 0 is deleted")},
 		},
 		{
 			input:      testInput{hardCodedInput, 100},
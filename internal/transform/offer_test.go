@@ -30,21 +30,21 @@ func TestTransformOffer(t *testing.T) {
 					},
 				},
 			},
-			OfferOutput{}, fmt.Errorf("Could not extract offer data from ledger entry; actual type is LedgerEntryTypeAccount"),
+			OfferOutput{}, &TransformError{Category: UnsupportedType, Dataset: OffersDataset, Err: fmt.Errorf("Could not extract offer data from ledger entry; actual type is LedgerEntryTypeAccount")},
 		},
 		{
 			wrapOfferEntry(xdr.OfferEntry{
 				SellerId: genericAccountID,
 				OfferId:  -1,
 			}, 0),
-			OfferOutput{}, fmt.Errorf("OfferID is negative (-1) for offer from account: %s", genericAccountAddress),
+			OfferOutput{}, &TransformError{Category: InvalidData, Dataset: OffersDataset, EntryKey: "-1", Err: fmt.Errorf("OfferID is negative (-1) for offer from account: %s", genericAccountAddress)},
 		},
 		{
 			wrapOfferEntry(xdr.OfferEntry{
 				SellerId: genericAccountID,
 				Amount:   -2,
 			}, 0),
-			OfferOutput{}, fmt.Errorf("Amount is negative (-2) for offer 0"),
+			OfferOutput{}, &TransformError{Category: InvalidData, Dataset: OffersDataset, EntryKey: "0", Err: fmt.Errorf("Amount is negative (-2) for offer 0")},
 		},
 		{
 			wrapOfferEntry(xdr.OfferEntry{
@@ -54,7 +54,7 @@ func TestTransformOffer(t *testing.T) {
 					D: 10,
 				},
 			}, 0),
-			OfferOutput{}, fmt.Errorf("Price numerator is negative (-3) for offer 0"),
+			OfferOutput{}, &TransformError{Category: InvalidData, Dataset: OffersDataset, EntryKey: "0", Err: fmt.Errorf("Price numerator is negative (-3) for offer 0")},
 		},
 		{
 			wrapOfferEntry(xdr.OfferEntry{
@@ -64,7 +64,7 @@ func TestTransformOffer(t *testing.T) {
 					D: -4,
 				},
 			}, 0),
-			OfferOutput{}, fmt.Errorf("Price denominator is negative (-4) for offer 0"),
+			OfferOutput{}, &TransformError{Category: InvalidData, Dataset: OffersDataset, EntryKey: "0", Err: fmt.Errorf("Price denominator is negative (-4) for offer 0")},
 		},
 		{
 			wrapOfferEntry(xdr.OfferEntry{
@@ -74,7 +74,7 @@ func TestTransformOffer(t *testing.T) {
 					D: 0,
 				},
 			}, 0),
-			OfferOutput{}, fmt.Errorf("Price denominator is 0 for offer 0"),
+			OfferOutput{}, &TransformError{Category: InvalidData, Dataset: OffersDataset, EntryKey: "0", Err: fmt.Errorf("Price denominator is 0 for offer 0")},
 		},
 		{
 			hardCodedInput,
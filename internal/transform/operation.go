@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/stellar/stellar-etl/internal/toid"
 
@@ -14,32 +15,42 @@ import (
 )
 
 //TransformOperation converts an operation from the history archive ingestion system into a form suitable for BigQuery
-func TransformOperation(operation xdr.Operation, operationIndex int32, transaction ingestio.LedgerTransaction, ledgerSeq int32) (OperationOutput, error) {
+func TransformOperation(operation xdr.Operation, operationIndex int32, transaction ingestio.LedgerTransaction, ledgerSeq int32, ledgerCloseTime time.Time) (OperationOutput, error) {
 	outputTransactionID := toid.New(ledgerSeq, int32(transaction.Index), 0).ToInt64()
 	outputOperationID := toid.New(ledgerSeq, int32(transaction.Index), operationIndex).ToInt64()
+	errorContext := TransformError{
+		Dataset:          OperationsDataset,
+		LedgerSequence:   uint32(ledgerSeq),
+		TransactionIndex: int32(transaction.Index),
+		OperationIndex:   operationIndex,
+		EntryKey:         strconv.FormatInt(outputOperationID, 10),
+	}
 
 	outputSourceAccount, err := utils.GetAccountAddressFromMuxedAccount(getOperationSourceAccount(operation, transaction))
 	if err != nil {
-		return OperationOutput{}, fmt.Errorf("for operation %d (ledger id=%d): %v", operationIndex, outputOperationID, err)
+		return OperationOutput{}, errorContext.wrap(DecodeFailure, fmt.Errorf("for operation %d (ledger id=%d): %v", operationIndex, outputOperationID, err))
 	}
 
 	outputOperationType := int32(operation.Body.Type)
 	if outputOperationType < 0 {
-		return OperationOutput{}, fmt.Errorf("The operation type (%d) is negative for  operation %d (operation id=%d)", outputOperationType, operationIndex, outputOperationID)
+		return OperationOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The operation type (%d) is negative for  operation %d (operation id=%d)", outputOperationType, operationIndex, outputOperationID))
 	}
 
 	outputDetails, err := extractOperationDetails(operation, transaction, operationIndex)
 	if err != nil {
-		return OperationOutput{}, err
+		return OperationOutput{}, errorContext.wrap(CategoryOf(err), err)
 	}
 
 	transformedOperation := OperationOutput{
-		SourceAccount:    outputSourceAccount,
-		Type:             outputOperationType,
-		ApplicationOrder: operationIndex + 1, // Application order is 1-indexed
-		TransactionID:    outputTransactionID,
-		OperationID:      outputOperationID,
-		OperationDetails: outputDetails,
+		SourceAccount:         outputSourceAccount,
+		Type:                  outputOperationType,
+		ApplicationOrder:      operationIndex + 1, // Application order is 1-indexed
+		TransactionID:         outputTransactionID,
+		OperationID:           outputOperationID,
+		OperationDetails:      outputDetails,
+		TransactionHash:       utils.HashToHexString(transaction.Result.TransactionHash),
+		TransactionSuccessful: transaction.Result.Successful(),
+		LedgerClosedAt:        ledgerCloseTime,
 	}
 
 	return transformedOperation, nil
@@ -145,6 +156,41 @@ func addOperationFlagToOperationDetails(operationDetails *Details, flag uint32,
 	}
 }
 
+/*
+	addOfferResultToOperationDetails adds the outcome of a successful offer operation to the details. Offers that are left in the orderbook
+	are created or updated, and report the ID of the offer and the amount that is left in it. An offer that the result reports as deleted
+	was either deleted by the operation, if the requested amount is 0, or fully filled by the trades of the operation. New offers that are
+	fully filled never enter the orderbook, so their resulting offer ID is 0.
+*/
+func addOfferResultToOperationDetails(operationDetails *Details, result xdr.ManageOfferSuccessResult, requestedOfferID xdr.Int64, requestedAmount xdr.Int64) error {
+	switch result.Offer.Effect {
+	case xdr.ManageOfferEffectManageOfferCreated, xdr.ManageOfferEffectManageOfferUpdated:
+		offer := result.Offer.Offer
+		if offer == nil {
+			return fmt.Errorf("Could not access the offer in the result of the offer operation")
+		}
+
+		operationDetails.OfferEffect = "created"
+		if result.Offer.Effect == xdr.ManageOfferEffectManageOfferUpdated {
+			operationDetails.OfferEffect = "updated"
+		}
+
+		operationDetails.ResultOfferID = int64(offer.OfferId)
+		operationDetails.RemainingAmount = utils.ConvertStroopValueToReal(offer.Amount)
+	case xdr.ManageOfferEffectManageOfferDeleted:
+		operationDetails.OfferEffect = "fully_filled"
+		if requestedAmount == 0 {
+			operationDetails.OfferEffect = "deleted"
+		}
+
+		operationDetails.ResultOfferID = int64(requestedOfferID)
+	default:
+		return fmt.Errorf("Unknown offer effect: %d", result.Offer.Effect)
+	}
+
+	return nil
+}
+
 func extractOperationDetails(operation xdr.Operation, transaction ingestio.LedgerTransaction, operationIndex int32) (Details, error) {
 	outputDetails := Details{}
 	sourceAccount := getOperationSourceAccount(operation, transaction)
@@ -170,6 +216,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		outputDetails.Funder = sourceAccountAddress
 		outputDetails.Account = op.Destination.Address()
 		outputDetails.StartingBalance = utils.ConvertStroopValueToReal(op.StartingBalance)
+		outputDetails.StartingBalanceStroops = int64(op.StartingBalance)
 
 	case xdr.OperationTypePayment:
 		op, ok := operation.Body.GetPaymentOp()
@@ -185,6 +232,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 
 		outputDetails.To = toAccountAddress
 		outputDetails.Amount = utils.ConvertStroopValueToReal(op.Amount)
+		outputDetails.AmountStroops = int64(op.Amount)
 		err = addAssetDetailsToOperationDetails(&outputDetails, op.Asset, "")
 		if err != nil {
 			return Details{}, err
@@ -204,7 +252,9 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 
 		outputDetails.To = toAccountAddress
 		outputDetails.Amount = utils.ConvertStroopValueToReal(op.DestAmount)
+		outputDetails.AmountStroops = int64(op.DestAmount)
 		outputDetails.SourceMax = utils.ConvertStroopValueToReal(op.SendMax)
+		outputDetails.SourceMaxStroops = int64(op.SendMax)
 		addAssetDetailsToOperationDetails(&outputDetails, op.DestAsset, "")
 		addAssetDetailsToOperationDetails(&outputDetails, op.SendAsset, "source")
 
@@ -218,6 +268,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 				return Details{}, fmt.Errorf("Could not access PathPaymentStrictReceive result info for this operation (index %d)", operationIndex)
 			}
 			outputDetails.SourceAmount = utils.ConvertStroopValueToReal(result.SendAmount())
+			outputDetails.SourceAmountStroops = int64(result.SendAmount())
 		}
 
 		outputDetails.Path = convertPathToAssetOutput(op.Path)
@@ -236,6 +287,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 
 		outputDetails.To = toAccountAddress
 		outputDetails.SourceAmount = utils.ConvertStroopValueToReal(op.SendAmount)
+		outputDetails.SourceAmountStroops = int64(op.SendAmount)
 		outputDetails.DestinationMin = amount.String(op.DestMin)
 		addAssetDetailsToOperationDetails(&outputDetails, op.DestAsset, "")
 		addAssetDetailsToOperationDetails(&outputDetails, op.SendAsset, "source")
@@ -250,6 +302,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 				return Details{}, fmt.Errorf("Could not access GetPathPaymentStrictSendResult result info for this operation (index %d)", operationIndex)
 			}
 			outputDetails.Amount = utils.ConvertStroopValueToReal(result.DestAmount())
+			outputDetails.AmountStroops = int64(result.DestAmount())
 		}
 
 		outputDetails.Path = convertPathToAssetOutput(op.Path)
@@ -262,6 +315,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 
 		outputDetails.OfferID = int64(op.OfferId)
 		outputDetails.Amount = utils.ConvertStroopValueToReal(op.BuyAmount)
+		outputDetails.AmountStroops = int64(op.BuyAmount)
 		parsedPrice, err := strconv.ParseFloat(op.Price.String(), 64)
 		if err != nil {
 			return Details{}, err
@@ -275,6 +329,25 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		addAssetDetailsToOperationDetails(&outputDetails, op.Buying, "buying")
 		addAssetDetailsToOperationDetails(&outputDetails, op.Selling, "selling")
 
+		if transaction.Result.Successful() {
+			resultBody, ok := currentOperationResult.GetTr()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access result body for this operation (index %d)", operationIndex)
+			}
+			result, ok := resultBody.GetManageBuyOfferResult()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access ManageBuyOffer result info for this operation (index %d)", operationIndex)
+			}
+			success, ok := result.GetSuccess()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access ManageBuyOffer success result for this operation (index %d)", operationIndex)
+			}
+			err = addOfferResultToOperationDetails(&outputDetails, success, op.OfferId, op.BuyAmount)
+			if err != nil {
+				return Details{}, err
+			}
+		}
+
 	case xdr.OperationTypeManageSellOffer:
 		op, ok := operation.Body.GetManageSellOfferOp()
 		if !ok {
@@ -283,6 +356,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 
 		outputDetails.OfferID = int64(op.OfferId)
 		outputDetails.Amount = utils.ConvertStroopValueToReal(op.Amount)
+		outputDetails.AmountStroops = int64(op.Amount)
 		parsedPrice, err := strconv.ParseFloat(op.Price.String(), 64)
 		if err != nil {
 			return Details{}, err
@@ -296,6 +370,25 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		addAssetDetailsToOperationDetails(&outputDetails, op.Buying, "buying")
 		addAssetDetailsToOperationDetails(&outputDetails, op.Selling, "selling")
 
+		if transaction.Result.Successful() {
+			resultBody, ok := currentOperationResult.GetTr()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access result body for this operation (index %d)", operationIndex)
+			}
+			result, ok := resultBody.GetManageSellOfferResult()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access ManageSellOffer result info for this operation (index %d)", operationIndex)
+			}
+			success, ok := result.GetSuccess()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access ManageSellOffer success result for this operation (index %d)", operationIndex)
+			}
+			err = addOfferResultToOperationDetails(&outputDetails, success, op.OfferId, op.Amount)
+			if err != nil {
+				return Details{}, err
+			}
+		}
+
 	case xdr.OperationTypeCreatePassiveSellOffer:
 		op, ok := operation.Body.GetCreatePassiveSellOfferOp()
 		if !ok {
@@ -303,6 +396,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		}
 
 		outputDetails.Amount = utils.ConvertStroopValueToReal(op.Amount)
+		outputDetails.AmountStroops = int64(op.Amount)
 		parsedPrice, err := strconv.ParseFloat(op.Price.String(), 64)
 		if err != nil {
 			return Details{}, err
@@ -316,6 +410,25 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		addAssetDetailsToOperationDetails(&outputDetails, op.Buying, "buying")
 		addAssetDetailsToOperationDetails(&outputDetails, op.Selling, "selling")
 
+		if transaction.Result.Successful() {
+			resultBody, ok := currentOperationResult.GetTr()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access result body for this operation (index %d)", operationIndex)
+			}
+			result, ok := resultBody.GetCreatePassiveSellOfferResult()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access CreatePassiveSellOffer result info for this operation (index %d)", operationIndex)
+			}
+			success, ok := result.GetSuccess()
+			if !ok {
+				return Details{}, fmt.Errorf("Could not access CreatePassiveSellOffer success result for this operation (index %d)", operationIndex)
+			}
+			err = addOfferResultToOperationDetails(&outputDetails, success, 0, op.Amount)
+			if err != nil {
+				return Details{}, err
+			}
+		}
+
 	case xdr.OperationTypeSetOptions:
 		op, ok := operation.Body.GetSetOptionsOp()
 		if !ok {
@@ -369,6 +482,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		outputDetails.Trustor = sourceAccountAddress
 		outputDetails.Trustee = outputDetails.AssetIssuer
 		outputDetails.Limit = utils.ConvertStroopValueToReal(op.Limit)
+		outputDetails.LimitStroops = int64(op.Limit)
 
 	case xdr.OperationTypeAllowTrust:
 		op, ok := operation.Body.GetAllowTrustOp()
@@ -419,7 +533,7 @@ func extractOperationDetails(operation xdr.Operation, transaction ingestio.Ledge
 		outputDetails.BumpTo = fmt.Sprintf("%d", op.BumpTo)
 
 	default:
-		return Details{}, fmt.Errorf("Unknown operation type: %s", operation.Body.Type.String())
+		return Details{}, categorize(UnsupportedType, fmt.Errorf("Unknown operation type: %s", operation.Body.Type.String()))
 	}
 
 	ensureSlicesAreNotNil(&outputDetails)
@@ -43,12 +43,12 @@ func TestTransformOperation(t *testing.T) {
 		{
 			negativeOpTypeInput,
 			OperationOutput{},
-			fmt.Errorf("The operation type (-1) is negative for  operation 1 (operation id=4097)"),
+			&TransformError{Category: InvalidData, Dataset: OperationsDataset, TransactionIndex: 1, OperationIndex: 1, EntryKey: "4097", Err: fmt.Errorf("The operation type (-1) is negative for  operation 1 (operation id=4097)")},
 		},
 		{
 			unknownOpTypeInput,
 			OperationOutput{},
-			fmt.Errorf("Unknown operation type: "),
+			&TransformError{Category: UnsupportedType, Dataset: OperationsDataset, TransactionIndex: 1, OperationIndex: 1, EntryKey: "4097", Err: fmt.Errorf("Unknown operation type: ")},
 		},
 	}
 	hardCodedInputTransaction, err := makeOperationTestInput()
@@ -64,12 +64,65 @@ func TestTransformOperation(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		actualOutput, actualError := TransformOperation(test.input.operation, test.input.index, test.input.transaction, 0)
+		actualOutput, actualError := TransformOperation(test.input.operation, test.input.index, test.input.transaction, 0, genericCloseTime)
 		assert.Equal(t, test.wantErr, actualError)
 		assert.Equal(t, test.wantOutput, actualOutput)
 	}
 }
 
+func TestAddOfferResultToOperationDetails(t *testing.T) {
+	type functionInput struct {
+		result           xdr.ManageOfferSuccessResult
+		requestedOfferID xdr.Int64
+		requestedAmount  xdr.Int64
+	}
+	type functionOutput struct {
+		details Details
+		err     error
+	}
+
+	withEffect := func(effect xdr.ManageOfferEffect, offer *xdr.OfferEntry) xdr.ManageOfferSuccessResult {
+		return xdr.ManageOfferSuccessResult{Offer: xdr.ManageOfferSuccessResultOffer{Effect: effect, Offer: offer}}
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{withEffect(xdr.ManageOfferEffectManageOfferCreated, &xdr.OfferEntry{OfferId: 7, Amount: 25000000}), 0, 30000000},
+			functionOutput{Details{OfferEffect: "created", ResultOfferID: 7, RemainingAmount: 2.5}, nil},
+		},
+		{
+			functionInput{withEffect(xdr.ManageOfferEffectManageOfferUpdated, &xdr.OfferEntry{OfferId: 7, Amount: 10000000}), 7, 10000000},
+			functionOutput{Details{OfferEffect: "updated", ResultOfferID: 7, RemainingAmount: 1}, nil},
+		},
+		{
+			functionInput{withEffect(xdr.ManageOfferEffectManageOfferDeleted, nil), 7, 0},
+			functionOutput{Details{OfferEffect: "deleted", ResultOfferID: 7}, nil},
+		},
+		{
+			functionInput{withEffect(xdr.ManageOfferEffectManageOfferDeleted, nil), 7, 10000000},
+			functionOutput{Details{OfferEffect: "fully_filled", ResultOfferID: 7}, nil},
+		},
+		{
+			functionInput{withEffect(xdr.ManageOfferEffectManageOfferCreated, nil), 0, 10000000},
+			functionOutput{Details{}, fmt.Errorf("Could not access the offer in the result of the offer operation")},
+		},
+		{
+			functionInput{withEffect(xdr.ManageOfferEffect(3), nil), 0, 10000000},
+			functionOutput{Details{}, fmt.Errorf("Unknown offer effect: 3")},
+		},
+	}
+
+	for _, test := range tests {
+		actualDetails := Details{}
+		actualError := addOfferResultToOperationDetails(&actualDetails, test.input.result, test.input.requestedOfferID, test.input.requestedAmount)
+		assert.Equal(t, test.output.err, actualError)
+		assert.Equal(t, test.output.details, actualDetails)
+	}
+}
+
 // Creates a single transaction that contains one of every operation type
 func makeOperationTestInput() (inputTransaction ingestio.LedgerTransaction, err error) {
 	inputTransaction = genericLedgerTransaction
@@ -313,6 +366,34 @@ func makeOperationTestInput() (inputTransaction ingestio.LedgerTransaction, err
 				},
 			},
 		},
+		// The new sell offer is partly filled, the passive offer is fully filled, and the buy offer is updated
+		xdr.OperationResult{
+			Code: xdr.OperationResultCodeOpInner,
+			Tr: &xdr.OperationResultTr{
+				Type: xdr.OperationTypeManageSellOffer,
+				ManageSellOfferResult: &xdr.ManageSellOfferResult{
+					Code: xdr.ManageSellOfferResultCodeManageSellOfferSuccess,
+					Success: &xdr.ManageOfferSuccessResult{
+						Offer: xdr.ManageOfferSuccessResultOffer{
+							Effect: xdr.ManageOfferEffectManageOfferCreated,
+							Offer:  &xdr.OfferEntry{OfferId: 260678439, Amount: 500000000},
+						},
+					},
+				},
+			},
+		},
+		xdr.OperationResult{
+			Code: xdr.OperationResultCodeOpInner,
+			Tr: &xdr.OperationResultTr{
+				Type: xdr.OperationTypeCreatePassiveSellOffer,
+				CreatePassiveSellOfferResult: &xdr.ManageSellOfferResult{
+					Code: xdr.ManageSellOfferResultCodeManageSellOfferSuccess,
+					Success: &xdr.ManageOfferSuccessResult{
+						Offer: xdr.ManageOfferSuccessResultOffer{Effect: xdr.ManageOfferEffectManageOfferDeleted},
+					},
+				},
+			},
+		},
 		xdr.OperationResult{},
 		xdr.OperationResult{},
 		xdr.OperationResult{},
@@ -320,9 +401,21 @@ func makeOperationTestInput() (inputTransaction ingestio.LedgerTransaction, err
 		xdr.OperationResult{},
 		xdr.OperationResult{},
 		xdr.OperationResult{},
-		xdr.OperationResult{},
-		xdr.OperationResult{},
-		xdr.OperationResult{},
+		xdr.OperationResult{
+			Code: xdr.OperationResultCodeOpInner,
+			Tr: &xdr.OperationResultTr{
+				Type: xdr.OperationTypeManageBuyOffer,
+				ManageBuyOfferResult: &xdr.ManageBuyOfferResult{
+					Code: xdr.ManageBuyOfferResultCodeManageBuyOfferSuccess,
+					Success: &xdr.ManageOfferSuccessResult{
+						Offer: xdr.ManageOfferSuccessResultOffer{
+							Effect: xdr.ManageOfferEffectManageOfferUpdated,
+							Offer:  &xdr.OfferEntry{OfferId: 100, Amount: 2783102120},
+						},
+					},
+				},
+			},
+		},
 		xdr.OperationResult{
 			Code: xdr.OperationResultCodeOpInner,
 			Tr: &xdr.OperationResultTr{
@@ -364,14 +457,15 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4096,
 			OperationDetails: Details{
-				Account:          hardCodedDestAccountAddress,
-				Funder:           hardCodedSourceAccountAddress,
-				StartingBalance:  2.5,
-				Path:             []AssetOutput{},
-				ClearFlags:       []int32{},
-				ClearFlagsString: []string{},
-				SetFlags:         []int32{},
-				SetFlagsString:   []string{},
+				Account:                hardCodedDestAccountAddress,
+				Funder:                 hardCodedSourceAccountAddress,
+				StartingBalance:        2.5,
+				StartingBalanceStroops: 25000000,
+				Path:                   []AssetOutput{},
+				ClearFlags:             []int32{},
+				ClearFlagsString:       []string{},
+				SetFlags:               []int32{},
+				SetFlagsString:         []string{},
 			},
 		},
 		OperationOutput{
@@ -384,6 +478,7 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 				From:             hardCodedSourceAccountAddress,
 				To:               hardCodedDestAccountAddress,
 				Amount:           35,
+				AmountStroops:    350000000,
 				AssetCode:        "USDT",
 				AssetType:        "credit_alphanum4",
 				AssetIssuer:      hardCodedDestAccountAddress,
@@ -404,6 +499,7 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 				From:             hardCodedSourceAccountAddress,
 				To:               hardCodedDestAccountAddress,
 				Amount:           35,
+				AmountStroops:    350000000,
 				AssetType:        "native",
 				Path:             []AssetOutput{},
 				ClearFlags:       []int32{},
@@ -419,18 +515,21 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4099,
 			OperationDetails: Details{
-				From:             hardCodedSourceAccountAddress,
-				To:               hardCodedDestAccountAddress,
-				SourceAmount:     894.6764349,
-				SourceMax:        895.14959,
-				Amount:           895.14959,
-				SourceAssetType:  "native",
-				AssetType:        "native",
-				Path:             []AssetOutput{usdtAssetOutput},
-				ClearFlags:       []int32{},
-				ClearFlagsString: []string{},
-				SetFlags:         []int32{},
-				SetFlagsString:   []string{},
+				From:                hardCodedSourceAccountAddress,
+				To:                  hardCodedDestAccountAddress,
+				SourceAmount:        894.6764349,
+				SourceAmountStroops: 8946764349,
+				SourceMax:           895.14959,
+				SourceMaxStroops:    8951495900,
+				Amount:              895.14959,
+				AmountStroops:       8951495900,
+				SourceAssetType:     "native",
+				AssetType:           "native",
+				Path:                []AssetOutput{usdtAssetOutput},
+				ClearFlags:          []int32{},
+				ClearFlagsString:    []string{},
+				SetFlags:            []int32{},
+				SetFlagsString:      []string{},
 			},
 		},
 		OperationOutput{
@@ -440,8 +539,9 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4100,
 			OperationDetails: Details{
-				Price:  0.514092,
-				Amount: 76.586,
+				Price:         0.514092,
+				Amount:        76.586,
+				AmountStroops: 765860000,
 				PriceR: Price{
 					Numerator:   128523,
 					Denominator: 250000,
@@ -450,6 +550,9 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 				SellingAssetType:   "credit_alphanum4",
 				SellingAssetIssuer: hardCodedDestAccountAddress,
 				BuyingAssetType:    "native",
+				OfferEffect:        "created",
+				ResultOfferID:      260678439,
+				RemainingAmount:    50,
 				Path:               []AssetOutput{},
 				ClearFlags:         []int32{},
 				ClearFlagsString:   []string{},
@@ -464,8 +567,9 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4101,
 			OperationDetails: Details{
-				Amount: 63.1595,
-				Price:  0.0791606,
+				Amount:        63.1595,
+				AmountStroops: 631595000,
+				Price:         0.0791606,
 				PriceR: Price{
 					Numerator:   99583200,
 					Denominator: 1257990000,
@@ -474,6 +578,7 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 				BuyingAssetType:   "credit_alphanum4",
 				BuyingAssetIssuer: hardCodedDestAccountAddress,
 				SellingAssetType:  "native",
+				OfferEffect:       "fully_filled",
 				Path:              []AssetOutput{},
 				ClearFlags:        []int32{},
 				ClearFlagsString:  []string{},
@@ -513,6 +618,7 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 				Trustor:          hardCodedSourceAccountAddress,
 				Trustee:          hardCodedDestAccountAddress,
 				Limit:            50000000000,
+				LimitStroops:     500000000000000000,
 				AssetCode:        "USDT",
 				AssetType:        "credit_alphanum4",
 				AssetIssuer:      hardCodedDestAccountAddress,
@@ -612,8 +718,9 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4109,
 			OperationDetails: Details{
-				Price:  0.3496823,
-				Amount: 765.4501001,
+				Price:         0.3496823,
+				Amount:        765.4501001,
+				AmountStroops: 7654501001,
 				PriceR: Price{
 					Numerator:   635863285,
 					Denominator: 1818402817,
@@ -623,6 +730,9 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 				SellingAssetIssuer: hardCodedDestAccountAddress,
 				BuyingAssetType:    "native",
 				OfferID:            100,
+				OfferEffect:        "updated",
+				ResultOfferID:      100,
+				RemainingAmount:    278.310212,
 				Path:               []AssetOutput{},
 				ClearFlags:         []int32{},
 				ClearFlagsString:   []string{},
@@ -637,18 +747,20 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4110,
 			OperationDetails: Details{
-				From:             hardCodedSourceAccountAddress,
-				To:               hardCodedDestAccountAddress,
-				SourceAmount:     0.1598182,
-				DestinationMin:   "428.0460538",
-				Amount:           433.4043858,
-				Path:             []AssetOutput{usdtAssetOutput},
-				SourceAssetType:  "native",
-				AssetType:        "native",
-				ClearFlags:       []int32{},
-				ClearFlagsString: []string{},
-				SetFlags:         []int32{},
-				SetFlagsString:   []string{},
+				From:                hardCodedSourceAccountAddress,
+				To:                  hardCodedDestAccountAddress,
+				SourceAmount:        0.1598182,
+				SourceAmountStroops: 1598182,
+				DestinationMin:      "428.0460538",
+				Amount:              433.4043858,
+				AmountStroops:       4334043858,
+				Path:                []AssetOutput{usdtAssetOutput},
+				SourceAssetType:     "native",
+				AssetType:           "native",
+				ClearFlags:          []int32{},
+				ClearFlagsString:    []string{},
+				SetFlags:            []int32{},
+				SetFlagsString:      []string{},
 			},
 		},
 		OperationOutput{
@@ -658,20 +770,29 @@ func makeOperationTestOutputs() (transformedOperations []OperationOutput) {
 			TransactionID:    4096,
 			OperationID:      4111,
 			OperationDetails: Details{
-				From:             hardCodedSourceAccountAddress,
-				To:               hardCodedDestAccountAddress,
-				SourceAmount:     0.1598182,
-				DestinationMin:   "428.0460538",
-				Amount:           428.0460538,
-				SourceAssetType:  "native",
-				AssetType:        "native",
-				Path:             []AssetOutput{},
-				ClearFlags:       []int32{},
-				ClearFlagsString: []string{},
-				SetFlags:         []int32{},
-				SetFlagsString:   []string{},
+				From:                hardCodedSourceAccountAddress,
+				To:                  hardCodedDestAccountAddress,
+				SourceAmount:        0.1598182,
+				SourceAmountStroops: 1598182,
+				DestinationMin:      "428.0460538",
+				Amount:              428.0460538,
+				AmountStroops:       4280460538,
+				SourceAssetType:     "native",
+				AssetType:           "native",
+				Path:                []AssetOutput{},
+				ClearFlags:          []int32{},
+				ClearFlagsString:    []string{},
+				SetFlags:            []int32{},
+				SetFlagsString:      []string{},
 			},
 		},
 	}
+
+	// The test transaction is successful, and its result has an empty hash
+	for i := range transformedOperations {
+		transformedOperations[i].TransactionHash = "0000000000000000000000000000000000000000000000000000000000000000"
+		transformedOperations[i].TransactionSuccessful = true
+		transformedOperations[i].LedgerClosedAt = genericCloseTime
+	}
 	return
 }
@@ -46,6 +46,15 @@ type TransactionOutput struct {
 	TimeBounds       string    `json:"time_bounds"`
 	Successful       bool      `json:"successful"`
 	TransactionID    int64     `json:"id"`
+	// The XDR, the signatures, and the fee account of the transaction are not columns of history_transactions, but Horizon shows them with each
+	// transaction. The fee bump fields are only set for fee bump transactions, whose other fields describe the inner transaction
+	FeeAccount           string   `json:"-"`
+	EnvelopeXDR          string   `json:"-"`
+	ResultXDR            string   `json:"-"`
+	Signatures           []string `json:"-"`
+	FeeBumpFee           int64    `json:"-"`
+	FeeBumpSignatures    []string `json:"-"`
+	InnerTransactionHash string   `json:"-"`
 
 	/*
 		TODO implement
@@ -72,6 +81,34 @@ type AccountOutput struct {
 	Deleted              bool   `json:"deleted"`
 }
 
+// AccountCompositeOutput is an account with the breakdown of its subentries and its reserve in stroops, as exported with the composite flag of export_accounts
+type AccountCompositeOutput struct {
+	AccountID            string `json:"account_id"`
+	Balance              int64  `json:"balance"`
+	BuyingLiabilities    int64  `json:"buying_liabilities"`
+	SellingLiabilities   int64  `json:"selling_liabilities"`
+	SequenceNumber       int64  `json:"sequence_number"`
+	NumSubentries        uint32 `json:"num_subentries"`
+	InflationDestination string `json:"inflation_destination"`
+	Flags                uint32 `json:"flags"`
+	HomeDomain           string `json:"home_domain"`
+	MasterWeight         int32  `json:"master_weight"`
+	ThresholdLow         int32  `json:"threshold_low"`
+	ThresholdMedium      int32  `json:"threshold_medium"`
+	ThresholdHigh        int32  `json:"threshold_high"`
+	LastModifiedLedger   uint32 `json:"last_modified_ledger"`
+	Deleted              bool   `json:"deleted"`
+	NumTrustlines        uint32 `json:"num_trustlines"`
+	NumOffers            uint32 `json:"num_offers"`
+	NumDataEntries       uint32 `json:"num_data_entries"`
+	NumSigners           uint32 `json:"num_signers"`
+	NumSponsored         uint32 `json:"num_sponsored"`
+	NumSponsoring        uint32 `json:"num_sponsoring"`
+	BaseReserve          uint32 `json:"base_reserve"`
+	TotalReserve         int64  `json:"total_reserve"`
+	AvailableBalance     int64  `json:"available_balance"`
+}
+
 // OperationOutput is a representation of an operation that aligns with the BigQuery table history_operations
 type OperationOutput struct {
 	SourceAccount    string  `json:"source_account"`
@@ -80,6 +117,10 @@ type OperationOutput struct {
 	OperationDetails Details `json:"details"`
 	TransactionID    int64   `json:"transaction_id"`
 	OperationID      int64   `json:"id"`
+	// The hash and the result of the transaction and the close time of the ledger are not columns of history_operations, but Horizon shows them with each operation
+	TransactionHash       string    `json:"-"`
+	TransactionSuccessful bool      `json:"-"`
+	LedgerClosedAt        time.Time `json:"-"`
 }
 
 // Details is a struct that provides additional information about operations in a way that aligns with the details struct in the BigQuery table history_operations
@@ -129,6 +170,15 @@ type Details struct {
 	ClearFlagsString   []string      `json:"clear_flags_s"`
 	DestinationMin     string        `json:"destination_min"`
 	BumpTo             string        `json:"bump_to"`
+	OfferEffect        string        `json:"offer_effect"`     // created, updated, deleted, or fully_filled for successful offer operations
+	ResultOfferID      int64         `json:"result_offer_id"`  // ID of the offer that the operation created, updated, or deleted
+	RemainingAmount    float64       `json:"remaining_amount"` // amount of the selling asset that is left in the offer after the operation
+	// The amounts in stroops are not columns of history_operations, but Horizon formats the amounts from them, since floats lose precision
+	AmountStroops          int64 `json:"-"`
+	LimitStroops           int64 `json:"-"`
+	SourceAmountStroops    int64 `json:"-"`
+	SourceMaxStroops       int64 `json:"-"`
+	StartingBalanceStroops int64 `json:"-"`
 }
 
 // Price represents the price of an asset as a fraction
@@ -198,6 +248,27 @@ type TradeOutput struct {
 	HistoryOperationID    int64     `json:"history_operation_id"`
 }
 
+// DepositOutput is a payment, path payment, or claimable balance claim that credits one of the deposit accounts of export_deposits
+type DepositOutput struct {
+	Account         string    `json:"account"`          // deposit account that was credited
+	AccountMuxedID  string    `json:"account_muxed_id"` // ID of the muxed account that was credited, in decimal, if the deposit was made to one
+	From            string    `json:"from"`             // sender of the payment, or the sponsor of the claimed balance, if it has one
+	OperationType   string    `json:"operation_type"`
+	Amount          float64   `json:"amount"`
+	AssetCode       string    `json:"asset_code"`
+	AssetIssuer     string    `json:"asset_issuer"`
+	AssetType       string    `json:"asset_type"`
+	BalanceID       string    `json:"balance_id"` // hex encoding of the ID of the claimed balance
+	Incomplete      bool      `json:"incomplete"` // set for claims whose transaction meta is missing or does not remove the claimed balance, so that their amount, asset, and sender are unknown
+	MemoType        string    `json:"memo_type"`
+	Memo            string    `json:"memo"`        // hash and return memos are written in hex
+	MemoStatus      string    `json:"memo_status"` // matched, unmatched, missing, malformed, or unchecked
+	TransactionHash string    `json:"transaction_hash"`
+	LedgerSequence  uint32    `json:"ledger_sequence"`
+	ClosedAt        time.Time `json:"closed_at"`
+	OperationID     int64     `json:"operation_id"`
+}
+
 //DimAccount is a representation of an account that aligns with the BigQuery table dim_accounts
 type DimAccount struct {
 	ID      uint64 `json:"account_id"`
@@ -238,3 +309,20 @@ type NormalizedOfferOutput struct {
 	Account DimAccount
 	Event   FactOfferEvent
 }
+
+// ChangeSource records where in the ledgers a change to a ledger entry was made. The transaction hash is empty for changes that were not made
+// by a transaction, like the changes of protocol upgrades
+type ChangeSource struct {
+	LedgerSequence  uint32    `json:"ledger_sequence"`
+	ClosedAt        time.Time `json:"closed_at"`
+	TransactionHash string    `json:"transaction_hash"`
+}
+
+// ChangeEnvelope is a change to a ledger entry in the envelope of a Debezium change event. Before and after hold the output rows of the entry
+type ChangeEnvelope struct {
+	Before interface{}  `json:"before"`
+	After  interface{}  `json:"after"`
+	Op     string       `json:"op"`
+	Source ChangeSource `json:"source"`
+	TsMs   int64        `json:"ts_ms"`
+}
@@ -0,0 +1,660 @@
+// Code generated by jsongen. DO NOT EDIT.
+
+package transform
+
+import "strconv"
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o LedgerOutput) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"sequence\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.Sequence), 10)
+	dst = append(dst, ",\"ledger_hash\":"...)
+	dst = appendJSONString(dst, string(o.LedgerHash))
+	dst = append(dst, ",\"previous_ledger_hash\":"...)
+	dst = appendJSONString(dst, string(o.PreviousLedgerHash))
+	dst = append(dst, ",\"ledger_header\":"...)
+	dst = appendJSONString(dst, string(o.LedgerHeader))
+	dst = append(dst, ",\"transaction_count\":"...)
+	dst = strconv.AppendInt(dst, int64(o.TransactionCount), 10)
+	dst = append(dst, ",\"operation_count\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OperationCount), 10)
+	dst = append(dst, ",\"successful_transaction_count\":"...)
+	dst = strconv.AppendInt(dst, int64(o.SuccessfulTransactionCount), 10)
+	dst = append(dst, ",\"failed_transaction_count\":"...)
+	dst = strconv.AppendInt(dst, int64(o.FailedTransactionCount), 10)
+	dst = append(dst, ",\"tx_set_operation_count\":"...)
+	dst = appendJSONString(dst, string(o.TxSetOperationCount))
+	dst = append(dst, ",\"closed_at\":"...)
+	dst, err = appendJSONTime(dst, o.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"total_coins\":"...)
+	dst = strconv.AppendInt(dst, int64(o.TotalCoins), 10)
+	dst = append(dst, ",\"fee_pool\":"...)
+	dst = strconv.AppendInt(dst, int64(o.FeePool), 10)
+	dst = append(dst, ",\"base_fee\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.BaseFee), 10)
+	dst = append(dst, ",\"base_reserve\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.BaseReserve), 10)
+	dst = append(dst, ",\"max_tx_set_size\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.MaxTxSetSize), 10)
+	dst = append(dst, ",\"protocol_version\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.ProtocolVersion), 10)
+	dst = append(dst, ",\"id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.LedgerID), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o TransactionOutput) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"transaction_hash\":"...)
+	dst = appendJSONString(dst, string(o.TransactionHash))
+	dst = append(dst, ",\"ledger_sequence\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LedgerSequence), 10)
+	dst = append(dst, ",\"application_order\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.ApplicationOrder), 10)
+	dst = append(dst, ",\"account\":"...)
+	dst = appendJSONString(dst, string(o.Account))
+	dst = append(dst, ",\"account_sequence\":"...)
+	dst = strconv.AppendInt(dst, int64(o.AccountSequence), 10)
+	dst = append(dst, ",\"max_fee\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.MaxFee), 10)
+	dst = append(dst, ",\"fee_charged\":"...)
+	dst = strconv.AppendInt(dst, int64(o.FeeCharged), 10)
+	dst = append(dst, ",\"operation_count\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OperationCount), 10)
+	dst = append(dst, ",\"created_at\":"...)
+	dst, err = appendJSONTime(dst, o.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"memo_type\":"...)
+	dst = appendJSONString(dst, string(o.MemoType))
+	dst = append(dst, ",\"memo\":"...)
+	dst = appendJSONString(dst, string(o.Memo))
+	dst = append(dst, ",\"time_bounds\":"...)
+	dst = appendJSONString(dst, string(o.TimeBounds))
+	dst = append(dst, ",\"successful\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Successful))
+	dst = append(dst, ",\"id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.TransactionID), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o OperationOutput) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"source_account\":"...)
+	dst = appendJSONString(dst, string(o.SourceAccount))
+	dst = append(dst, ",\"type\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Type), 10)
+	dst = append(dst, ",\"application_order\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ApplicationOrder), 10)
+	dst = append(dst, ",\"details\":"...)
+	dst, err = o.OperationDetails.AppendJSON(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"transaction_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.TransactionID), 10)
+	dst = append(dst, ",\"id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OperationID), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o TradeOutput) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"order\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Order), 10)
+	dst = append(dst, ",\"ledger_closed_at\":"...)
+	dst, err = appendJSONTime(dst, o.LedgerClosedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OfferID), 10)
+	dst = append(dst, ",\"base_account_address\":"...)
+	dst = appendJSONString(dst, string(o.BaseAccountAddress))
+	dst = append(dst, ",\"base_asset_code\":"...)
+	dst = appendJSONString(dst, string(o.BaseAssetCode))
+	dst = append(dst, ",\"base_asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.BaseAssetIssuer))
+	dst = append(dst, ",\"base_asset_type\":"...)
+	dst = appendJSONString(dst, string(o.BaseAssetType))
+	dst = append(dst, ",\"base_amount\":"...)
+	dst = strconv.AppendInt(dst, int64(o.BaseAmount), 10)
+	dst = append(dst, ",\"counter_account_address\":"...)
+	dst = appendJSONString(dst, string(o.CounterAccountAddress))
+	dst = append(dst, ",\"counter_asset_code\":"...)
+	dst = appendJSONString(dst, string(o.CounterAssetCode))
+	dst = append(dst, ",\"counter_asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.CounterAssetIssuer))
+	dst = append(dst, ",\"counter_asset_type\":"...)
+	dst = appendJSONString(dst, string(o.CounterAssetType))
+	dst = append(dst, ",\"counter_amount\":"...)
+	dst = strconv.AppendInt(dst, int64(o.CounterAmount), 10)
+	dst = append(dst, ",\"base_is_seller\":"...)
+	dst = strconv.AppendBool(dst, bool(o.BaseIsSeller))
+	dst = append(dst, ",\"price_n\":"...)
+	dst = strconv.AppendInt(dst, int64(o.PriceN), 10)
+	dst = append(dst, ",\"price_d\":"...)
+	dst = strconv.AppendInt(dst, int64(o.PriceD), 10)
+	dst = append(dst, ",\"base_offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.BaseOfferID), 10)
+	dst = append(dst, ",\"counter_offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.CounterOfferID), 10)
+	dst = append(dst, ",\"history_operation_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.HistoryOperationID), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o DepositOutput) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"account\":"...)
+	dst = appendJSONString(dst, string(o.Account))
+	dst = append(dst, ",\"account_muxed_id\":"...)
+	dst = appendJSONString(dst, string(o.AccountMuxedID))
+	dst = append(dst, ",\"from\":"...)
+	dst = appendJSONString(dst, string(o.From))
+	dst = append(dst, ",\"operation_type\":"...)
+	dst = appendJSONString(dst, string(o.OperationType))
+	dst = append(dst, ",\"amount\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.Amount), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"asset_code\":"...)
+	dst = appendJSONString(dst, string(o.AssetCode))
+	dst = append(dst, ",\"asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.AssetIssuer))
+	dst = append(dst, ",\"asset_type\":"...)
+	dst = appendJSONString(dst, string(o.AssetType))
+	dst = append(dst, ",\"balance_id\":"...)
+	dst = appendJSONString(dst, string(o.BalanceID))
+	dst = append(dst, ",\"incomplete\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Incomplete))
+	dst = append(dst, ",\"memo_type\":"...)
+	dst = appendJSONString(dst, string(o.MemoType))
+	dst = append(dst, ",\"memo\":"...)
+	dst = appendJSONString(dst, string(o.Memo))
+	dst = append(dst, ",\"memo_status\":"...)
+	dst = appendJSONString(dst, string(o.MemoStatus))
+	dst = append(dst, ",\"transaction_hash\":"...)
+	dst = appendJSONString(dst, string(o.TransactionHash))
+	dst = append(dst, ",\"ledger_sequence\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LedgerSequence), 10)
+	dst = append(dst, ",\"closed_at\":"...)
+	dst, err = appendJSONTime(dst, o.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"operation_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OperationID), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o AccountOutput) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"account_id\":"...)
+	dst = appendJSONString(dst, string(o.AccountID))
+	dst = append(dst, ",\"balance\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Balance), 10)
+	dst = append(dst, ",\"buying_liabilities\":"...)
+	dst = strconv.AppendInt(dst, int64(o.BuyingLiabilities), 10)
+	dst = append(dst, ",\"selling_liabilities\":"...)
+	dst = strconv.AppendInt(dst, int64(o.SellingLiabilities), 10)
+	dst = append(dst, ",\"sequence_number\":"...)
+	dst = strconv.AppendInt(dst, int64(o.SequenceNumber), 10)
+	dst = append(dst, ",\"num_subentries\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumSubentries), 10)
+	dst = append(dst, ",\"inflation_destination\":"...)
+	dst = appendJSONString(dst, string(o.InflationDestination))
+	dst = append(dst, ",\"flags\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.Flags), 10)
+	dst = append(dst, ",\"home_domain\":"...)
+	dst = appendJSONString(dst, string(o.HomeDomain))
+	dst = append(dst, ",\"master_weight\":"...)
+	dst = strconv.AppendInt(dst, int64(o.MasterWeight), 10)
+	dst = append(dst, ",\"threshold_low\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ThresholdLow), 10)
+	dst = append(dst, ",\"threshold_medium\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ThresholdMedium), 10)
+	dst = append(dst, ",\"threshold_high\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ThresholdHigh), 10)
+	dst = append(dst, ",\"last_modified_ledger\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LastModifiedLedger), 10)
+	dst = append(dst, ",\"deleted\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Deleted))
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o AccountCompositeOutput) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"account_id\":"...)
+	dst = appendJSONString(dst, string(o.AccountID))
+	dst = append(dst, ",\"balance\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Balance), 10)
+	dst = append(dst, ",\"buying_liabilities\":"...)
+	dst = strconv.AppendInt(dst, int64(o.BuyingLiabilities), 10)
+	dst = append(dst, ",\"selling_liabilities\":"...)
+	dst = strconv.AppendInt(dst, int64(o.SellingLiabilities), 10)
+	dst = append(dst, ",\"sequence_number\":"...)
+	dst = strconv.AppendInt(dst, int64(o.SequenceNumber), 10)
+	dst = append(dst, ",\"num_subentries\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumSubentries), 10)
+	dst = append(dst, ",\"inflation_destination\":"...)
+	dst = appendJSONString(dst, string(o.InflationDestination))
+	dst = append(dst, ",\"flags\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.Flags), 10)
+	dst = append(dst, ",\"home_domain\":"...)
+	dst = appendJSONString(dst, string(o.HomeDomain))
+	dst = append(dst, ",\"master_weight\":"...)
+	dst = strconv.AppendInt(dst, int64(o.MasterWeight), 10)
+	dst = append(dst, ",\"threshold_low\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ThresholdLow), 10)
+	dst = append(dst, ",\"threshold_medium\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ThresholdMedium), 10)
+	dst = append(dst, ",\"threshold_high\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ThresholdHigh), 10)
+	dst = append(dst, ",\"last_modified_ledger\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LastModifiedLedger), 10)
+	dst = append(dst, ",\"deleted\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Deleted))
+	dst = append(dst, ",\"num_trustlines\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumTrustlines), 10)
+	dst = append(dst, ",\"num_offers\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumOffers), 10)
+	dst = append(dst, ",\"num_data_entries\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumDataEntries), 10)
+	dst = append(dst, ",\"num_signers\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumSigners), 10)
+	dst = append(dst, ",\"num_sponsored\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumSponsored), 10)
+	dst = append(dst, ",\"num_sponsoring\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.NumSponsoring), 10)
+	dst = append(dst, ",\"base_reserve\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.BaseReserve), 10)
+	dst = append(dst, ",\"total_reserve\":"...)
+	dst = strconv.AppendInt(dst, int64(o.TotalReserve), 10)
+	dst = append(dst, ",\"available_balance\":"...)
+	dst = strconv.AppendInt(dst, int64(o.AvailableBalance), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o OfferOutput) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"seller_id\":"...)
+	dst = appendJSONString(dst, string(o.SellerID))
+	dst = append(dst, ",\"offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OfferID), 10)
+	dst = append(dst, ",\"selling_asset\":"...)
+	dst = appendJSONString(dst, string(o.SellingAsset))
+	dst = append(dst, ",\"buying_asset\":"...)
+	dst = appendJSONString(dst, string(o.BuyingAsset))
+	dst = append(dst, ",\"amount\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Amount), 10)
+	dst = append(dst, ",\"pricen\":"...)
+	dst = strconv.AppendInt(dst, int64(o.PriceN), 10)
+	dst = append(dst, ",\"priced\":"...)
+	dst = strconv.AppendInt(dst, int64(o.PriceD), 10)
+	dst = append(dst, ",\"price\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.Price), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"flags\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.Flags), 10)
+	dst = append(dst, ",\"last_modified_ledger\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LastModifiedLedger), 10)
+	dst = append(dst, ",\"deleted\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Deleted))
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o TrustlineOutput) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"ledger_key\":"...)
+	dst = appendJSONString(dst, string(o.LedgerKey))
+	dst = append(dst, ",\"account_id\":"...)
+	dst = appendJSONString(dst, string(o.AccountID))
+	dst = append(dst, ",\"asset_code\":"...)
+	dst = appendJSONString(dst, string(o.AssetCode))
+	dst = append(dst, ",\"asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.AssetIssuer))
+	dst = append(dst, ",\"asset_type\":"...)
+	dst = strconv.AppendInt(dst, int64(o.AssetType), 10)
+	dst = append(dst, ",\"balance\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Balance), 10)
+	dst = append(dst, ",\"trust_line_limit\":"...)
+	dst = strconv.AppendInt(dst, int64(o.TrustlineLimit), 10)
+	dst = append(dst, ",\"buying_liabilities\":"...)
+	dst = strconv.AppendInt(dst, int64(o.BuyingLiabilities), 10)
+	dst = append(dst, ",\"selling_liabilities\":"...)
+	dst = strconv.AppendInt(dst, int64(o.SellingLiabilities), 10)
+	dst = append(dst, ",\"flags\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.Flags), 10)
+	dst = append(dst, ",\"last_modified_ledger\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LastModifiedLedger), 10)
+	dst = append(dst, ",\"deleted\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Deleted))
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o DimMarket) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"market_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.ID), 10)
+	dst = append(dst, ",\"base_code\":"...)
+	dst = appendJSONString(dst, string(o.BaseCode))
+	dst = append(dst, ",\"base_issuer\":"...)
+	dst = appendJSONString(dst, string(o.BaseIssuer))
+	dst = append(dst, ",\"counter_code\":"...)
+	dst = appendJSONString(dst, string(o.CounterCode))
+	dst = append(dst, ",\"counter_issuer\":"...)
+	dst = appendJSONString(dst, string(o.CounterIssuer))
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o DimOffer) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"horizon_offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.HorizonID), 10)
+	dst = append(dst, ",\"dim_offer_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.DimOfferID), 10)
+	dst = append(dst, ",\"market_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.MarketID), 10)
+	dst = append(dst, ",\"maker_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.MakerID), 10)
+	dst = append(dst, ",\"action\":"...)
+	dst = appendJSONString(dst, string(o.Action))
+	dst = append(dst, ",\"base_amount\":"...)
+	dst = strconv.AppendInt(dst, int64(o.BaseAmount), 10)
+	dst = append(dst, ",\"counter_amount\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.CounterAmount), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"price\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.Price), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o DimAccount) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"account_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.ID), 10)
+	dst = append(dst, ",\"address\":"...)
+	dst = appendJSONString(dst, string(o.Address))
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o FactOfferEvent) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"ledger_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LedgerSeq), 10)
+	dst = append(dst, ",\"offer_instance_id\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.OfferInstanceID), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o Details) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, "{\"account\":"...)
+	dst = appendJSONString(dst, string(o.Account))
+	dst = append(dst, ",\"amount\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.Amount), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"asset_code\":"...)
+	dst = appendJSONString(dst, string(o.AssetCode))
+	dst = append(dst, ",\"asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.AssetIssuer))
+	dst = append(dst, ",\"asset_type\":"...)
+	dst = appendJSONString(dst, string(o.AssetType))
+	dst = append(dst, ",\"authorize\":"...)
+	dst = strconv.AppendBool(dst, bool(o.Authorize))
+	dst = append(dst, ",\"buying_asset_code\":"...)
+	dst = appendJSONString(dst, string(o.BuyingAssetCode))
+	dst = append(dst, ",\"buying_asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.BuyingAssetIssuer))
+	dst = append(dst, ",\"buying_asset_type\":"...)
+	dst = appendJSONString(dst, string(o.BuyingAssetType))
+	dst = append(dst, ",\"from\":"...)
+	dst = appendJSONString(dst, string(o.From))
+	dst = append(dst, ",\"funder\":"...)
+	dst = appendJSONString(dst, string(o.Funder))
+	dst = append(dst, ",\"high_threshold\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.HighThreshold), 10)
+	dst = append(dst, ",\"home_domain\":"...)
+	dst = appendJSONString(dst, string(o.HomeDomain))
+	dst = append(dst, ",\"inflation_dest\":"...)
+	dst = appendJSONString(dst, string(o.InflationDest))
+	dst = append(dst, ",\"into\":"...)
+	dst = appendJSONString(dst, string(o.Into))
+	dst = append(dst, ",\"limit\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.Limit), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"low_threshold\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.LowThreshold), 10)
+	dst = append(dst, ",\"master_key_weight\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.MasterKeyWeight), 10)
+	dst = append(dst, ",\"med_threshold\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.MedThreshold), 10)
+	dst = append(dst, ",\"name\":"...)
+	dst = appendJSONString(dst, string(o.Name))
+	dst = append(dst, ",\"offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.OfferID), 10)
+	dst = append(dst, ",\"path\":"...)
+	if o.Path == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, item0 := range o.Path {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst, err = item0.AppendJSON(dst)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		dst = append(dst, ']')
+	}
+
+	dst = append(dst, ",\"price\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.Price), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"price_r\":"...)
+	dst, err = o.PriceR.AppendJSON(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"selling_asset_code\":"...)
+	dst = appendJSONString(dst, string(o.SellingAssetCode))
+	dst = append(dst, ",\"selling_asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.SellingAssetIssuer))
+	dst = append(dst, ",\"selling_asset_type\":"...)
+	dst = appendJSONString(dst, string(o.SellingAssetType))
+	dst = append(dst, ",\"set_flags\":"...)
+	if o.SetFlags == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, item0 := range o.SetFlags {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst = strconv.AppendInt(dst, int64(item0), 10)
+		}
+
+		dst = append(dst, ']')
+	}
+
+	dst = append(dst, ",\"set_flags_s\":"...)
+	if o.SetFlagsString == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, item0 := range o.SetFlagsString {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst = appendJSONString(dst, string(item0))
+		}
+
+		dst = append(dst, ']')
+	}
+
+	dst = append(dst, ",\"signer_key\":"...)
+	dst = appendJSONString(dst, string(o.SignerKey))
+	dst = append(dst, ",\"signer_weight\":"...)
+	dst = strconv.AppendUint(dst, uint64(o.SignerWeight), 10)
+	dst = append(dst, ",\"source_amount\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.SourceAmount), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"source_asset_code\":"...)
+	dst = appendJSONString(dst, string(o.SourceAssetCode))
+	dst = append(dst, ",\"source_asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.SourceAssetIssuer))
+	dst = append(dst, ",\"source_asset_type\":"...)
+	dst = appendJSONString(dst, string(o.SourceAssetType))
+	dst = append(dst, ",\"source_max\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.SourceMax), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"starting_balance\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.StartingBalance), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, ",\"to\":"...)
+	dst = appendJSONString(dst, string(o.To))
+	dst = append(dst, ",\"trustee\":"...)
+	dst = appendJSONString(dst, string(o.Trustee))
+	dst = append(dst, ",\"trustor\":"...)
+	dst = appendJSONString(dst, string(o.Trustor))
+	dst = append(dst, ",\"value\":"...)
+	dst = appendJSONString(dst, string(o.Value))
+	dst = append(dst, ",\"clear_flags\":"...)
+	if o.ClearFlags == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, item0 := range o.ClearFlags {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst = strconv.AppendInt(dst, int64(item0), 10)
+		}
+
+		dst = append(dst, ']')
+	}
+
+	dst = append(dst, ",\"clear_flags_s\":"...)
+	if o.ClearFlagsString == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, item0 := range o.ClearFlagsString {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+
+			dst = appendJSONString(dst, string(item0))
+		}
+
+		dst = append(dst, ']')
+	}
+
+	dst = append(dst, ",\"destination_min\":"...)
+	dst = appendJSONString(dst, string(o.DestinationMin))
+	dst = append(dst, ",\"bump_to\":"...)
+	dst = appendJSONString(dst, string(o.BumpTo))
+	dst = append(dst, ",\"offer_effect\":"...)
+	dst = appendJSONString(dst, string(o.OfferEffect))
+	dst = append(dst, ",\"result_offer_id\":"...)
+	dst = strconv.AppendInt(dst, int64(o.ResultOfferID), 10)
+	dst = append(dst, ",\"remaining_amount\":"...)
+	dst, err = appendJSONFloat(dst, float64(o.RemainingAmount), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o AssetOutput) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"asset_code\":"...)
+	dst = appendJSONString(dst, string(o.AssetCode))
+	dst = append(dst, ",\"asset_issuer\":"...)
+	dst = appendJSONString(dst, string(o.AssetIssuer))
+	dst = append(dst, ",\"asset_type\":"...)
+	dst = appendJSONString(dst, string(o.AssetType))
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// AppendJSON appends the JSON encoding of o to dst. The encoding is the same as the one from encoding/json
+func (o Price) AppendJSON(dst []byte) ([]byte, error) {
+	dst = append(dst, "{\"n\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Numerator), 10)
+	dst = append(dst, ",\"d\":"...)
+	dst = strconv.AppendInt(dst, int64(o.Denominator), 10)
+	dst = append(dst, '}')
+	return dst, nil
+}
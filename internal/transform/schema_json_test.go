@@ -0,0 +1,139 @@
+package transform
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type jsonAppender interface {
+	AppendJSON(dst []byte) ([]byte, error)
+}
+
+// fillValue sets every field of the value, so that a field that the generated code does not encode changes the output. The variant picks
+// different values, and odd variants leave slices nil
+func fillValue(value reflect.Value, variant int) {
+	if value.Type() == reflect.TypeOf(time.Time{}) {
+		value.Set(reflect.ValueOf(time.Date(2020, 12, 1, 10, 30, 15, 123456789*variant, time.FixedZone("", 3600*variant))))
+		return
+	}
+
+	strings := []string{`plain`, "<html> & \"quotes\" \\ \n\r\t\x01\x1f", "é \u2028 \u2029 end"}
+	floats := []float64{123.25, 1e-7, 1e21, -0.000001, 0}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(strings[variant%len(strings)])
+	case reflect.Bool:
+		value.SetBool(variant%2 == 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(int64(-7 * (variant + 1)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(uint64(7 * (variant + 1)))
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(floats[variant%len(floats)])
+	case reflect.Slice:
+		if variant%2 == 1 {
+			return
+		}
+
+		value.Set(reflect.MakeSlice(value.Type(), 2, 2))
+		for i := 0; i < 2; i++ {
+			fillValue(value.Index(i), variant+i)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).PkgPath == "" {
+				fillValue(value.Field(i), variant+i)
+			}
+		}
+	}
+}
+
+func TestAppendJSON(t *testing.T) {
+	rows := []interface{}{
+		LedgerOutput{}, TransactionOutput{}, OperationOutput{}, TradeOutput{}, AccountOutput{}, AccountCompositeOutput{}, OfferOutput{},
+		TrustlineOutput{}, DimMarket{}, DimOffer{}, DimAccount{}, FactOfferEvent{},
+	}
+
+	for _, row := range rows {
+		for variant := 0; variant < 4; variant++ {
+			value := reflect.New(reflect.TypeOf(row)).Elem()
+			fillValue(value, variant)
+
+			expected, err := json.Marshal(value.Interface())
+			assert.NoError(t, err)
+
+			actual, err := value.Interface().(jsonAppender).AppendJSON([]byte("prefix"))
+			assert.NoError(t, err)
+			assert.Equal(t, "prefix"+string(expected), string(actual), "%T is not encoded like encoding/json does; run go generate", row)
+		}
+
+		// The zero value has nil slices and the zero time
+		expected, err := json.Marshal(row)
+		assert.NoError(t, err)
+		actual, err := row.(jsonAppender).AppendJSON(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	type functionInput struct {
+		value string
+	}
+	type functionOutput struct {
+		encoded string
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{functionInput{"GABC"}, functionOutput{`"GABC"`}},
+		{functionInput{"a<b>&c"}, functionOutput{`"a\u003cb\u003e\u0026c"`}},
+		{functionInput{"\b\f\x7f"}, functionOutput{"\"\\b\\f\x7f\""}},
+		{functionInput{"invalid \xff\xfe utf8"}, functionOutput{"\"invalid \ufffd\ufffd utf8\""}},
+		{functionInput{"\u2028"}, functionOutput{`"\u2028"`}},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.output.encoded, string(appendJSONString(nil, test.input.value)))
+	}
+}
+
+func TestAppendJSONErrors(t *testing.T) {
+	type functionInput struct {
+		row jsonAppender
+	}
+	type functionOutput struct {
+		err string
+	}
+
+	tests := []struct {
+		input  functionInput
+		output functionOutput
+	}{
+		{
+			functionInput{OfferOutput{Price: math.NaN()}},
+			functionOutput{"json: unsupported value: NaN"},
+		},
+		{
+			functionInput{OperationOutput{OperationDetails: Details{Amount: math.Inf(-1)}}},
+			functionOutput{"json: unsupported value: -Inf"},
+		},
+		{
+			functionInput{LedgerOutput{ClosedAt: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			functionOutput{"Time.MarshalJSON: year outside of range [0,9999]"},
+		},
+	}
+
+	for _, test := range tests {
+		actual, err := test.input.row.AppendJSON(nil)
+		assert.Nil(t, actual)
+		assert.EqualError(t, err, test.output.err)
+	}
+}
@@ -2,6 +2,7 @@ package transform
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/stellar/stellar-etl/internal/toid"
@@ -13,22 +14,31 @@ import (
 
 // TransformTrade converts a relevant operation from the history archive ingestion system into a form suitable for BigQuery
 func TransformTrade(operationIndex int32, operationID int64, transaction ingestio.LedgerTransaction, ledgerCloseTime time.Time) ([]TradeOutput, error) {
+	parsedID := toid.Parse(operationID)
+	errorContext := TransformError{
+		Dataset:          TradesDataset,
+		LedgerSequence:   uint32(parsedID.LedgerSequence),
+		TransactionIndex: parsedID.TransactionOrder,
+		OperationIndex:   operationIndex,
+		EntryKey:         strconv.FormatInt(operationID, 10),
+	}
+
 	operationResults, ok := transaction.Result.OperationResults()
 	if !ok {
-		return []TradeOutput{}, fmt.Errorf("Could not get any results from this transaction")
+		return []TradeOutput{}, errorContext.wrap(DecodeFailure, fmt.Errorf("Could not get any results from this transaction"))
 	}
 
 	if !transaction.Result.Successful() {
-		return []TradeOutput{}, fmt.Errorf("Transaction failed; no trades")
+		return []TradeOutput{}, errorContext.wrap(UnsupportedType, fmt.Errorf("Transaction failed; no trades"))
 	}
 
 	operation := transaction.Envelope.Operations()[operationIndex]
 	outputOperationID := operationID
 	claimedOffers, counterOffer, err := extractClaimedOffers(operationResults, operationIndex, operation.Body.Type)
 	if err != nil {
-		return []TradeOutput{}, err
+		return []TradeOutput{}, errorContext.wrap(CategoryOf(err), err)
 	}
-	
+
 	var outputCounterOfferID int64
 	if counterOffer != nil {
 		outputCounterOfferID = int64(counterOffer.OfferId)
@@ -45,43 +55,43 @@ func TransformTrade(operationIndex int32, operationID int64, transaction ingesti
 
 		outputOfferID := int64(claimOffer.OfferId)
 		if outputOfferID < 0 {
-			return []TradeOutput{}, fmt.Errorf("Offer ID is negative (%d) for operation at index %d", outputOfferID, operationIndex)
+			return []TradeOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Offer ID is negative (%d) for operation at index %d", outputOfferID, operationIndex))
 		}
 
 		outputBaseAccountAddress, err := claimOffer.SellerId.GetAddress()
 		if err != nil {
-			return []TradeOutput{}, err
+			return []TradeOutput{}, errorContext.wrap(DecodeFailure, err)
 		}
 
 		var outputBaseAssetType, outputBaseAssetCode, outputBaseAssetIssuer string
 		err = claimOffer.AssetSold.Extract(&outputBaseAssetType, &outputBaseAssetCode, &outputBaseAssetIssuer)
 		if err != nil {
-			return []TradeOutput{}, err
+			return []TradeOutput{}, errorContext.wrap(DecodeFailure, err)
 		}
 
 		outputBaseAmount := int64(claimOffer.AmountSold)
 		if outputBaseAmount < 0 {
-			return []TradeOutput{}, fmt.Errorf("Amount sold is negative (%d) for operation at index %d", outputBaseAmount, operationIndex)
+			return []TradeOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Amount sold is negative (%d) for operation at index %d", outputBaseAmount, operationIndex))
 		}
 
 		outputCounterAccountAddress, err := utils.GetAccountAddressFromMuxedAccount(sourceAccount)
 		if err != nil {
-			return []TradeOutput{}, err
+			return []TradeOutput{}, errorContext.wrap(DecodeFailure, err)
 		}
 
 		var outputCounterAssetType, outputCounterAssetCode, outputCounterAssetIssuer string
 		err = claimOffer.AssetBought.Extract(&outputCounterAssetType, &outputCounterAssetCode, &outputCounterAssetIssuer)
 		if err != nil {
-			return []TradeOutput{}, err
+			return []TradeOutput{}, errorContext.wrap(DecodeFailure, err)
 		}
 
 		outputCounterAmount := int64(claimOffer.AmountBought)
 		if outputCounterAmount < 0 {
-			return []TradeOutput{}, fmt.Errorf("Amount bought is negative (%d) for operation at index %d", outputCounterAmount, operationIndex)
+			return []TradeOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Amount bought is negative (%d) for operation at index %d", outputCounterAmount, operationIndex))
 		}
 
 		if outputBaseAmount == 0 && outputCounterAmount == 0 {
-			return []TradeOutput{}, fmt.Errorf("Both base and counter amount are 0 for operation at index %d", operationIndex)
+			return []TradeOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Both base and counter amount are 0 for operation at index %d", operationIndex))
 		}
 
 		// Final price should be buy / sell
@@ -118,7 +128,7 @@ func TransformTrade(operationIndex int32, operationID int64, transaction ingesti
 
 func extractClaimedOffers(operationResults []xdr.OperationResult, operationIndex int32, operationType xdr.OperationType) (claimedOffers []xdr.ClaimOfferAtom, counterOffer *xdr.OfferEntry, err error) {
 	if operationIndex >= int32(len(operationResults)) {
-		err = fmt.Errorf("Operation index of %d is out of bounds in result slice (len = %d)", operationIndex, len(operationResults))
+		err = categorize(InvalidData, fmt.Errorf("Operation index of %d is out of bounds in result slice (len = %d)", operationIndex, len(operationResults)))
 		return
 	}
 
@@ -209,7 +219,7 @@ func extractClaimedOffers(operationResults []xdr.OperationResult, operationIndex
 		err = fmt.Errorf("Could not get GetPathPaymentStrictReceiveSuccess for operation at index %d", operationIndex)
 
 	default:
-		err = fmt.Errorf("Operation of type %s at index %d does not result in trades", operationType, operationIndex)
+		err = categorize(UnsupportedType, fmt.Errorf("Operation of type %s at index %d does not result in trades", operationType, operationIndex))
 		return
 	}
 
@@ -144,35 +144,35 @@ func TestTransformTrade(t *testing.T) {
 	tests := []transformTest{
 		{
 			wrongTypeInput,
-			[]TradeOutput{}, fmt.Errorf("Operation of type OperationTypeBumpSequence at index 0 does not result in trades"),
+			[]TradeOutput{}, &TransformError{Category: UnsupportedType, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Operation of type OperationTypeBumpSequence at index 0 does not result in trades")},
 		},
 		{
 			resultOutOfRangeInput,
-			[]TradeOutput{}, fmt.Errorf("Operation index of 0 is out of bounds in result slice (len = 0)"),
+			[]TradeOutput{}, &TransformError{Category: InvalidData, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Operation index of 0 is out of bounds in result slice (len = 0)")},
 		},
 		{
 			failedTxInput,
-			[]TradeOutput{}, fmt.Errorf("Transaction failed; no trades"),
+			[]TradeOutput{}, &TransformError{Category: UnsupportedType, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Transaction failed; no trades")},
 		},
 		{
 			noTrInput,
-			[]TradeOutput{}, fmt.Errorf("Could not get result Tr for operation at index 0"),
+			[]TradeOutput{}, &TransformError{Category: DecodeFailure, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Could not get result Tr for operation at index 0")},
 		},
 		{
 			failedResultInput,
-			[]TradeOutput{}, fmt.Errorf("Could not get ManageOfferSuccess for operation at index 0"),
+			[]TradeOutput{}, &TransformError{Category: DecodeFailure, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Could not get ManageOfferSuccess for operation at index 0")},
 		},
 		{
 			negBaseAmountInput,
-			[]TradeOutput{}, fmt.Errorf("Amount sold is negative (-1) for operation at index 0"),
+			[]TradeOutput{}, &TransformError{Category: InvalidData, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Amount sold is negative (-1) for operation at index 0")},
 		},
 		{
 			negCounterAmountInput,
-			[]TradeOutput{}, fmt.Errorf("Amount bought is negative (-2) for operation at index 0"),
+			[]TradeOutput{}, &TransformError{Category: InvalidData, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Amount bought is negative (-2) for operation at index 0")},
 		},
 		{
 			negOfferIDInput,
-			[]TradeOutput{}, fmt.Errorf("Offer ID is negative (-3) for operation at index 0"),
+			[]TradeOutput{}, &TransformError{Category: InvalidData, Dataset: TradesDataset, EntryKey: "100", Err: fmt.Errorf("Offer ID is negative (-3) for operation at index 0")},
 		},
 	}
 
@@ -20,61 +20,77 @@ func TransformTransaction(transaction io.LedgerTransaction, lhe xdr.LedgerHeader
 	outputApplicationOrder := uint32(transaction.Index)
 
 	outputTransactionID := toid.New(int32(outputLedgerSequence), int32(outputApplicationOrder), 0).ToInt64()
+	errorContext := TransformError{
+		Dataset:          TransactionsDataset,
+		LedgerSequence:   outputLedgerSequence,
+		TransactionIndex: int32(outputApplicationOrder),
+		EntryKey:         outputTransactionHash,
+	}
 
 	outputAccount, err := utils.GetAccountAddressFromMuxedAccount(transaction.Envelope.SourceAccount())
 	if err != nil {
-		return TransactionOutput{}, fmt.Errorf("for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err)
+		return TransactionOutput{}, errorContext.wrap(DecodeFailure, fmt.Errorf("for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err))
 	}
 
 	outputAccountSequence := transaction.Envelope.SeqNum()
 	if outputAccountSequence < 0 {
-		return TransactionOutput{}, fmt.Errorf("The account's sequence number (%d) is negative for ledger %d; transaction %d (transaction id=%d)", outputAccountSequence, outputLedgerSequence, outputApplicationOrder, outputTransactionID)
+		return TransactionOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The account's sequence number (%d) is negative for ledger %d; transaction %d (transaction id=%d)", outputAccountSequence, outputLedgerSequence, outputApplicationOrder, outputTransactionID))
 	}
 
 	outputMaxFee := transaction.Envelope.Fee()
 	if outputMaxFee < 0 {
-		return TransactionOutput{}, fmt.Errorf("The fee (%d) is negative for ledger %d; transaction %d (transaction id=%d)", outputMaxFee, outputLedgerSequence, outputApplicationOrder, outputTransactionID)
+		return TransactionOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The fee (%d) is negative for ledger %d; transaction %d (transaction id=%d)", outputMaxFee, outputLedgerSequence, outputApplicationOrder, outputTransactionID))
 	}
 
 	outputFeeCharged := int64(transaction.Result.Result.FeeCharged)
 	if outputFeeCharged < 0 {
-		return TransactionOutput{}, fmt.Errorf("The fee charged (%d) is negative for ledger %d; transaction %d (transaction id=%d)", outputFeeCharged, outputLedgerSequence, outputApplicationOrder, outputTransactionID)
+		return TransactionOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The fee charged (%d) is negative for ledger %d; transaction %d (transaction id=%d)", outputFeeCharged, outputLedgerSequence, outputApplicationOrder, outputTransactionID))
 	}
 
 	outputOperationCount := int32(len(transaction.Envelope.Operations()))
 	outputCreatedAt, err := utils.TimePointToUTCTimeStamp(ledgerHeader.ScpValue.CloseTime)
 	if err != nil {
-		return TransactionOutput{}, fmt.Errorf("for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err)
-	}
-
-	memoObject := transaction.Envelope.Memo()
-	outputMemoContents := ""
-	switch xdr.MemoType(memoObject.Type) {
-	case xdr.MemoTypeMemoText:
-		outputMemoContents = memoObject.MustText()
-	case xdr.MemoTypeMemoId:
-		outputMemoContents = strconv.FormatUint(uint64(memoObject.MustId()), 10)
-	case xdr.MemoTypeMemoHash:
-		hash := memoObject.MustHash()
-		outputMemoContents = base64.StdEncoding.EncodeToString(hash[:])
-	case xdr.MemoTypeMemoReturn:
-		hash := memoObject.MustRetHash()
-		outputMemoContents = base64.StdEncoding.EncodeToString(hash[:])
+		return TransactionOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err))
 	}
 
-	outputMemoType := memoObject.Type.String()
+	outputMemoType, outputMemoContents := extractMemo(transaction.Envelope.Memo())
 	timeBound := transaction.Envelope.TimeBounds()
 	outputTimeBounds := ""
 	if timeBound != nil {
 		if timeBound.MaxTime < timeBound.MinTime {
-			return TransactionOutput{}, fmt.Errorf("The max time is earlier than the min time (%d < %d) for ledger %d; transaction %d (transaction id=%d)",
-				timeBound.MaxTime, timeBound.MinTime, outputLedgerSequence, outputApplicationOrder, outputTransactionID)
+			return TransactionOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The max time is earlier than the min time (%d < %d) for ledger %d; transaction %d (transaction id=%d)",
+				timeBound.MaxTime, timeBound.MinTime, outputLedgerSequence, outputApplicationOrder, outputTransactionID))
 		}
 
 		outputTimeBounds = fmt.Sprintf("[%d, %d)", timeBound.MinTime, timeBound.MaxTime)
 	}
 
 	outputSuccessful := transaction.Result.Successful()
+	outputFeeAccount := outputAccount
+	outputFeeBumpFee := int64(0)
+	outputFeeBumpSignatures := []string(nil)
+	outputInnerTransactionHash := ""
+	if transaction.Envelope.IsFeeBump() {
+		outputFeeAccount, err = utils.GetAccountAddressFromMuxedAccount(transaction.Envelope.FeeBumpAccount())
+		if err != nil {
+			return TransactionOutput{}, errorContext.wrap(DecodeFailure, fmt.Errorf("for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err))
+		}
+
+		outputFeeBumpFee = transaction.Envelope.FeeBumpFee()
+		outputFeeBumpSignatures = encodeSignatures(transaction.Envelope.FeeBumpSignatures())
+		outputInnerTransactionHash = utils.HashToHexString(transaction.Result.InnerHash())
+	}
+
+	outputEnvelopeXDR, err := xdr.MarshalBase64(transaction.Envelope)
+	if err != nil {
+		return TransactionOutput{}, errorContext.wrap(DecodeFailure, fmt.Errorf("could not encode the envelope for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err))
+	}
+
+	outputResultXDR, err := xdr.MarshalBase64(transaction.Result.Result)
+	if err != nil {
+		return TransactionOutput{}, errorContext.wrap(DecodeFailure, fmt.Errorf("could not encode the result for ledger %d; transaction %d (transaction id=%d): %v", outputLedgerSequence, outputApplicationOrder, outputTransactionID, err))
+	}
+
 	transformedTransaction := TransactionOutput{
 		TransactionHash:  outputTransactionHash,
 		LedgerSequence:   outputLedgerSequence,
@@ -90,6 +106,43 @@ func TransformTransaction(transaction io.LedgerTransaction, lhe xdr.LedgerHeader
 		Memo:             outputMemoContents,
 		TimeBounds:       outputTimeBounds,
 		Successful:       outputSuccessful,
+
+		FeeAccount:           outputFeeAccount,
+		EnvelopeXDR:          outputEnvelopeXDR,
+		ResultXDR:            outputResultXDR,
+		Signatures:           encodeSignatures(transaction.Envelope.Signatures()),
+		FeeBumpFee:           outputFeeBumpFee,
+		FeeBumpSignatures:    outputFeeBumpSignatures,
+		InnerTransactionHash: outputInnerTransactionHash,
 	}
 	return transformedTransaction, nil
 }
+
+// encodeSignatures returns the signatures of an envelope in base 64, like Horizon shows them
+func encodeSignatures(signatures []xdr.DecoratedSignature) []string {
+	encoded := make([]string, 0, len(signatures))
+	for _, signature := range signatures {
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(signature.Signature))
+	}
+
+	return encoded
+}
+
+// extractMemo returns the type and the contents of a memo. ID memos are written in decimal, and hash and return memos in base 64
+func extractMemo(memo xdr.Memo) (string, string) {
+	contents := ""
+	switch memo.Type {
+	case xdr.MemoTypeMemoText:
+		contents = memo.MustText()
+	case xdr.MemoTypeMemoId:
+		contents = strconv.FormatUint(uint64(memo.MustId()), 10)
+	case xdr.MemoTypeMemoHash:
+		hash := memo.MustHash()
+		contents = base64.StdEncoding.EncodeToString(hash[:])
+	case xdr.MemoTypeMemoReturn:
+		hash := memo.MustRetHash()
+		contents = base64.StdEncoding.EncodeToString(hash[:])
+	}
+
+	return memo.Type.String(), contents
+}
@@ -7,6 +7,7 @@ import (
 
 	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/xdr"
+	"github.com/stellar/stellar-etl/internal/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,6 +37,7 @@ func TestTransformTransaction(t *testing.T) {
 
 	badFeeChargedInput := genericInput
 	badFeeChargedInput.transaction.Result.Result.FeeCharged = -1
+	genericTransactionHash := utils.HashToHexString(genericLedgerTransaction.Result.TransactionHash)
 
 	hardCodedTransaction, hardCodedLedgerHeader, err := makeTransactionTestInput()
 	assert.NoError(t, err)
@@ -43,27 +45,67 @@ func TestTransformTransaction(t *testing.T) {
 	hardCodedOutput, err := makeTransactionTestOutput()
 	assert.NoError(t, err)
 
+	// A fee bump transaction is described by its inner transaction, with the fee account, the fee, and the signatures of the fee bump kept for Horizon
+	feeBumpInput := hardCodedInput
+	innerHash := xdr.Hash{0x01, 0x02}
+	feeBumpInput.transaction.Envelope = xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTxFeeBump,
+		FeeBump: &xdr.FeeBumpTransactionEnvelope{
+			Tx: xdr.FeeBumpTransaction{
+				FeeSource: testAccount2,
+				Fee:       200000,
+				InnerTx:   xdr.FeeBumpTransactionInnerTx{Type: xdr.EnvelopeTypeEnvelopeTypeTx, V1: hardCodedTransaction.Envelope.V1},
+			},
+			Signatures: []xdr.DecoratedSignature{{Signature: []byte{0x01, 0x02, 0x03}}},
+		},
+	}
+	feeBumpInput.transaction.Result.Result = xdr.TransactionResult{
+		FeeCharged: 400,
+		Result: xdr.TransactionResultResult{
+			Code: xdr.TransactionResultCodeTxFeeBumpInnerFailed,
+			InnerResultPair: &xdr.InnerTransactionResultPair{
+				TransactionHash: innerHash,
+				Result:          xdr.InnerTransactionResult{FeeCharged: 300, Result: xdr.InnerTransactionResultResult{Code: xdr.TransactionResultCodeTxFailed, Results: &[]xdr.OperationResult{}}},
+			},
+		},
+	}
+	feeBumpOutput := hardCodedOutput
+	feeBumpOutput.FeeCharged = 400
+	feeBumpOutput.FeeAccount = testAccount2Address
+	feeBumpOutput.EnvelopeXDR, err = xdr.MarshalBase64(feeBumpInput.transaction.Envelope)
+	assert.NoError(t, err)
+	feeBumpOutput.ResultXDR, err = xdr.MarshalBase64(feeBumpInput.transaction.Result.Result)
+	assert.NoError(t, err)
+	feeBumpOutput.FeeBumpFee = 200000
+	feeBumpOutput.FeeBumpSignatures = []string{"AQID"}
+	feeBumpOutput.InnerTransactionHash = utils.HashToHexString(innerHash)
+
 	tests := []transformTest{
 		transformTest{
 			negativeSeqInput,
 			TransactionOutput{},
-			fmt.Errorf("The account's sequence number (-1) is negative for ledger 0; transaction 1 (transaction id=4096)"),
+			&TransformError{Category: InvalidData, Dataset: TransactionsDataset, TransactionIndex: 1, EntryKey: genericTransactionHash, Err: fmt.Errorf("The account's sequence number (-1) is negative for ledger 0; transaction 1 (transaction id=4096)")},
 		},
 		{
 			badFeeChargedInput,
 			TransactionOutput{},
-			fmt.Errorf("The fee charged (-1) is negative for ledger 0; transaction 1 (transaction id=4096)"),
+			&TransformError{Category: InvalidData, Dataset: TransactionsDataset, TransactionIndex: 1, EntryKey: genericTransactionHash, Err: fmt.Errorf("The fee charged (-1) is negative for ledger 0; transaction 1 (transaction id=4096)")},
 		},
 		{
 			badTimeboundInput,
 			TransactionOutput{},
-			fmt.Errorf("The max time is earlier than the min time (100 < 1594586912) for ledger 0; transaction 1 (transaction id=4096)"),
+			&TransformError{Category: InvalidData, Dataset: TransactionsDataset, TransactionIndex: 1, EntryKey: genericTransactionHash, Err: fmt.Errorf("The max time is earlier than the min time (100 < 1594586912) for ledger 0; transaction 1 (transaction id=4096)")},
 		},
 		{
 			hardCodedInput,
 			hardCodedOutput,
 			nil,
 		},
+		{
+			feeBumpInput,
+			feeBumpOutput,
+			nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -90,6 +132,10 @@ func makeTransactionTestOutput() (output TransactionOutput, err error) {
 		Memo:             "HL5aCgozQHIW7sSc5XdcfmR",
 		TimeBounds:       "[0, 1594272628)",
 		Successful:       false,
+		FeeAccount:       testAccount1Address,
+		EnvelopeXDR:      "AAAAAgAAAACI4aa0pXFSj6qfJuIObLw/5zyugLRGYwxb7wFSr3B9eAABX5ABjydzAABBtwAAAAEAAAAAAAAAAAAAAABfBqt0AAAAAQAAABdITDVhQ2dvelFISVc3c1NjNVhkY2ZtUgAAAAABAAAAAQAAAAAcR0GXGO76pFs4y38vJVAanjnLg4emNun7zAx0pHcDGAAAAAIAAAAAAAAAAAAAAAAAAAAAZ8wAhkw7iRaMaq/gvDRwntAhxQVy4vmIYTQiCCwiKXIAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		ResultXDR:        "AAAAAAAAASz/////AAAAAf////8AAAAA",
+		Signatures:       []string{},
 	}
 	return
 }
@@ -118,7 +164,7 @@ func makeTransactionTestInput() (transaction ingestio.LedgerTransaction, history
 							SourceAccount: &testAccount2,
 							Body: xdr.OperationBody{
 								Type:                       xdr.OperationTypePathPaymentStrictReceive,
-								PathPaymentStrictReceiveOp: &xdr.PathPaymentStrictReceiveOp{},
+								PathPaymentStrictReceiveOp: &xdr.PathPaymentStrictReceiveOp{Destination: testAccount3},
 							},
 						},
 					},
@@ -132,7 +178,7 @@ func makeTransactionTestInput() (transaction ingestio.LedgerTransaction, history
 				Result: xdr.TransactionResultResult{
 					Code: xdr.TransactionResultCodeTxFailed,
 					Results: &[]xdr.OperationResult{
-						xdr.OperationResult{},
+						xdr.OperationResult{Code: xdr.OperationResultCodeOpBadAuth},
 					},
 				},
 			},
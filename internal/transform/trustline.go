@@ -12,19 +12,21 @@ import (
 
 //TransformTrustline converts a trustline from the history archive ingestion system into a form suitable for BigQuery
 func TransformTrustline(ledgerChange ingestio.Change) (TrustlineOutput, error) {
+	errorContext := TransformError{Dataset: TrustlinesDataset}
 	ledgerEntry, outputDeleted, err := utils.ExtractEntryFromChange(ledgerChange)
 	if err != nil {
-		return TrustlineOutput{}, err
+		return TrustlineOutput{}, errorContext.wrap(DecodeFailure, err)
 	}
 
+	errorContext.LedgerSequence = uint32(ledgerEntry.LastModifiedLedgerSeq)
 	trustEntry, ok := ledgerEntry.Data.GetTrustLine()
 	if !ok {
-		return TrustlineOutput{}, fmt.Errorf("Could not extract trustline data from ledger entry; actual type is %s", ledgerEntry.Data.Type)
+		return TrustlineOutput{}, errorContext.wrap(UnsupportedType, fmt.Errorf("Could not extract trustline data from ledger entry; actual type is %s", ledgerEntry.Data.Type))
 	}
 
 	outputAccountID, err := trustEntry.AccountId.GetAddress()
 	if err != nil {
-		return TrustlineOutput{}, err
+		return TrustlineOutput{}, errorContext.wrap(DecodeFailure, err)
 	}
 
 	var assetType, outputAssetCode, outputAssetIssuer string
@@ -32,24 +34,26 @@ func TransformTrustline(ledgerChange ingestio.Change) (TrustlineOutput, error) {
 	asset := trustEntry.Asset
 	err = asset.Extract(&assetType, &outputAssetCode, &outputAssetIssuer)
 	if err != nil {
-		return TrustlineOutput{}, errors.Wrap(err, fmt.Sprintf("could not parse asset for trustline with account %s", outputAccountID))
+		return TrustlineOutput{}, errorContext.wrap(DecodeFailure, errors.Wrap(err, fmt.Sprintf("could not parse asset for trustline with account %s", outputAccountID)))
 	}
 
 	outputLedgerKey, err := trustLineEntryToLedgerKeyString(trustEntry)
 	if err != nil {
-		return TrustlineOutput{}, errors.Wrap(err, fmt.Sprintf("could not create ledger key string for trustline with account %s and asset %s", outputAccountID, asset))
+		return TrustlineOutput{}, errorContext.wrap(Serialization, errors.Wrap(err, fmt.Sprintf("could not create ledger key string for trustline with account %s and asset %s", outputAccountID, asset)))
 	}
 
+	errorContext.EntryKey = outputLedgerKey
+
 	outputAssetType := int32(asset.Type)
 
 	outputBalance := int64(trustEntry.Balance)
 	if outputBalance < 0 {
-		return TrustlineOutput{}, fmt.Errorf("Balance is negative (%d) for trustline (account is %s and asset is %s)", outputBalance, outputAccountID, asset)
+		return TrustlineOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Balance is negative (%d) for trustline (account is %s and asset is %s)", outputBalance, outputAccountID, asset))
 	}
 
 	outputLimit := int64(trustEntry.Limit)
 	if outputLimit < 0 {
-		return TrustlineOutput{}, fmt.Errorf("Limit is negative (%d) for trustline (account is %s and asset is %s)", outputLimit, outputAccountID, asset)
+		return TrustlineOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("Limit is negative (%d) for trustline (account is %s and asset is %s)", outputLimit, outputAccountID, asset))
 	}
 
 	//The V1 struct is the first version of the extender from trustlineEntry. It contains information on liabilities, and in the future
@@ -60,11 +64,11 @@ func TransformTrustline(ledgerChange ingestio.Change) (TrustlineOutput, error) {
 		liabilities := trustlineExtensionInfo.Liabilities
 		outputBuyingLiabilities, outputSellingLiabilities = int64(liabilities.Buying), int64(liabilities.Selling)
 		if outputBuyingLiabilities < 0 {
-			return TrustlineOutput{}, fmt.Errorf("The buying liabilities count is negative (%d) for trustline (account is %s and asset is %s)", outputBuyingLiabilities, outputAccountID, asset)
+			return TrustlineOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The buying liabilities count is negative (%d) for trustline (account is %s and asset is %s)", outputBuyingLiabilities, outputAccountID, asset))
 		}
 
 		if outputSellingLiabilities < 0 {
-			return TrustlineOutput{}, fmt.Errorf("The selling liabilities count is negative (%d) for trustline (account is %s and asset is %s)", outputSellingLiabilities, outputAccountID, asset)
+			return TrustlineOutput{}, errorContext.wrap(InvalidData, fmt.Errorf("The selling liabilities count is negative (%d) for trustline (account is %s and asset is %s)", outputSellingLiabilities, outputAccountID, asset))
 		}
 	}
 
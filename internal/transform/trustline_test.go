@@ -18,6 +18,9 @@ func TestTransformTrustline(t *testing.T) {
 
 	hardCodedInput := makeTrustlineTestInput()
 	hardCodedOutput := makeTrustlineTestOutput()
+	nativeTrustlineKey, err := trustLineEntryToLedgerKeyString(xdr.TrustLineEntry{AccountId: genericAccountID, Asset: nativeAsset})
+	assert.NoError(t, err)
+
 	tests := []transformTest{
 		{
 			ingestio.Change{
@@ -29,7 +32,7 @@ func TestTransformTrustline(t *testing.T) {
 					},
 				},
 			},
-			TrustlineOutput{}, fmt.Errorf("Could not extract trustline data from ledger entry; actual type is LedgerEntryTypeOffer"),
+			TrustlineOutput{}, &TransformError{Category: UnsupportedType, Dataset: TrustlinesDataset, Err: fmt.Errorf("Could not extract trustline data from ledger entry; actual type is LedgerEntryTypeOffer")},
 		},
 		{
 			wrapTrustlineEntry(xdr.TrustLineEntry{
@@ -37,7 +40,7 @@ func TestTransformTrustline(t *testing.T) {
 				Asset:     nativeAsset,
 				AccountId: genericAccountID,
 			}, 0),
-			TrustlineOutput{}, fmt.Errorf("Balance is negative (-1) for trustline (account is %s and asset is native)", genericAccountAddress),
+			TrustlineOutput{}, &TransformError{Category: InvalidData, Dataset: TrustlinesDataset, EntryKey: nativeTrustlineKey, Err: fmt.Errorf("Balance is negative (-1) for trustline (account is %s and asset is native)", genericAccountAddress)},
 		},
 		{
 			hardCodedInput,
@@ -5,13 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
 	ingestio "github.com/stellar/go/ingest/io"
 	"github.com/stellar/go/ingest/ledgerbackend"
 	"github.com/stellar/go/keypair"
-	"github.com/stellar/go/support/log"
 	"github.com/stellar/go/txnbuild"
 	"github.com/stellar/go/xdr"
 )
@@ -102,30 +103,75 @@ func CreateSampleResultMeta(successful bool, subOperationCount int) xdr.Transact
 	}
 }
 
-// AddCommonFlags adds the flags common to all commands: end-ledger, stdout, and strict-export
+// AddCommonFlags adds the flags common to all commands: end-ledger, stdout, strict-export, format, and index-pattern
 func AddCommonFlags(flags *pflag.FlagSet) {
 	flags.Uint32P("end-ledger", "e", 0, "The ledger sequence number for the end of the export range")
 	flags.Bool("stdout", false, "If set, the output will be printed to stdout instead of to a file")
 	flags.Bool("strict-export", false, "If set, transform errors will be reported as fatal errors instead of warnings.")
+	flags.String("format", "json", "The format of the output (json, avro, debezium, es-bulk, or horizon). Avro output is written as object container files with an embedded schema. Debezium output is only supported by export_ledger_entry_changes, and writes each change as a change event envelope. Es-bulk output is written as bulk index actions for Elasticsearch and OpenSearch. Horizon output writes ledgers, transactions, operations, and trades as the resources of the Horizon API")
+	flags.String("index-pattern", "stellar-{dataset}", "The name of the index that rows in the es-bulk format are written to. {dataset} is replaced with the name of the dataset")
 }
 
-// AddArchiveFlags adds the history archive specific flags: start-ledger, output, and limit
+// DefaultReadAhead is the number of checkpoints that are downloaded ahead of the one that is being read from the history archive, unless the read-ahead flag is set
+const DefaultReadAhead = 2
+
+// AddArchiveFlags adds the history archive specific flags: start-ledger, output, limit, verify, clamp-range, read-ahead, meta-stream, fatal-errors, expressions, table-path, and the sink flags
 func AddArchiveFlags(objectName string, flags *pflag.FlagSet) {
 	flags.Uint32P("start-ledger", "s", 1, "The ledger sequence number for the beginning of the export period. Defaults to genesis ledger")
 	flags.StringP("output", "o", "exported_"+objectName+".txt", "Filename of the output file")
 	flags.Int64P("limit", "l", -1, "Maximum number of "+objectName+" to export. If the limit is set to a negative number, all the objects in the provided range are exported")
-
+	flags.Bool("verify", false, "If set, the transaction set and transaction result set of each ledger are checked against the hashes in the ledger header before any data is exported")
+	flags.Bool("clamp-range", false, "If set, the export range is narrowed to the ledgers that the history archive holds instead of failing when part of it is missing from a pruned archive")
+	flags.Uint32("read-ahead", DefaultReadAhead, "Number of checkpoints that are downloaded from the history archive in the background, ahead of the one that is being exported. If set to 0, each checkpoint is only downloaded once its first ledger is read")
+	AddMetaStreamFlag(flags)
+	addFatalErrorsFlag(flags)
+	AddExpressionsFlag(flags)
+	AddTableFlags(flags)
+	AddSinkFlags(flags)
 }
 
-// AddBucketFlags adds the bucket list specifc flags: output
+// AddBucketFlags adds the bucket list specifc flags: output, fatal-errors, expressions, table-path, and the sink flags
 func AddBucketFlags(objectName string, flags *pflag.FlagSet) {
 	flags.StringP("output", "o", "exported_"+objectName+".txt", "Filename of the output file")
+	addFatalErrorsFlag(flags)
+	AddExpressionsFlag(flags)
+	AddTableFlags(flags)
+	AddSinkFlags(flags)
+}
+
+// AddTableFlags adds the table-path flag, which commits the exported data to Delta Lake tables instead of writing it to output files
+func AddTableFlags(flags *pflag.FlagSet) {
+	flags.String("table-path", "", "Local path or S3 URL (s3://bucket/prefix) of the folder that contains the Delta Lake tables. If set, each dataset is committed to the table in the folder with the name of the dataset instead of being written to output files")
+}
+
+// AddSinkFlags adds the sink, optional-sink, and sink-attempts flags, which deliver each export batch to several destinations instead of the output files
+func AddSinkFlags(flags *pflag.FlagSet) {
+	flags.StringArray("sink", []string{}, "Destination that every export batch is delivered to: a local folder, an S3 URL (s3://bucket/prefix), a PostgreSQL URL (postgres://...), or a Kinesis stream (kinesis://stream). Can be repeated; a batch is complete only once every sink has confirmed it")
+	flags.StringArray("optional-sink", []string{}, "Like sink, but a batch is complete even if this sink fails to confirm it. Can be repeated")
+	flags.Int("sink-attempts", 3, "Number of times each sink tries to deliver a batch before it gives up")
+}
+
+// addFatalErrorsFlag adds the fatal-errors flag, which lists the transform error categories that stop an export even when strict-export is not set
+func addFatalErrorsFlag(flags *pflag.FlagSet) {
+	flags.StringSlice("fatal-errors", []string{}, "Comma separated list of transform error categories that are reported as fatal errors (invalid_data, unsupported_type, decode_failure, serialization)")
 }
 
-// AddCoreFlags adds the captive core specifc flags: core-executable, core-config, batch-size, and output flags
+// AddExpressionsFlag adds the expressions flag, which configures derived columns and row filters for the exported datasets
+func AddExpressionsFlag(flags *pflag.FlagSet) {
+	flags.String("expressions", "", "Filepath of a JSON file with derived columns and row filters for the exported datasets. Derived columns cannot be written in the horizon or debezium formats")
+}
+
+// AddMetaStreamFlag adds the meta-stream flag, which reads the ledgers from the metadata output stream of a stellar-core node that is already running
+func AddMetaStreamFlag(flags *pflag.FlagSet) {
+	flags.String("meta-stream", "", "Filepath of the file or named pipe that a running stellar-core writes its metadata output stream (METADATA_OUTPUT_STREAM) to, or fd:N for an inherited file descriptor. If set, ledgers are read from the stream instead of the history archives or a new stellar-core instance. The stream is followed when the end-ledger is 0")
+}
+
+// AddCoreFlags adds the captive core specifc flags: core-executable, core-config, core-socket, meta-stream, batch-size, and output flags
 func AddCoreFlags(flags *pflag.FlagSet, defaultFolder string) {
 	flags.StringP("core-executable", "x", "", "Filepath to the stellar-core executable")
 	flags.StringP("core-config", "c", "", "Filepath to the a config file for stellar-core")
+	flags.String("core-socket", "", "Filepath to the socket of a running core_daemon. If set, ledgers are read from the daemon instead of a new stellar-core instance")
+	AddMetaStreamFlag(flags)
 
 	flags.Uint32P("batch-size", "b", 64, "number of ledgers to export changes from in each batches")
 	flags.StringP("output", "o", defaultFolder, "Folder that will contain the output files")
@@ -133,108 +179,299 @@ func AddCoreFlags(flags *pflag.FlagSet, defaultFolder string) {
 	flags.Uint32P("start-ledger", "s", 1, "The ledger sequence number for the beginning of the export period. Defaults to genesis ledger")
 }
 
+// AddStateFlags adds the state-file and on-reset flags, which detect network resets between and during exports
+func AddStateFlags(flags *pflag.FlagSet) {
+	flags.String("state-file", "", "Filepath of the file that records the hashes of the exported ledgers. Defaults to state.json in the output folder; if there is no output folder, no state is kept unless it is set")
+	flags.String("on-reset", "stop", "What to do when the network has been reset since the recorded ledgers were exported: stop, or namespace to write the output to a new subfolder of the output folder and table path")
+}
+
+// AddDaemonFlags adds the core daemon specific flags: core-executable, core-config, meta-stream, start-ledger, end-ledger, core-socket, and retain-ledgers
+func AddDaemonFlags(flags *pflag.FlagSet) {
+	flags.StringP("core-executable", "x", "", "Filepath to the stellar-core executable")
+	flags.StringP("core-config", "c", "", "Filepath to the a config file for stellar-core")
+	flags.Uint32P("start-ledger", "s", 1, "The ledger sequence number of the first ledger that the daemon provides. Defaults to genesis ledger")
+	flags.Uint32P("end-ledger", "e", 0, "The ledger sequence number of the last ledger that the daemon provides. If omitted, the daemon runs continuously")
+	flags.String("core-socket", "stellar-etl-core.sock", "Filepath of the socket that exporters connect to")
+	flags.Uint32("retain-ledgers", 640, "Maximum number of ledgers that the daemon reads ahead of the slowest connected exporter")
+	AddMetaStreamFlag(flags)
+}
+
 // AddExportTypeFlags adds the captive core specifc flags: export-{type} flags
 func AddExportTypeFlags(flags *pflag.FlagSet) {
 	flags.BoolP("export-accounts", "a", false, "set in order to export account changes")
 	flags.BoolP("export-trustlines", "t", false, "set in order to export trustline changes")
 	flags.BoolP("export-offers", "f", false, "set in order to export offer changes")
+	flags.Bool("export-orderbooks", false, "set in order to export normalized orderbooks from the same stellar-core instance as the changes")
 }
 
-// MustCommonFlags gets the values of the the flags common to all commands: end-ledger, stdout, and strict-export. If any do not exist, it stops the program fatally using the logger
-func MustCommonFlags(flags *pflag.FlagSet, logger *log.Entry) (endNum uint32, useStdout bool, strictExport bool) {
-	endNum, err := flags.GetUint32("end-ledger")
+// GetCommonFlags gets the values of the the flags common to all commands: end-ledger, stdout, and strict-export. If any do not exist, an error is returned
+func GetCommonFlags(flags *pflag.FlagSet) (endNum uint32, useStdout bool, strictExport bool, err error) {
+	endNum, err = flags.GetUint32("end-ledger")
 	if err != nil {
-		logger.Fatal("could not get end sequence number: ", err)
+		return 0, false, false, fmt.Errorf("could not get end sequence number: %v", err)
 	}
 
 	useStdout, err = flags.GetBool("stdout")
 	if err != nil {
-		logger.Fatal("could not get stdout boolean: ", err)
+		return 0, false, false, fmt.Errorf("could not get stdout boolean: %v", err)
 	}
 
 	strictExport, err = flags.GetBool("strict-export")
 	if err != nil {
-		logger.Fatal("could not get strict-export boolean: ", err)
+		return 0, false, false, fmt.Errorf("could not get strict-export boolean: %v", err)
 	}
 
 	return
 }
 
-// MustArchiveFlags gets the values of the the history archive specific flags: start-ledger, output, and limit
-func MustArchiveFlags(flags *pflag.FlagSet, logger *log.Entry) (startNum uint32, path string, limit int64) {
-	startNum, err := flags.GetUint32("start-ledger")
+// GetArchiveFlags gets the values of the the history archive specific flags: start-ledger, output, limit, and verify
+func GetArchiveFlags(flags *pflag.FlagSet) (startNum uint32, path string, limit int64, verify bool, err error) {
+	startNum, err = flags.GetUint32("start-ledger")
 	if err != nil {
-		logger.Fatal("could not get start sequence number: ", err)
+		return 0, "", 0, false, fmt.Errorf("could not get start sequence number: %v", err)
 	}
 
 	path, err = flags.GetString("output")
 	if err != nil {
-		logger.Fatal("could not get output filename: ", err)
+		return 0, "", 0, false, fmt.Errorf("could not get output filename: %v", err)
 	}
 
 	limit, err = flags.GetInt64("limit")
 	if err != nil {
-		logger.Fatal("could not get limit: ", err)
+		return 0, "", 0, false, fmt.Errorf("could not get limit: %v", err)
+	}
+
+	verify, err = flags.GetBool("verify")
+	if err != nil {
+		return 0, "", 0, false, fmt.Errorf("could not get verify boolean: %v", err)
 	}
 
 	return
 }
 
-// MustBucketFlags gets the values of the bucket list specific flags: output
-func MustBucketFlags(flags *pflag.FlagSet, logger *log.Entry) (path string) {
-	path, err := flags.GetString("output")
+// GetClampFlag gets the value of the clamp-range flag
+func GetClampFlag(flags *pflag.FlagSet) (bool, error) {
+	clamp, err := flags.GetBool("clamp-range")
+	if err != nil {
+		return false, fmt.Errorf("could not get clamp-range boolean: %v", err)
+	}
+
+	return clamp, nil
+}
+
+// GetReadAheadFlag gets the value of the read-ahead flag
+func GetReadAheadFlag(flags *pflag.FlagSet) (uint32, error) {
+	readAhead, err := flags.GetUint32("read-ahead")
+	if err != nil {
+		return 0, fmt.Errorf("could not get read-ahead depth: %v", err)
+	}
+
+	return readAhead, nil
+}
+
+// GetFatalErrorsFlag gets the names of the error categories listed in the fatal-errors flag
+func GetFatalErrorsFlag(flags *pflag.FlagSet) ([]string, error) {
+	categories, err := flags.GetStringSlice("fatal-errors")
+	if err != nil {
+		return nil, fmt.Errorf("could not get fatal error categories: %v", err)
+	}
+
+	return categories, nil
+}
+
+// GetFormatFlag gets the value of the format flag
+func GetFormatFlag(flags *pflag.FlagSet) (string, error) {
+	format, err := flags.GetString("format")
+	if err != nil {
+		return "", fmt.Errorf("could not get output format: %v", err)
+	}
+
+	return format, nil
+}
+
+// GetIndexPatternFlag gets the value of the index-pattern flag
+func GetIndexPatternFlag(flags *pflag.FlagSet) (string, error) {
+	pattern, err := flags.GetString("index-pattern")
+	if err != nil {
+		return "", fmt.Errorf("could not get index pattern: %v", err)
+	}
+
+	return pattern, nil
+}
+
+// GetExpressionsFlag gets the value of the expressions flag
+func GetExpressionsFlag(flags *pflag.FlagSet) (string, error) {
+	path, err := flags.GetString("expressions")
 	if err != nil {
-		logger.Fatal("could not get output filename: ", err)
+		return "", fmt.Errorf("could not get expressions filename: %v", err)
+	}
+
+	return path, nil
+}
+
+// GetMetaStreamFlag gets the value of the meta-stream flag
+func GetMetaStreamFlag(flags *pflag.FlagSet) (string, error) {
+	metaStream, err := flags.GetString("meta-stream")
+	if err != nil {
+		return "", fmt.Errorf("could not get metadata stream location: %v", err)
+	}
+
+	return metaStream, nil
+}
+
+// GetStateFlags gets the values of the state-file and on-reset flags
+func GetStateFlags(flags *pflag.FlagSet) (statePath, onReset string, err error) {
+	statePath, err = flags.GetString("state-file")
+	if err != nil {
+		return "", "", fmt.Errorf("could not get state filename: %v", err)
+	}
+
+	onReset, err = flags.GetString("on-reset")
+	if err != nil {
+		return "", "", fmt.Errorf("could not get on-reset action: %v", err)
+	}
+
+	if onReset != "stop" && onReset != "namespace" {
+		return "", "", fmt.Errorf("on-reset must be stop or namespace, not %s", onReset)
 	}
 
 	return
 }
 
-// MustCoreFlags gets the values for the core-executable, core-config, start ledger batch-size, and output flags. If any do not exist, it stops the program fatally using the logger
-func MustCoreFlags(flags *pflag.FlagSet, logger *log.Entry) (execPath, configPath string, startNum, batchSize uint32, path string) {
-	execPath, err := flags.GetString("core-executable")
+// GetTableFlags gets the value of the table-path flag
+func GetTableFlags(flags *pflag.FlagSet) (string, error) {
+	tablePath, err := flags.GetString("table-path")
 	if err != nil {
-		logger.Fatal("could not get path to stellar-core executable, which is mandatory when not starting at the genesis ledger (ledger 1): ", err)
+		return "", fmt.Errorf("could not get table path: %v", err)
+	}
+
+	return tablePath, nil
+}
+
+// GetSinkFlags gets the values of the sink, optional-sink, and sink-attempts flags
+func GetSinkFlags(flags *pflag.FlagSet) (requiredSinks, optionalSinks []string, attempts int, err error) {
+	requiredSinks, err = flags.GetStringArray("sink")
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("could not get sinks: %v", err)
+	}
+
+	optionalSinks, err = flags.GetStringArray("optional-sink")
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("could not get optional sinks: %v", err)
+	}
+
+	attempts, err = flags.GetInt("sink-attempts")
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("could not get sink attempts: %v", err)
+	}
+
+	if attempts < 1 {
+		return nil, nil, 0, fmt.Errorf("sink-attempts (%d) must be greater than 0", attempts)
+	}
+
+	return
+}
+
+// GetBucketFlags gets the values of the bucket list specific flags: output
+func GetBucketFlags(flags *pflag.FlagSet) (path string, err error) {
+	path, err = flags.GetString("output")
+	if err != nil {
+		return "", fmt.Errorf("could not get output filename: %v", err)
+	}
+
+	return
+}
+
+// GetCoreFlags gets the values for the core-executable, core-config, start ledger batch-size, output, and core-socket flags. If any do not exist, an error is returned
+func GetCoreFlags(flags *pflag.FlagSet) (execPath, configPath string, startNum, batchSize uint32, path, coreSocket string, err error) {
+	execPath, err = flags.GetString("core-executable")
+	if err != nil {
+		return "", "", 0, 0, "", "", fmt.Errorf("could not get path to stellar-core executable, which is mandatory when not starting at the genesis ledger (ledger 1): %v", err)
 	}
 
 	configPath, err = flags.GetString("core-config")
 	if err != nil {
-		logger.Fatal("could not get path to stellar-core config file, is mandatory when not starting at the genesis ledger (ledger 1): ", err)
+		return "", "", 0, 0, "", "", fmt.Errorf("could not get path to stellar-core config file, is mandatory when not starting at the genesis ledger (ledger 1): %v", err)
 	}
 
 	path, err = flags.GetString("output")
 	if err != nil {
-		logger.Fatal("could not get output filename: ", err)
+		return "", "", 0, 0, "", "", fmt.Errorf("could not get output filename: %v", err)
 	}
 
 	startNum, err = flags.GetUint32("start-ledger")
 	if err != nil {
-		logger.Fatal("could not get start sequence number: ", err)
+		return "", "", 0, 0, "", "", fmt.Errorf("could not get start sequence number: %v", err)
 	}
 
 	batchSize, err = flags.GetUint32("batch-size")
 	if err != nil {
-		logger.Fatal("could not get batch size: ", err)
+		return "", "", 0, 0, "", "", fmt.Errorf("could not get batch size: %v", err)
+	}
+
+	coreSocket, err = flags.GetString("core-socket")
+	if err != nil {
+		return "", "", 0, 0, "", "", fmt.Errorf("could not get core socket path: %v", err)
 	}
 
 	return
 }
 
-// MustExportTypeFlags gets the values for the export-accounts, export-offers, and export-trustlines flags. If any do not exist, it stops the program fatally using the logger
-func MustExportTypeFlags(flags *pflag.FlagSet, logger *log.Entry) (exportAccounts, exportOffers, exportTrustlines bool) {
-	exportAccounts, err := flags.GetBool("export-accounts")
+// GetDaemonFlags gets the values for the core daemon flags. If any do not exist, an error is returned
+func GetDaemonFlags(flags *pflag.FlagSet) (execPath, configPath string, startNum, endNum uint32, coreSocket string, retain uint32, err error) {
+	execPath, err = flags.GetString("core-executable")
 	if err != nil {
-		logger.Fatal("could not get export accounts flag: ", err)
+		return "", "", 0, 0, "", 0, fmt.Errorf("could not get path to stellar-core executable: %v", err)
+	}
+
+	configPath, err = flags.GetString("core-config")
+	if err != nil {
+		return "", "", 0, 0, "", 0, fmt.Errorf("could not get path to stellar-core config file: %v", err)
+	}
+
+	startNum, err = flags.GetUint32("start-ledger")
+	if err != nil {
+		return "", "", 0, 0, "", 0, fmt.Errorf("could not get start sequence number: %v", err)
+	}
+
+	endNum, err = flags.GetUint32("end-ledger")
+	if err != nil {
+		return "", "", 0, 0, "", 0, fmt.Errorf("could not get end sequence number: %v", err)
+	}
+
+	coreSocket, err = flags.GetString("core-socket")
+	if err != nil {
+		return "", "", 0, 0, "", 0, fmt.Errorf("could not get core socket path: %v", err)
+	}
+
+	retain, err = flags.GetUint32("retain-ledgers")
+	if err != nil {
+		return "", "", 0, 0, "", 0, fmt.Errorf("could not get the number of retained ledgers: %v", err)
+	}
+
+	return
+}
+
+// GetExportTypeFlags gets the values for the export-accounts, export-offers, export-trustlines, and export-orderbooks flags. If any do not exist, an error is returned
+func GetExportTypeFlags(flags *pflag.FlagSet) (exportAccounts, exportOffers, exportTrustlines, exportOrderbooks bool, err error) {
+	exportAccounts, err = flags.GetBool("export-accounts")
+	if err != nil {
+		return false, false, false, false, fmt.Errorf("could not get export accounts flag: %v", err)
 	}
 
 	exportOffers, err = flags.GetBool("export-offers")
 	if err != nil {
-		logger.Fatal("could not get export offers flag: ", err)
+		return false, false, false, false, fmt.Errorf("could not get export offers flag: %v", err)
 	}
 
 	exportTrustlines, err = flags.GetBool("export-trustlines")
 	if err != nil {
-		logger.Fatal("could not get export trustlines flag: ", err)
+		return false, false, false, false, fmt.Errorf("could not get export trustlines flag: %v", err)
+	}
+
+	exportOrderbooks, err = flags.GetBool("export-orderbooks")
+	if err != nil {
+		return false, false, false, false, fmt.Errorf("could not get export orderbooks flag: %v", err)
 	}
 
 	return
@@ -299,3 +536,35 @@ func GetMostRecentCheckpoint(seq uint32) uint32 {
 	}
 	return seq - remainder
 }
+
+// JSONField is an exported field of a struct, named like encoding/json names it
+type JSONField struct {
+	Name  string
+	Index int
+	Type  reflect.Type
+}
+
+// JSONFields returns the fields of a struct type that encoding/json encodes, in order. Names are taken from the json tags, so that schemas derived
+// from output structs match the JSON output. Fields tagged with "-" are left out, and fields without a tag keep their Go names
+func JSONFields(structType reflect.Type) []JSONField {
+	fields := []JSONField{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, JSONField{Name: name, Index: i, Type: field.Type})
+	}
+
+	return fields
+}